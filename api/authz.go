@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/dcos/dcos-diagnostics/api/rest"
+)
+
+// Permission is a level of access to the API, every level includes all lower levels
+type Permission string
+
+const (
+	// PermissionNone denies access to all endpoints
+	PermissionNone Permission = "none"
+	// PermissionRead allows reading health, logs and bundles
+	PermissionRead Permission = "read"
+	// PermissionWrite additionally allows creating and deleting bundles and changing the daemon state
+	PermissionWrite Permission = "write"
+)
+
+var permissionLevels = map[Permission]int{
+	PermissionNone:  0,
+	PermissionRead:  1,
+	PermissionWrite: 2,
+}
+
+// Authorizer checks permissions of principals authenticated by the admin router. The principal is taken
+// from the X-Dcos-Principal header, requests without it are made by the anonymous principal.
+// A nil Authorizer allows everything.
+type Authorizer struct {
+	// Principals maps principal to its permission
+	Principals map[string]Permission `json:"principals"`
+	// Default is a permission of principals not listed in Principals, none when not set
+	Default Permission `json:"default"`
+	// Routes maps "METHOD route" e.g., "GET /system/health/v1/node/diagnostics/{id}" to the required permission.
+	// Routes not listed require read permission for GET and HEAD and write permission for other methods.
+	Routes map[string]Permission `json:"routes"`
+}
+
+// LoadAuthorizer reads authorization config from JSON file at path
+func LoadAuthorizer(path string) (*Authorizer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read authorization config %s: %s", path, err)
+	}
+	var a Authorizer
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("could not parse authorization config %s: %s", path, err)
+	}
+	if err := a.validate(); err != nil {
+		return nil, fmt.Errorf("invalid authorization config %s: %s", path, err)
+	}
+	return &a, nil
+}
+
+func (a *Authorizer) validate() error {
+	if a.Default == "" {
+		a.Default = PermissionNone
+	}
+	if _, ok := permissionLevels[a.Default]; !ok {
+		return fmt.Errorf("unknown default permission %s", a.Default)
+	}
+	for principal, p := range a.Principals {
+		if _, ok := permissionLevels[p]; !ok {
+			return fmt.Errorf("unknown permission %s of %s", p, principal)
+		}
+	}
+	for route, p := range a.Routes {
+		if _, ok := permissionLevels[p]; !ok {
+			return fmt.Errorf("unknown permission %s of %s", p, route)
+		}
+	}
+	return nil
+}
+
+// required returns a permission needed to call the route with given method
+func (a *Authorizer) required(url string, method string) Permission {
+	if p, ok := a.Routes[method+" "+url]; ok {
+		return p
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return PermissionRead
+	}
+	return PermissionWrite
+}
+
+// allowed returns true if the principal could call the route with given method
+func (a *Authorizer) allowed(principal string, url string, method string) bool {
+	granted, ok := a.Principals[principal]
+	if !ok {
+		granted = a.Default
+	}
+	return permissionLevels[granted] >= permissionLevels[a.required(url, method)]
+}
+
+func authzMiddleware(next http.Handler, url string, authz *Authorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := r.Header.Get(rest.PrincipalHeader)
+		if principal == "" {
+			principal = "anonymous"
+		}
+		if !authz.allowed(principal, url, r.Method) {
+			httpError(w, fmt.Sprintf("%s is not allowed to %s %s", principal, r.Method, r.URL.Path), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/api/rest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthzRequiresWritePermissionToDeleteBundle(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	dir := cfg.FlagDiagnosticsBundleDir
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bundle-0"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bundle-0", "state.json"),
+		[]byte(`{"id":"bundle-0","status":"Done"}`), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bundle-0", "file.zip"), make([]byte, 10), 0600))
+
+	bh, err := rest.NewBundleHandler(dir, nil, time.Minute, time.Minute, nil)
+	require.NoError(t, err)
+
+	router := NewRouter(&Dt{
+		Cfg:           cfg,
+		DtDCOSTools:   &fakeDCOSTools{},
+		MR:            &MonitoringResponse{},
+		BundleHandler: *bh,
+		Authz: &Authorizer{
+			Default: PermissionNone,
+			Principals: map[string]Permission{
+				"viewer": PermissionRead,
+				"admin":  PermissionWrite,
+			},
+		},
+	})
+
+	request := func(method string, principal string) int {
+		req := httptest.NewRequest(method, nodeBundlesEndpoint+"/bundle-0", nil)
+		if principal != "" {
+			req.Header.Set(rest.PrincipalHeader, principal)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "viewer"))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodDelete, "viewer"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "admin"))
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, "admin"))
+}
+
+func TestAuthorizerRoutesOverrideDefaultPermissions(t *testing.T) {
+	a := &Authorizer{
+		Default:    PermissionRead,
+		Principals: map[string]Permission{"admin": PermissionWrite},
+		Routes:     map[string]Permission{"GET " + nodeBundleFileEndpoint: PermissionWrite},
+	}
+
+	assert.True(t, a.allowed("anyone", nodeBundleEndpoint, http.MethodGet))
+	assert.False(t, a.allowed("anyone", nodeBundleFileEndpoint, http.MethodGet))
+	assert.False(t, a.allowed("anyone", clusterBundleEndpoint, http.MethodPut))
+	assert.True(t, a.allowed("admin", nodeBundleFileEndpoint, http.MethodGet))
+	assert.True(t, a.allowed("admin", clusterBundleEndpoint, http.MethodPut))
+}
+
+func TestLoadAuthorizer(t *testing.T) {
+	f, err := ioutil.TempFile("", "authz")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`{"principals": {"admin": "write"}, "routes": {"GET /metrics": "read"}}`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	a, err := LoadAuthorizer(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, &Authorizer{
+		Default:    PermissionNone,
+		Principals: map[string]Permission{"admin": PermissionWrite},
+		Routes:     map[string]Permission{"GET /metrics": PermissionRead},
+	}, a)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`{"principals": {"admin": "root"}}`), 0600))
+	_, err = LoadAuthorizer(f.Name())
+	assert.EqualError(t, err, "invalid authorization config "+f.Name()+": unknown permission root of admin")
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxCandidateConfigSize limits the size of endpoints config validated without being applied
+const maxCandidateConfigSize = 1024 * 1024
+
+// providersDiff lists keys of providers that would be added, removed or modified by a reload
+type providersDiff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// configValidationReport describes a candidate endpoints config and what would change if it was reloaded
+type configValidationReport struct {
+	Valid  bool     `json:"valid"`
+	File   string   `json:"file,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+	// diffs and the number of collectors are reported only for valid candidates
+	HTTPEndpoints *providersDiff `json:"http_endpoints,omitempty"`
+	LocalFiles    *providersDiff `json:"local_files,omitempty"`
+	LocalCommands *providersDiff `json:"local_commands,omitempty"`
+	Collectors    int            `json:"collectors,omitempty"`
+}
+
+// A handler function to validate a candidate endpoints config sent in the request body without applying it.
+// The candidate replaces the configured endpoints config file given with the file query parameter, it could be
+// omitted when there is at most one config file. It is checked the same way reload does and the report lists
+// providers that would be added, removed or modified compared to the loaded ones.
+func (h *handler) validateProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	report := configValidationReport{}
+
+	target, err := candidateTarget(h.cfg.FlagDiagnosticsBundleEndpointsConfigFiles, r.URL.Query().Get("file"))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report.File = target
+
+	candidate, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCandidateConfigSize+1))
+	if err != nil {
+		httpError(w, fmt.Sprintf("could not read candidate config: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(candidate) > maxCandidateConfigSize {
+		httpError(w, fmt.Sprintf("candidate config is larger than %d bytes", maxCandidateConfigSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if errs := validateEndpointsConfig(candidate); len(errs) > 0 {
+		report.Errors = errs
+		writeValidationReport(w, http.StatusBadRequest, report)
+		return
+	}
+
+	// the candidate is loaded from a temporary file the same way configured files are loaded on reload
+	f, err := ioutil.TempFile("", "endpoints-config")
+	if err != nil {
+		httpError(w, fmt.Sprintf("could not store candidate config: %s", err), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(candidate)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		httpError(w, fmt.Sprintf("could not store candidate config: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	cfg := *h.cfg
+	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = replaceConfigFile(h.cfg.FlagDiagnosticsBundleEndpointsConfigFiles, target, f.Name())
+
+	providers, err := buildLogProviders(&cfg, h.tools)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not load providers: %s", err))
+	}
+	collectors, err := LoadCollectors(&cfg, h.tools, h.collectorsClient)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not load collectors: %s", err))
+	}
+	if len(report.Errors) > 0 {
+		writeValidationReport(w, http.StatusBadRequest, report)
+		return
+	}
+
+	current := h.job.getLogProviders()
+	report.Valid = true
+	report.HTTPEndpoints = diffProviders(current.HTTPEndpoints, providers.HTTPEndpoints)
+	report.LocalFiles = diffProviders(current.LocalFiles, providers.LocalFiles)
+	report.LocalCommands = diffProviders(current.LocalCommands, providers.LocalCommands)
+	report.Collectors = len(collectors)
+	writeValidationReport(w, http.StatusOK, report)
+}
+
+func writeValidationReport(w http.ResponseWriter, code int, report configValidationReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
+// candidateTarget returns the configured endpoints config file the candidate replaces, empty when it is added
+func candidateTarget(files []string, requested string) (string, error) {
+	if requested == "" {
+		switch len(files) {
+		case 0:
+			return "", nil
+		case 1:
+			return files[0], nil
+		}
+		return "", fmt.Errorf("there are %d endpoints config files, select the one to validate with file parameter", len(files))
+	}
+	for _, f := range files {
+		if f == requested {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a configured endpoints config file", requested)
+}
+
+// replaceConfigFile returns a copy of files with target replaced by candidate, candidate is appended when target is empty
+func replaceConfigFile(files []string, target, candidate string) []string {
+	replaced := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		if f == target {
+			f = candidate
+		}
+		replaced = append(replaced, f)
+	}
+	if target == "" {
+		replaced = append(replaced, candidate)
+	}
+	return replaced
+}
+
+// validateEndpointsConfig checks the config is a single JSON object with known fields of expected types
+// and that every provider has its required fields set
+func validateEndpointsConfig(data []byte) []string {
+	var providers LogProviders
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&providers); err != nil {
+		return []string{fmt.Sprintf("could not parse candidate config: %s", err)}
+	}
+	if decoder.More() {
+		return []string{"could not parse candidate config: unexpected data after the config object"}
+	}
+
+	var errs []string
+	for i, p := range providers.HTTPEndpoints {
+		if p.Port <= 0 || p.Port > 65535 {
+			errs = append(errs, fmt.Sprintf("HTTPEndpoints[%d]: invalid Port %d", i, p.Port))
+		}
+		if p.URI == "" {
+			errs = append(errs, fmt.Sprintf("HTTPEndpoints[%d]: URI is required", i))
+		}
+	}
+	for i, p := range providers.LocalFiles {
+		if p.Location == "" {
+			errs = append(errs, fmt.Sprintf("LocalFiles[%d]: Location is required", i))
+		}
+		if p.Rotated < 0 {
+			errs = append(errs, fmt.Sprintf("LocalFiles[%d]: Rotated must not be negative", i))
+		}
+	}
+	for i, p := range providers.LocalCommands {
+		if len(p.Command) == 0 {
+			errs = append(errs, fmt.Sprintf("LocalCommands[%d]: Command is required", i))
+		}
+	}
+	for i, p := range providers.MetricsEndpoints {
+		if p.Port <= 0 || p.Port > 65535 {
+			errs = append(errs, fmt.Sprintf("MetricsEndpoints[%d]: invalid Port %d", i, p.Port))
+		}
+	}
+	return errs
+}
+
+// diffProviders compares providers maps keyed by their file names, both maps must have the same value type
+func diffProviders(current, candidate interface{}) *providersDiff {
+	diff := &providersDiff{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	cur, cand := reflect.ValueOf(current), reflect.ValueOf(candidate)
+	for _, key := range cand.MapKeys() {
+		old := cur.MapIndex(key)
+		switch {
+		case !old.IsValid():
+			diff.Added = append(diff.Added, key.String())
+		case !reflect.DeepEqual(old.Interface(), cand.MapIndex(key).Interface()):
+			diff.Modified = append(diff.Modified, key.String())
+		}
+	}
+	for _, key := range cur.MapKeys() {
+		if !cand.MapIndex(key).IsValid() {
+			diff.Removed = append(diff.Removed, key.String())
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff
+}
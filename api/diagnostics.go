@@ -12,7 +12,6 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -23,9 +22,10 @@ import (
 
 	"github.com/dcos/dcos-diagnostics/fetcher"
 
+	"github.com/dcos/dcos-diagnostics/api/rest"
+	"github.com/dcos/dcos-diagnostics/collector"
 	"github.com/dcos/dcos-diagnostics/config"
 	"github.com/dcos/dcos-diagnostics/dcos"
-	"github.com/dcos/dcos-diagnostics/units"
 	"github.com/dcos/dcos-diagnostics/util"
 
 	"github.com/shirou/gopsutil/disk"
@@ -51,9 +51,12 @@ type DiagnosticsJob struct {
 	statusMutex   sync.RWMutex
 	progressMutex sync.RWMutex
 
-	cancelFunc   context.CancelFunc
-	logProviders logProviders
-	client       *http.Client
+	cancelFunc     context.CancelFunc
+	providersMutex sync.RWMutex
+	logProviders   logProviders
+	client         *http.Client
+	history        *historyLog
+	diskUsage      func(path string) (*disk.UsageStat, error) // disk.Usage when nil
 
 	Cfg       *config.Config
 	DCOSTools dcos.Tooler
@@ -101,6 +104,7 @@ type bundleReportStatus struct {
 	JobStarted            string   `json:"job_started"`
 	JobEnded              string   `json:"job_ended,omitempty"`
 	JobDuration           string   `json:"job_duration,omitempty"`
+	TimeRemaining         string   `json:"time_remaining,omitempty"`
 	JobProgressPercentage float32  `json:"job_progress_percentage"`
 
 	// config related fields
@@ -167,6 +171,11 @@ func (j *DiagnosticsJob) run(req bundleCreateRequest) (createResponse, error) {
 		}
 	}
 
+	if err := j.checkDiskSpace(); err != nil {
+		j.setStatus(err.Error())
+		return prepareCreateResponseWithErr(http.StatusInsufficientStorage, err)
+	}
+
 	// Null errors on every new run.
 	j.Errors = nil
 
@@ -188,6 +197,7 @@ func (j *DiagnosticsJob) run(req bundleCreateRequest) (createResponse, error) {
 		duration := time.Since(start)
 		bundleCreationTimeHistogram.Observe(duration.Seconds())
 		bundleCreationTimeGauge.Set(duration.Seconds())
+		j.recordHistory(bundleName, len(foundNodes), duration)
 	}()
 
 	var r createResponse
@@ -282,6 +292,24 @@ func appendToZip(writer *zip.Writer, path string) error {
 	return nil
 }
 
+// recordHistory appends the outcome of the finished job to the collection history if it is enabled
+func (j *DiagnosticsJob) recordHistory(bundleName string, nodes int, duration time.Duration) {
+	if j.history == nil {
+		return
+	}
+	err := j.history.append(historyEntry{
+		Time:     time.Now(),
+		ID:       strings.TrimSuffix(bundleName, filepath.Ext(bundleName)),
+		Nodes:    nodes,
+		Errors:   len(j.getErrors()),
+		Duration: duration.String(),
+		Status:   j.getStatus(),
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("Could not record collection history")
+	}
+}
+
 func (j *DiagnosticsJob) flushReport(zipWriter *zip.Writer, fileName string, report *bytes.Buffer) {
 	zipFile, err := zipWriter.Create(fileName)
 	if err != nil {
@@ -317,7 +345,7 @@ func (j *DiagnosticsJob) collectDataFromNodes(ctx context.Context, nodes []dcos.
 		if err != nil {
 			return nil, fmt.Errorf("could not start fetchers: %s", err)
 		}
-		go f.Run(ctx)
+		go f.WithConcurrency(j.Cfg.FlagDiagnosticsBundleFetcherConcurrency).Run(ctx)
 	}
 
 	j.waitForStatusUpdates(ctx, fetchStatusUpdate, len(fetchRequests), summaryReport, summaryErrorsReport)
@@ -561,7 +589,6 @@ func (j *DiagnosticsJob) getStatusAll() (map[string]bundleReportStatus, error) {
 	}
 
 	statuses := make(map[string]bundleReportStatus, len(masterNodes))
-	var errs []error
 
 	localIP, err := j.DCOSTools.DetectIP()
 	if err != nil {
@@ -570,31 +597,29 @@ func (j *DiagnosticsJob) getStatusAll() (map[string]bundleReportStatus, error) {
 		statuses[localIP] = j.getBundleReportStatus()
 	}
 
-	for _, master := range masterNodes {
+	errs := forEachMaster(masterNodes, j.DCOSTools, j.Cfg.FlagMasterRediscoveries, func(master dcos.Node) error {
 		if master.IP == localIP {
-			continue
+			return nil
 		}
 		var status bundleReportStatus
 		url := fmt.Sprintf("http://%s:%d%s/report/diagnostics/status", master.IP, j.Cfg.FlagMasterPort, baseRoute)
 		body, code, err := j.DCOSTools.Get(url, time.Second*3)
 		if code != 200 {
 			logrus.WithField("StatusCode", code).WithField("URL", url).Error("Could not get data")
-			errs = append(errs, fmt.Errorf("could not get data from %s got %d status", url, code))
-			continue
+			return fmt.Errorf("could not get data from %s got %d status", url, code)
 		}
 		if err != nil {
 			logrus.WithError(err).WithField("URL", url).Error("Could not get data")
-			errs = append(errs, fmt.Errorf("could not get data from %s: %s", url, err))
-			continue
+			return fmt.Errorf("could not get data from %s: %s", url, err)
 		}
 		err = json.Unmarshal(body, &status)
 		if err != nil {
 			logrus.WithError(err).WithField("IP", master.IP).Errorf("Could not determine job status for master")
-			errs = append(errs, fmt.Errorf("could not determine job status for master %s: %s", master.IP, err))
-			continue
+			return fmt.Errorf("could not determine job status for master %s: %s", master.IP, err)
 		}
 		statuses[master.IP] = status
-	}
+		return nil
+	})
 	if len(statuses) == 0 || len(errs) != 0 {
 		return statuses, fmt.Errorf("could not determine whether the diagnostics job is running or not: %v", errs)
 	}
@@ -607,8 +632,7 @@ func (j *DiagnosticsJob) getBundleReportStatus() bundleReportStatus {
 	// use a temp var `used`, since disk.Usage panics if partition does not exist.
 	var used float64
 	cfg := j.Cfg
-	//TODO(janisz): Inject disk.Usage to DiagnosticsJob so this could be tested.
-	usageStat, err := disk.Usage(cfg.FlagDiagnosticsBundleDir)
+	usageStat, err := j.getDiskUsage(cfg.FlagDiagnosticsBundleDir)
 	if err == nil {
 		used = usageStat.UsedPercent
 	} else {
@@ -623,9 +647,13 @@ func (j *DiagnosticsJob) getBundleReportStatus() bundleReportStatus {
 	running := j.Running
 	ended := ""
 	duration := ""
+	remaining := ""
 	if !running {
 		ended = j.JobEnded.String()
 		duration = j.JobEnded.Sub(j.JobStarted).String()
+	} else {
+		timeout := time.Minute * time.Duration(cfg.FlagDiagnosticsJobTimeoutMinutes)
+		remaining = rest.TimeRemaining(time.Now(), j.JobStarted, timeout).String()
 	}
 
 	status := bundleReportStatus{
@@ -636,6 +664,7 @@ func (j *DiagnosticsJob) getBundleReportStatus() bundleReportStatus {
 		JobStarted:            j.JobStarted.String(),
 		JobEnded:              ended,
 		JobDuration:           duration,
+		TimeRemaining:         remaining,
 		JobProgressPercentage: jobProgressPercentage,
 
 		DiagnosticBundlesBaseDir:                 cfg.FlagDiagnosticsBundleDir,
@@ -740,20 +769,22 @@ func listAllBundles(cfg *config.Config, DCOSTools dcos.Tooler) (map[string][]bun
 	if err != nil {
 		return collectedBundles, err
 	}
-	for _, master := range masterNodes {
+	// masters that could not be listed are skipped so bundles stored on the rest of masters are still listed
+	forEachMaster(masterNodes, DCOSTools, cfg.FlagMasterRediscoveries, func(master dcos.Node) error {
 		var bundleUrls []bundle
 		url := fmt.Sprintf("http://%s:%d%s/report/diagnostics/list", master.IP, cfg.FlagMasterPort, baseRoute)
 		body, _, err := DCOSTools.Get(url, time.Second*3)
 		if err != nil {
 			logrus.WithError(err).WithFields(logrus.Fields{"body": body, "URL": url}).Errorf("Could not HTTP GET")
-			continue
+			return err
 		}
 		if err = json.Unmarshal(body, &bundleUrls); err != nil {
 			logrus.WithError(err).WithFields(logrus.Fields{"body": body, "URL": url}).Errorf("Could not unmarshal response")
-			continue
+			return err
 		}
 		collectedBundles[fmt.Sprintf("%s:%d", master.IP, cfg.FlagMasterPort)] = bundleUrls
-	}
+		return nil
+	})
 	return collectedBundles, nil
 }
 
@@ -877,8 +908,10 @@ func (j *DiagnosticsJob) getLogsEndpoints() (endpoints map[string]endpointSpec,
 		return endpoints, err
 	}
 
+	providers := j.getLogProviders()
+
 	// http endpoints
-	for fileName, httpEndpoint := range j.logProviders.HTTPEndpoints {
+	for fileName, httpEndpoint := range providers.HTTPEndpoints {
 		// if a role wasn't detected, consider to load all endpoints from a cfg file.
 		// if the role could not be detected or it is not set in a cfg file use the log endpoint.
 		// do not use the role only if it is set, detected and does not match the role form a cfg.
@@ -892,7 +925,7 @@ func (j *DiagnosticsJob) getLogsEndpoints() (endpoints map[string]endpointSpec,
 	}
 
 	// file endpoints
-	for sanitizedLocation, file := range j.logProviders.LocalFiles {
+	for sanitizedLocation, file := range providers.LocalFiles {
 		if !roleMatched(currentRole, file.Role) {
 			continue
 		}
@@ -902,7 +935,7 @@ func (j *DiagnosticsJob) getLogsEndpoints() (endpoints map[string]endpointSpec,
 	}
 
 	// command endpoints
-	for cmdKey, c := range j.logProviders.LocalCommands {
+	for cmdKey, c := range providers.LocalCommands {
 		if !roleMatched(currentRole, c.Role) {
 			continue
 		}
@@ -917,13 +950,34 @@ func (j *DiagnosticsJob) getLogsEndpoints() (endpoints map[string]endpointSpec,
 
 // Init will prepare diagnostics job, read config files etc.
 func (j *DiagnosticsJob) Init() error {
-	providers, err := loadProviders(j.Cfg, j.DCOSTools)
+	providers, err := j.loadLogProviders()
 	if err != nil {
 		return fmt.Errorf("could not init diagnostic job: %s", err)
 	}
 	// set JobProgressPercentage -1 means the job has never been executed
 	j.setJobProgressPercentage(-1)
-	j.logProviders = logProviders{
+	j.setLogProviders(providers)
+
+	j.client = util.NewHTTPClient(j.Cfg.GetSingleEntryTimeout(), j.Transport)
+	j.history = newHistoryLog(filepath.Join(j.Cfg.FlagDiagnosticsBundleDir, collectionHistoryFileName),
+		int64(j.Cfg.FlagCollectionHistoryMaxSizeKB)*1024, j.Cfg.FlagCollectionHistoryMaxFiles)
+
+	return nil
+}
+
+// loadLogProviders reads providers from config files and indexes them by names used in logs endpoints.
+func (j *DiagnosticsJob) loadLogProviders() (logProviders, error) {
+	return buildLogProviders(j.Cfg, j.DCOSTools)
+}
+
+// buildLogProviders loads providers with given config and keys them by names of files they are stored in
+func buildLogProviders(cfg *config.Config, tools dcos.Tooler) (logProviders, error) {
+	providers, err := loadProviders(cfg, tools)
+	if err != nil {
+		return logProviders{}, err
+	}
+
+	result := logProviders{
 		HTTPEndpoints: make(map[string]HTTPProvider),
 		LocalFiles:    make(map[string]FileProvider),
 		LocalCommands: make(map[string]CommandProvider),
@@ -934,13 +988,13 @@ func (j *DiagnosticsJob) Init() error {
 		if endpoint.FileName != "" {
 			fileName = endpoint.FileName
 		}
-		j.logProviders.HTTPEndpoints[fileName] = endpoint
+		result.HTTPEndpoints[fileName] = endpoint
 	}
 
 	// trim left "/" and replace all slashes with underscores.
 	for _, fileProvider := range providers.LocalFiles {
 		key := strings.Replace(strings.TrimLeft(fileProvider.Location, "/"), "/", "_", -1)
-		j.logProviders.LocalFiles[key] = fileProvider
+		result.LocalFiles[key] = fileProvider
 	}
 
 	// sanitize command to use as filename
@@ -949,13 +1003,23 @@ func (j *DiagnosticsJob) Init() error {
 			cmdWithArgs := strings.Join(commandProvider.Command, "_")
 			trimmedCmdWithArgs := strings.Replace(cmdWithArgs, "/", "", -1)
 			key := fmt.Sprintf("%s.output", trimmedCmdWithArgs)
-			j.logProviders.LocalCommands[key] = commandProvider
+			result.LocalCommands[key] = commandProvider
 		}
 	}
 
-	j.client = util.NewHTTPClient(j.Cfg.GetSingleEntryTimeout(), j.Transport)
+	return result, nil
+}
 
-	return nil
+func (j *DiagnosticsJob) getLogProviders() logProviders {
+	j.providersMutex.RLock()
+	defer j.providersMutex.RUnlock()
+	return j.logProviders
+}
+
+func (j *DiagnosticsJob) setLogProviders(providers logProviders) {
+	j.providersMutex.Lock()
+	defer j.providersMutex.Unlock()
+	j.logProviders = providers
 }
 
 func roleMatched(myRole string, roles []string) bool {
@@ -972,8 +1036,10 @@ func (j *DiagnosticsJob) dispatchLogs(ctx context.Context, provider, entity stri
 		return r, fmt.Errorf("could not get a node role: %s", err)
 	}
 
+	providers := j.getLogProviders()
+
 	if provider == "units" {
-		endpoint, ok := j.logProviders.HTTPEndpoints[entity]
+		endpoint, ok := providers.HTTPEndpoints[entity]
 		if !ok {
 			return r, errors.New("Not found " + entity)
 		}
@@ -986,12 +1052,16 @@ func (j *DiagnosticsJob) dispatchLogs(ctx context.Context, provider, entity stri
 		if err != nil {
 			return r, fmt.Errorf("error parsing '%s': %s", j.Cfg.FlagDiagnosticsBundleUnitsLogsSinceString, err.Error())
 		}
-		return units.ReadJournalOutputSince(ctx, entity, duration)
+		fallbackFiles, err := collector.ParseJournalFallbackFiles(j.Cfg.FlagJournalFallbackFiles)
+		if err != nil {
+			return r, err
+		}
+		return collector.NewSystemd(entity, false, entity, duration).WithFallbackFile(fallbackFiles[entity]).Collect(ctx)
 	}
 
 	if provider == "files" {
 		logrus.Debugf("dispatching a file %s", entity)
-		fileProvider, ok := j.logProviders.LocalFiles[entity]
+		fileProvider, ok := providers.LocalFiles[entity]
 		if !ok {
 			return r, errors.New("Not found " + entity)
 		}
@@ -1001,7 +1071,11 @@ func (j *DiagnosticsJob) dispatchLogs(ctx context.Context, provider, entity stri
 		}
 		logrus.Debugf("Found a file %s", fileProvider.Location)
 
-		file, err := os.Open(fileProvider.Location)
+		symlinks, err := symlinkPolicy(j.Cfg)
+		if err != nil {
+			return r, err
+		}
+		file, err := symlinks.Open(fileProvider.Location)
 		if err != nil && fileProvider.Optional {
 			return ioutil.NopCloser(bytes.NewReader([]byte(err.Error()))), nil
 		}
@@ -1009,7 +1083,7 @@ func (j *DiagnosticsJob) dispatchLogs(ctx context.Context, provider, entity stri
 	}
 	if provider == "cmds" {
 		logrus.Debugf("dispatching a command %s", entity)
-		cmdProvider, ok := j.logProviders.LocalCommands[entity]
+		cmdProvider, ok := providers.LocalCommands[entity]
 		if !ok {
 			return r, errors.New("Not found " + entity)
 		}
@@ -1018,15 +1092,23 @@ func (j *DiagnosticsJob) dispatchLogs(ctx context.Context, provider, entity stri
 			return r, errors.New("Not allowed to execute a command")
 		}
 
-		cmd := exec.CommandContext(ctx, cmdProvider.Command[0], cmdProvider.Command[1:]...)
-		output, err := cmd.CombinedOutput()
-		if err != nil && cmdProvider.Optional {
-			// combine output with error
-			o := append([]byte(err.Error()+"\n"), output...)
-			return ioutil.NopCloser(bytes.NewReader(o)), nil
+		command, optional := cmdProvider.Command, cmdProvider.Optional
+		if wrapper := strings.Fields(j.Cfg.FlagCommandElevationWrapper); cmdProvider.Elevated && len(wrapper) > 0 {
+			// elevation could be denied on some nodes and the wrapper waiting for a password must not block
+			command, optional = append(wrapper, command...), true
+			if timeout := time.Duration(j.Cfg.FlagCommandExecTimeoutSec) * time.Second; timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+		}
+		output, err := collector.RunCommand(ctx, command)
+		if err != nil && optional {
+			// combine output with error, the output header tells the exit code
+			return ioutil.NopCloser(io.MultiReader(strings.NewReader(err.Error()+"\n"), output)), nil
 		}
 
-		return ioutil.NopCloser(bytes.NewReader(output)), err
+		return output, err
 	}
 	return r, errors.New("Unknown provider " + provider)
 }
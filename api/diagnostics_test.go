@@ -25,6 +25,7 @@ import (
 	"github.com/dcos/dcos-diagnostics/dcos"
 	"github.com/dcos/dcos-diagnostics/mocks"
 
+	"github.com/shirou/gopsutil/disk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -184,7 +185,44 @@ func TestDispatchLogsForCommand(t *testing.T) {
 
 	data, err := ioutil.ReadAll(r)
 	require.NoError(t, err)
-	assert.Equal(t, "OK\n", string(data))
+	assert.Regexp(t, "^# exit code: 0, duration: .+\nOK\n$", string(data))
+}
+
+func TestDispatchLogsForElevatedCommandIsLimitedByTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+
+	job := DiagnosticsJob{Cfg: testCfg(), DCOSTools: &fakeDCOSTools{}}
+	job.Cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{filepath.Join("testdata", "endpoint-config.json")}
+
+	err := job.Init()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "elevation")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// wrapper waiting for a password forever
+	wrapper := filepath.Join(dir, "wrapper")
+	require.NoError(t, ioutil.WriteFile(wrapper, []byte("#!/bin/sh\nexec sleep 60\n"), 0755))
+	job.Cfg.FlagCommandElevationWrapper = wrapper
+	job.Cfg.FlagCommandExecTimeoutSec = 1
+
+	providers := job.getLogProviders()
+	providers.LocalCommands = map[string]CommandProvider{
+		"echo_OK.output": {Command: []string{"echo", "OK"}, Role: []string{"master"}, Elevated: true},
+	}
+	job.setLogProviders(providers)
+
+	start := time.Now()
+	r, err := job.dispatchLogs(context.TODO(), "cmds", "echo_OK.output")
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < 30*time.Second)
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "signal: killed")
 }
 
 func TestDispatchLogsForCommandThatNotExistsButIsOptional(t *testing.T) {
@@ -200,6 +238,7 @@ func TestDispatchLogsForCommandThatNotExistsButIsOptional(t *testing.T) {
 	data, err := ioutil.ReadAll(r)
 	require.NoError(t, err)
 	assert.Contains(t, string(data), `exec: "does": executable file not found in `)
+	assert.Contains(t, string(data), "# exit code: -1, duration: ")
 }
 
 func TestDispatchLogsForCommandThatNotExistsAndIsRequired(t *testing.T) {
@@ -409,19 +448,23 @@ func TestGetStatus(t *testing.T) {
 		{
 			&DiagnosticsJob{Running: true, Cfg: config, DCOSTools: tools,
 				JobStarted: time.Date(2019, 9, 1, 4, 45, 0, 0, time.UTC),
+				diskUsage:  func(string) (*disk.UsageStat, error) { return &disk.UsageStat{UsedPercent: 42}, nil },
 			},
 			bundleReportStatus{
 				Running:                        true,
 				JobStarted:                     "2019-09-01 04:45:00 +0000 UTC",
+				TimeRemaining:                  "0s",
 				Errors:                         []string{},
 				DiagnosticBundlesBaseDir:       config.FlagDiagnosticsBundleDir,
 				DiagnosticsJobTimeoutMin:       config.FlagDiagnosticsJobTimeoutMinutes,
 				DiagnosticsUnitsLogsSinceHours: config.FlagDiagnosticsBundleUnitsLogsSinceString,
+				DiskUsedPercent:                42,
 			},
 		}, {
 			&DiagnosticsJob{Running: false, Cfg: config, DCOSTools: tools,
 				JobStarted: time.Date(2019, 9, 1, 4, 45, 0, 0, time.UTC),
 				JobEnded:   time.Date(2019, 9, 1, 5, 00, 0, 0, time.UTC),
+				diskUsage:  func(string) (*disk.UsageStat, error) { return &disk.UsageStat{UsedPercent: 42}, nil },
 			},
 			bundleReportStatus{
 				Running:                        false,
@@ -432,13 +475,13 @@ func TestGetStatus(t *testing.T) {
 				DiagnosticBundlesBaseDir:       config.FlagDiagnosticsBundleDir,
 				DiagnosticsJobTimeoutMin:       config.FlagDiagnosticsJobTimeoutMinutes,
 				DiagnosticsUnitsLogsSinceHours: config.FlagDiagnosticsBundleUnitsLogsSinceString,
+				DiskUsedPercent:                42,
 			},
 		},
 	}
 
 	for _, i := range parameters {
 		actual := i.job.getBundleReportStatus()
-		i.expected.DiskUsedPercent = actual.DiskUsedPercent //TODO(janisz): Inject disk.Usage to DiagnosticsJob so this could be tested.
 		assert.Equal(t, i.expected, actual)
 	}
 }
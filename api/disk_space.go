@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/sirupsen/logrus"
+)
+
+// insufficientSpaceError is returned when the bundle directory has not enough free space to start a diagnostics job
+type insufficientSpaceError struct {
+	dir      string
+	free     uint64
+	required uint64
+	reason   string
+}
+
+func (e insufficientSpaceError) Error() string {
+	return fmt.Sprintf("not enough free space in %s: %d bytes free, %d bytes required by %s",
+		e.dir, e.free, e.required, e.reason)
+}
+
+// getDiskUsage returns usage of the partition holding the path. disk.Usage is used unless replaced e.g., in tests.
+func (j *DiagnosticsJob) getDiskUsage(path string) (*disk.UsageStat, error) {
+	if j.diskUsage != nil {
+		return j.diskUsage(path)
+	}
+	return disk.Usage(path)
+}
+
+// checkDiskSpace refuses to start the job when free space of the bundle directory partition is below the configured
+// threshold or below the size of the largest bundle stored there, which estimates the size of the next one.
+// It is better to reject the job upfront than to fail it when the disk fills up with a partially written zip.
+// When the usage could not be read the job is started anyway.
+func (j *DiagnosticsJob) checkDiskSpace() error {
+	dir := j.Cfg.FlagDiagnosticsBundleDir
+	minFree := uint64(j.Cfg.FlagDiagnosticsBundleMinFreeSpaceMB) * 1024 * 1024
+	if minFree == 0 && !j.Cfg.FlagDiagnosticsBundleEstimateSpace {
+		return nil
+	}
+
+	usage, err := j.getDiskUsage(dir)
+	if err != nil {
+		logrus.WithError(err).Warnf("Could not get a disk usage of %s, free space is not checked", dir)
+		return nil
+	}
+
+	if usage.Free < minFree {
+		return insufficientSpaceError{dir: dir, free: usage.Free, required: minFree, reason: "diagnostics-bundle-min-free-space"}
+	}
+
+	if !j.Cfg.FlagDiagnosticsBundleEstimateSpace {
+		return nil
+	}
+	estimate, err := j.estimateBundleSize()
+	if err != nil {
+		logrus.WithError(err).Warn("Could not estimate a bundle size, free space is checked only against the threshold")
+		return nil
+	}
+	if usage.Free < estimate {
+		return insufficientSpaceError{dir: dir, free: usage.Free, required: estimate, reason: "the largest previous bundle"}
+	}
+	return nil
+}
+
+// estimateBundleSize returns the size of the largest bundle in the bundle directory, 0 when there are none
+func (j *DiagnosticsJob) estimateBundleSize() (uint64, error) {
+	bundles, err := j.findLocalBundle()
+	if err != nil {
+		return 0, err
+	}
+	var largest int64
+	for _, bundle := range bundles {
+		stat, err := os.Stat(bundle)
+		if err != nil {
+			return 0, fmt.Errorf("could not stat %s: %s", bundle, err)
+		}
+		if stat.Size() > largest {
+			largest = stat.Size()
+		}
+	}
+	return uint64(largest), nil
+}
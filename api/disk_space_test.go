@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+func lowDiskTools() *MockedTools {
+	tools := new(MockedTools)
+	tools.On("GetNodeRole").Return("master", nil)
+	tools.On("DetectIP").Return("127.0.0.1", nil)
+	tools.On("GetAgentNodes").Return([]dcos.Node{}, nil)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Leader: true, IP: "127.0.0.1", Role: "master"}}, nil)
+	return tools
+}
+
+func freeSpace(free uint64) func(string) (*disk.UsageStat, error) {
+	return func(string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Free: free}, nil
+	}
+}
+
+func TestRunIsRefusedWhenFreeSpaceIsBelowThreshold(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	cfg.FlagDiagnosticsBundleMinFreeSpaceMB = 100
+
+	job := &DiagnosticsJob{Cfg: cfg, DCOSTools: lowDiskTools(), client: http.DefaultClient, diskUsage: freeSpace(10 * 1024 * 1024)}
+
+	response, err := job.run(bundleCreateRequest{Nodes: []string{"all"}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusInsufficientStorage, response.ResponseCode)
+	assert.Equal(t, fmt.Sprintf("not enough free space in %s: 10485760 bytes free, 104857600 bytes required by "+
+		"diagnostics-bundle-min-free-space", cfg.FlagDiagnosticsBundleDir), response.Status)
+	assert.False(t, job.Running)
+
+	bundles, err := job.findLocalBundle()
+	require.NoError(t, err)
+	assert.Empty(t, bundles)
+}
+
+func TestRunIsRefusedWhenFreeSpaceIsBelowEstimatedBundleSize(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	cfg.FlagDiagnosticsBundleEstimateSpace = true
+
+	previous := filepath.Join(cfg.FlagDiagnosticsBundleDir, "bundle-2020-01-01-1577836800.zip")
+	require.NoError(t, ioutil.WriteFile(previous, make([]byte, 2048), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cfg.FlagDiagnosticsBundleDir, "bundle-2020-01-02-1577923200.zip"),
+		make([]byte, 1024), 0600))
+
+	job := &DiagnosticsJob{Cfg: cfg, DCOSTools: lowDiskTools(), client: http.DefaultClient, diskUsage: freeSpace(2000)}
+
+	response, err := job.run(bundleCreateRequest{Nodes: []string{"all"}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusInsufficientStorage, response.ResponseCode)
+	assert.Contains(t, response.Status, "2000 bytes free, 2048 bytes required by the largest previous bundle")
+	assert.False(t, job.Running)
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cfg.FlagDiagnosticsBundleDir, "bundle-2020-01-01-1577836800.zip"),
+		make([]byte, 2048), 0600))
+
+	job := &DiagnosticsJob{Cfg: cfg, diskUsage: freeSpace(0)}
+	assert.NoError(t, job.checkDiskSpace(), "check is disabled by default")
+
+	cfg.FlagDiagnosticsBundleMinFreeSpaceMB = 1
+	cfg.FlagDiagnosticsBundleEstimateSpace = true
+	job.diskUsage = freeSpace(1024 * 1024)
+	assert.NoError(t, job.checkDiskSpace())
+
+	job.diskUsage = func(string) (*disk.UsageStat, error) { return nil, fmt.Errorf("no partition") }
+	assert.NoError(t, job.checkDiskSpace(), "job is started when usage could not be read")
+}
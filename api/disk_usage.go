@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BundleDiskUsage is a space used by a single entry of the bundle directory.
+// Bundles stored as directories are counted with all their files.
+type BundleDiskUsage struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// InodeUsage is an inode usage of the file system holding the bundle directory.
+type InodeUsage struct {
+	Total       uint64  `json:"total"`
+	Free        uint64  `json:"free"`
+	Used        uint64  `json:"used"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// DiskUsage is a breakdown of space used by the bundle directory. Inodes are reported only
+// on platforms where the file system exposes them.
+type DiskUsage struct {
+	Dir     string            `json:"dir"`
+	Total   int64             `json:"total"`
+	Bundles []BundleDiskUsage `json:"bundles"`
+	Inodes  *InodeUsage       `json:"inodes,omitempty"`
+}
+
+// bundleDirUsage returns sizes of all entries in the bundle directory.
+func bundleDirUsage(dir string) (DiskUsage, error) {
+	usage := DiskUsage{
+		Dir:     dir,
+		Bundles: []BundleDiskUsage{},
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return usage, fmt.Errorf("could not read bundle dir %s: %s", dir, err)
+	}
+
+	for _, entry := range entries {
+		size, err := pathSize(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return usage, err
+		}
+		usage.Bundles = append(usage.Bundles, BundleDiskUsage{Name: entry.Name(), Size: size})
+		usage.Total += size
+	}
+
+	inodes, err := inodeUsage(dir)
+	if err != nil {
+		log.WithError(err).WithField("dir", dir).Warn("Could not get inode usage")
+	}
+	usage.Inodes = inodes
+
+	return usage, nil
+}
+
+// pathSize returns a size of the file or a total size of all files in the directory.
+func pathSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not get size of %s: %s", path, err)
+	}
+	return size, nil
+}
+
+// A handler function returning space and inodes used by bundles stored on this node.
+func (h *handler) diskUsageHandler(w http.ResponseWriter, _ *http.Request) {
+	usage, err := bundleDirUsage(h.cfg.FlagDiagnosticsBundleDir)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// inodeUsage returns inode usage of the file system holding the path.
+func inodeUsage(path string) (*InodeUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, fmt.Errorf("could not stat file system of %s: %s", path, err)
+	}
+
+	usage := &InodeUsage{
+		Total: uint64(stat.Files),
+		Free:  uint64(stat.Ffree),
+	}
+	usage.Used = usage.Total - usage.Free
+	if usage.Total > 0 {
+		usage.UsedPercent = float64(usage.Used) / float64(usage.Total) * 100
+	}
+	return usage, nil
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleDirUsageReportsInodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	usage, err := bundleDirUsage(dir)
+	require.NoError(t, err)
+	require.NotNil(t, usage.Inodes)
+	assert.Equal(t, usage.Inodes.Total, usage.Inodes.Used+usage.Inodes.Free)
+}
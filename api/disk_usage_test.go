@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsageHandler(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	dir := cfg.FlagDiagnosticsBundleDir
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bundle-0", "contents"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bundle-0", "state.json"), make([]byte, 10), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bundle-0", "contents", "file"), make([]byte, 100), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bundle-1"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bundle-1", "file.zip"), make([]byte, 1000), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bundle-2020-01-01-1577836800.zip"), make([]byte, 5), 0600))
+
+	router := NewRouter(&Dt{
+		Cfg:         cfg,
+		DtDCOSTools: &fakeDCOSTools{},
+		MR:          &MonitoringResponse{},
+	})
+
+	body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/report/diagnostics/disk-usage", http.MethodGet, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var usage DiskUsage
+	require.NoError(t, json.Unmarshal(body, &usage))
+	assert.Equal(t, dir, usage.Dir)
+	assert.Equal(t, int64(1115), usage.Total)
+	assert.Equal(t, []BundleDiskUsage{
+		{Name: "bundle-0", Size: 110},
+		{Name: "bundle-1", Size: 1000},
+		{Name: "bundle-2020-01-01-1577836800.zip", Size: 5},
+	}, usage.Bundles)
+}
+
+func TestDiskUsageHandlerReturnsErrorWhenDirIsMissing(t *testing.T) {
+	cfg := testCfg()
+	os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+
+	router := NewRouter(&Dt{
+		Cfg:         cfg,
+		DtDCOSTools: &fakeDCOSTools{},
+		MR:          &MonitoringResponse{},
+	})
+
+	_, code, err := MakeHTTPRequest(t, router, "/system/health/v1/report/diagnostics/disk-usage", http.MethodGet, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, code)
+}
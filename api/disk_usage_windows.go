@@ -0,0 +1,6 @@
+package api
+
+// inodeUsage returns nil because NTFS does not limit the number of files with inodes.
+func inodeUsage(string) (*InodeUsage, error) {
+	return nil, nil
+}
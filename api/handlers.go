@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -11,10 +12,14 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/dcos/dcos-diagnostics/api/rest"
 	"github.com/dcos/dcos-diagnostics/config"
 	"github.com/dcos/dcos-diagnostics/dcos"
+	"github.com/dcos/dcos-diagnostics/units"
+	"github.com/dcos/dcos-diagnostics/util"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
@@ -26,6 +31,16 @@ type handler struct {
 	job                *DiagnosticsJob
 	systemdUnits       *SystemdUnits
 	monitoringResponse *MonitoringResponse
+	bundleHandler      rest.BundleHandler
+	collectorsClient   *http.Client
+}
+
+// reloadProvidersResponse contains a number of providers and collectors loaded on reload.
+type reloadProvidersResponse struct {
+	HTTPEndpoints int `json:"http_endpoints"`
+	LocalFiles    int `json:"local_files"`
+	LocalCommands int `json:"local_commands"`
+	Collectors    int `json:"collectors"`
 }
 
 // Route handlers
@@ -308,6 +323,42 @@ func (h *handler) createBundleHandler(w http.ResponseWriter, r *http.Request) {
 	writeCreateResponse(w, response)
 }
 
+// A handler function to reload providers and collectors from config files without restarting the daemon.
+// When any config file is invalid the previous configuration is kept.
+func (h *handler) reloadProvidersHandler(w http.ResponseWriter, _ *http.Request) {
+	if h.job == nil || h.collectorsClient == nil {
+		httpError(w, "could not reload providers: collectors are not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	providers, err := h.job.loadLogProviders()
+	if err != nil {
+		httpError(w, fmt.Sprintf("could not reload providers: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	collectors, err := LoadCollectors(h.cfg, h.tools, h.collectorsClient)
+	if err != nil {
+		httpError(w, fmt.Sprintf("could not reload collectors: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	h.job.setLogProviders(providers)
+	h.bundleHandler.SetCollectors(collectors)
+
+	response := reloadProvidersResponse{
+		HTTPEndpoints: len(providers.HTTPEndpoints),
+		LocalFiles:    len(providers.LocalFiles),
+		LocalCommands: len(providers.LocalCommands),
+		Collectors:    len(collectors),
+	}
+	log.WithField("providers", response).Info("Providers reloaded")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
+
 // A handler function to to get a list of available logs on a node.
 func (h *handler) logsListHandler(w http.ResponseWriter, _ *http.Request) {
 	endpoints, err := h.job.getLogsEndpoints()
@@ -347,6 +398,117 @@ func (h *handler) getUnitLogHandler(w http.ResponseWriter, r *http.Request) {
 	log.Infof("Done read %s", vars["entity"])
 }
 
+// unitLogEntry is a single line of a unit log streamed as NDJSON
+type unitLogEntry struct {
+	Unit    string `json:"unit"`
+	Message string `json:"message"`
+}
+
+// readJournalOutputSince opens unit logs, it is a variable so tests can use fake journal
+var readJournalOutputSince = units.ReadJournalOutputSince
+
+// A handler function to stream logs of multiple units as NDJSON. Units are given with unit query param
+// and logs are read since a duration given with since query param. Every line is tagged with the unit
+// it comes from and lines from different units are written as soon as they are read.
+func (h *handler) streamUnitsLogsHandler(w http.ResponseWriter, r *http.Request) {
+	unitNames := r.URL.Query()["unit"]
+	if len(unitNames) == 0 {
+		httpError(w, "at least one unit is required", http.StatusBadRequest)
+		return
+	}
+
+	sinceString := h.cfg.FlagDiagnosticsBundleUnitsLogsSinceString
+	if s := r.URL.Query().Get("since"); s != "" {
+		sinceString = s
+	}
+	since, err := time.ParseDuration(sinceString)
+	if err != nil {
+		httpError(w, fmt.Sprintf("invalid since %s: %s", sinceString, err), http.StatusBadRequest)
+		return
+	}
+
+	// all readers are open while streaming so more units than the limit would never get a free reader
+	if limit := units.MaxJournalReaders(); limit > 0 && len(unitNames) > limit {
+		httpError(w, fmt.Sprintf("at most %d units could be streamed at once", limit), http.StatusBadRequest)
+		return
+	}
+
+	knownUnits, err := h.tools.GetUnitNames()
+	if err != nil {
+		httpError(w, fmt.Sprintf("could not get units: %s", err), http.StatusInternalServerError)
+		return
+	}
+	for _, unit := range unitNames {
+		if !util.IsInList(unit, knownUnits) {
+			httpError(w, fmt.Sprintf("unit %s not found", unit), http.StatusNotFound)
+			return
+		}
+	}
+
+	// readers are closed when the response is finished or the client disconnects
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	readers := make([]io.ReadCloser, 0, len(unitNames))
+	defer func() {
+		for _, rc := range readers {
+			rc.Close()
+		}
+	}()
+	for _, unit := range unitNames {
+		rc, err := readJournalOutputSince(ctx, unit, since)
+		if err != nil {
+			httpError(w, fmt.Sprintf("could not read %s logs: %s", unit, err), http.StatusInternalServerError)
+			return
+		}
+		readers = append(readers, rc)
+	}
+
+	entries := make(chan unitLogEntry)
+	wg := sync.WaitGroup{}
+	for i, unit := range unitNames {
+		wg.Add(1)
+		go func(unit string, rc io.Reader) {
+			defer wg.Done()
+			scanner := bufio.NewScanner(rc)
+			for scanner.Scan() {
+				select {
+				case entries <- unitLogEntry{Unit: unit, Message: scanner.Text()}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				log.WithError(err).WithField("unit", unit).Warn("Could not read unit logs")
+			}
+		}(unit, readers[i])
+	}
+	go func() {
+		wg.Wait()
+		close(entries)
+	}()
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(entry); err != nil {
+				log.WithError(err).Warn("Could not write unit logs")
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
 func httpError(w http.ResponseWriter, msg string, code int) {
 	log.WithField("Code", code).Error(msg)
 	http.Error(w, msg, code)
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,15 +10,21 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/dcos/dcos-diagnostics/api/rest"
 	"github.com/dcos/dcos-diagnostics/config"
 	"github.com/dcos/dcos-diagnostics/dcos"
+	"github.com/dcos/dcos-diagnostics/units"
 
 	"github.com/gorilla/mux"
 	assertPackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -611,3 +618,274 @@ func (s *HandlersTestSuit) TestIsInListFunc() {
 func TestHandlersTestSuit(t *testing.T) {
 	suite.Run(t, new(HandlersTestSuit))
 }
+
+func TestReloadProvidersHandlerAppliesChangedConfigWithoutRestart(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	endpointsConfig := filepath.Join(workDir, "endpoints-config.json")
+	err = ioutil.WriteFile(endpointsConfig, []byte(`{"LocalFiles": [{"Location": "/etc/hosts"}]}`), 0600)
+	require.NoError(t, err)
+
+	cfg := testCfg()
+	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{endpointsConfig}
+
+	job := &DiagnosticsJob{Cfg: cfg, DCOSTools: &fakeDCOSTools{}}
+	require.NoError(t, job.Init())
+
+	bh, err := rest.NewBundleHandler(filepath.Join(workDir, "bundles"), nil, time.Minute, time.Second, nil)
+	require.NoError(t, err)
+
+	router := NewRouter(&Dt{
+		Cfg:              cfg,
+		DtDCOSTools:      job.DCOSTools,
+		DtDiagnosticsJob: job,
+		BundleHandler:    *bh,
+		MR:               &MonitoringResponse{},
+		CollectorsClient: &http.Client{},
+	})
+
+	assertPackage.Len(t, job.getLogProviders().LocalFiles, 1)
+
+	err = ioutil.WriteFile(endpointsConfig, []byte(`{
+		"LocalFiles": [{"Location": "/etc/hosts"}, {"Location": "/etc/resolv.conf"}],
+		"LocalCommands": [{"Command": ["echo", "OK"]}]
+	}`), 0600)
+	require.NoError(t, err)
+
+	body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/providers/reload", http.MethodPost, nil)
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusOK, code)
+	assertPackage.JSONEq(t, `{"http_endpoints": 8, "local_files": 2, "local_commands": 1, "collectors": 17}`, string(body))
+
+	assertPackage.Equal(t, map[string]FileProvider{
+		"etc_hosts":       {Location: "/etc/hosts"},
+		"etc_resolv.conf": {Location: "/etc/resolv.conf"},
+	}, job.getLogProviders().LocalFiles)
+	assertPackage.Equal(t, map[string]CommandProvider{
+		"echo_OK.output": {Command: []string{"echo", "OK"}},
+	}, job.getLogProviders().LocalCommands)
+
+	// invalid config must not replace the loaded one
+	err = ioutil.WriteFile(endpointsConfig, []byte(`not a JSON`), 0600)
+	require.NoError(t, err)
+
+	_, code, err = MakeHTTPRequest(t, router, "/system/health/v1/providers/reload", http.MethodPost, nil)
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusBadRequest, code)
+	assertPackage.Len(t, job.getLogProviders().LocalFiles, 2)
+	assertPackage.Len(t, job.getLogProviders().LocalCommands, 1)
+}
+
+func TestReloadProvidersHandlerReturns503WhenCollectorsAreNotInitialized(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	cfg := testCfg()
+	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{filepath.Join(workDir, "endpoints-config.json")}
+
+	// the job could not be initialized so the daemon has no client for collectors
+	job := &DiagnosticsJob{Cfg: cfg, DCOSTools: &fakeDCOSTools{}}
+	require.Error(t, job.Init())
+
+	bh, err := rest.NewBundleHandler(filepath.Join(workDir, "bundles"), nil, time.Minute, time.Second, nil)
+	require.NoError(t, err)
+
+	router := NewRouter(&Dt{
+		Cfg:              cfg,
+		DtDCOSTools:      job.DCOSTools,
+		DtDiagnosticsJob: job,
+		BundleHandler:    *bh,
+		MR:               &MonitoringResponse{},
+	})
+
+	body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/providers/reload", http.MethodPost, nil)
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusServiceUnavailable, code)
+	assertPackage.Contains(t, string(body), "collectors are not initialized")
+}
+
+func TestValidateProvidersHandlerReportsChangesWithoutApplyingThem(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	endpointsConfig := filepath.Join(workDir, "endpoints-config.json")
+	err = ioutil.WriteFile(endpointsConfig, []byte(`{
+		"HTTPEndpoints": [{"Port": 5050, "URI": "/flags", "FileName": "mesos-flags.json"}],
+		"LocalFiles": [{"Location": "/etc/hosts"}],
+		"LocalCommands": [{"Command": ["echo", "OK"]}]
+	}`), 0600)
+	require.NoError(t, err)
+
+	cfg := testCfg()
+	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{endpointsConfig}
+
+	job := &DiagnosticsJob{Cfg: cfg, DCOSTools: &fakeDCOSTools{}}
+	require.NoError(t, job.Init())
+
+	bh, err := rest.NewBundleHandler(filepath.Join(workDir, "bundles"), nil, time.Minute, time.Second, nil)
+	require.NoError(t, err)
+
+	router := NewRouter(&Dt{
+		Cfg:              cfg,
+		DtDCOSTools:      job.DCOSTools,
+		DtDiagnosticsJob: job,
+		BundleHandler:    *bh,
+		MR:               &MonitoringResponse{},
+		CollectorsClient: &http.Client{},
+	})
+	loaded := job.getLogProviders()
+
+	candidate := `{
+		"HTTPEndpoints": [{"Port": 5050, "URI": "/flags", "FileName": "mesos-flags.json", "Optional": true}],
+		"LocalFiles": [{"Location": "/etc/hosts"}, {"Location": "/etc/resolv.conf"}]
+	}`
+	body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/providers/reload/validate", http.MethodPost,
+		strings.NewReader(candidate))
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusOK, code)
+	assertPackage.JSONEq(t, `{
+		"valid": true,
+		"file": "`+endpointsConfig+`",
+		"http_endpoints": {"added": [], "removed": [], "modified": ["mesos-flags.json"]},
+		"local_files": {"added": ["etc_resolv.conf"], "removed": [], "modified": []},
+		"local_commands": {"added": [], "removed": ["echo_OK.output"], "modified": []},
+		"collectors": 17
+	}`, string(body))
+
+	// nothing is applied
+	assertPackage.Equal(t, loaded, job.getLogProviders())
+	config, err := ioutil.ReadFile(endpointsConfig)
+	require.NoError(t, err)
+	assertPackage.Contains(t, string(config), "echo")
+
+	body, code, err = MakeHTTPRequest(t, router, "/system/health/v1/providers/reload/validate", http.MethodPost,
+		strings.NewReader(`{"LocalFile": [], "LocalCommands": [{"Command": []}]}`))
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusBadRequest, code)
+	assertPackage.JSONEq(t, `{
+		"valid": false,
+		"file": "`+endpointsConfig+`",
+		"errors": ["could not parse candidate config: json: unknown field \"LocalFile\""]
+	}`, string(body))
+
+	body, code, err = MakeHTTPRequest(t, router, "/system/health/v1/providers/reload/validate", http.MethodPost,
+		strings.NewReader(`{"HTTPEndpoints": [{"URI": "/flags"}], "LocalCommands": [{"Command": []}]}`))
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusBadRequest, code)
+	var report configValidationReport
+	require.NoError(t, json.Unmarshal(body, &report))
+	assertPackage.Equal(t, []string{"HTTPEndpoints[0]: invalid Port 0", "LocalCommands[0]: Command is required"}, report.Errors)
+
+	_, code, err = MakeHTTPRequest(t, router, "/system/health/v1/providers/reload/validate?file=/etc/other.json",
+		http.MethodPost, strings.NewReader(candidate))
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusBadRequest, code)
+	assertPackage.Equal(t, loaded, job.getLogProviders())
+}
+
+// flushRecorder notifies about every flush so tests can control streamed responses
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func (r *flushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	r.flushed <- struct{}{}
+}
+
+func TestStreamUnitsLogsHandler(t *testing.T) {
+	journals := map[string]*io.PipeReader{}
+	writers := map[string]*io.PipeWriter{}
+	for _, unit := range []string{"unit_a", "unit_b"} {
+		journals[unit], writers[unit] = io.Pipe()
+	}
+	readJournalOutputSince = func(_ context.Context, unit string, since time.Duration) (io.ReadCloser, error) {
+		assertPackage.Equal(t, time.Hour, since)
+		return journals[unit], nil
+	}
+	defer func() { readJournalOutputSince = units.ReadJournalOutputSince }()
+
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	router := NewRouter(&Dt{Cfg: cfg, DtDCOSTools: &fakeDCOSTools{}, MR: &MonitoringResponse{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/system/health/v1/logs/stream?unit=unit_a&unit=unit_b&since=1h", nil)
+	rr := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(rr, req.WithContext(ctx))
+		close(done)
+	}()
+
+	for _, line := range []struct{ unit, message string }{
+		{"unit_a", "a1"},
+		{"unit_b", "b1"},
+		{"unit_a", "a2"},
+		{"unit_b", "b2"},
+	} {
+		_, err := io.WriteString(writers[line.unit], line.message+"\n")
+		require.NoError(t, err)
+		select {
+		case <-rr.flushed:
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not flushed", line.message)
+		}
+	}
+
+	// client disconnects
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return after client disconnected")
+	}
+
+	_, err := io.WriteString(writers["unit_a"], "a3\n")
+	assertPackage.Equal(t, io.ErrClosedPipe, err, "readers should be closed")
+
+	assertPackage.Equal(t, http.StatusOK, rr.Code)
+	assertPackage.Equal(t, "application/x-ndjson", rr.Header().Get("Content-type"))
+	assertPackage.Equal(t, `{"unit":"unit_a","message":"a1"}
+{"unit":"unit_b","message":"b1"}
+{"unit":"unit_a","message":"a2"}
+{"unit":"unit_b","message":"b2"}
+`, rr.Body.String())
+}
+
+func TestStreamUnitsLogsHandlerValidatesRequest(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	router := NewRouter(&Dt{Cfg: cfg, DtDCOSTools: &fakeDCOSTools{}, MR: &MonitoringResponse{}})
+
+	for url, expected := range map[string]int{
+		"/system/health/v1/logs/stream":                           http.StatusBadRequest,
+		"/system/health/v1/logs/stream?unit=unit_a&since=forever": http.StatusBadRequest,
+		"/system/health/v1/logs/stream?unit=sshd":                 http.StatusNotFound,
+	} {
+		_, code, err := MakeHTTPRequest(t, router, url, http.MethodGet, nil)
+		require.NoError(t, err)
+		assertPackage.Equal(t, expected, code, url)
+	}
+}
+
+func TestStreamUnitsLogsHandlerRefusesMoreUnitsThanJournalReaders(t *testing.T) {
+	units.SetMaxJournalReaders(1)
+	defer units.SetMaxJournalReaders(0)
+
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	router := NewRouter(&Dt{Cfg: cfg, DtDCOSTools: &fakeDCOSTools{}, MR: &MonitoringResponse{}})
+
+	body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/logs/stream?unit=unit_a&unit=unit_b", http.MethodGet, nil)
+	require.NoError(t, err)
+	assertPackage.Equal(t, http.StatusBadRequest, code)
+	assertPackage.Contains(t, string(body), "at most 1 units could be streamed at once")
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+// healthAnalysisFileName lists unhealthy units of the node with remediation hints
+const healthAnalysisFileName = "health-analysis.json"
+
+// unitHintPlaceholder in a hint is replaced with the ID of the unhealthy unit
+const unitHintPlaceholder = "{unit}"
+
+// remediationHint is attached to unhealthy units which health message matches the pattern
+type remediationHint struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Hint    string `json:"hint"`
+
+	re *regexp.Regexp
+}
+
+// defaultRemediationHints cover messages returned by CheckUnitHealth, they are used when no hints file is given
+var defaultRemediationHints = []remediationHint{
+	{
+		Name:    "flapping",
+		Pattern: `is flapping`,
+		Hint: "The unit was active but it keeps failing after restarts. Check the error it exits with " +
+			"in `journalctl -u {unit} -n 200` and recent changes of its configuration or dependencies.",
+	},
+	{
+		Name:    "never-active",
+		Pattern: "has never entered `active` state",
+		Hint: "The unit fails before its main process starts, most likely in ExecStartPre. " +
+			"Check the commands in `systemctl cat {unit}` and their output in `journalctl -u {unit}`.",
+	},
+	{
+		Name:    "not-loaded",
+		Pattern: `is not loaded`,
+		Hint: "The unit file is missing or invalid. Check `systemctl status {unit}` and " +
+			"whether the DC/OS installation of the node completed.",
+	},
+	{
+		Name:    "failed-exit-status",
+		Pattern: `ExecMainStatus return failed status`,
+		Hint:    "The main process of the unit exited with a non zero status. Check `journalctl -u {unit}` for the cause.",
+	},
+}
+
+// loadRemediationHints returns hints read from the JSON file or the default hints if the path is empty
+func loadRemediationHints(path string) ([]remediationHint, error) {
+	hints := defaultRemediationHints
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read remediation hints: %s", err)
+		}
+		hints = nil
+		if err := json.Unmarshal(data, &hints); err != nil {
+			return nil, fmt.Errorf("could not parse remediation hints %s: %s", path, err)
+		}
+	}
+
+	compiled := make([]remediationHint, 0, len(hints))
+	for _, h := range hints {
+		re, err := regexp.Compile(h.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of remediation hint %s: %s", h.Name, err)
+		}
+		h.re = re
+		compiled = append(compiled, h)
+	}
+	return compiled, nil
+}
+
+// unitHealthAnalysis describes the unhealthy unit and hints matching its health message
+type unitHealthAnalysis struct {
+	UnitID     string            `json:"unit_id"`
+	PrettyName string            `json:"pretty_name"`
+	Message    string            `json:"message"`
+	Hints      []matchedHintJSON `json:"hints"`
+}
+
+type matchedHintJSON struct {
+	Name string `json:"name"`
+	Hint string `json:"hint"`
+}
+
+// healthAnalysis is a struct implementing Collector interface. It collects units of the node that are
+// currently unhealthy along with remediation hints matching their health messages.
+type healthAnalysis struct {
+	name     string
+	optional bool
+	tools    dcos.Tooler
+	hints    []remediationHint
+}
+
+func newHealthAnalysis(name string, optional bool, tools dcos.Tooler, hints []remediationHint) *healthAnalysis {
+	return &healthAnalysis{
+		name:     name,
+		optional: optional,
+		tools:    tools,
+		hints:    hints,
+	}
+}
+
+func (c healthAnalysis) Name() string {
+	return c.name
+}
+
+func (c healthAnalysis) Optional() bool {
+	return c.optional
+}
+
+func (c healthAnalysis) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	units, err := (&SystemdUnits{}).GetUnits(c.tools)
+	if err != nil {
+		return nil, fmt.Errorf("could not get units: %s", err)
+	}
+
+	analysis := []unitHealthAnalysis{}
+	for _, unit := range units {
+		if unit.UnitHealth == dcos.Healthy {
+			continue
+		}
+		// unit output of unhealthy units is followed by the journal
+		message := strings.SplitN(unit.UnitOutput, "\n", 2)[0]
+		analysis = append(analysis, unitHealthAnalysis{
+			UnitID:     unit.UnitID,
+			PrettyName: unit.PrettyName,
+			Message:    message,
+			Hints:      c.match(unit.UnitID, message),
+		})
+	}
+
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal health analysis: %s", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c healthAnalysis) match(unitID string, message string) []matchedHintJSON {
+	matched := []matchedHintJSON{}
+	for _, h := range c.hints {
+		if h.re.MatchString(message) {
+			matched = append(matched, matchedHintJSON{
+				Name: h.Name,
+				Hint: strings.Replace(h.Hint, unitHintPlaceholder, unitID, -1),
+			})
+		}
+	}
+	return matched
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unitProperties(id, activeState, subState string, activeEnter, inactiveEnter uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":                              id,
+		"Description":                     "Fake: " + id,
+		"LoadState":                       "loaded",
+		"ActiveState":                     activeState,
+		"SubState":                        subState,
+		"ActiveEnterTimestampMonotonic":   activeEnter,
+		"InactiveEnterTimestampMonotonic": inactiveEnter,
+	}
+}
+
+func TestHealthAnalysisAttachesHintsToFailingUnits(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("InitializeUnitControllerConnection").Return(nil)
+	tools.On("CloseUnitControllerConnection").Return(nil)
+	tools.On("GetUnitNames").Return([]string{"healthy.service", "flapping.service", "never-active.service"}, nil)
+	tools.On("GetUnitProperties", "healthy.service").Return(unitProperties("healthy.service", "active", "running", 1, 0), nil)
+	tools.On("GetUnitProperties", "flapping.service").Return(unitProperties("flapping.service", "activating", "auto-restart", 1, 2), nil)
+	tools.On("GetUnitProperties", "never-active.service").Return(unitProperties("never-active.service", "activating", "auto-restart", 0, 2), nil)
+	tools.On("GetJournalOutput").Return("journal output", nil)
+
+	hints, err := loadRemediationHints("")
+	require.NoError(t, err)
+
+	c := newHealthAnalysis(healthAnalysisFileName, true, tools, hints)
+	r, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	defer r.Close()
+
+	var analysis []unitHealthAnalysis
+	require.NoError(t, json.NewDecoder(r).Decode(&analysis))
+
+	require.Len(t, analysis, 2)
+	assert.Equal(t, "flapping.service", analysis[0].UnitID)
+	assert.Equal(t, "Unit flapping.service is flapping. Please check `systemctl status flapping.service` to check current Unit state.", analysis[0].Message)
+	require.Len(t, analysis[0].Hints, 1)
+	assert.Equal(t, "flapping", analysis[0].Hints[0].Name)
+	assert.Contains(t, analysis[0].Hints[0].Hint, "journalctl -u flapping.service")
+
+	assert.Equal(t, "never-active.service", analysis[1].UnitID)
+	require.Len(t, analysis[1].Hints, 1)
+	assert.Equal(t, "never-active", analysis[1].Hints[0].Name)
+	assert.Contains(t, analysis[1].Hints[0].Hint, "systemctl cat never-active.service")
+}
+
+func TestLoadRemediationHintsFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hints")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hints.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"name": "custom", "pattern": "flapping$", "hint": "restart {unit}"}]`), 0600))
+
+	hints, err := loadRemediationHints(path)
+	require.NoError(t, err)
+	require.Len(t, hints, 1)
+
+	c := newHealthAnalysis(healthAnalysisFileName, true, nil, hints)
+	assert.Equal(t, []matchedHintJSON{{Name: "custom", Hint: "restart a.service"}}, c.match("a.service", "Unit a.service is flapping"))
+	assert.Empty(t, c.match("a.service", "Unit a.service is dead"))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"name": "invalid", "pattern": "(", "hint": ""}]`), 0600))
+	_, err = loadRemediationHints(path)
+	assert.EqualError(t, err, "invalid pattern of remediation hint invalid: error parsing regexp: missing closing ): `(`")
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// collectionHistoryFileName is a log of diagnostics jobs outcomes stored in the bundle directory
+const collectionHistoryFileName = "collection-history.log"
+
+// historyEntry summarizes the outcome of a single diagnostics job
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	ID       string    `json:"id"`
+	Nodes    int       `json:"nodes"`
+	Errors   int       `json:"errors"`
+	Duration string    `json:"duration"`
+	Status   string    `json:"status"`
+}
+
+// historyLog is an append-only log of diagnostics jobs with one JSON entry per line. When appending an entry
+// would make the log larger than maxSize it is compressed to <path>.1.gz and older logs are shifted
+// up to maxFiles. Zero maxSize disables the log.
+type historyLog struct {
+	sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+}
+
+func newHistoryLog(path string, maxSize int64, maxFiles int) *historyLog {
+	return &historyLog{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+}
+
+func (h *historyLog) append(entry historyEntry) error {
+	if h.maxSize <= 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not marshal history entry: %s", err)
+	}
+	line = append(line, '\n')
+
+	h.Lock()
+	defer h.Unlock()
+
+	if s, err := os.Stat(h.path); err == nil && s.Size() > 0 && s.Size()+int64(len(line)) > h.maxSize {
+		if err := h.rotate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", h.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("could not write to %s: %s", h.path, err)
+	}
+	return nil
+}
+
+// rotate shifts compressed logs removing the oldest one and compresses the current log
+func (h *historyLog) rotate() error {
+	if h.maxFiles <= 0 {
+		return os.Remove(h.path)
+	}
+
+	if err := os.Remove(h.rotatedPath(h.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove the oldest history: %s", err)
+	}
+	for i := h.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(h.rotatedPath(i), h.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rotate history: %s", err)
+		}
+	}
+
+	if err := gzipFile(h.path, h.rotatedPath(1)); err != nil {
+		return err
+	}
+	return os.Remove(h.path)
+}
+
+func (h *historyLog) rotatedPath(i int) string {
+	return fmt.Sprintf("%s.%d.gz", h.path, i)
+}
+
+// gzipFile writes compressed content of src to dst
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %s", dst, err)
+	}
+	defer out.Close()
+
+	w := gzip.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("could not compress %s: %s", src, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("could not compress %s: %s", src, err)
+	}
+	return out.Close()
+}
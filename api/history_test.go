@@ -0,0 +1,93 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryLogAppendsEntriesAndRotates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, collectionHistoryFileName)
+	entry := func(id string) historyEntry {
+		return historyEntry{
+			Time:     time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+			ID:       id,
+			Nodes:    3,
+			Errors:   1,
+			Duration: "1m0s",
+			Status:   "Diagnostics job failed",
+		}
+	}
+	line, err := json.Marshal(entry("bundle-0"))
+	require.NoError(t, err)
+
+	// two entries fit in the log
+	h := newHistoryLog(path, int64(2*(len(line)+1)), 2)
+
+	require.NoError(t, h.append(entry("bundle-0")))
+	require.NoError(t, h.append(entry("bundle-1")))
+	assert.Equal(t, []string{"bundle-0", "bundle-1"}, readHistoryIDs(t, path, false))
+	assert.NoFileExists(t, path+".1.gz")
+
+	require.NoError(t, h.append(entry("bundle-2")))
+	assert.Equal(t, []string{"bundle-2"}, readHistoryIDs(t, path, false))
+	assert.Equal(t, []string{"bundle-0", "bundle-1"}, readHistoryIDs(t, path+".1.gz", true))
+
+	for _, id := range []string{"bundle-3", "bundle-4", "bundle-5", "bundle-6"} {
+		require.NoError(t, h.append(entry(id)))
+	}
+	assert.Equal(t, []string{"bundle-6"}, readHistoryIDs(t, path, false))
+	assert.Equal(t, []string{"bundle-4", "bundle-5"}, readHistoryIDs(t, path+".1.gz", true))
+	assert.Equal(t, []string{"bundle-2", "bundle-3"}, readHistoryIDs(t, path+".2.gz", true))
+	assert.NoFileExists(t, path+".3.gz")
+}
+
+func TestHistoryLogIsDisabledWithZeroSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, collectionHistoryFileName)
+	require.NoError(t, newHistoryLog(path, 0, 5).append(historyEntry{ID: "bundle-0"}))
+	assert.NoFileExists(t, path)
+}
+
+func readHistoryIDs(t *testing.T, path string, compressed bool) []string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		defer gz.Close()
+		r = gz
+	}
+
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		var e historyEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		assert.Equal(t, 3, e.Nodes)
+		assert.Equal(t, 1, e.Errors)
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
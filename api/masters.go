@@ -0,0 +1,57 @@
+package api
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+// forEachMaster calls fn for every one of given masters. A leader election could make masters fail transiently or
+// change the master set while the operation is in progress, so when fn fails for some masters they are re-discovered
+// up to rediscoveries times: masters that failed and are still in the set are called again, masters that joined the set
+// are called for the first time and masters that left the set are dropped. Every master is called successfully at
+// most once. It returns errors of masters that still failed in the end.
+func forEachMaster(masters []dcos.Node, tools dcos.Tooler, rediscoveries int, fn func(master dcos.Node) error) []error {
+	done := make(map[string]bool, len(masters))
+	for attempt := 0; ; attempt++ {
+		var failed []string
+		var errs []error
+		for _, master := range masters {
+			if done[master.IP] {
+				continue
+			}
+			if err := fn(master); err != nil {
+				failed = append(failed, master.IP)
+				errs = append(errs, err)
+				continue
+			}
+			done[master.IP] = true
+		}
+
+		if len(failed) == 0 || attempt >= rediscoveries {
+			return errs
+		}
+
+		current, err := tools.GetMasterNodes()
+		if err != nil || len(current) == 0 {
+			logrus.WithError(err).Warn("Could not re-discover masters, keeping the previous master set")
+			current = masters
+		}
+		for _, ip := range failed {
+			if !containsNode(current, ip) {
+				logrus.WithField("IP", ip).Info("Master left the master set, it is no longer called")
+			}
+		}
+		logrus.WithField("failed", failed).WithField("attempt", attempt+1).Info("Masters re-discovered after failures")
+		masters = current
+	}
+}
+
+func containsNode(nodes []dcos.Node, ip string) bool {
+	for _, n := range nodes {
+		if n.IP == ip {
+			return true
+		}
+	}
+	return false
+}
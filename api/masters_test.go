@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+func listURL(ip string) string {
+	return fmt.Sprintf("http://%s:1050%s/report/diagnostics/list", ip, baseRoute)
+}
+
+func TestListAllBundlesFollowsMasterSetChange(t *testing.T) {
+	cfg := testCfg()
+	cfg.FlagMasterRediscoveries = 1
+
+	tools := new(MockedTools)
+	// 127.0.0.1 lost leadership and left the master set, 127.0.0.3 joined it during the election
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{Leader: true, IP: "127.0.0.1", Role: "master"},
+		{IP: "127.0.0.2", Role: "master"},
+	}, nil).Once()
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{IP: "127.0.0.2", Role: "master"},
+		{Leader: true, IP: "127.0.0.3", Role: "master"},
+	}, nil).Once()
+	tools.On("Get", listURL("127.0.0.1"), 3*time.Second).Return([]byte{}, 0, fmt.Errorf("connection refused")).Once()
+	tools.On("Get", listURL("127.0.0.2"), 3*time.Second).
+		Return([]byte(`[{"file_name":"bundle-2.zip","file_size":2}]`), http.StatusOK, nil).Once()
+	tools.On("Get", listURL("127.0.0.3"), 3*time.Second).
+		Return([]byte(`[{"file_name":"bundle-3.zip","file_size":3}]`), http.StatusOK, nil).Once()
+
+	bundles, err := listAllBundles(cfg, tools)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]bundle{
+		"127.0.0.2:1050": {{File: "bundle-2.zip", Size: 2}},
+		"127.0.0.3:1050": {{File: "bundle-3.zip", Size: 3}},
+	}, bundles)
+
+	tools.AssertExpectations(t)
+}
+
+func TestGetStatusAllRetriesMastersFailedDuringElection(t *testing.T) {
+	cfg := testCfg()
+	cfg.FlagMasterRediscoveries = 1
+
+	masters := []dcos.Node{
+		{IP: "127.0.0.1", Role: "master"},
+		{Leader: true, IP: "127.0.0.2", Role: "master"},
+	}
+	statusURL := fmt.Sprintf("http://127.0.0.2:1050%s/report/diagnostics/status", baseRoute)
+
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("127.0.0.1", nil)
+	tools.On("GetMasterNodes").Return(masters, nil).Twice()
+	tools.On("Get", statusURL, 3*time.Second).Return([]byte{}, http.StatusServiceUnavailable, nil).Once()
+	tools.On("Get", statusURL, 3*time.Second).Return([]byte(`{"is_running":true}`), http.StatusOK, nil).Once()
+
+	job := &DiagnosticsJob{Cfg: cfg, DCOSTools: tools}
+
+	status, err := job.getStatusAll()
+	require.NoError(t, err)
+	assert.Len(t, status, 2)
+	assert.True(t, status["127.0.0.2"].Running)
+
+	tools.AssertExpectations(t)
+}
+
+func TestForEachMasterGivesUpAfterRediscoveries(t *testing.T) {
+	masters := []dcos.Node{{IP: "127.0.0.1"}, {IP: "127.0.0.2"}}
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return(masters, nil).Twice()
+
+	calls := map[string]int{}
+	errs := forEachMaster(masters, tools, 2, func(master dcos.Node) error {
+		calls[master.IP]++
+		if master.IP == "127.0.0.2" {
+			return fmt.Errorf("%s is down", master.IP)
+		}
+		return nil
+	})
+
+	assert.Equal(t, []error{fmt.Errorf("127.0.0.2 is down")}, errs)
+	assert.Equal(t, map[string]int{"127.0.0.1": 1, "127.0.0.2": 3}, calls)
+	tools.AssertExpectations(t)
+}
@@ -1,11 +1,18 @@
 package api
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -23,6 +30,8 @@ type LogProviders struct {
 	HTTPEndpoints []HTTPProvider
 	LocalFiles    []FileProvider
 	LocalCommands []CommandProvider
+	// MetricsEndpoints are Prometheus endpoints scraped into bundles created with the local bundle API
+	MetricsEndpoints []MetricsProvider
 }
 
 // HTTPProvider is a provider for fetching an HTTP endpoint.
@@ -39,6 +48,21 @@ type FileProvider struct {
 	Location string
 	Role     []string
 	Optional bool
+	// Rotated is a number of the most recent rotated files (e.g., foo.log.1, foo.log.2.gz) to collect along with Location
+	Rotated int
+	// RotatedPattern is a glob matching rotated files in the Location directory, defaults to the file name followed by a dot
+	RotatedPattern string
+	// Decompress makes gzipped rotated files stored decompressed
+	Decompress bool
+}
+
+// MetricsProvider is a local Prometheus endpoint scraped into metrics/<Name>.prom. URI defaults to /metrics.
+// Scrapes are always optional so a missing exporter does not fail the bundle.
+type MetricsProvider struct {
+	Name string
+	Port int
+	URI  string
+	Role []string
 }
 
 // CommandProvider is a local command to execute.
@@ -46,14 +70,81 @@ type CommandProvider struct {
 	Command  []string
 	Role     []string
 	Optional bool
+	// Elevated commands run through the command-elevation-wrapper. Only commands from trusted endpoints
+	// config files could be elevated.
+	Elevated bool
+	// OS and Distros restrict the command to given operating systems (e.g., linux) and distribution IDs
+	// from os-release (e.g., centos, ubuntu). Empty lists do not restrict it.
+	OS      []string
+	Distros []string
+}
+
+// platforms returns predicates of platforms the command applies to
+func (p CommandProvider) platforms() []collector.PlatformPredicate {
+	var predicates []collector.PlatformPredicate
+	if len(p.OS) > 0 {
+		predicates = append(predicates, collector.OnlyOS(p.OS...))
+	}
+	if len(p.Distros) > 0 {
+		predicates = append(predicates, collector.OnlyDistros(p.Distros...))
+	}
+	return predicates
 }
 
 const (
 	GoosWindows = "windows"
 
 	GoosDarwin = "darwin"
+
+	GoosLinux = "linux"
 )
 
+const (
+	mesosAttributesVariable  = "MESOS_ATTRIBUTES"
+	acceleratorsFileName     = "accelerators/nvidia.xml"
+	timeSyncFileName         = "time/sync-status.txt"
+	journalHealthFileName    = "systemd/journal-health.txt"
+	healthProbeFileName      = "custom/health-probe.txt"
+	tlsCertsFileName         = "tls/certs.json"
+	networkCaptureFileName   = "network/capture.pcap"
+	interfacesFileName       = "network/interfaces.json"
+	routesFileName           = "routes.json"
+	environmentFileName      = "environment.json"
+	processesFileName        = "processes.json"
+	selfGoroutinesFileName   = "self/goroutines.txt"
+	selfHeapFileName         = "self/heap.pprof"
+	sandboxesFileName        = "mesos/sandboxes.json"
+	containerEventsFileName  = "containers/events.json"
+	containerStorageFileName = "containers/storage.json"
+	kubeletUnitName          = "kubelet.service"
+	kubernetesDir            = "kubernetes"
+	selfUnitName             = "dcos-diagnostics.service"
+	selfLogsFileName         = "dcos-diagnostics-self.log"
+	metricsDir               = "metrics"
+	storageDir               = "storage"
+	metricsRoute             = "/metrics"
+	versionsRoute            = "/dcos-metadata/dcos-version.json"
+
+	// sandboxesMaxDepth reaches run directories: slaves/<id>/frameworks/<id>/executors/<id>/runs/<id>
+	sandboxesMaxDepth   = 8
+	sandboxesMaxEntries = 10000
+
+	// containerStorageMaxEntries bounds the number of mounts and containers reported by the container storage
+	containerStorageMaxEntries = 1000
+)
+
+// gpuAttributes are Mesos agent attributes advertising that the node has GPUs
+var gpuAttributes = []string{"gpu", "gpus"}
+
+// detectPlatform returns the platform collectors are filtered by
+var detectPlatform = collector.DetectPlatform
+
+// systemdUnitDirs are directories searched for unit files of components that are not part of DC/OS
+var systemdUnitDirs = []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"}
+
+// containerRuntimeUnits are units of container runtimes used by kubelet in order of preference
+var containerRuntimeUnits = []string{"containerd.service", "crio.service", "docker.service"}
+
 func loadProviders(cfg *config.Config, DCOSTools dcos.Tooler) (*LogProviders, error) {
 	// load the internal providers
 	internalProviders, err := loadInternalProviders(cfg, DCOSTools)
@@ -86,6 +177,7 @@ func loadExternalProviders(endpointsConfgFiles []string) (externalProviders LogP
 		externalProviders.HTTPEndpoints = append(externalProviders.HTTPEndpoints, logProviders.HTTPEndpoints...)
 		externalProviders.LocalFiles = append(externalProviders.LocalFiles, logProviders.LocalFiles...)
 		externalProviders.LocalCommands = append(externalProviders.LocalCommands, logProviders.LocalCommands...)
+		externalProviders.MetricsEndpoints = append(externalProviders.MetricsEndpoints, logProviders.MetricsEndpoints...)
 	}
 
 	return externalProviders, nil
@@ -102,7 +194,10 @@ func loadSystemdCollectors(cfg *config.Config, DCOSTools dcos.Tooler) ([]collect
 		return nil, fmt.Errorf("could not get unit names: %s", err)
 	}
 
-	units = append(units, cfg.SystemdUnits...)
+	units, err = filterDeniedUnits(append(units, cfg.SystemdUnits...), cfg.FlagDiagnosticsBundleUnitsDenylist)
+	if err != nil {
+		return nil, err
+	}
 	collectors := make([]collector.Collector, 0, len(units))
 
 	duration, err := time.ParseDuration(cfg.FlagDiagnosticsBundleUnitsLogsSinceString)
@@ -110,16 +205,76 @@ func loadSystemdCollectors(cfg *config.Config, DCOSTools dcos.Tooler) ([]collect
 		return nil, fmt.Errorf("error parsing '%s': %s", cfg.FlagDiagnosticsBundleUnitsLogsSinceString, err)
 	}
 
+	fallbackFiles, err := collector.ParseJournalFallbackFiles(cfg.FlagJournalFallbackFiles)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, unit := range units {
 		collectors = append(
 			collectors,
-			collector.NewSystemd(unit, false, unit, duration),
+			collector.NewSystemd(unit, false, unit, duration).WithFallbackFile(fallbackFiles[unit]),
 		)
 	}
 
+	selfDenied, err := isUnitDenied(selfUnitName, cfg.FlagDiagnosticsBundleUnitsDenylist)
+	if err != nil {
+		return nil, err
+	}
+	// a unit collected explicitly already contains own logs
+	if !selfDenied && !containsUnit(units, selfUnitName) {
+		// own logs are collected last so they contain logs of the bundle collection
+		selfLogs := collector.NewSystemd(selfLogsFileName, true, selfUnitName, duration).WithFallbackFile(fallbackFiles[selfUnitName])
+		collectors = append(collectors, collector.WithPriority(selfLogs, collector.PriorityLow))
+	}
+
 	return collectors, nil
 }
 
+func containsUnit(units []string, name string) bool {
+	for _, unit := range units {
+		if unit == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDeniedUnits returns units not matching any of the denylist glob patterns
+func filterDeniedUnits(units []string, denylist []string) ([]string, error) {
+	if len(denylist) == 0 {
+		return units, nil
+	}
+
+	allowed := make([]string, 0, len(units))
+	for _, unit := range units {
+		denied, err := isUnitDenied(unit, denylist)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			logrus.WithField("unit", unit).Debug("Unit is denylisted, its logs will not be collected")
+			continue
+		}
+		allowed = append(allowed, unit)
+	}
+	return allowed, nil
+}
+
+// isUnitDenied returns true if the unit name matches any of the denylist glob patterns
+func isUnitDenied(unit string, denylist []string) (bool, error) {
+	for _, pattern := range denylist {
+		matched, err := path.Match(pattern, unit)
+		if err != nil {
+			return false, fmt.Errorf("invalid units denylist pattern %s: %s", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func loadInternalProviders(cfg *config.Config, DCOSTools dcos.Tooler) (internalConfigProviders LogProviders, err error) {
 	units, err := DCOSTools.GetUnitNames()
 	if err != nil {
@@ -136,9 +291,14 @@ func loadInternalProviders(cfg *config.Config, DCOSTools dcos.Tooler) (internalC
 		return internalConfigProviders, err
 	}
 
+	units, err = filterDeniedUnits(append(units, cfg.SystemdUnits...), cfg.FlagDiagnosticsBundleUnitsDenylist)
+	if err != nil {
+		return internalConfigProviders, err
+	}
+
 	// load default HTTP
 	var httpEndpoints []HTTPProvider
-	for _, unit := range append(units, cfg.SystemdUnits...) {
+	for _, unit := range units {
 		httpEndpoints = append(httpEndpoints, HTTPProvider{
 			Port:     port,
 			URI:      fmt.Sprintf("%s/logs/units/%s", baseRoute, unit),
@@ -158,6 +318,50 @@ func loadInternalProviders(cfg *config.Config, DCOSTools dcos.Tooler) (internalC
 	}, nil
 }
 
+// ValidateCollectorsHostname checks if the host name used to build URLs of local endpoints
+// resolves to an address of this node. Otherwise all endpoint collectors would fail.
+func ValidateCollectorsHostname(cfg *config.Config) error {
+	host := cfg.GetCollectorsHostname()
+	if host == "" {
+		return errors.New("host name is empty, set --hostname or --collectors-hostname")
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("could not resolve host name %s, set --collectors-hostname to a local address: %s", host, err)
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return fmt.Errorf("could not get local addresses: %s", err)
+	}
+
+	for _, ip := range ips {
+		if ip.IsUnspecified() {
+			return fmt.Errorf("host name %s resolves to unspecified address %s, set --collectors-hostname to a local address", host, ip)
+		}
+		if ip.IsLoopback() {
+			return nil
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("host name %s resolves to %v which is not an address of this node, set --collectors-hostname to a local address", host, ips)
+}
+
+// symlinkPolicy returns how collected files behind symbolic links are handled
+func symlinkPolicy(cfg *config.Config) (collector.SymlinkPolicy, error) {
+	mode, err := collector.ParseSymlinkMode(cfg.FlagFileSymlinks)
+	if err != nil {
+		return collector.SymlinkPolicy{}, err
+	}
+	return collector.SymlinkPolicy{Mode: mode, Roots: cfg.FlagFileSymlinksRoots}, nil
+}
+
 func LoadCollectors(cfg *config.Config, tools dcos.Tooler, client *http.Client) ([]collector.Collector, error) {
 
 	collectors, err := loadSystemdCollectors(cfg, tools)
@@ -165,6 +369,16 @@ func LoadCollectors(cfg *config.Config, tools dcos.Tooler, client *http.Client)
 		return nil, fmt.Errorf("could load systemd collectors: %s", err)
 	}
 
+	symlinks, err := symlinkPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize file collectors: %s", err)
+	}
+
+	allowlist, err := collector.ParseEndpointAllowlist(cfg.FlagEndpointAllowlist)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize endpoint collectors: %s", err)
+	}
+
 	// load the external providers from a cfg file
 	providers, err := loadExternalProviders(cfg.FlagDiagnosticsBundleEndpointsConfigFiles)
 	if err != nil {
@@ -199,24 +413,54 @@ func LoadCollectors(cfg *config.Config, tools dcos.Tooler, client *http.Client)
 			fileName = endpoint.FileName
 		}
 
-		url, err := util.UseTLSScheme(fmt.Sprintf("http://%s:%d%s", cfg.FlagHostname, endpoint.Port, endpoint.URI), cfg.FlagForceTLS)
+		url, err := util.UseTLSScheme(fmt.Sprintf("http://%s:%d%s", cfg.GetCollectorsHostname(), endpoint.Port, endpoint.URI), cfg.FlagForceTLS)
 		if err != nil {
 			return nil, fmt.Errorf("could not initialize internal log providers: %s", err)
 
 		}
 
-		c := collector.NewEndpoint(fileName, endpoint.Optional, url, client)
+		endpointCollector, err := collector.NewEndpoint(fileName, endpoint.Optional, url, client, allowlist)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialize internal log providers: %s", err)
+		}
+		if cfg.FlagEndpointSchemeFallback {
+			endpointCollector = endpointCollector.WithSchemeFallback()
+		}
+		var c collector.Collector = endpointCollector
+		// health report and versions are needed to read the rest of the bundle
+		if endpoint.URI == baseRoute || endpoint.URI == versionsRoute {
+			c = collector.WithPriority(c, collector.PriorityHigh)
+		}
 		collectors = append(collectors, c)
 	}
 
+	// scrape own metrics along with the configured exporters
+	providers.MetricsEndpoints = append(providers.MetricsEndpoints, MetricsProvider{
+		Name: "dcos-diagnostics",
+		Port: port,
+		URI:  metricsRoute,
+	})
+	metricsCollectors, err := loadMetricsCollectors(cfg, role, providers.MetricsEndpoints, client, allowlist)
+	if err != nil {
+		return nil, err
+	}
+	collectors = append(collectors, metricsCollectors...)
+
 	for _, fileProvider := range providers.LocalFiles {
 		if !roleMatched(role, fileProvider.Role) {
 			continue
 		}
 
 		key := strings.TrimLeft(fileProvider.Location, "/")
-		c := collector.NewFile(key, fileProvider.Optional, fileProvider.Location)
+		c := collector.NewFile(key, fileProvider.Optional, fileProvider.Location).WithSymlinks(symlinks)
 		collectors = append(collectors, c)
+
+		// rotated files might not exist yet so they are always optional
+		for i := 0; i < fileProvider.Rotated; i++ {
+			rotatedKey := fmt.Sprintf("%s.%d", key, i+1)
+			collectors = append(collectors, collector.NewRotated(rotatedKey, true, fileProvider.Location,
+				fileProvider.RotatedPattern, i, fileProvider.Decompress).WithSymlinks(symlinks))
+		}
 	}
 
 	// sanitize command to use as filename
@@ -229,9 +473,288 @@ func LoadCollectors(cfg *config.Config, tools dcos.Tooler, client *http.Client)
 		trimmedCmdWithArgs := strings.Replace(cmdWithArgs, "/", "", -1)
 		key := fmt.Sprintf("%s.output", trimmedCmdWithArgs)
 		c := collector.NewCmd(key, commandProvider.Optional, commandProvider.Command)
+		if commandProvider.Elevated {
+			c = c.WithElevation(strings.Fields(cfg.FlagCommandElevationWrapper), time.Duration(cfg.FlagCommandExecTimeoutSec)*time.Second)
+		}
+		collectors = append(collectors, collector.WithPlatforms(c, commandProvider.platforms()...))
+
+	}
+
+	// time synchronization daemons are checked only on Linux
+	timeout := time.Duration(cfg.FlagCommandExecTimeoutSec) * time.Second
+	collectors = append(collectors, collector.WithPlatforms(
+		collector.NewTimeSync(timeSyncFileName, true, timeout, collector.TimeSyncCommands), collector.OnlyOS(GoosLinux)))
+
+	// journald limits explain why logs of units might be shorter than requested
+	if cfg.FlagJournalHealth {
+		collectors = append(collectors, collector.WithPlatforms(
+			collector.NewJournalHealth(journalHealthFileName, true, timeout), collector.OnlyOS(GoosLinux)))
+	}
+
+	collectors = append(collectors, newSourceCollector(sourceFileName, true, tools))
+	collectors = append(collectors, collector.NewEnvironment(environmentFileName, true))
+	collectors = append(collectors, collector.NewProvenance(collector.ProvenanceFileName, true,
+		collector.DefaultClusterIDPath, collector.DefaultVersionPath))
+
+	if cfg.FlagProcessesTop > 0 {
+		collectors = append(collectors, collector.NewProcesses(processesFileName, true, cfg.FlagProcessesTop))
+	}
+
+	if cfg.FlagStorageInfo {
+		storageCollectors, err := loadStorageCollectors(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not load storage collectors: %s", err)
+		}
+		collectors = append(collectors, storageCollectors...)
+	}
+
+	if cfg.FlagHealthAnalysis {
+		hints, err := loadRemediationHints(cfg.FlagHealthHintsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load health analysis: %s", err)
+		}
+		collectors = append(collectors, newHealthAnalysis(healthAnalysisFileName, true, tools, hints))
+	}
+
+	if cfg.FlagHealthProbeScript != "" {
+		if err := collector.CheckHealthProbeScript(cfg.FlagHealthProbeScript); err != nil {
+			return nil, fmt.Errorf("could not load health probe: %s", err)
+		}
+		collectors = append(collectors, collector.NewHealthProbe(healthProbeFileName, true, cfg.FlagHealthProbeScript,
+			time.Duration(cfg.FlagHealthProbeTimeoutSec)*time.Second, int64(cfg.FlagHealthProbeMaxSizeKB)*1024))
+	}
+
+	if len(cfg.FlagTLSCertFiles) > 0 {
+		collectors = append(collectors, collector.NewCertificates(tlsCertsFileName, true, cfg.FlagTLSCertFiles))
+	}
+
+	if cfg.FlagBundleRoutes {
+		collectors = append(collectors, newRoutesCollector(routesFileName, true, cfg))
+	}
+
+	// packet captures are opt-in and tcpdump is run only on Linux
+	if cfg.FlagNetworkCapture && runtime.GOOS == GoosLinux {
+		capture, err := collector.NewPacketCapture(networkCaptureFileName, cfg.FlagNetworkCaptureInterface,
+			cfg.FlagNetworkCaptureFilter, collector.CaptureLimits{
+				Duration: time.Duration(cfg.FlagNetworkCaptureDurationSec) * time.Second,
+				Packets:  cfg.FlagNetworkCapturePackets,
+				SnapLen:  cfg.FlagNetworkCaptureSnapLen,
+				Size:     int64(cfg.FlagNetworkCaptureMaxSizeMB) * 1024 * 1024,
+			})
+		if err != nil {
+			return nil, fmt.Errorf("could not load network capture: %s", err)
+		}
+		collectors = append(collectors, capture)
+	}
+
+	if cfg.FlagNetworkInterfaces {
+		collectors = append(collectors, collector.WithPlatforms(
+			collector.NewNetworkInterfaces(interfacesFileName, true, cfg.FlagNetworkInterfacesEthtool,
+				cfg.FlagNetworkInterfacesMax), collector.OnlyOS(GoosLinux)))
+	}
+
+	if cfg.FlagSelfDiagnostics {
+		collectors = append(collectors,
+			collector.NewGoroutines(selfGoroutinesFileName, true),
+			collector.NewHeapProfile(selfHeapFileName, true))
+	}
+
+	// Kubernetes components run with systemd so they are collected only on Linux
+	if cfg.FlagKubernetesLogs && runtime.GOOS != GoosWindows && runtime.GOOS != GoosDarwin {
+		kubernetesCollectors, err := loadKubernetesCollectors(cfg, systemdUnitDirs)
+		if err != nil {
+			return nil, fmt.Errorf("could not load kubernetes collectors: %s", err)
+		}
+		collectors = append(collectors, kubernetesCollectors...)
+	}
+
+	if role == dcos.AgentRole || role == dcos.AgentPublicRole {
+		if cfg.FlagMesosWorkDir != "" {
+			collectors = append(collectors, collector.NewSandboxes(sandboxesFileName, true, cfg.FlagMesosWorkDir,
+				sandboxesMaxDepth, sandboxesMaxEntries))
+		}
+		collectors = append(collectors, collector.WithPlatforms(
+			collector.NewContainerStorage(containerStorageFileName, true, containerStorageMaxEntries),
+			collector.OnlyOS(GoosLinux)))
+		if c := loadAcceleratorCollector(cfg); c != nil {
+			collectors = append(collectors, c)
+		}
+		if cfg.FlagContainerEventsPeriodMinutes > 0 {
+			collectors = append(collectors, collector.NewContainerEvents(containerEventsFileName, true,
+				time.Duration(cfg.FlagContainerEventsPeriodMinutes)*time.Minute, cfg.FlagContainerEventsMax))
+		}
+	}
+
+	return collector.FilterByPlatform(collectors, detectPlatform()), nil
+}
+
+// loadKubernetesCollectors returns optional collectors of kubelet and container runtime logs
+// if kubelet unit is installed on the node, otherwise nil is returned.
+// loadMetricsCollectors returns collectors storing Prometheus text format responses of metrics endpoints
+// as metrics/<name>.prom
+func loadMetricsCollectors(cfg *config.Config, role string, endpoints []MetricsProvider, client *http.Client,
+	allowlist collector.EndpointAllowlist) ([]collector.Collector, error) {
+	var collectors []collector.Collector
+	for _, endpoint := range endpoints {
+		if !roleMatched(role, endpoint.Role) {
+			continue
+		}
+
+		uri := endpoint.URI
+		if uri == "" {
+			uri = metricsRoute
+		}
+		name := endpoint.Name
+		if name == "" {
+			name = fmt.Sprintf("%d-%s", endpoint.Port, util.SanitizeString(uri))
+		}
+
+		url, err := util.UseTLSScheme(fmt.Sprintf("http://%s:%d%s", cfg.GetCollectorsHostname(), endpoint.Port, uri), cfg.FlagForceTLS)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialize metrics collector %s: %s", name, err)
+		}
+
+		fileName := path.Join(metricsDir, util.SanitizeString(name)+".prom")
+		c, err := collector.NewEndpoint(fileName, true, url, client, allowlist)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialize metrics collector %s: %s", name, err)
+		}
+		if cfg.FlagEndpointSchemeFallback {
+			c = c.WithSchemeFallback()
+		}
 		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
 
+func loadKubernetesCollectors(cfg *config.Config, unitDirs []string) ([]collector.Collector, error) {
+	if !unitInstalled(kubeletUnitName, unitDirs) {
+		logrus.Debug("Kubelet is not installed, Kubernetes logs will not be collected")
+		return nil, nil
 	}
 
+	duration, err := time.ParseDuration(cfg.FlagDiagnosticsBundleUnitsLogsSinceString)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing '%s': %s", cfg.FlagDiagnosticsBundleUnitsLogsSinceString, err)
+	}
+
+	fallbackFiles, err := collector.ParseJournalFallbackFiles(cfg.FlagJournalFallbackFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	units := []string{kubeletUnitName}
+	for _, unit := range containerRuntimeUnits {
+		if unitInstalled(unit, unitDirs) {
+			units = append(units, unit)
+			break
+		}
+	}
+	units, err = filterDeniedUnits(units, cfg.FlagDiagnosticsBundleUnitsDenylist)
+	if err != nil {
+		return nil, err
+	}
+
+	collectors := make([]collector.Collector, 0, len(units))
+	for _, unit := range units {
+		name := path.Join(kubernetesDir, strings.TrimSuffix(unit, ".service")+".log")
+		collectors = append(collectors, collector.NewSystemd(name, true, unit, duration).WithFallbackFile(fallbackFiles[unit]))
+	}
+	return collectors, nil
+}
+
+// unitInstalled returns true if the unit file exists in any of given directories
+func unitInstalled(unit string, unitDirs []string) bool {
+	for _, dir := range unitDirs {
+		if _, err := os.Stat(filepath.Join(dir, unit)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadStorageCollectors returns optional collectors of configured storage commands stored as storage/<name>.txt
+func loadStorageCollectors(cfg *config.Config) ([]collector.Collector, error) {
+	commands, err := collector.ParseStorageCommands(cfg.FlagStorageCommands)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timeout := time.Duration(cfg.FlagCommandExecTimeoutSec) * time.Second
+	collectors := make([]collector.Collector, 0, len(commands))
+	for _, name := range names {
+		fileName := path.Join(storageDir, util.SanitizeString(name)+".txt")
+		// storage tools are available only on Linux
+		collectors = append(collectors, collector.WithPlatforms(collector.NewStorage(fileName, commands[name], timeout),
+			collector.OnlyOS(GoosLinux)))
+	}
 	return collectors, nil
 }
+
+// loadAcceleratorCollector returns an optional collector for accelerator diagnostics
+// if the node advertises GPU attributes, otherwise nil is returned.
+func loadAcceleratorCollector(cfg *config.Config) collector.Collector {
+	command := strings.Fields(cfg.FlagAcceleratorInfoCommand)
+	if len(command) == 0 {
+		return nil
+	}
+
+	attributes, err := readMesosAttributes(cfg.FlagMesosAttributesFile)
+	if err != nil {
+		logrus.WithError(err).Warn("Could not read node attributes, accelerators will not be collected")
+		return nil
+	}
+
+	for _, name := range gpuAttributes {
+		value, ok := attributes[name]
+		if ok && value != "false" && value != "0" {
+			return collector.NewCmd(acceleratorsFileName, true, command)
+		}
+	}
+	return nil
+}
+
+// readMesosAttributes returns Mesos agent attributes from the environment file used to start the agent.
+// Attributes are expected in MESOS_ATTRIBUTES variable in format name:value;name:value.
+// Missing file means there are no attributes.
+func readMesosAttributes(path string) (map[string]string, error) {
+	attributes := make(map[string]string)
+	if path == "" {
+		return attributes, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return attributes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, mesosAttributesVariable+"=") {
+			continue
+		}
+		value := strings.Trim(strings.TrimPrefix(line, mesosAttributesVariable+"="), `"'`)
+		for _, attribute := range strings.Split(value, ";") {
+			kv := strings.SplitN(attribute, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			attributes[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", path, err)
+	}
+
+	return attributes, nil
+}
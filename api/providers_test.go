@@ -1,13 +1,27 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dcos/dcos-diagnostics/collector"
 )
 
 func TestLoadCollectors(t *testing.T) {
@@ -21,6 +35,7 @@ func TestLoadCollectors(t *testing.T) {
 	cfg := testCfg()
 	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{
 		filepath.Join("testdata", "endpoint-config.json"),
+		filepath.Join("testdata", "endpoint-config-versions.json"),
 	}
 
 	got, err := LoadCollectors(cfg, tools, http.DefaultClient)
@@ -28,16 +43,18 @@ func TestLoadCollectors(t *testing.T) {
 	assert.NoError(t, err)
 
 	if runtime.GOOS != GoosWindows && runtime.GOOS != GoosDarwin {
-		assert.Len(t, got, 15)
+		assert.Len(t, got, 22)
 	} else {
-		assert.Len(t, got, 14)
+		assert.Len(t, got, 19)
 	}
 	expected := []string{
 		"5050-master_state-summary.json",
 		"5050-registrar_1__registry.json",
 		"uri_not_avail.txt",
 		"5050-system_stats_json.json",
+		"80-dcos-metadata_dcos-version_json.json",
 		"dcos-diagnostics-health.json",
+		"metrics/dcos-diagnostics.prom",
 		"var/lib/dcos/exhibitor/zookeeper/snapshot/myid",
 		"var/lib/dcos/exhibitor/conf/zoo.cfg",
 		"not/existing/file",
@@ -49,10 +66,142 @@ func TestLoadCollectors(t *testing.T) {
 		"does_not_exist.output",
 	}
 	if runtime.GOOS != GoosWindows && runtime.GOOS != GoosDarwin {
-		expected = append([]string{"dcos-diagnostics"}, expected...)
+		expected = append([]string{"dcos-diagnostics", "dcos-diagnostics-self.log"}, expected...)
+		expected = append(expected, "time/sync-status.txt")
 	}
+	expected = append(expected, "source.json", "environment.json", "provenance.json")
 	for i, c := range got {
 		assert.Equal(t, expected[i], c.Name())
+		switch c.Name() {
+		case "dcos-diagnostics-health.json", "80-dcos-metadata_dcos-version_json.json":
+			assert.Equal(t, collector.PriorityHigh, collector.Priority(c))
+		case "dcos-diagnostics-self.log":
+			assert.Equal(t, collector.PriorityLow, collector.Priority(c))
+			assert.True(t, c.Optional())
+		default:
+			assert.Equal(t, collector.PriorityDefault, collector.Priority(c), c.Name())
+		}
+	}
+}
+
+func TestLoadCollectors_SandboxesListedOnlyOnAgents(t *testing.T) {
+	for role, expected := range map[string]bool{"agent": true, "agent_public": true, "master": false} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return(role, nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagMesosWorkDir = "/var/lib/mesos/slave"
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if expected {
+			assert.Contains(t, names, "mesos/sandboxes.json", role)
+		} else {
+			assert.NotContains(t, names, "mesos/sandboxes.json", role)
+		}
+	}
+}
+
+func TestLoadCollectors_SelfDiagnosticsOnlyWhenEnabled(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("master", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagSelfDiagnostics = enabled
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if enabled {
+			assert.Contains(t, names, "self/goroutines.txt")
+			assert.Contains(t, names, "self/heap.pprof")
+		} else {
+			assert.NotContains(t, names, "self/goroutines.txt")
+			assert.NotContains(t, names, "self/heap.pprof")
+		}
+	}
+}
+
+func TestLoadKubernetesCollectors(t *testing.T) {
+	unitDir, err := ioutil.TempDir("", "systemd-units")
+	require.NoError(t, err)
+	defer os.RemoveAll(unitDir)
+
+	cfg := testCfg()
+
+	got, err := loadKubernetesCollectors(cfg, []string{unitDir})
+	require.NoError(t, err)
+	assert.Empty(t, got, "kubelet is not installed")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(unitDir, "kubelet.service"), nil, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(unitDir, "docker.service"), nil, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(unitDir, "containerd.service"), nil, 0600))
+
+	got, err = loadKubernetesCollectors(cfg, []string{"/not/existing", unitDir})
+	require.NoError(t, err)
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name())
+		assert.True(t, c.Optional())
+		assert.IsType(t, &collector.Systemd{}, c)
+	}
+	assert.Equal(t, []string{"kubernetes/kubelet.log", "kubernetes/containerd.log"}, names)
+
+	cfg.FlagDiagnosticsBundleUnitsDenylist = []string{"containerd*"}
+	got, err = loadKubernetesCollectors(cfg, []string{unitDir})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "kubernetes/kubelet.log", got[0].Name())
+}
+
+func TestLoadCollectors_KubernetesCollectorsOnlyWhenEnabled(t *testing.T) {
+	if runtime.GOOS == GoosWindows || runtime.GOOS == GoosDarwin {
+		t.Skip("Kubernetes logs are collected only on Linux")
+	}
+	unitDir, err := ioutil.TempDir("", "systemd-units")
+	require.NoError(t, err)
+	defer os.RemoveAll(unitDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(unitDir, "kubelet.service"), nil, 0600))
+
+	defaultUnitDirs := systemdUnitDirs
+	systemdUnitDirs = []string{unitDir}
+	defer func() { systemdUnitDirs = defaultUnitDirs }()
+
+	for _, enabled := range []bool{false, true} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("agent", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagKubernetesLogs = enabled
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if enabled {
+			assert.Contains(t, names, "kubernetes/kubelet.log")
+		} else {
+			assert.NotContains(t, names, "kubernetes/kubelet.log")
+		}
 	}
 }
 
@@ -95,6 +244,59 @@ func TestLoadCollectors_GetUnitNamesErrors(t *testing.T) {
 	assert.Empty(t, got)
 }
 
+func TestLoadSystemdCollectors_DenylistedUnitsAreNotCollected(t *testing.T) {
+	if runtime.GOOS == GoosWindows || runtime.GOOS == GoosDarwin {
+		t.Skip("skipping test; GetUnitNames is not called on Windows.")
+	}
+
+	t.Parallel()
+	tools := new(MockedTools)
+
+	tools.On("GetUnitNames").Return([]string{"dcos-mesos-master.service", "dcos-secrets.service", "dcos-vault.service"}, nil)
+
+	cfg := testCfg()
+	cfg.SystemdUnits = []string{"dcos-noisy.service"}
+	cfg.FlagDiagnosticsBundleUnitsDenylist = []string{"dcos-vault.service", "dcos-secret*", "*noisy*"}
+
+	got, err := loadSystemdCollectors(cfg, tools)
+	require.NoError(t, err)
+
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name())
+	}
+	assert.Equal(t, []string{"dcos-mesos-master.service", "dcos-diagnostics-self.log"}, names)
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleUnitsDenylist = []string{"dcos-["}
+
+		got, err := loadSystemdCollectors(cfg, tools)
+		assert.EqualError(t, err, "invalid units denylist pattern dcos-[: syntax error in pattern")
+		assert.Empty(t, got)
+	})
+}
+
+func TestLoadSystemdCollectors_SelfLogsAreNotDuplicated(t *testing.T) {
+	if runtime.GOOS == GoosWindows || runtime.GOOS == GoosDarwin {
+		t.Skip("skipping test; GetUnitNames is not called on Windows.")
+	}
+
+	t.Parallel()
+	tools := new(MockedTools)
+
+	tools.On("GetUnitNames").Return([]string{"dcos-mesos-master.service", "dcos-diagnostics.service"}, nil)
+
+	got, err := loadSystemdCollectors(testCfg(), tools)
+	require.NoError(t, err)
+
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name())
+	}
+	assert.Equal(t, []string{"dcos-mesos-master.service", "dcos-diagnostics.service"}, names)
+}
+
 func TestLoadCollectors_GetNodeRoleReturnsInvalidRole(t *testing.T) {
 	t.Parallel()
 	tools := new(MockedTools)
@@ -112,3 +314,527 @@ func TestLoadCollectors_GetNodeRoleReturnsInvalidRole(t *testing.T) {
 	assert.EqualError(t, err, "incorrect role invalid, must be: master, agent or agent_public")
 	assert.Empty(t, got)
 }
+
+func TestLoadCollectors_AcceleratorsCollectedOnlyOnGPUAgents(t *testing.T) {
+	if runtime.GOOS == GoosWindows {
+		t.Skip("skipping test; echo is not a binary on Windows.")
+	}
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "mesos-attributes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		role       string
+		attributes string
+		expected   bool
+	}{
+		{role: "agent", attributes: "MESOS_ATTRIBUTES=gpu:true;zone:a\n", expected: true},
+		{role: "agent_public", attributes: "MESOS_ATTRIBUTES='gpus:4'\n", expected: true},
+		{role: "agent", attributes: "MESOS_ATTRIBUTES=gpu:false\n"},
+		{role: "agent", attributes: "MESOS_ATTRIBUTES=zone:a\n"},
+		{role: "agent"},
+		{role: "master", attributes: "MESOS_ATTRIBUTES=gpu:true\n"},
+	}
+
+	for i, tt := range tests {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return(tt.role, nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagAcceleratorInfoCommand = "echo <nvidia_smi_log/>"
+		cfg.FlagMesosAttributesFile = filepath.Join(dir, fmt.Sprintf("mesos-slave-common-%d", i))
+		if tt.attributes != "" {
+			require.NoError(t, ioutil.WriteFile(cfg.FlagMesosAttributesFile, []byte(tt.attributes), 0600))
+		}
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		last := got[len(got)-1]
+		if !tt.expected {
+			assert.NotEqual(t, "accelerators/nvidia.xml", last.Name(), tt)
+			continue
+		}
+
+		assert.Equal(t, "accelerators/nvidia.xml", last.Name(), tt)
+		assert.True(t, last.Optional())
+		rc, err := last.Collect(context.Background())
+		require.NoError(t, err)
+		output, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Regexp(t, "^# exit code: 0, duration: .+\n<nvidia_smi_log/>\n$", string(output))
+	}
+}
+
+func TestLoadCollectors_RotatedFilesCollectedNewestFirst(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "rotated")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	for i, name := range []string{"app.log", "app.log.1", "app.log.2.gz", "app.log.3.gz", "app.log.4.gz"} {
+		content := []byte(name)
+		if filepath.Ext(name) == ".gz" {
+			buf := &bytes.Buffer{}
+			w := gzip.NewWriter(buf)
+			_, err := w.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			content = buf.Bytes()
+		}
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, content, 0600))
+		mtime := now.Add(-time.Duration(i) * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	location := filepath.Join(dir, "app.log")
+	endpointsConfig := filepath.Join(dir, "endpoints-config.json")
+	err = ioutil.WriteFile(endpointsConfig, []byte(fmt.Sprintf(
+		`{"LocalFiles": [{"Location": %q, "Rotated": 3, "Decompress": true}]}`, location)), 0600)
+	require.NoError(t, err)
+
+	tools := new(MockedTools)
+	tools.On("GetNodeRole").Return("master", nil)
+	tools.On("GetUnitNames").Return([]string{}, nil)
+
+	cfg := testCfg()
+	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{endpointsConfig}
+
+	got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+	require.NoError(t, err)
+
+	contents := map[string]string{}
+	for _, c := range got {
+		if _, ok := c.(*collector.Rotated); !ok {
+			if _, ok := c.(*collector.File); !ok {
+				continue
+			}
+		}
+		rc, err := c.Collect(context.Background())
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		contents[c.Name()] = string(data)
+	}
+
+	key := strings.TrimLeft(location, "/")
+	assert.Equal(t, map[string]string{
+		key:        "app.log",
+		key + ".1": "app.log.1",
+		key + ".2": "app.log.2.gz",
+		key + ".3": "app.log.3.gz",
+	}, contents)
+}
+
+func TestValidateCollectorsHostname(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		hostname string
+		override string
+		err      string
+	}{
+		{hostname: "127.0.0.1"},
+		{hostname: "localhost"},
+		{hostname: "0.0.0.0", override: "127.0.0.1"},
+		{hostname: "", err: "host name is empty, set --hostname or --collectors-hostname"},
+		{hostname: "0.0.0.0", err: "host name 0.0.0.0 resolves to unspecified address 0.0.0.0, set --collectors-hostname to a local address"},
+		{hostname: "127.0.0.1", override: "192.0.2.1", err: "host name 192.0.2.1 resolves to [192.0.2.1] which is not an address of this node, set --collectors-hostname to a local address"},
+	}
+
+	for _, tt := range tests {
+		cfg := testCfg()
+		cfg.FlagHostname = tt.hostname
+		cfg.FlagCollectorsHostname = tt.override
+
+		err := ValidateCollectorsHostname(cfg)
+		if tt.err == "" {
+			assert.NoError(t, err, tt.hostname)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestLoadMetricsCollectors(t *testing.T) {
+	metrics := "# HELP up Target is up\n# TYPE up gauge\nup 1\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, metrics)
+	}))
+	defer server.Close()
+
+	host, portString, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portString)
+	require.NoError(t, err)
+
+	cfg := testCfg()
+	cfg.FlagCollectorsHostname = host
+
+	got, err := loadMetricsCollectors(cfg, "agent", []MetricsProvider{
+		{Name: "node_exporter", Port: port},
+		{Name: "mesos", Port: port, URI: "/metrics/snapshot", Role: []string{"master"}},
+	}, server.Client(), nil)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	c := got[0]
+	assert.Equal(t, "metrics/node_exporter.prom", c.Name())
+	assert.True(t, c.Optional())
+
+	rc, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, metrics, string(data))
+}
+
+func TestLoadCollectors_ProcessesOnlyWhenEnabled(t *testing.T) {
+	for _, top := range []int{20, 0} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("master", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagProcessesTop = top
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if top > 0 {
+			assert.Contains(t, names, "processes.json")
+		} else {
+			assert.NotContains(t, names, "processes.json")
+		}
+	}
+}
+
+func TestLoadCollectors_JournalHealthOnlyWhenEnabled(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("master", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagJournalHealth = enabled
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if enabled {
+			assert.Contains(t, names, "systemd/journal-health.txt")
+		} else {
+			assert.NotContains(t, names, "systemd/journal-health.txt")
+		}
+	}
+}
+
+func TestLoadCollectors_RejectsEndpointsNotAllowed(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("GetNodeRole").Return("master", nil)
+	tools.On("GetUnitNames").Return([]string{}, nil)
+
+	cfg := testCfg()
+	cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+	cfg.FlagCollectorsHostname = "127.0.0.1"
+
+	cfg.FlagEndpointAllowlist = []string{"127.0.0.1:*"}
+	_, err := LoadCollectors(cfg, tools, http.DefaultClient)
+	require.NoError(t, err)
+
+	cfg.FlagEndpointAllowlist = []string{"*.mesos:*"}
+	_, err = LoadCollectors(cfg, tools, http.DefaultClient)
+	assert.Contains(t, err.Error(), "does not match endpoint allowlist [*.mesos:*]")
+
+	cfg.FlagEndpointAllowlist = []string{"leader.mesos"}
+	_, err = LoadCollectors(cfg, tools, http.DefaultClient)
+	assert.Contains(t, err.Error(), "invalid endpoint allowlist pattern leader.mesos")
+}
+
+func TestLoadCollectors_StorageOnlyWhenEnabled(t *testing.T) {
+	if runtime.GOOS == GoosWindows || runtime.GOOS == GoosDarwin {
+		t.Skip("skipping test; storage is collected only on Linux.")
+	}
+
+	for _, enabled := range []bool{true, false} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("agent", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagStorageInfo = enabled
+		cfg.FlagStorageCommands = []string{"lsblk=echo NAME sda", "pvs=/does/not/exist/pvs --all"}
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		storage := map[string]collector.Collector{}
+		for _, c := range got {
+			if strings.HasPrefix(c.Name(), storageDir+"/") {
+				storage[c.Name()] = c
+			}
+		}
+		if !enabled {
+			assert.Empty(t, storage)
+			continue
+		}
+		require.Len(t, storage, 2)
+
+		rc, err := storage["storage/lsblk.txt"].Collect(context.Background())
+		require.NoError(t, err)
+		output, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Contains(t, string(output), "NAME sda\n")
+
+		// missing tool is noted instead of failing the bundle
+		rc, err = storage["storage/pvs.txt"].Collect(context.Background())
+		require.NoError(t, err)
+		output, err = ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Contains(t, string(output), "/does/not/exist/pvs is not installed on this node")
+	}
+}
+
+func TestLoadCollectors_TLSCertificatesOnlyWhenConfigured(t *testing.T) {
+	if runtime.GOOS == GoosWindows || runtime.GOOS == GoosDarwin {
+		t.Skip("skipping test; systemd collectors are loaded only on Linux.")
+	}
+
+	for _, files := range [][]string{nil, {"/etc/ssl/certs/ca.crt"}} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("agent", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagTLSCertFiles = files
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if files == nil {
+			assert.NotContains(t, names, tlsCertsFileName)
+		} else {
+			assert.Contains(t, names, tlsCertsFileName)
+		}
+	}
+}
+
+func TestLoadCollectors_ContainerEventsOnlyOnAgents(t *testing.T) {
+	for _, role := range []string{"master", "agent", "agent_public"} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return(role, nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagContainerEventsPeriodMinutes = 60
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if role == "master" {
+			assert.NotContains(t, names, containerEventsFileName)
+		} else {
+			assert.Contains(t, names, containerEventsFileName)
+		}
+	}
+}
+
+func TestLoadCollectors_ContainerStorageOnlyOnAgents(t *testing.T) {
+	if runtime.GOOS != GoosLinux {
+		t.Skip("skipping test; container storage is loaded only on Linux.")
+	}
+
+	for _, role := range []string{"master", "agent", "agent_public"} {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return(role, nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		if role == "master" {
+			assert.NotContains(t, names, containerStorageFileName)
+		} else {
+			assert.Contains(t, names, containerStorageFileName)
+		}
+	}
+}
+
+func TestLoadCollectors_NetworkInterfacesRequireOptIn(t *testing.T) {
+	load := func(enabled bool) []collector.Collector {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("agent", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagNetworkInterfaces = enabled
+		cfg.FlagNetworkInterfacesEthtool = "ethtool"
+		cfg.FlagNetworkInterfacesMax = 64
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+		return got
+	}
+
+	for _, c := range load(false) {
+		assert.NotEqual(t, interfacesFileName, c.Name())
+	}
+
+	var interfaces collector.Collector
+	for _, c := range load(true) {
+		if c.Name() == interfacesFileName {
+			interfaces = c
+		}
+	}
+	require.NotNil(t, interfaces)
+	assert.True(t, interfaces.Optional())
+	assert.True(t, collector.AppliesTo(interfaces, collector.Platform{OS: GoosLinux}))
+	assert.False(t, collector.AppliesTo(interfaces, collector.Platform{OS: GoosWindows}))
+}
+
+func TestLoadCollectors_NetworkCaptureRequiresOptInAndLimits(t *testing.T) {
+	if runtime.GOOS != GoosLinux {
+		t.Skip("skipping test; network capture is loaded only on Linux.")
+	}
+
+	load := func(enabled bool, durationSec int) ([]string, error) {
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("agent", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = nil
+		cfg.FlagNetworkCapture = enabled
+		cfg.FlagNetworkCaptureInterface = "any"
+		cfg.FlagNetworkCaptureDurationSec = durationSec
+		cfg.FlagNetworkCapturePackets = 1000
+		cfg.FlagNetworkCaptureSnapLen = 256
+		cfg.FlagNetworkCaptureMaxSizeMB = 10
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		return names, err
+	}
+
+	names, err := load(false, 5)
+	require.NoError(t, err)
+	assert.NotContains(t, names, networkCaptureFileName)
+
+	names, err = load(true, 5)
+	require.NoError(t, err)
+	assert.Contains(t, names, networkCaptureFileName)
+
+	_, err = load(true, 3600)
+	assert.EqualError(t, err, "could not load network capture: capture duration 1h0m0s must be positive and at most 1m0s")
+}
+
+func TestLoadCollectors_FiltersCollectorsByPlatform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "endpoint-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	endpointConfig := filepath.Join(dir, "endpoint-config.json")
+	require.NoError(t, ioutil.WriteFile(endpointConfig, []byte(`{
+		"LocalCommands": [
+			{"Command": ["nft", "list", "ruleset"], "Optional": true, "OS": ["linux"], "Distros": ["centos", "rhel"]},
+			{"Command": ["iptables-save"], "Optional": true, "OS": ["linux"], "Distros": ["ubuntu"]},
+			{"Command": ["uname", "-a"], "Optional": true}
+		]
+	}`), 0600))
+
+	defaultDetectPlatform := detectPlatform
+	defer func() { detectPlatform = defaultDetectPlatform }()
+
+	tests := []struct {
+		platform collector.Platform
+		expected []string
+		excluded []string
+	}{
+		{
+			platform: collector.Platform{OS: GoosLinux, Distro: "centos"},
+			expected: []string{"nft_list_ruleset.output", "uname_-a.output", "time/sync-status.txt"},
+			excluded: []string{"iptables-save.output"},
+		},
+		{
+			platform: collector.Platform{OS: GoosLinux, Distro: "ubuntu"},
+			expected: []string{"iptables-save.output", "uname_-a.output", "time/sync-status.txt"},
+			excluded: []string{"nft_list_ruleset.output"},
+		},
+		{
+			platform: collector.Platform{OS: GoosWindows},
+			expected: []string{"uname_-a.output"},
+			excluded: []string{"nft_list_ruleset.output", "iptables-save.output", "time/sync-status.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		detectPlatform = func() collector.Platform { return tt.platform }
+
+		tools := new(MockedTools)
+		tools.On("GetNodeRole").Return("master", nil)
+		tools.On("GetUnitNames").Return([]string{}, nil)
+
+		cfg := testCfg()
+		cfg.FlagDiagnosticsBundleEndpointsConfigFiles = []string{endpointConfig}
+
+		got, err := LoadCollectors(cfg, tools, http.DefaultClient)
+		require.NoError(t, err)
+
+		var names []string
+		for _, c := range got {
+			names = append(names, c.Name())
+		}
+		for _, name := range tt.expected {
+			assert.Contains(t, names, name, tt.platform)
+		}
+		for _, name := range tt.excluded {
+			assert.NotContains(t, names, name, tt.platform)
+		}
+	}
+}
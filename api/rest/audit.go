@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// auditFileName is a file in the bundle dir with events of creating, downloading and deleting the bundle
+	auditFileName = "audit.json"
+	// PrincipalHeader is a header with the authenticated principal set by the proxy in front of the API
+	PrincipalHeader = "X-Dcos-Principal"
+	// anonymousPrincipal is recorded when the request has no principal
+	anonymousPrincipal = "anonymous"
+)
+
+// AuditAction is an operation on the bundle recorded in the audit log
+type AuditAction string
+
+const (
+	AuditCreate   AuditAction = "create"
+	AuditDownload AuditAction = "download"
+	AuditDelete   AuditAction = "delete"
+)
+
+// AuditEntry records who did what with the bundle and when
+type AuditEntry struct {
+	Time      time.Time   `json:"time"`
+	Action    AuditAction `json:"action"`
+	Principal string      `json:"principal"`
+}
+
+// auditLock serializes updates of audit logs, they are rare so a single lock is enough
+var auditLock sync.Mutex
+
+// principal returns the principal the request was made by
+func principal(r *http.Request) string {
+	if p := r.Header.Get(PrincipalHeader); p != "" {
+		return p
+	}
+	return anonymousPrincipal
+}
+
+type principalKey struct{}
+
+// withPrincipal stores the principal in ctx so requests made to other nodes are recorded on its behalf
+func withPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// forwardPrincipal sets the principal stored in ctx on the request made to another node
+func forwardPrincipal(ctx context.Context, request *http.Request) {
+	if p, ok := ctx.Value(principalKey{}).(string); ok {
+		request.Header.Set(PrincipalHeader, p)
+	}
+}
+
+// recordAudit appends the event to the audit log of the bundle. Failures are only logged so they never
+// fail the request.
+func recordAudit(workDir string, id string, action AuditAction, r *http.Request, now time.Time) {
+	entry := AuditEntry{Time: now, Action: action, Principal: principal(r)}
+	if err := appendAudit(filepath.Join(workDir, id, auditFileName), entry); err != nil {
+		logrus.WithField("ID", id).WithError(err).Warn("Could not record audit entry")
+	}
+}
+
+func appendAudit(path string, entry AuditEntry) error {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	entries, err := readAudit(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	entries = append(entries, entry)
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("could not marshal audit log: %s", err)
+	}
+	return writeFileAtomically(path, data)
+}
+
+func readAudit(path string) ([]AuditEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not unmarshal audit log %s: %s", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRecordsCreateAndDownloadWithPrincipal(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, collectorTimeout, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleFileEndpoint, bh.GetFile).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	req.Header.Set(PrincipalHeader, "bootstrapuser")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	require.Eventually(t, func() bool {
+		bundle, err := bh.getBundleState("bundle-0")
+		return err == nil && bundle.Status == Done
+	}, time.Second, time.Millisecond)
+
+	req, err = http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/file", nil)
+	require.NoError(t, err)
+	req.Header.Set(PrincipalHeader, "operator")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	entries, err := readAudit(filepath.Join(workdir, "bundle-0", auditFileName))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, AuditCreate, entries[0].Action)
+	assert.Equal(t, "bootstrapuser", entries[0].Principal)
+	assert.Equal(t, AuditDownload, entries[1].Action)
+	assert.Equal(t, "operator", entries[1].Principal)
+	assert.False(t, entries[1].Time.Before(entries[0].Time))
+}
+
+func TestAuditRecordsAnonymousPrincipal(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	require.NoError(t, os.Mkdir(filepath.Join(workdir, "bundle-0"), dirPerm))
+
+	req := httptest.NewRequest(http.MethodDelete, bundlesEndpoint+"/bundle-0", nil)
+	recordAudit(workdir, "bundle-0", AuditDelete, req, time.Now())
+
+	entries, err := readAudit(filepath.Join(workdir, "bundle-0", auditFileName))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditDelete, entries[0].Action)
+	assert.Equal(t, "anonymous", entries[0].Principal)
+}
+
+func TestClientForwardsPrincipal(t *testing.T) {
+	principals := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principals <- r.Header.Get(PrincipalHeader)
+		w.Write(jsonMarshal(Bundle{ID: "bundle-0", Status: Deleted}))
+	}))
+	defer server.Close()
+
+	client := NewDiagnosticsClient(server.Client())
+	require.NoError(t, client.Delete(withPrincipal(context.Background(), "operator"), server.URL, "bundle-0"))
+	assert.Equal(t, "operator", <-principals)
+}
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// archiveReportFileName is a file added to combined archives listing included and skipped bundles
+const archiveReportFileName = "archive-report.json"
+
+// ArchiveReport describes content of a combined archive. Skipped maps bundle ID to the reason it was not included.
+type ArchiveReport struct {
+	Included []string          `json:"included"`
+	Skipped  map[string]string `json:"skipped"`
+}
+
+// Archive streams a zip with bundles given with id query param. Every bundle is stored in a directory named
+// after its ID. Missing or not finished bundles are skipped and listed in archive-report.json.
+func (h BundleHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["id"]
+	if len(ids) == 0 {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("at least one bundle id is required"))
+		return
+	}
+
+	report := ArchiveReport{
+		Included: []string{},
+		Skipped:  map[string]string{},
+	}
+	bundles := make([]Bundle, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := report.Skipped[id]; ok || containsBundle(bundles, id) {
+			continue
+		}
+		bundle, err := h.archivedBundle(id)
+		if err != nil {
+			report.Skipped[id] = err.Error()
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+
+	w.Header().Add("Content-Type", "application/zip, application/octet-stream")
+	w.Header().Add("Content-disposition", "attachment; filename=bundles.zip")
+
+	zipWriter := zip.NewWriter(w)
+	for _, bundle := range bundles {
+		if err := h.appendBundleToZip(zipWriter, bundle); err != nil {
+			// the archive is already partially sent so we could only log it and note it in the report
+			logrus.WithField("ID", bundle.ID).WithError(err).Error("Could not add bundle to archive")
+			report.Skipped[bundle.ID] = err.Error()
+			continue
+		}
+		report.Included = append(report.Included, bundle.ID)
+		now := h.clock.Now()
+		recordAudit(h.workDir, bundle.ID, AuditDownload, r, now)
+		h.touch(bundle.ID, now)
+	}
+
+	reportFile, err := zipWriter.Create(archiveReportFileName)
+	if err != nil {
+		logrus.WithError(err).Error("Could not add report to archive")
+		return
+	}
+	if _, err := io.Copy(reportFile, bytes.NewReader(jsonMarshal(report))); err != nil {
+		logrus.WithError(err).Error("Could not add report to archive")
+		return
+	}
+	if err := zipWriter.Close(); err != nil {
+		logrus.WithError(err).Error("Could not finish archive")
+	}
+}
+
+// archivedBundle returns the state of the bundle if it could be archived
+func (h BundleHandler) archivedBundle(id string) (Bundle, error) {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id {
+		return Bundle{}, fmt.Errorf("invalid bundle id")
+	}
+	if !h.bundleExists(id) {
+		return Bundle{}, fmt.Errorf("bundle %s not found", id)
+	}
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		return bundle, err
+	}
+	if bundle.Status != Done {
+		return bundle, fmt.Errorf("bundle %s is not done (status %s)", id, bundle.Status)
+	}
+	return bundle, nil
+}
+
+func (h BundleHandler) appendBundleToZip(writer *zip.Writer, bundle Bundle) error {
+	if bundle.Layout == LayoutDirectory {
+		return appendDirToZip(writer, filepath.Join(h.workDir, bundle.ID, contentsDir), bundle.ID)
+	}
+
+	dataFile := filepath.Join(h.workDir, bundle.ID, dataFileName)
+	r, err := zip.OpenReader(dataFile)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", dataFile, err)
+	}
+	defer r.Close()
+	registerDecompressors(&r.Reader)
+
+	entries := newZipEntries(DuplicateEntriesRename)
+	for _, f := range r.File {
+		if err := addFileToZip(writer, f, bundle.ID, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendDirToZip adds regular files from dir to the zip under base directory
+func appendDirToZip(writer *zip.Writer, dir string, base string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		fileName := path.Join(base, filepath.ToSlash(name))
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		file, err := writer.Create(fileName)
+		if err != nil {
+			return fmt.Errorf("could not create file %s: %s", fileName, err)
+		}
+		if _, err := io.Copy(file, f); err != nil {
+			return fmt.Errorf("could not copy file %s to zip: %s", fileName, err)
+		}
+		return nil
+	})
+}
+
+func containsBundle(bundles []Bundle, id string) bool {
+	for _, b := range bundles {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveCombinesBundlesAndReportsMissingOnes(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	for id, status := range map[string]Status{"bundle-0": Done, "bundle-1": Done, "bundle-2": InProgress} {
+		bundleDir := filepath.Join(workdir, id)
+		require.NoError(t, os.Mkdir(bundleDir, dirPerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(bundleDir, stateFileName),
+			jsonMarshal(Bundle{ID: id, Type: Local, Status: status}), filePerm))
+		require.NoError(t, writeTestZip(filepath.Join(bundleDir, dataFileName), "test.txt", id+"\n"))
+	}
+
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/archive?id=bundle-0&id=bundle-1&id=bundle-2&id=missing&id=../bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	bh.Archive(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip, application/octet-stream", rr.Header().Get("Content-Type"))
+
+	r, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	assert.Len(t, files, 3)
+	assert.Equal(t, "bundle-0\n", files[filepath.Join("bundle-0", "test.txt")])
+	assert.Equal(t, "bundle-1\n", files[filepath.Join("bundle-1", "test.txt")])
+	assert.JSONEq(t, `{
+		"included": ["bundle-0", "bundle-1"],
+		"skipped": {
+			"bundle-2": "bundle bundle-2 is not done (status InProgress)",
+			"missing": "bundle missing not found",
+			"../bundle-0": "invalid bundle id"
+		}
+	}`, files[archiveReportFileName])
+}
+
+func TestArchiveRecordsDownloadOfIncludedBundles(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	for id, status := range map[string]Status{"bundle-0": Done, "bundle-1": InProgress} {
+		bundleDir := filepath.Join(workdir, id)
+		require.NoError(t, os.Mkdir(bundleDir, dirPerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(bundleDir, stateFileName),
+			jsonMarshal(Bundle{ID: id, Type: Local, Status: status}), filePerm))
+		require.NoError(t, writeTestZip(filepath.Join(bundleDir, dataFileName), "test.txt", id+"\n"))
+	}
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: now.Add(-time.Hour)}
+
+	req, err := http.NewRequest(http.MethodGet, "/archive?id=bundle-0&id=bundle-1", nil)
+	require.NoError(t, err)
+	req.Header.Set(PrincipalHeader, "operator")
+	rr := httptest.NewRecorder()
+	bh.Archive(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	entries, err := readAudit(filepath.Join(workdir, "bundle-0", auditFileName))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditDownload, entries[0].Action)
+	assert.Equal(t, "operator", entries[0].Principal)
+
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	require.NotNil(t, bundle.LastAccessed)
+	assert.False(t, bundle.LastAccessed.Before(now))
+
+	// skipped bundle was not downloaded
+	assert.NoFileExists(t, filepath.Join(workdir, "bundle-1", auditFileName))
+	assert.NoFileExists(t, filepath.Join(workdir, "bundle-1", lastAccessFileName))
+}
+
+func TestArchiveReturns400WithoutIDs(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/archive", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	bh.Archive(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code":400,"error":"at least one bundle id is required"}`, rr.Body.String())
+}
@@ -0,0 +1,293 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CatalogEntry is metadata of a bundle recorded in the catalog. Entries of deleted bundles are kept so the catalog
+// is a history of all bundles created by the daemon.
+type CatalogEntry struct {
+	ID   string `json:"id"`
+	Type Type   `json:"type"`
+	// Labels are properties of the bundle bundles could be searched by e.g., source=scheduled
+	Labels  map[string]string `json:"labels,omitempty"`
+	Size    int64             `json:"size,omitempty"`
+	Status  Status            `json:"status"`
+	Started time.Time         `json:"started_at,omitempty"`
+	Stopped time.Time         `json:"stopped_at,omitempty"`
+	// Updated is the time the entry was changed for the last time
+	Updated  time.Time `json:"updated_at"`
+	Checksum string    `json:"checksum,omitempty"`
+	// UploadKey is the object store key the bundle was uploaded under, empty until the upload is done
+	UploadKey string `json:"upload_key,omitempty"`
+}
+
+// CatalogQuery selects catalog entries, empty fields match all entries
+type CatalogQuery struct {
+	// Labels all have to match
+	Labels map[string]string
+	// Statuses match any of them
+	Statuses []Status
+	// Since and Until limit the time the bundle was started at
+	Since time.Time
+	Until time.Time
+}
+
+func (q CatalogQuery) matches(e CatalogEntry) bool {
+	for k, v := range q.Labels {
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+	if len(q.Statuses) > 0 && !containsStatus(q.Statuses, e.Status) {
+		return false
+	}
+	if !q.Since.IsZero() && e.Started.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Started.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+func containsStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Catalog is an index of bundles stored as a JSON file. It is updated every time a bundle state is written so
+// bundles of all clusters of a fleet could be searched without reading their state files.
+type Catalog struct {
+	sync.Mutex
+	path    string
+	clock   Clock
+	entries map[string]CatalogEntry
+}
+
+// NewCatalog returns the catalog stored in the file at path, the file is created with the first entry
+func NewCatalog(path string) (*Catalog, error) {
+	c := &Catalog{
+		path:    path,
+		clock:   &realClock{},
+		entries: map[string]CatalogEntry{},
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read catalog %s: %s", path, err)
+	}
+	var entries []CatalogEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse catalog %s: %s", path, err)
+	}
+	for _, e := range entries {
+		c.entries[e.ID] = e
+	}
+	return c, nil
+}
+
+// catalogEntry returns the catalog entry of the bundle
+func catalogEntry(bundle Bundle) CatalogEntry {
+	labels := map[string]string{}
+	if bundle.Source != "" {
+		labels["source"] = string(bundle.Source)
+	}
+	if bundle.Layout != "" {
+		labels["layout"] = string(bundle.Layout)
+	}
+	if bundle.Compression != "" {
+		labels["compression"] = string(bundle.Compression)
+	}
+	if bundle.Pinned {
+		labels["pinned"] = "true"
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	entry := CatalogEntry{
+		ID:       bundle.ID,
+		Type:     bundle.Type,
+		Labels:   labels,
+		Size:     bundle.Size,
+		Status:   bundle.Status,
+		Started:  bundle.Started,
+		Stopped:  bundle.Stopped,
+		Checksum: bundle.Checksum,
+	}
+	if bundle.Upload != nil && bundle.Upload.Done {
+		entry.UploadKey = bundle.Upload.Key
+	}
+	return entry
+}
+
+// record adds or updates the entry of the bundle. The file is written only when the entry changed so progress
+// updates of bundles in progress do not rewrite it.
+func (c *Catalog) record(bundle Bundle) error {
+	entry := catalogEntry(bundle)
+
+	c.Lock()
+	defer c.Unlock()
+	if old, ok := c.entries[entry.ID]; ok {
+		entry.Updated = old.Updated
+		if reflect.DeepEqual(old, entry) {
+			return nil
+		}
+	}
+	entry.Updated = c.clock.Now()
+	c.entries[entry.ID] = entry
+	return c.write()
+}
+
+// write replaces the catalog file with entries sorted by the start time
+func (c *Catalog) write() error {
+	entries := make([]CatalogEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sortCatalogEntries(entries)
+
+	raw, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not write catalog %s: %s", c.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write catalog %s: %s", c.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write catalog %s: %s", c.path, err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("could not write catalog %s: %s", c.path, err)
+	}
+	return nil
+}
+
+// Query returns entries matching the query sorted by the start time
+func (c *Catalog) Query(q CatalogQuery) []CatalogEntry {
+	c.Lock()
+	defer c.Unlock()
+	entries := []CatalogEntry{}
+	for _, e := range c.entries {
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sortCatalogEntries(entries)
+	return entries
+}
+
+func sortCatalogEntries(entries []CatalogEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Started.Equal(entries[j].Started) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].Started.Before(entries[j].Started)
+	})
+}
+
+// recordInCatalog updates the catalog with the bundle stored in workDir, failures are only logged so they do not
+// fail bundles. The size is not stored in the state so it is read from the data of done bundles.
+func recordInCatalog(catalog *Catalog, workDir string, bundle Bundle) {
+	if catalog == nil {
+		return
+	}
+	if bundle.Status == Done && bundle.Size == 0 {
+		if bundle.Layout == LayoutDirectory {
+			bundle.Size, _ = dirSize(filepath.Join(workDir, bundle.ID, contentsDir))
+		} else if info, err := os.Stat(filepath.Join(workDir, bundle.ID, dataFileName)); err == nil {
+			bundle.Size = info.Size()
+		}
+	}
+	if err := catalog.record(bundle); err != nil {
+		logrus.WithField("ID", bundle.ID).WithError(err).Warn("Could not update bundle catalog")
+	}
+}
+
+// parseCatalogQuery reads the query from label=key:value, status and since/until RFC 3339 query params
+func parseCatalogQuery(r *http.Request) (CatalogQuery, error) {
+	params := r.URL.Query()
+	q := CatalogQuery{}
+
+	for _, label := range params["label"] {
+		parts := strings.SplitN(label, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return q, fmt.Errorf("invalid label %q, expected key:value", label)
+		}
+		if q.Labels == nil {
+			q.Labels = map[string]string{}
+		}
+		q.Labels[parts[0]] = parts[1]
+	}
+	for _, status := range params["status"] {
+		s, ok := toID[status]
+		if !ok {
+			return q, fmt.Errorf("invalid status %q", status)
+		}
+		q.Statuses = append(q.Statuses, s)
+	}
+
+	var err error
+	if since := params.Get("since"); since != "" {
+		if q.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return q, fmt.Errorf("invalid since: %s", err)
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if q.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return q, fmt.Errorf("invalid until: %s", err)
+		}
+	}
+	return q, nil
+}
+
+// WithCatalog makes the handler record bundles in the catalog, nil disables it
+func (h *BundleHandler) WithCatalog(catalog *Catalog) *BundleHandler {
+	h.catalog = catalog
+	return h
+}
+
+// SetCatalog makes the handler record cluster bundles in the catalog, nil disables it
+func (c *ClusterBundleHandler) SetCatalog(catalog *Catalog) {
+	c.catalog = catalog
+}
+
+// Catalog returns catalog entries of bundles filtered by label, status and the start time range
+func (h BundleHandler) Catalog(w http.ResponseWriter, r *http.Request) {
+	if h.catalog == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("bundle catalog is disabled"))
+		return
+	}
+	q, err := parseCatalogQuery(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	write(w, jsonMarshal(h.catalog.Query(q)))
+}
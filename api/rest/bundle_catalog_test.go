@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogIsUpdatedOnBundleLifecycle(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	catalogPath := filepath.Join(workdir, "catalog.json")
+	catalog, err := NewCatalog(catalogPath)
+	require.NoError(t, err)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+	bh, err := NewBundleHandler(filepath.Join(workdir, "bundles"), collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithCatalog(catalog)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleEndpoint, bh.Delete).Methods(http.MethodDelete)
+	router.HandleFunc(bundlesEndpoint+"-catalog", bh.Catalog).Methods(http.MethodGet)
+
+	serve := func(method string, url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPut, bundlesEndpoint+"/bundle-0").Code)
+	require.Eventually(t, func() bool {
+		entries := catalog.Query(CatalogQuery{Statuses: []Status{Done}})
+		return len(entries) == 1
+	}, time.Second, time.Millisecond)
+
+	done := catalog.Query(CatalogQuery{})[0]
+	assert.Equal(t, "bundle-0", done.ID)
+	assert.Equal(t, Local, done.Type)
+	assert.Equal(t, map[string]string{"source": string(SourceManual)}, done.Labels)
+	assert.NotEmpty(t, done.Checksum)
+	assert.NotZero(t, done.Size)
+	assert.False(t, done.Updated.IsZero())
+
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete, bundlesEndpoint+"/bundle-0").Code)
+	assert.Empty(t, catalog.Query(CatalogQuery{Statuses: []Status{Done}}))
+
+	// the catalog is persisted so it survives the daemon restart
+	reloaded, err := NewCatalog(catalogPath)
+	require.NoError(t, err)
+	entries := reloaded.Query(CatalogQuery{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, Deleted, entries[0].Status)
+
+	rr := serve(http.MethodGet, bundlesEndpoint+"-catalog?status=Deleted&label=source:manual")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got []CatalogEntry
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "bundle-0", got[0].ID)
+}
+
+func TestCatalogQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	catalog, err := NewCatalog(filepath.Join(dir, "catalog.json"))
+	require.NoError(t, err)
+
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	bundles := []Bundle{
+		{ID: "bundle-0", Type: Local, Source: SourceScheduled, Status: Done, Started: now.Add(-48 * time.Hour),
+			Upload: &UploadProgress{Key: "clusters/a/bundle-0.zip", Done: true}},
+		{ID: "bundle-1", Type: Cluster, Source: SourceManual, Status: Failed, Started: now.Add(-24 * time.Hour)},
+		{ID: "bundle-2", Type: Local, Source: SourceScheduled, Status: Done, Started: now, Pinned: true},
+		{ID: "bundle-3", Type: Local, Source: SourceScheduled, Status: InProgress, Started: now.Add(time.Hour)},
+	}
+	for _, b := range bundles {
+		require.NoError(t, catalog.record(b))
+	}
+
+	ids := func(entries []CatalogEntry) []string {
+		result := []string{}
+		for _, e := range entries {
+			result = append(result, e.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "all", query: "", expected: []string{"bundle-0", "bundle-1", "bundle-2", "bundle-3"}},
+		{name: "by label", query: "label=source:scheduled", expected: []string{"bundle-0", "bundle-2", "bundle-3"}},
+		{name: "by labels", query: "label=source:scheduled&label=pinned:true", expected: []string{"bundle-2"}},
+		{name: "by statuses", query: "status=Failed&status=InProgress", expected: []string{"bundle-1", "bundle-3"}},
+		{name: "by time range", query: "since=2020-04-30T00:00:00Z&until=2020-05-01T12:00:00Z",
+			expected: []string{"bundle-1", "bundle-2"}},
+		{name: "combined", query: "label=source:scheduled&status=Done&since=2020-04-29T00:00:00Z",
+			expected: []string{"bundle-0", "bundle-2"}},
+		{name: "no match", query: "label=source:automation", expected: []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/catalog?"+tc.query, nil)
+			require.NoError(t, err)
+			q, err := parseCatalogQuery(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ids(catalog.Query(q)))
+		})
+	}
+
+	entries := catalog.Query(CatalogQuery{Labels: map[string]string{"source": "scheduled"}, Statuses: []Status{Done}})
+	assert.Equal(t, "clusters/a/bundle-0.zip", entries[0].UploadKey)
+	assert.Empty(t, entries[1].UploadKey)
+}
+
+func TestCatalogHandlerRejectsInvalidQueryAndDisabledCatalog(t *testing.T) {
+	bh := BundleHandler{}
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/catalog", nil)
+	require.NoError(t, err)
+	bh.Catalog(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	bh.WithCatalog(&Catalog{entries: map[string]CatalogEntry{}})
+	for _, query := range []string{"label=source", "status=Finished", "since=yesterday", "until=2020-05-01"} {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/catalog?"+query, nil)
+		require.NoError(t, err)
+		bh.Catalog(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
@@ -0,0 +1,182 @@
+package rest
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// zip end of central directory records, see section 4.3 of the APPNOTE
+const (
+	directoryEndSignature      = 0x06054b50
+	directory64EndSignature    = 0x06064b50
+	directory64LocSignature    = 0x07064b50
+	directoryEndLen            = 22
+	directory64EndLen          = 56
+	directory64LocLen          = 20
+	zipVersion45               = 45
+	uint16max                  = 0xffff
+	uint32max                  = 0xffffffff
+	directory64TrailerExtraLen = directory64EndLen + directory64LocLen
+)
+
+// checkpointZipWriter stores bundle content in a single zip file that stays readable while it is written.
+// Before every new entry the entries written so far are finished and the central directory listing all of them
+// is written after them. The next entries are appended after that directory, so when the process is killed
+// the first checkpointed bytes of the file still form a valid zip with all entries written before the last
+// checkpoint. The size of this readable part is reported with onCheckpoint.
+type checkpointZipWriter struct {
+	segment      *zip.Writer // entries written since the last checkpoint
+	entries      int         // number of entries in the segment
+	file         *os.File
+	method       uint16
+	directory    []byte // central directory records of all checkpointed entries
+	records      int    // number of records in directory
+	checkpointed bool
+	onCheckpoint func(size int64)
+}
+
+func newCheckpointZipWriter(file *os.File, compression Compression, onCheckpoint func(size int64)) *checkpointZipWriter {
+	w := &checkpointZipWriter{file: file, method: compression.zipMethod(), onCheckpoint: onCheckpoint}
+	w.startSegment(0)
+	return w
+}
+
+func (w *checkpointZipWriter) Create(name string) (io.Writer, error) {
+	if err := w.checkpoint(); err != nil {
+		return nil, fmt.Errorf("could not checkpoint bundle: %s", err)
+	}
+	w.entries++
+	return w.segment.CreateHeader(&zip.FileHeader{Name: name, Method: w.method})
+}
+
+func (w *checkpointZipWriter) Close() error {
+	if err := w.checkpoint(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// startSegment starts writing new entries at given offset of the file
+func (w *checkpointZipWriter) startSegment(offset int64) {
+	w.segment = zip.NewWriter(w.file)
+	w.segment.SetOffset(offset)
+	registerCompressors(w.segment)
+	w.entries = 0
+}
+
+// checkpoint finishes entries written since the last checkpoint and writes the central directory of
+// all entries at the end of the file. It does nothing when there are no new entries.
+func (w *checkpointZipWriter) checkpoint() error {
+	if w.entries == 0 && w.checkpointed {
+		return nil
+	}
+
+	// closing the segment finishes its last entry and writes the directory of segment entries only
+	if err := w.segment.Close(); err != nil {
+		return err
+	}
+	end, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	records, directoryOffset, err := w.readSegmentDirectory(end)
+	if err != nil {
+		return err
+	}
+	w.records += records
+
+	// replace the segment directory with the directory of all entries
+	if _, err := w.file.Seek(directoryOffset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(w.directory); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(directoryEnd(w.records, int64(len(w.directory)), directoryOffset)); err != nil {
+		return err
+	}
+	size, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err := w.file.Truncate(size); err != nil {
+		return err
+	}
+
+	w.checkpointed = true
+	if w.onCheckpoint != nil {
+		w.onCheckpoint(size)
+	}
+	w.startSegment(size)
+	return nil
+}
+
+// readSegmentDirectory appends records of the segment directory ending at end to the directory
+// and returns their number and the offset of the segment directory
+func (w *checkpointZipWriter) readSegmentDirectory(end int64) (int, int64, error) {
+	buf := make([]byte, directoryEndLen)
+	if _, err := w.file.ReadAt(buf, end-directoryEndLen); err != nil {
+		return 0, 0, err
+	}
+	if binary.LittleEndian.Uint32(buf) != directoryEndSignature {
+		return 0, 0, fmt.Errorf("invalid end of central directory at %d", end-directoryEndLen)
+	}
+	records := int64(binary.LittleEndian.Uint16(buf[10:]))
+	size := int64(binary.LittleEndian.Uint32(buf[12:]))
+	offset := int64(binary.LittleEndian.Uint32(buf[16:]))
+
+	if records == uint16max || size == uint32max || offset == uint32max {
+		buf = make([]byte, directory64EndLen)
+		if _, err := w.file.ReadAt(buf, end-directoryEndLen-directory64TrailerExtraLen); err != nil {
+			return 0, 0, err
+		}
+		if binary.LittleEndian.Uint32(buf) != directory64EndSignature {
+			return 0, 0, fmt.Errorf("invalid zip64 end of central directory")
+		}
+		records = int64(binary.LittleEndian.Uint64(buf[32:]))
+		size = int64(binary.LittleEndian.Uint64(buf[40:]))
+		offset = int64(binary.LittleEndian.Uint64(buf[48:]))
+	}
+
+	directory := make([]byte, size)
+	if _, err := w.file.ReadAt(directory, offset); err != nil {
+		return 0, 0, err
+	}
+	w.directory = append(w.directory, directory...)
+	return int(records), offset, nil
+}
+
+// directoryEnd returns the end of central directory records, with zip64 records when needed
+func directoryEnd(records int, size int64, offset int64) []byte {
+	var buf []byte
+	if records >= uint16max || size >= uint32max || offset >= uint32max {
+		buf = make([]byte, directory64TrailerExtraLen, directory64TrailerExtraLen+directoryEndLen)
+		binary.LittleEndian.PutUint32(buf, directory64EndSignature)
+		binary.LittleEndian.PutUint64(buf[4:], directory64EndLen-12) // size of the remaining record
+		binary.LittleEndian.PutUint16(buf[12:], zipVersion45)        // version made by
+		binary.LittleEndian.PutUint16(buf[14:], zipVersion45)        // version needed to extract
+		binary.LittleEndian.PutUint64(buf[24:], uint64(records))     // records on this disk
+		binary.LittleEndian.PutUint64(buf[32:], uint64(records))     // total records
+		binary.LittleEndian.PutUint64(buf[40:], uint64(size))
+		binary.LittleEndian.PutUint64(buf[48:], uint64(offset))
+
+		loc := buf[directory64EndLen:]
+		binary.LittleEndian.PutUint32(loc, directory64LocSignature)
+		binary.LittleEndian.PutUint64(loc[8:], uint64(offset+size)) // offset of the zip64 end record
+		binary.LittleEndian.PutUint32(loc[16:], 1)                  // total number of disks
+
+		records, size, offset = uint16max, uint32max, uint32max
+	}
+
+	end := make([]byte, directoryEndLen)
+	binary.LittleEndian.PutUint32(end, directoryEndSignature)
+	binary.LittleEndian.PutUint16(end[8:], uint16(records))
+	binary.LittleEndian.PutUint16(end[10:], uint16(records))
+	binary.LittleEndian.PutUint32(end[12:], uint32(size))
+	binary.LittleEndian.PutUint32(end[16:], uint32(offset))
+	return append(buf, end...)
+}
@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointZipWriterKeepsEntriesReadableWhenAborted(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file, err := os.Create(filepath.Join(dir, dataFileName))
+	require.NoError(t, err)
+	defer file.Close()
+
+	var checkpoints []int64
+	w := newCheckpointZipWriter(file, CompressionDeflate, func(size int64) {
+		checkpoints = append(checkpoints, size)
+	})
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = io.WriteString(f, "content of "+name)
+		require.NoError(t, err)
+	}
+	f, err := w.Create("c.txt")
+	require.NoError(t, err)
+	// random data is not compressed so it is written to the file before the entry is finished
+	_, err = io.CopyN(f, rand.New(rand.NewSource(1)), 1<<20)
+	require.NoError(t, err)
+	// simulate abort e.g., OOM kill, the writer is never closed
+
+	require.Len(t, checkpoints, 3)
+	stat, err := file.Stat()
+	require.NoError(t, err)
+	assert.True(t, stat.Size() > checkpoints[2])
+
+	r, err := zip.NewReader(io.NewSectionReader(file, 0, checkpoints[2]), checkpoints[2])
+	require.NoError(t, err)
+	require.Len(t, r.File, 2)
+	for i, name := range []string{"a.txt", "b.txt"} {
+		assert.Equal(t, name, r.File[i].Name)
+		rc, err := r.File[i].Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		assert.Equal(t, "content of "+name, string(content))
+	}
+}
+
+func TestCheckpointZipWriterWritesAllEntriesOnClose(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file, err := os.Create(filepath.Join(dir, dataFileName))
+	require.NoError(t, err)
+
+	w := newCheckpointZipWriter(file, CompressionZstd, nil)
+	for _, name := range []string{"a.txt", "b/c.txt", "d.txt"} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = io.WriteString(f, "content of "+name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	r, err := zip.OpenReader(filepath.Join(dir, dataFileName))
+	require.NoError(t, err)
+	defer r.Close()
+	registerDecompressors(&r.Reader)
+
+	require.Len(t, r.File, 3)
+	for i, name := range []string{"a.txt", "b/c.txt", "d.txt"} {
+		assert.Equal(t, name, r.File[i].Name)
+		rc, err := r.File[i].Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		assert.Equal(t, "content of "+name, string(content))
+	}
+}
+
+func TestIfGetFileReturnsCheckpointedPartOfInterruptedBundle(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: now}
+	bh.WithIncremental(true)
+
+	bundle := Bundle{ID: "bundle", Started: now, Status: Started}
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, bundle.ID), dirPerm))
+	_, err = bh.writeStateFile(bundle)
+	require.NoError(t, err)
+
+	dataWriter, err := bh.newBundleWriter(bundle)
+	require.NoError(t, err)
+	f, err := dataWriter.Create("collected.txt")
+	require.NoError(t, err)
+	_, err = io.WriteString(f, "collected")
+	require.NoError(t, err)
+	_, err = dataWriter.Create("interrupted.txt")
+	require.NoError(t, err)
+	// the daemon was killed, the writer is never closed
+
+	state, err := bh.getBundleState(bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, InProgress, state.Status)
+	assert.True(t, state.Incomplete)
+	assert.NotZero(t, state.Checkpoint)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleFileEndpoint, bh.GetFile)
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle/file", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.Bytes()
+	assert.Len(t, body, int(state.Checkpoint))
+	r, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	require.Len(t, r.File, 1)
+	assert.Equal(t, "collected.txt", r.File[0].Name)
+}
@@ -0,0 +1,243 @@
+package rest
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ParseCompactFilters returns regular expressions of lines removed from text entries of compacted bundles
+func ParseCompactFilters(patterns []string) ([]*regexp.Regexp, error) {
+	filters := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compact filter %q: %s", p, err)
+		}
+		filters = append(filters, re)
+	}
+	return filters, nil
+}
+
+// Compact rewrites the data file of the finished zip bundle to save space: entries are recompressed with the best
+// compression level of their codec, trailing whitespace is trimmed and lines matching compact filters are removed
+// from text entries. Manifests are updated with new sizes and the state with the new size and checksum so the
+// bundle stays valid. The bundle is rewritten to a temporary file and swapped so it is never left half written.
+// Removed data could only be restored by collecting a new bundle.
+func (h BundleHandler) Compact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !h.bundleExists(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.compactLock.Lock()
+	defer h.compactLock.Unlock()
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if bundle.Status != Done {
+		writeJSONError(w, http.StatusConflict,
+			fmt.Errorf("bundle %s is not done (status %s), only done bundles could be compacted", id, bundle.Status))
+		return
+	}
+	if bundle.Layout == LayoutDirectory {
+		writeJSONError(w, http.StatusBadRequest,
+			fmt.Errorf("bundle %s has %s layout, only zip bundles could be compacted", id, bundle.Layout))
+		return
+	}
+
+	dataPath := filepath.Join(h.workDir, id, dataFileName)
+	if err := compactZipBundle(dataPath, h.compactFilters); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not compact bundle %s: %s", id, err))
+		return
+	}
+
+	stat, err := os.Stat(dataPath)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not stat compacted bundle %s: %s", id, err))
+		return
+	}
+	checksum, err := fileChecksum(dataPath)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	bundle.Size = stat.Size()
+	bundle.Checksum = checksum
+	bundle.Compacted = true
+
+	newRawState, err := h.writeStateFile(bundle)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError,
+			fmt.Errorf("bundle %s was compacted but state could not be updated: %s", id, err))
+		return
+	}
+	write(w, newRawState)
+}
+
+// compactZipBundle rewrites the zip bundle at the path applying filters to its text entries
+func compactZipBundle(bundlePath string, filters []*regexp.Regexp) error {
+	r, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", bundlePath, err)
+	}
+	defer r.Close()
+	registerDecompressors(&r.Reader)
+
+	tmp, err := ioutil.TempFile(filepath.Dir(bundlePath), dataFileName+".compact")
+	if err != nil {
+		return fmt.Errorf("could not create compacted file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zipWriter := zip.NewWriter(tmp)
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flate.BestCompression)
+	})
+	zipWriter.RegisterCompressor(zipMethodZstd, func(out io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(out, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	})
+
+	// manifests are written after all entries so they could list sizes of compacted entries
+	sizes := make(map[string]int64, len(r.File))
+	var manifests []*zip.File
+	for _, f := range r.File {
+		if path.Base(f.Name) == manifestFileName {
+			manifests = append(manifests, f)
+			continue
+		}
+		size, err := compactEntry(zipWriter, f, filters)
+		if err != nil {
+			return err
+		}
+		sizes[f.Name] = size
+	}
+	for _, f := range manifests {
+		if err := compactManifest(zipWriter, f, sizes); err != nil {
+			return err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("could not close compacted zip: %s", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("could not sync compacted zip: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not close compacted file: %s", err)
+	}
+	return os.Rename(tmp.Name(), bundlePath)
+}
+
+// compactEntry copies the entry to the writer, text entries are filtered. It returns the size of the written entry.
+func compactEntry(w *zip.Writer, f *zip.File, filters []*regexp.Regexp) (int64, error) {
+	var buf bytes.Buffer
+	if err := readZipEntry(f, &buf); err != nil {
+		return 0, fmt.Errorf("could not read %s: %s", f.Name, err)
+	}
+	data := buf.Bytes()
+	if isCompactableText(f.Name, data) {
+		data = compactText(data, filters)
+	}
+	return writeCompactedEntry(w, f, data)
+}
+
+// isCompactableText returns true for text entries which lines could be filtered, structured entries are left intact
+func isCompactableText(name string, data []byte) bool {
+	switch path.Ext(name) {
+	case ".json", ".html", ".xml":
+		return false
+	}
+	return utf8.Valid(data)
+}
+
+// compactText trims trailing whitespace of lines and removes lines matching any of the filters
+func compactText(data []byte, filters []*regexp.Regexp) []byte {
+	var out bytes.Buffer
+	out.Grow(len(data))
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if matchesAny(line, filters) {
+			continue
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	// keep the last line without a trailing new line as it was
+	if out.Len() > 0 && len(data) > 0 && data[len(data)-1] != '\n' {
+		out.Truncate(out.Len() - 1)
+	}
+	return out.Bytes()
+}
+
+func matchesAny(line string, filters []*regexp.Regexp) bool {
+	for _, re := range filters {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// compactManifest writes the manifest with sizes of entries updated to their compacted sizes
+func compactManifest(w *zip.Writer, f *zip.File, sizes map[string]int64) error {
+	var m bundleManifest
+	if err := readZipJSON(f, &m); err != nil {
+		return err
+	}
+	dir := path.Dir(f.Name)
+	for i, e := range m.Entries {
+		if size, ok := sizes[path.Join(dir, e.Name)]; ok {
+			m.Entries[i].Size = size
+		}
+	}
+	_, err := writeCompactedEntry(w, f, jsonMarshal(m))
+	return err
+}
+
+// writeCompactedEntry writes data as the entry keeping its name, modification time and codec.
+// Stored entries are compressed with deflate.
+func writeCompactedEntry(w *zip.Writer, f *zip.File, data []byte) (int64, error) {
+	header := &zip.FileHeader{
+		Name:     f.Name,
+		Comment:  f.Comment,
+		Method:   f.Method,
+		Modified: f.Modified,
+	}
+	if header.Method == zip.Store && !strings.HasSuffix(f.Name, "/") {
+		header.Method = zip.Deflate
+	}
+	entry, err := w.CreateHeader(header)
+	if err != nil {
+		return 0, fmt.Errorf("could not create %s: %s", f.Name, err)
+	}
+	n, err := entry.Write(data)
+	if err != nil {
+		return 0, fmt.Errorf("could not write %s: %s", f.Name, err)
+	}
+	return int64(n), nil
+}
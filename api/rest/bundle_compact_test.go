@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bundleCompactEndpoint = bundleEndpoint + "/compact"
+
+// noisyLog returns sample log interleaved with debug lines and trailing whitespace
+func noisyLog(lines int) []byte {
+	buf := &bytes.Buffer{}
+	for _, line := range strings.SplitAfter(string(sampleLog(lines)), "\n") {
+		if line == "" {
+			continue
+		}
+		buf.WriteString("level=debug msg=\"DEBUG tick\"    \n")
+		buf.WriteString(strings.TrimSuffix(line, "\n") + "   \t\n")
+	}
+	return buf.Bytes()
+}
+
+func TestParseCompactFilters(t *testing.T) {
+	filters, err := ParseCompactFilters([]string{"DEBUG", `^level=(debug|trace)\b`})
+	require.NoError(t, err)
+	assert.Len(t, filters, 2)
+
+	_, err = ParseCompactFilters([]string{"("})
+	assert.EqualError(t, err, "invalid compact filter \"(\": error parsing regexp: missing closing ): `(`")
+}
+
+func TestIfCompactShrinksBundleAndKeepsItValid(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1.json", rc: ioutil.NopCloser(bytes.NewReader([]byte(`{"DEBUG": true}`)))},
+		MockCollector{name: "logs/collector-2", rc: ioutil.NopCloser(bytes.NewReader(noisyLog(100)))},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithCompactFilters([]*regexp.Regexp{regexp.MustCompile("DEBUG")})
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleValidateEndpoint, bh.Validate).Methods(http.MethodGet)
+	router.HandleFunc(bundleCompactEndpoint, bh.Compact).Methods(http.MethodPost)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var before Bundle
+	for { // busy wait for bundle
+		before, err = bh.getBundleState("bundle-0")
+		require.NoError(t, err)
+		if before.Status == Done {
+			require.Empty(t, before.Errors)
+			break
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodPost, bundlesEndpoint+"/bundle-0/compact", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var after Bundle
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &after))
+	assert.True(t, after.Compacted)
+	assert.Less(t, after.Size, before.Size)
+	assert.NotEqual(t, before.Checksum, after.Checksum)
+
+	stat, err := os.Stat(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	assert.Equal(t, stat.Size(), after.Size)
+	checksum, err := fileChecksum(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	assert.Equal(t, checksum, after.Checksum)
+
+	state, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	assert.Equal(t, after.Checksum, state.Checksum)
+	assert.True(t, state.Compacted)
+
+	r, err := zip.OpenReader(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	defer r.Close()
+	entries := map[string]string{}
+	for _, f := range r.File {
+		entries[f.Name] = string(readZipFile(t, f))
+	}
+	assert.Equal(t, `{"DEBUG": true}`, entries["collector-1.json"], "structured entries are not filtered")
+	assert.Equal(t, string(sampleLog(100)), entries["logs/collector-2"])
+
+	req, err = http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/validate", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var report ValidationReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, ValidationReport{ID: "bundle-0", Valid: true, Entries: 3}, report)
+}
+
+func TestIfCompactRejectsBundlesThatCouldNotBeCompacted(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	for _, b := range []Bundle{
+		{ID: "in-progress", Status: InProgress},
+		{ID: "directory", Status: Done, Layout: LayoutDirectory},
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workdir, b.ID, contentsDir), dirPerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, b.ID, dataFileName), nil, filePerm))
+		_, err = bh.writeStateFile(b)
+		require.NoError(t, err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleCompactEndpoint, bh.Compact).Methods(http.MethodPost)
+
+	for id, code := range map[string]int{
+		"missing":     http.StatusNotFound,
+		"in-progress": http.StatusConflict,
+		"directory":   http.StatusBadRequest,
+	} {
+		req, err := http.NewRequest(http.MethodPost, bundlesEndpoint+"/"+id+"/compact", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, code, rr.Code, id)
+	}
+}
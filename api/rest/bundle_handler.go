@@ -1,7 +1,7 @@
 package rest
 
 import (
-	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -10,15 +10,20 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/dcos/dcos-diagnostics/collector"
+	"github.com/dcos/dcos-diagnostics/util"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,21 +31,135 @@ import (
 const (
 	stateFileName = "state.json" // file with information about diagnostics run
 	dataFileName  = "file.zip"   // data gathered by diagnostics
+	contentsDir   = "contents"   // data gathered by diagnostics when bundle has directory layout
+	// time the bundle was downloaded for the last time, kept apart so downloads never rewrite the state file
+	lastAccessFileName = "last_access"
 
 	summaryErrorsReportFileName = "summaryErrorsReport.txt" // error log in bundle
 
+	// checksumHeader carries the checksum of the data file of done zip bundles so downloads could be verified
+	checksumHeader = "X-Bundle-Checksum"
+
 	filePerm = 0600
 	dirPerm  = 0700
+
+	// defaultSetupBackoff is the wait before the first retry of failed bundle setup
+	defaultSetupBackoff = 500 * time.Millisecond
+)
+
+// createDataFile creates data files of zip bundles
+var createDataFile = os.Create
+
+// results of a single collector run reported in collector_result_total metric
+const (
+	collectorResultSuccess         = "success"
+	collectorResultFailure         = "failure"
+	collectorResultOptionalFailure = "optional_failure"
+)
+
+var (
+	collectorDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "collector_duration_seconds",
+		Help: "Time taken to run a single collector",
+	}, []string{"collector"})
+	collectorResultCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "collector_result_total",
+		Help: "Number of collector runs by result",
+	}, []string{"collector", "result"})
+)
+
+// Layout describes how bundle data is stored on the node
+type Layout string
+
+const (
+	// LayoutZip stores bundle data in a single zip file. This is the default.
+	LayoutZip Layout = "zip"
+	// LayoutDirectory stores bundle data as plain files in a directory so they could be inspected
+	// without extraction. The directory is archived on download.
+	LayoutDirectory Layout = "directory"
+)
+
+// Source tells who requested the bundle
+type Source string
+
+const (
+	// SourceManual bundles are requested by a human. This is the default.
+	SourceManual Source = "manual"
+	// SourceScheduled bundles are created by the daemon on schedule
+	SourceScheduled Source = "scheduled"
+	// SourceAutomation bundles are requested by scripts and other tools
+	SourceAutomation Source = "automation"
 )
 
+// parseSource returns the source with given name, empty name means manual
+func parseSource(name Source) (Source, error) {
+	switch name {
+	case "":
+		return SourceManual, nil
+	case SourceManual, SourceScheduled, SourceAutomation:
+		return name, nil
+	}
+	return "", fmt.Errorf("invalid source %s, expected %s, %s or %s", name, SourceManual, SourceScheduled, SourceAutomation)
+}
+
+// maxBundleIDLength keeps directories and files named after bundles within filesystem limits
+const maxBundleIDLength = 128
+
+var bundleIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validateBundleID checks the bundle ID is safe to use as a file name in the work dir
+func validateBundleID(id string) error {
+	if len(id) > maxBundleIDLength {
+		return fmt.Errorf("invalid bundle ID: longer than %d characters", maxBundleIDLength)
+	}
+	if !bundleIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid bundle ID %q: only letters, digits, dash and underscore are allowed", id)
+	}
+	return nil
+}
+
 type Bundle struct {
 	ID      string    `json:"id,omitempty"`
 	Type    Type      `json:"type"`
+	Source  Source    `json:"source,omitempty"`
+	Layout  Layout    `json:"layout,omitempty"`
+	Pinned  bool      `json:"pinned,omitempty"`
 	Size    int64     `json:"size,omitempty"` // length in bytes for regular files; 0 when Canceled or Deleted
 	Status  Status    `json:"status"`
 	Started time.Time `json:"started_at,omitempty"`
 	Stopped time.Time `json:"stopped_at,omitempty"`
 	Errors  []string  `json:"errors,omitempty"`
+	// Warnings are failures of optional collectors, they do not affect the bundle status
+	Warnings []string `json:"warnings,omitempty"`
+	// TimeRemaining is a part of the creation timeout left for bundles that are not finished yet
+	TimeRemaining string `json:"time_remaining,omitempty"`
+	// Compression is a codec used for zip entries, deflate when not set
+	Compression Compression `json:"compression,omitempty"`
+	// Checksum is a hex encoded SHA-256 of the data file computed when the bundle is done
+	Checksum string `json:"checksum,omitempty"`
+	// LastAccessed is the time the bundle was downloaded for the last time, nil if it was never downloaded
+	LastAccessed *time.Time `json:"last_accessed_at,omitempty"`
+	// Checkpoint is a size of the readable part of the data file of an incremental bundle that is not finished yet
+	Checkpoint int64 `json:"checkpoint,omitempty"`
+	// Incomplete is set when the incremental bundle creation was interrupted, only its checkpointed part could be downloaded
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Upload is the progress of the upload of the bundle to an object store, nil if it was never uploaded
+	Upload *UploadProgress `json:"upload,omitempty"`
+	// Compacted is set when the data file of the bundle was rewritten by compaction
+	Compacted bool `json:"compacted,omitempty"`
+	// OptionsHash identifies options a cluster bundle was created with so only retries with the same options
+	// get the bundle in progress
+	OptionsHash string `json:"options_hash,omitempty"`
+}
+
+// TimeRemaining returns how much of the timeout is left at now for the job started at given time.
+// It is rounded to seconds and never negative.
+func TimeRemaining(now time.Time, started time.Time, timeout time.Duration) time.Duration {
+	remaining := started.Add(timeout).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining.Round(time.Second)
 }
 
 func (b *Bundle) IsFinished() bool {
@@ -56,6 +175,8 @@ func (b *Bundle) Failed(when time.Time, why error) {
 type ErrorResponse struct {
 	Code  int    `json:"code"`
 	Error string `json:"error"`
+	// Reason is a stable code of DiagnosticsError
+	Reason string `json:"reason,omitempty"`
 }
 
 type Clock interface {
@@ -66,7 +187,8 @@ type realClock struct{}
 
 func (realClock) Now() time.Time { return time.Now() }
 
-func NewBundleHandler(workDir string, collectors []collector.Collector, timeout, collectorTimeout time.Duration) (*BundleHandler, error) {
+func NewBundleHandler(workDir string, collectors []collector.Collector, timeout, collectorTimeout time.Duration,
+	client *http.Client) (*BundleHandler, error) {
 	err := initializeWorkDir(workDir)
 	if err != nil {
 		return nil, err
@@ -74,11 +196,17 @@ func NewBundleHandler(workDir string, collectors []collector.Collector, timeout,
 
 	return &BundleHandler{
 		stateFileLock:         &sync.RWMutex{},
+		compactLock:           &sync.Mutex{},
 		clock:                 realClock{},
 		workDir:               workDir,
-		collectors:            collectors,
+		collectors:            &collectorSet{collectors: collectors},
+		stateCache:            newStateCache(DefaultStateCacheSize),
+		deadlines:             &deadlines{bundles: map[string]time.Time{}},
+		bundleLocks:           &bundleLocks{locks: map[string]*sync.Mutex{}},
 		bundleCreationTimeout: timeout,
 		collectorTimeout:      collectorTimeout,
+		client:                client,
+		setupBackoff:          defaultSetupBackoff,
 	}, nil
 }
 
@@ -86,30 +214,334 @@ func NewBundleHandler(workDir string, collectors []collector.Collector, timeout,
 // responsible for diagnostics bundle lifecycle
 type BundleHandler struct {
 	stateFileLock         *sync.RWMutex // used to synchronize access to state file
+	compactLock           *sync.Mutex   // allows only one bundle compaction at a time
 	clock                 Clock
-	workDir               string                // location where bundles are generated and stored
-	collectors            []collector.Collector // information what should be in the bundle
-	bundleCreationTimeout time.Duration         // limits how long bundle creation could take
-	collectorTimeout      time.Duration         // limits how long single collection can take
+	workDir               string                      // location where bundles are generated and stored
+	collectors            *collectorSet               // information what should be in the bundle
+	stateCache            *stateCache                 // parsed state files shared by all copies of the BundleHandler
+	deadlines             *deadlines                  // collection deadlines of bundles in progress in this process
+	bundleLocks           *bundleLocks                // serialize state updates based on the previous state
+	bundleCreationTimeout time.Duration               // limits how long bundle creation could take
+	collectorTimeout      time.Duration               // limits how long single collection can take
+	client                *http.Client                // used to fetch extra endpoints requested on bundle creation
+	compression           Compression                 // codec used for entries of zip bundles, deflate when not set
+	incremental           bool                        // keep zip bundles readable while they are created
+	events                *eventHub                   // collector events of bundles in progress, nil when disabled
+	emptyResults          EmptyResults                // handling of collectors returning no data, noted when not set
+	endpointAllowlist     collector.EndpointAllowlist // extra endpoints that could be fetched, all when empty
+	stateBatcher          *stateBatcher               // coalesces state updates of unfinished bundles, nil when disabled
+	htmlIndex             bool                        // add index.html linking collected entries to local bundles
+	setupRetries          int                         // retries of bundle setup failed e.g., on transient disk errors
+	setupBackoff          time.Duration               // wait before the first setup retry, doubled for following ones
+	compactFilters        []*regexp.Regexp            // lines removed from text entries of compacted bundles
+	minFreeInodes         uint64                      // free inodes of the work dir required to create a bundle, 0 disables
+	catalog               *Catalog                    // index of bundles updated with every state write, nil when disabled
+	capabilitiesFile      string                      // status file capabilities are checked in before collection, empty disables
+	postHook              *PostHook                   // run after every local bundle is collected, nil when disabled
+	uploader              *Uploader                   // stores bundles in an object store on request, nil when disabled
+}
+
+// collectorSet holds collectors shared by all copies of the BundleHandler
+// so they could be replaced while the daemon is running
+type collectorSet struct {
+	sync.RWMutex
+	collectors []collector.Collector
+}
+
+func (s *collectorSet) get() []collector.Collector {
+	s.RLock()
+	defer s.RUnlock()
+	return s.collectors
+}
+
+func (s *collectorSet) set(collectors []collector.Collector) {
+	s.Lock()
+	defer s.Unlock()
+	s.collectors = collectors
+}
+
+// deadlines holds collection deadlines of bundles in progress shared by all copies of the BundleHandler.
+// The collection is aborted at its context deadline so the time remaining is measured to it.
+type deadlines struct {
+	sync.Mutex
+	bundles map[string]time.Time
+}
+
+func (d *deadlines) set(id string, deadline time.Time) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.bundles[id] = deadline
+}
+
+func (d *deadlines) get(id string) (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+	d.Lock()
+	defer d.Unlock()
+	deadline, ok := d.bundles[id]
+	return deadline, ok
+}
+
+func (d *deadlines) remove(id string) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	delete(d.bundles, id)
+}
+
+// bundleLocks serialize updates of bundle states that depend on the previous state e.g., pinning and removal
+// of the data, so none of them is lost. They are shared by all copies of the BundleHandler.
+type bundleLocks struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the bundle with given ID and returns the function unlocking it
+func (l *bundleLocks) lock(id string) func() {
+	if l == nil {
+		return func() {}
+	}
+	l.Lock()
+	m, ok := l.locks[id]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[id] = m
+	}
+	l.Unlock()
+	m.Lock()
+	return m.Unlock
 }
 
 type node struct {
 	IP      net.IP `json:"ip"`
 	Role    string `json:"role"`
 	baseURL string
+	leader  bool
+	// initialRole is the role the node was discovered with when it was reached with another role during collection
+	initialRole string
+	// mesosID and hostname tell apart distinct nodes presenting the same IP
+	mesosID  string
+	hostname string
+	// key identifies the node in reports and paths of cluster bundles when its IP is shared with another node
+	key string
+}
+
+// id returns the key identifying the node in cluster bundles, its IP unless another node has the same IP
+func (n node) id() string {
+	if n.key != "" {
+		return n.key
+	}
+	return n.IP.String()
+}
+
+// roleChange returns the role change of the node found during collection, nil if its role did not change
+func (n node) roleChange() *roleChange {
+	if n.initialRole == "" || n.initialRole == n.Role {
+		return nil
+	}
+	return &roleChange{From: n.initialRole, To: n.Role}
+}
+
+// roleChange records the node was promoted or demoted after it was discovered
+type roleChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// extraEndpoint is an ad-hoc endpoint requested on bundle creation that is collected
+// in addition to the configured ones
+type extraEndpoint struct {
+	URL      string `json:"url"`
+	FileName string `json:"filename"`
+	Optional bool   `json:"optional"`
+}
+
+type bundleOptions struct {
+	ExtraEndpoints []extraEndpoint `json:"extra_endpoints"`
+	Layout         Layout          `json:"layout"`
+	Source         Source          `json:"source"`
+	// FailFast aborts the collection on the first failure of a collector that is not optional and marks
+	// the bundle Failed
+	FailFast bool `json:"fail_fast"`
+}
+
+func getBundleOptionsFromRequest(r *http.Request) (bundleOptions, error) {
+	o := bundleOptions{}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			if err != io.EOF { // Accept empty body
+				return o, err
+			}
+		}
+	}
+	return o, nil
+}
+
+// extraCollectors validates requested extra endpoints and returns collectors for them
+func (h BundleHandler) extraCollectors(endpoints []extraEndpoint) ([]collector.Collector, error) {
+	client := h.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	collectors := make([]collector.Collector, 0, len(endpoints))
+	for _, e := range endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra endpoint URL %s: %s", e.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid extra endpoint URL %s: scheme must be http or https", e.URL)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid extra endpoint URL %s: missing host", e.URL)
+		}
+
+		fileName := e.FileName
+		if fileName == "" {
+			fileName = util.SanitizeString(u.Host + u.Path)
+		}
+		if filepath.IsAbs(fileName) || strings.Contains(fileName, "..") {
+			return nil, fmt.Errorf("invalid extra endpoint filename %s", fileName)
+		}
+
+		c, err := collector.NewEndpoint(fileName, e.Optional, u.String(), client, h.endpointAllowlist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra endpoint: %s", err)
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
+
+// WithCompression sets a codec used for entries of zip bundles, deflate when not set
+func (h *BundleHandler) WithCompression(compression Compression) *BundleHandler {
+	h.compression = compression
+	return h
+}
+
+// WithIncremental makes zip bundles readable while they are created so the data collected
+// before the daemon was killed e.g., by OOM, could still be downloaded.
+func (h *BundleHandler) WithIncremental(incremental bool) *BundleHandler {
+	h.incremental = incremental
+	return h
+}
+
+// WithSetupRetries sets how many times creation of the bundle data file is retried before the bundle fails.
+// Only the setup is retried, failed collectors are reported in the bundle.
+func (h *BundleHandler) WithSetupRetries(retries int) *BundleHandler {
+	h.setupRetries = retries
+	return h
+}
+
+// WithMinFreeInodes makes bundle creation fail with 507 when the work dir filesystem has less free inodes, 0 disables it
+func (h *BundleHandler) WithMinFreeInodes(inodes uint64) *BundleHandler {
+	h.minFreeInodes = inodes
+	return h
+}
+
+// WithHTMLIndex makes local bundles contain index.html linking collected entries grouped by
+// category so the bundle could be browsed without tools.
+func (h *BundleHandler) WithHTMLIndex(htmlIndex bool) *BundleHandler {
+	h.htmlIndex = htmlIndex
+	return h
+}
+
+// WithCompactFilters sets regular expressions of lines removed from text entries of compacted bundles
+func (h *BundleHandler) WithCompactFilters(filters []*regexp.Regexp) *BundleHandler {
+	h.compactFilters = filters
+	return h
+}
+
+// WithEmptyResults sets how results of collectors that returned no data are stored in local bundles
+func (h *BundleHandler) WithEmptyResults(emptyResults EmptyResults) *BundleHandler {
+	h.emptyResults = emptyResults
+	return h
+}
+
+// WithEndpointAllowlist restricts extra endpoints requested on bundle creation to URLs allowed by the allowlist
+func (h *BundleHandler) WithEndpointAllowlist(allowlist collector.EndpointAllowlist) *BundleHandler {
+	h.endpointAllowlist = allowlist
+	return h
+}
+
+// WithStateCacheSize sets how many parsed bundle states are kept in memory, 0 disables the cache.
+func (h *BundleHandler) WithStateCacheSize(size int) *BundleHandler {
+	h.stateCache = newStateCache(size)
+	return h
+}
+
+// WithStateWriteInterval makes state updates of unfinished bundles written at most once per interval,
+// 0 writes every update. Final states are always written immediately.
+func (h *BundleHandler) WithStateWriteInterval(interval time.Duration) *BundleHandler {
+	h.stateBatcher = newStateBatcher(interval)
+	return h
+}
+
+// SetCollectors replaces collectors used for bundles created from now on.
+// Bundles that are already in progress keep using previous collectors.
+func (h BundleHandler) SetCollectors(collectors []collector.Collector) {
+	h.collectors.set(collectors)
 }
 
 func (h BundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if h.bundleExists(id) {
-		writeJSONError(w, http.StatusConflict, fmt.Errorf("bundle %s already exists", id))
+	if err := validateBundleID(id); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	options, err := getBundleOptionsFromRequest(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("could not parse request body %s", err))
+		return
+	}
+
+	extraCollectors, err := h.extraCollectors(options.ExtraEndpoints)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if options.Layout != "" && options.Layout != LayoutZip && options.Layout != LayoutDirectory {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid layout %s", options.Layout))
+		return
+	}
+
+	source, err := parseSource(options.Source)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.checkFreeInodes(); err != nil {
+		writeJSONError(w, http.StatusInsufficientStorage, err)
 		return
 	}
 
 	bundleWorkDir := filepath.Join(h.workDir, id)
-	err := os.MkdirAll(bundleWorkDir, dirPerm)
+
+	if h.bundleExists(id) {
+		if !h.isStale(id) {
+			writeDiagnosticsError(w, &DiagnosticsBundleAlreadyExists{id: id})
+			return
+		}
+		logrus.WithField("ID", id).Warn("Bundle was not finished before timeout, overwriting it")
+		if err := os.RemoveAll(bundleWorkDir); err != nil {
+			writeJSONError(w, http.StatusInsufficientStorage, fmt.Errorf("could not remove stale bundle %s: %s", id, err))
+			return
+		}
+	}
+
+	err = os.MkdirAll(bundleWorkDir, dirPerm)
 	if err != nil {
 		writeJSONError(w, http.StatusInsufficientStorage, fmt.Errorf("could not create bundle %s workdir: %s", id, err))
 		return
@@ -117,17 +549,23 @@ func (h BundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	bundle := Bundle{
 		ID:      id,
+		Source:  source,
+		Layout:  options.Layout,
 		Started: h.clock.Now(),
 		Status:  Started,
 	}
+	if bundle.Layout != LayoutDirectory {
+		bundle.Compression = h.compression
+	}
 
 	bundleStatus, err := h.writeStateFile(bundle)
 	if err != nil {
 		writeJSONError(w, http.StatusInsufficientStorage, fmt.Errorf("could not update state file %s: %s", id, err))
 		return
 	}
+	recordAudit(h.workDir, id, AuditCreate, r, bundle.Started)
 
-	dataFile, err := os.Create(filepath.Join(h.workDir, id, dataFileName))
+	dataWriter, err := h.newBundleWriterWithRetry(bundle)
 	if err != nil {
 		bundle.Status = Failed
 		bundle.Stopped = h.clock.Now()
@@ -143,84 +581,296 @@ func (h BundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	//TODO(janisz): use context cancel function to cancel bundle creation https://jira.mesosphere.com/browse/DCOS_OSS-5222
 	ctx, _ := context.WithTimeout(context.Background(), h.bundleCreationTimeout) //nolint:govet
-	done := make(chan []string)
+	done := make(chan collectResult)
+
+	collectors := append(append([]collector.Collector{}, h.collectors.get()...), extraCollectors...)
+	// capabilities are checked before the collection so the report explains failures of degraded collectors
+	var capabilitiesWarning string
+	if h.capabilitiesFile != "" {
+		report := checkCapabilities(h.capabilitiesFile, collectors)
+		capabilitiesWarning = report.warning()
+		collectors = append(collectors, capabilitiesCollector{report: report})
+	}
+	if h.events != nil {
+		h.events.start(id)
+	}
+	go collectAll(ctx, done, dataWriter, collectors, collectOptions{
+		collectorTimeout: h.collectorTimeout,
+		emptyResults:     h.emptyResults,
+		htmlIndex:        h.htmlIndex,
+		failFast:         options.FailFast,
+		publish:          h.events.publisher(id),
+	})
 
-	go collectAll(ctx, done, dataFile, h.collectors, h.collectorTimeout)
+	deadline, _ := ctx.Deadline()
+	h.deadlines.set(id, deadline)
 
 	go func() {
+		// kept until the final state is written so the status of the running bundle never reads the clock
+		defer h.deadlines.remove(id)
+		if h.events != nil {
+			defer h.events.finish(id)
+		}
 		select {
 		case <-ctx.Done():
 			break
-		case bundle.Errors = <-done:
+		case result := <-done:
+			bundle.Errors = result.errors
+			bundle.Warnings = result.warnings
+			if capabilitiesWarning != "" {
+				bundle.Warnings = append([]string{capabilitiesWarning}, bundle.Warnings...)
+			}
 			bundle.Status = Done
+			if result.failed {
+				bundle.Status = Failed
+			}
 			bundle.Stopped = h.clock.Now()
+			if bundle.Layout != LayoutDirectory {
+				checksum, err := fileChecksum(filepath.Join(h.workDir, id, dataFileName))
+				if err != nil {
+					bundle.Errors = append(bundle.Errors, err.Error())
+				}
+				bundle.Checksum = checksum
+			}
 			if _, e := h.writeStateFile(bundle); e != nil {
 				logrus.WithError(e).Errorf("Could not update state file %s", id)
 			}
+			// the hook runs once the final state is written so it could already use the bundle through the API
+			if h.postHook != nil {
+				if err := h.postHook.run(id, h.bundleDataPath(bundle)); err != nil {
+					bundle.Warnings = append(bundle.Warnings, err.Error())
+					if _, e := h.writeStateFile(bundle); e != nil {
+						logrus.WithError(e).Errorf("Could not update state file %s", id)
+					}
+				}
+			}
 		}
 	}()
 
 	write(w, bundleStatus)
 }
 
-func collectAll(ctx context.Context, done chan<- []string, dataFile io.WriteCloser,
-	collectors []collector.Collector, collectorTimeout time.Duration) {
-	zipWriter := zip.NewWriter(dataFile)
-	var errors []string
+// newBundleWriterWithRetry retries creation of the bundle writer failed e.g., because of a transient disk error,
+// waiting twice as long before every next attempt. Failures of collectors never retry the bundle.
+func (h BundleHandler) newBundleWriterWithRetry(bundle Bundle) (bundleWriter, error) {
+	backoff := h.setupBackoff
+	dataWriter, err := h.newBundleWriter(bundle)
+	for attempt := 1; err != nil && attempt <= h.setupRetries; attempt++ {
+		logrus.WithField("ID", bundle.ID).WithError(err).
+			Warnf("Could not set up bundle, retrying in %s (%d/%d)", backoff, attempt, h.setupRetries)
+		time.Sleep(backoff)
+		backoff *= 2
+		dataWriter, err = h.newBundleWriter(bundle)
+	}
+	return dataWriter, err
+}
+
+// newBundleWriter returns a writer storing bundle data according to the bundle layout
+func (h BundleHandler) newBundleWriter(bundle Bundle) (bundleWriter, error) {
+	if bundle.Layout == LayoutDirectory {
+		return newDirBundleWriter(filepath.Join(h.workDir, bundle.ID, contentsDir))
+	}
 
-	for _, c := range collectors {
+	dataFile, err := createDataFile(filepath.Join(h.workDir, bundle.ID, dataFileName))
+	if err != nil {
+		return nil, err
+	}
+	compression, err := ParseCompression(string(bundle.Compression))
+	if err != nil {
+		dataFile.Close()
+		return nil, err
+	}
+	if h.incremental {
+		return newCheckpointZipWriter(dataFile, compression, func(size int64) {
+			state := bundle
+			state.Status = InProgress
+			state.Checkpoint = size
+			if err := h.updateStateFile(state); err != nil {
+				logrus.WithField("ID", bundle.ID).WithError(err).Warn("Could not checkpoint state file")
+			}
+		}), nil
+	}
+	return newZipBundleWriter(dataFile, compression), nil
+}
+
+// collectResult holds problems found during bundle collection. Warnings are failures
+// of optional collectors and they do not make the bundle incomplete.
+type collectResult struct {
+	errors   []string
+	warnings []string
+	// failed is set when the collection was aborted by the fail fast mode
+	failed bool
+}
+
+// collectOptions configure a single run of collectAll
+type collectOptions struct {
+	collectorTimeout time.Duration // limits how long single collection can take
+	emptyResults     EmptyResults  // handling of collectors returning no data, noted when not set
+	htmlIndex        bool          // add index.html linking collected entries
+	// failFast aborts the collection on the first failure of a collector that is not optional
+	failFast bool
+	// publish is called with events of collectors, nil ignores them
+	publish func(CollectorEvent)
+}
+
+func collectAll(ctx context.Context, done chan<- collectResult, dataWriter bundleWriter,
+	collectors []collector.Collector, options collectOptions) {
+	publish := options.publish
+	if publish == nil {
+		publish = func(CollectorEvent) {}
+	}
+	var errors, warnings []string
+	var entries []manifestEntry
+	var indexEntries []indexEntry
+	// failedFast is the name of the required collector that aborted the collection in the fail fast mode
+	var failedFast string
+
+	// run the most important collectors first so they are in the bundle even if it times out
+	for _, c := range collector.SortByPriority(collectors) {
 		if ctx.Err() != nil {
-			errors = append(errors, ctx.Err().Error())
-			break
+			errors = append(errors, fmt.Sprintf("skipped %s: %s", c.Name(), ctx.Err()))
+			continue
+		}
+		if failedFast != "" {
+			errors = append(errors, fmt.Sprintf("skipped %s: fail fast after %s failed", c.Name(), failedFast))
+			continue
 		}
-		collectorCtx, cancel := context.WithTimeout(ctx, collectorTimeout) //nolint: govet
-		err := collect(collectorCtx, c, zipWriter)
+		publish(CollectorEvent{Collector: c.Name(), Status: CollectorStarted})
+		collectorCtx, cancel := context.WithTimeout(ctx, options.collectorTimeout) //nolint: govet
+		entry, warning, err := collect(collectorCtx, c, dataWriter, options.emptyResults)
 		cancel()
-		if err != nil && !c.Optional() {
-			errors = append(errors, err.Error())
+		if warning != nil {
+			warnings = append(warnings, warning.Error())
+		}
+		if empty, ok := warning.(emptyResultError); ok && empty.skipped {
+			publish(CollectorEvent{Collector: c.Name(), Status: CollectorDone})
+			continue
+		}
+		if err != nil {
+			publish(CollectorEvent{Collector: c.Name(), Status: CollectorFailed, Size: entry.Size, Error: err.Error()})
+			if c.Optional() {
+				warnings = append(warnings, err.Error())
+			} else {
+				errors = append(errors, err.Error())
+				if options.failFast {
+					failedFast = c.Name()
+				}
+			}
+			failed := indexEntry{manifestEntry: entry, Status: indexStatusFailed}
+			failed.Note = err.Error()
+			indexEntries = append(indexEntries, failed)
+			continue
+		}
+		publish(CollectorEvent{Collector: c.Name(), Status: CollectorDone, Size: entry.Size})
+		stored := indexEntry{manifestEntry: entry, Status: indexStatusOK, Stored: true}
+		if _, ok := warning.(emptyResultError); ok {
+			entry.Note = emptyResultNote
+			stored.Status, stored.Note = indexStatusEmpty, emptyResultNote
+		} else if warning != nil {
+			stored.Status, stored.Note = indexStatusFailed, warning.Error()
 		}
+		entries = append(entries, entry)
+		indexEntries = append(indexEntries, stored)
 	}
 
 	if len(errors) != 0 {
-		summaryErrorReportFile, err := zipWriter.Create(summaryErrorsReportFileName)
+		summaryErrorReportFile, err := dataWriter.Create(summaryErrorsReportFileName)
 		if err != nil {
 			errors = append(errors, err.Error())
 		} else {
-			if _, err := summaryErrorReportFile.Write([]byte(strings.Join(errors, "\n"))); err != nil {
+			summary := []byte(strings.Join(errors, "\n"))
+			if _, err := summaryErrorReportFile.Write(summary); err != nil {
 				errors = append(errors, err.Error())
+			} else {
+				entry := manifestEntry{Name: summaryErrorsReportFileName, Size: int64(len(summary))}
+				entries = append(entries, entry)
+				indexEntries = append(indexEntries, indexEntry{manifestEntry: entry, Status: indexStatusOK, Stored: true})
 			}
 		}
 	}
 
-	if err := zipWriter.Close(); err != nil {
+	if options.htmlIndex {
+		entry, err := writeIndexEntry(dataWriter, indexEntries)
+		if err != nil {
+			errors = append(errors, err.Error())
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := writeManifest(dataWriter, entries); err != nil {
 		errors = append(errors, err.Error())
 	}
-	if err := dataFile.Close(); err != nil {
+
+	if err := dataWriter.Close(); err != nil {
 		errors = append(errors, err.Error())
 	}
 
-	done <- errors
+	done <- collectResult{errors: errors, warnings: warnings, failed: failedFast != ""}
 }
 
-func collect(ctx context.Context, c collector.Collector, zipWriter *zip.Writer) error {
+// collect writes data gathered by the collector to the bundle and returns its manifest entry. When the optional collector
+// fails its error message is stored instead of data and returned as a warning. When the collector returns
+// no data emptyResultError is returned as a warning and the entry is stored or skipped according to emptyResults.
+func collect(ctx context.Context, c collector.Collector, dataWriter bundleWriter, emptyResults EmptyResults) (entry manifestEntry, warning error, err error) {
+	entry.Name = c.Name()
+	start := time.Now()
+	result := collectorResultSuccess
+	defer func() {
+		if err != nil {
+			result = collectorResultFailure
+			if c.Optional() {
+				result = collectorResultOptionalFailure
+			}
+		}
+		collectorDurationHistogram.WithLabelValues(c.Name()).Observe(time.Since(start).Seconds())
+		collectorResultCounter.WithLabelValues(c.Name(), result).Inc()
+	}()
+
 	rc, err := c.Collect(ctx)
+	if output, ok := rc.(*collector.CommandOutput); ok {
+		entry.ExitCode = &output.ExitCode
+		entry.Duration = output.Duration.String()
+	}
+	if output, ok := rc.(*collector.EndpointOutput); ok {
+		entry.Note = fmt.Sprintf("collected from %s after %s", output.URL, output.Reason)
+	}
 	if err != nil {
 		if !c.Optional() {
-			return fmt.Errorf("could not collect %s: %s", c.Name(), err)
+			return entry, nil, fmt.Errorf("could not collect %s: %s", c.Name(), err)
+		}
+		result = collectorResultOptionalFailure
+		warning = fmt.Errorf("could not collect %s: %s", c.Name(), err)
+		message := []byte(err.Error())
+		if output, ok := rc.(*collector.CommandOutput); ok {
+			// keep the output of the failed command, its header tells the exit code
+			rc = ioutil.NopCloser(io.MultiReader(bytes.NewReader(append(message, '\n')), output))
+		} else {
+			rc = ioutil.NopCloser(bytes.NewReader(message))
 		}
-		rc = ioutil.NopCloser(bytes.NewReader([]byte(err.Error())))
 	}
 	defer rc.Close()
 
-	zipFile, err := zipWriter.Create(c.Name())
+	data := bufio.NewReader(rc)
+	if warning == nil {
+		if _, e := data.Peek(1); e == io.EOF {
+			warning = emptyResultError{name: c.Name(), skipped: emptyResults == EmptyResultsSkip}
+			if emptyResults == EmptyResultsSkip {
+				return entry, warning, nil
+			}
+		}
+	}
+
+	zipFile, err := dataWriter.Create(c.Name())
 	if err != nil {
-		return fmt.Errorf("could not create a %s in the zip: %s", c.Name(), err)
+		return entry, warning, fmt.Errorf("could not create a %s in the zip: %s", c.Name(), err)
 	}
-	if _, err := io.Copy(zipFile, rc); err != nil {
-		return fmt.Errorf("could not copy %s data to zip: %s", c.Name(), err)
+	entry.Size, err = io.Copy(zipFile, data)
+	if err != nil {
+		return entry, warning, fmt.Errorf("could not copy %s data to zip: %s", c.Name(), err)
 	}
 
-	return nil
+	return entry, warning, nil
 }
 
 func (h BundleHandler) Get(w http.ResponseWriter, r *http.Request) {
@@ -257,17 +907,51 @@ func (h BundleHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if bundle.Status != Done {
+	if bundle.Status != Done && !bundle.Incomplete {
 		writeJSONError(w, http.StatusNotFound,
 			fmt.Errorf("bundle %s is not done yet (status %s), try again later", bundle.ID, bundle.Status))
 		return
 	}
 
+	now := h.clock.Now()
+	recordAudit(h.workDir, id, AuditDownload, r, now)
+	h.touch(id, now)
+
+	if bundle.Incomplete {
+		w.Header().Add("Content-Type", "application/zip, application/octet-stream")
+		w.Header().Add("Content-disposition", fmt.Sprintf("attachment; filename=%s.zip", id))
+		h.serveCheckpoint(w, r, bundle)
+		return
+	}
+
+	if bundle.Layout == LayoutDirectory {
+		w.Header().Add("Content-Type", "application/gzip, application/octet-stream")
+		w.Header().Add("Content-disposition", fmt.Sprintf("attachment; filename=%s.tar.gz", id))
+		if err := writeTarGz(w, filepath.Join(h.workDir, id, contentsDir)); err != nil {
+			logrus.WithField("ID", id).WithError(err).Error("Could not archive bundle")
+		}
+		return
+	}
+
 	w.Header().Add("Content-Type", "application/zip, application/octet-stream")
 	w.Header().Add("Content-disposition", fmt.Sprintf("attachment; filename=%s.zip", id))
+	if bundle.Checksum != "" {
+		w.Header().Set(checksumHeader, bundle.Checksum)
+	}
 	http.ServeFile(w, r, filepath.Join(h.workDir, id, dataFileName))
 }
 
+// serveCheckpoint serves the readable part of the data file of the incomplete bundle
+func (h BundleHandler) serveCheckpoint(w http.ResponseWriter, r *http.Request, bundle Bundle) {
+	dataFile, err := os.Open(filepath.Join(h.workDir, bundle.ID, dataFileName))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not open data file %s: %s", bundle.ID, err))
+		return
+	}
+	defer dataFile.Close()
+	http.ServeContent(w, r, dataFileName, bundle.Started, io.NewSectionReader(dataFile, 0, bundle.Checkpoint))
+}
+
 func (h BundleHandler) List(w http.ResponseWriter, r *http.Request) {
 	ids, err := ioutil.ReadDir(h.workDir)
 	if err != nil {
@@ -275,8 +959,10 @@ func (h BundleHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bundles := make([]Bundle, 0, len(ids))
-
+	// bundles are encoded one by one as their states are read so they are never all held in memory
+	write(w, []byte("["))
+	encoder := json.NewEncoder(w)
+	first := true
 	for _, id := range ids {
 		if !id.IsDir() {
 			continue
@@ -286,11 +972,16 @@ func (h BundleHandler) List(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			logrus.WithField("ID", id.Name()).WithError(err).Warn("There is a problem with the bundle")
 		}
-		bundles = append(bundles, bundle)
-
+		if !first {
+			write(w, []byte(","))
+		}
+		first = false
+		if err := encoder.Encode(bundle); err != nil {
+			logrus.WithField("ID", id.Name()).WithError(err).Error("Could not write bundle to the list")
+			return
+		}
 	}
-
-	write(w, jsonMarshal(bundles))
+	write(w, []byte("]"))
 }
 
 func (h BundleHandler) getBundleState(id string) (Bundle, error) {
@@ -299,17 +990,36 @@ func (h BundleHandler) getBundleState(id string) (Bundle, error) {
 		Status: Unknown,
 	}
 
-	rawState, err := h.readStateFile(bundle)
+	bundle, err := h.loadStateFile(bundle)
 	if err != nil {
-		return bundle, fmt.Errorf("could not read state file for bundle %s: %s", id, err)
+		return bundle, err
 	}
+	h.loadLastAccess(&bundle)
 
-	err = json.Unmarshal(rawState, &bundle)
-	if err != nil {
-		return bundle, fmt.Errorf("could not unmarshal state file %s: %s", id, err)
+	if bundle.Status == Deleted || bundle.Status == Canceled || bundle.Status == Unknown || bundle.Status == Failed {
+		return bundle, nil
 	}
 
-	if bundle.Status == Deleted || bundle.Status == Canceled || bundle.Status == Unknown || bundle.Status == Failed {
+	if bundle.Status == Started || bundle.Status == InProgress {
+		var remaining time.Duration
+		if deadline, ok := h.deadlines.get(id); ok {
+			remaining = TimeRemaining(time.Now(), deadline, 0)
+		} else {
+			// the bundle is not collected by this process e.g., it was started before the restart
+			remaining = TimeRemaining(h.clock.Now(), bundle.Started, h.bundleCreationTimeout)
+		}
+		bundle.TimeRemaining = remaining.String()
+		// the bundle should be finished by now so the creation was interrupted
+		bundle.Incomplete = bundle.Checkpoint > 0 && remaining == 0
+	}
+
+	if bundle.Layout == LayoutDirectory {
+		size, err := dirSize(filepath.Join(h.workDir, id, contentsDir))
+		if err != nil {
+			bundle.Status = Unknown
+			return bundle, fmt.Errorf("could not stat data directory %s: %s", id, err)
+		}
+		bundle.Size = size
 		return bundle, nil
 	}
 
@@ -335,6 +1045,28 @@ func (h BundleHandler) bundleExists(id string) bool {
 	return true
 }
 
+// isStale returns true if the existing bundle is not finished and was started earlier than
+// the bundle creation timeout ago. It happens when the daemon was restarted during bundle creation.
+// Bundles with missing or invalid state are never stale.
+func (h BundleHandler) isStale(id string) bool {
+	rawState, err := h.readStateFile(Bundle{ID: id})
+	if err != nil {
+		return false
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(rawState, &bundle); err != nil {
+		return false
+	}
+
+	switch bundle.Status {
+	case Done, Canceled, Deleted, Failed:
+		return false
+	}
+
+	return h.clock.Now().Sub(bundle.Started) > h.bundleCreationTimeout
+}
+
 func (h BundleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -344,6 +1076,9 @@ func (h BundleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unlock := h.bundleLocks.lock(id)
+	defer unlock()
+
 	bundle, err := h.getBundleState(id)
 	if err != nil {
 		logrus.WithField("ID", id).WithError(err).Warn("There is a problem with the bundle")
@@ -361,7 +1096,7 @@ func (h BundleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	//TODO(janisz): Handle Canceled Status https://jira.mesosphere.com/browse/DCOS_OSS-5222
 
-	err = os.Remove(filepath.Join(h.workDir, id, dataFileName))
+	err = h.removeData(bundle)
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not delete bundle %s: %s", id, err))
 		return
@@ -374,18 +1109,139 @@ func (h BundleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 			fmt.Errorf("bundle %s was deleted but state could not be updated: %s", id, err))
 		return
 	}
+	recordAudit(h.workDir, id, AuditDelete, r, h.clock.Now())
+	write(w, newRawState)
+}
+
+// Pin marks the bundle as pinned so it is never removed by retention
+func (h BundleHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, true)
+}
+
+// Unpin removes the pin so the bundle could be removed by retention again
+func (h BundleHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, false)
+}
+
+func (h BundleHandler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !h.bundleExists(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	unlock := h.bundleLocks.lock(id)
+	defer unlock()
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// state of unfinished bundle is updated on completion so the pin would be lost
+	if !bundle.IsFinished() {
+		writeJSONError(w, http.StatusConflict,
+			fmt.Errorf("bundle %s is not finished yet (status %s), try again later", id, bundle.Status))
+		return
+	}
+
+	bundle.Pinned = pinned
+	newRawState, err := h.writeStateFile(bundle)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not update state file %s: %s", id, err))
+		return
+	}
 	write(w, newRawState)
 }
 
+// touch records that the bundle was accessed at given time so retention keeps it for a while. The time is stored
+// in its own file so the state file, that might be updated at the same time e.g., by pinning, is never rewritten.
+func (h BundleHandler) touch(id string, now time.Time) {
+	path := filepath.Join(h.workDir, id, lastAccessFileName)
+	if err := writeFileAtomically(path, []byte(now.UTC().Format(time.RFC3339Nano))); err != nil {
+		logrus.WithField("ID", id).WithError(err).Warn("Could not update last access time")
+	}
+}
+
+// loadLastAccess sets the last access time of the bundle recorded by touch. Times stored in the state file
+// by older versions are kept unless the bundle was accessed later.
+func (h BundleHandler) loadLastAccess(bundle *Bundle) {
+	raw, err := ioutil.ReadFile(filepath.Join(h.workDir, bundle.ID, lastAccessFileName))
+	if err != nil {
+		return
+	}
+	lastAccessed, err := time.Parse(time.RFC3339Nano, string(raw))
+	if err != nil {
+		logrus.WithField("ID", bundle.ID).WithError(err).Debug("Could not parse last access time")
+		return
+	}
+	if bundle.LastAccessed == nil || lastAccessed.After(*bundle.LastAccessed) {
+		bundle.LastAccessed = &lastAccessed
+	}
+}
+
+// removeData removes data gathered for the bundle leaving its state untouched
+func (h BundleHandler) removeData(bundle Bundle) error {
+	if bundle.Layout == LayoutDirectory {
+		return os.RemoveAll(filepath.Join(h.workDir, bundle.ID, contentsDir))
+	}
+	return os.Remove(filepath.Join(h.workDir, bundle.ID, dataFileName))
+}
+
+// writeStateFile writes the state of the bundle immediately replacing its pending update
 func (h BundleHandler) writeStateFile(bundle Bundle) ([]byte, error) {
+	return h.stateBatcher.writeNow(bundle, h.writeStateFileNow)
+}
+
+// updateStateFile records the progress of the unfinished bundle, the update might be written later
+// together with following ones
+func (h BundleHandler) updateStateFile(bundle Bundle) error {
+	return h.stateBatcher.update(bundle, h.writeStateFileNow)
+}
+
+func (h BundleHandler) writeStateFileNow(bundle Bundle) ([]byte, error) {
 	stateFilePath := filepath.Join(h.workDir, bundle.ID, stateFileName)
 	newRawState := jsonMarshal(bundle)
 	h.stateFileLock.Lock()
 	err := ioutil.WriteFile(stateFilePath, newRawState, filePerm)
+	h.stateCache.remove(bundle.ID)
 	h.stateFileLock.Unlock()
+	if err == nil {
+		recordInCatalog(h.catalog, h.workDir, bundle)
+	}
 	return newRawState, err
 }
 
+// loadStateFile returns the bundle updated with its state file. Parsed states are cached
+// until the state file changes so listing many bundles does not read all of them.
+func (h BundleHandler) loadStateFile(bundle Bundle) (Bundle, error) {
+	stateFilePath := filepath.Join(h.workDir, bundle.ID, stateFileName)
+	h.stateFileLock.RLock()
+	defer h.stateFileLock.RUnlock()
+
+	info, err := os.Stat(stateFilePath)
+	if err != nil {
+		return bundle, fmt.Errorf("could not read state file for bundle %s: %s", bundle.ID, err)
+	}
+	if cached, ok := h.stateCache.get(bundle.ID, info); ok {
+		return cached, nil
+	}
+
+	rawState, err := ioutil.ReadFile(stateFilePath)
+	if err != nil {
+		return bundle, fmt.Errorf("could not read state file for bundle %s: %s", bundle.ID, err)
+	}
+	if err := json.Unmarshal(rawState, &bundle); err != nil {
+		return bundle, fmt.Errorf("could not unmarshal state file %s: %s", bundle.ID, err)
+	}
+	h.stateCache.put(bundle.ID, info, bundle)
+
+	return bundle, nil
+}
+
 func (h BundleHandler) readStateFile(bundle Bundle) ([]byte, error) {
 	stateFilePath := filepath.Join(h.workDir, bundle.ID, stateFileName)
 	h.stateFileLock.RLock()
@@ -395,6 +1251,9 @@ func (h BundleHandler) readStateFile(bundle Bundle) ([]byte, error) {
 
 func writeJSONError(w http.ResponseWriter, code int, e error) {
 	resp := ErrorResponse{Code: code, Error: e.Error()}
+	if d, ok := e.(DiagnosticsError); ok {
+		resp.Reason = d.Code()
+	}
 	body := jsonMarshal(resp)
 
 	if e != nil {
@@ -1,9 +1,12 @@
 package rest
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +14,8 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +23,8 @@ import (
 	diagio "github.com/dcos/dcos-diagnostics/io"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,7 +44,7 @@ func TestIfReturnsEmptyListWhenDirIsEmpty(t *testing.T) {
 	defer os.RemoveAll(workdir)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -62,7 +69,7 @@ func TestIfReturnsEmptyListWhenDirIsEmptyContainsNoDirs(t *testing.T) {
 	_, err = ioutil.TempFile(workdir, "")
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -89,7 +96,7 @@ func TestIfDirsAsBundlesIdsWithStatusUnknown(t *testing.T) {
 		require.NoError(t, err)
 	}
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -144,7 +151,7 @@ func TestIfListShowsStatusWithoutAFile(t *testing.T) {
 		"stopped_at":"2019-05-21T00:00:00Z" }`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -174,7 +181,7 @@ func TestIfListWorksWithoutBundleDir(t *testing.T) {
 	err = os.RemoveAll(workdir)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -206,7 +213,7 @@ func TestIfShowsStatusWithoutAFileButStatusDoneShouldChangeStatusToUnknown(t *te
 		"stopped_at":"2019-05-21T00:00:00Z" }`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -249,7 +256,7 @@ func TestIfShowsStatusWithFileAndDontUpdatesFileSize(t *testing.T) {
 	err = ioutil.WriteFile(filepath.Join(bundleWorkDir, dataFileName), []byte(`OK`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
@@ -295,7 +302,7 @@ func TestIfGetShowsStatusWithoutAFileWhenBundleIsDeleted(t *testing.T) {
 		"stopped_at":"2019-05-21T00:00:00Z" }`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle", nil)
@@ -335,7 +342,7 @@ func TestIfGetShowsStatusWithoutAFileWhenBundleIsDone(t *testing.T) {
 		"stopped_at":"2019-05-21T00:00:00Z" }`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle", nil)
@@ -367,7 +374,7 @@ func TestIfGetReturns500WhenBundleStateIsNotJson(t *testing.T) {
 		[]byte(`invalid JSON`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-state-not-json", nil)
@@ -399,7 +406,7 @@ func TestIfDeleteReturns404WhenNoBundleFound(t *testing.T) {
 	defer os.RemoveAll(workdir)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Nanosecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Nanosecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/not-existing-bundle", nil)
@@ -425,7 +432,7 @@ func TestIfDeleteReturns500WhenNoBundleStateFound(t *testing.T) {
 	err = os.Mkdir(bundleWorkDir, dirPerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/not-existing-bundle-state", nil)
@@ -457,7 +464,7 @@ func TestIfDeleteReturns500WhenBundleStateIsNotJson(t *testing.T) {
 		[]byte(`invalid JSON`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/bundle-state-not-json", nil)
@@ -503,7 +510,7 @@ func TestIfDeleteReturns200WhenBundleWasDeletedBefore(t *testing.T) {
 	err = ioutil.WriteFile(stateFilePath, []byte(bundleState), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/deleted-bundle", nil)
@@ -535,7 +542,7 @@ func TestIfDeleteReturns500WhenBundleFileIsMissing(t *testing.T) {
 		"stopped_at":"2019-05-21T00:00:00Z" }`)), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/missing-data-file", nil)
@@ -575,7 +582,7 @@ func TestIfDeleteReturns200WhenBundleWasDeleted(t *testing.T) {
 	err = ioutil.WriteFile(filepath.Join(bundleWorkDir, dataFileName), []byte(`OK`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/bundle-0", nil)
@@ -621,7 +628,7 @@ func TestIfGetFileReturnsBundle(t *testing.T) {
 		[]byte(`OK`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle", nil)
@@ -664,7 +671,7 @@ func TestIfGetFileReturns404WhenBundleIsStarted(t *testing.T) {
 		[]byte(`OK`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle", nil)
@@ -709,7 +716,7 @@ func TestIfGetFileReturns410WhenBundleIsNotDone(t *testing.T) {
 		[]byte(`OK`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle", nil)
@@ -734,7 +741,7 @@ func TestIfGetFileReturnsErrorWhenBundleDoesNotExists(t *testing.T) {
 	defer os.RemoveAll(workdir)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle", nil)
@@ -769,7 +776,7 @@ func TestIfCreateReturns409WhenBundleWithGivenIdAlreadyExists(t *testing.T) {
 	err = ioutil.WriteFile(filepath.Join(bundleWorkDir, dataFileName), []byte(`OK`), filePerm)
 	require.NoError(t, err)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
@@ -782,10 +789,180 @@ func TestIfCreateReturns409WhenBundleWithGivenIdAlreadyExists(t *testing.T) {
 	router.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusConflict, rr.Code)
-	assert.JSONEq(t, `{"code":409,"error":"bundle bundle-0 already exists"}`, rr.Body.String())
+	assert.JSONEq(t, `{"code":409,"error":"bundle bundle-0 already exists","reason":"bundle_already_exists"}`, rr.Body.String())
 
 }
 
+func TestIfCreateOverwritesStaleBundle(t *testing.T) {
+	t.Parallel()
+	workdir, err := ioutil.TempDir("", "work-dir")
+	defer os.RemoveAll(workdir)
+	require.NoError(t, err)
+	bundleWorkDir := filepath.Join(workdir, "bundle-0")
+	err = os.MkdirAll(filepath.Join(bundleWorkDir, contentsDir), dirPerm)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(bundleWorkDir, stateFileName), []byte(`{
+		"id": "bundle-0",
+		"status": "InProgress",
+		"layout": "directory",
+		"started_at":"1991-05-21T00:00:00Z" }`), filePerm)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(bundleWorkDir, contentsDir, "partial"), []byte(`OK`), filePerm)
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Minute, collectorTimeout, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	bundle := Bundle{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+	assert.Equal(t, "bundle-0", bundle.ID)
+	assert.Equal(t, Started, bundle.Status)
+	assert.Empty(t, bundle.Layout)
+	assert.True(t, bundle.Started.After(time.Now().Add(-time.Minute)))
+	assert.NoDirExists(t, filepath.Join(bundleWorkDir, contentsDir))
+}
+
+func TestIfGetReturnsDecreasingTimeRemainingForBundleInProgress(t *testing.T) {
+	t.Parallel()
+	workdir, err := ioutil.TempDir("", "work-dir")
+	defer os.RemoveAll(workdir)
+	require.NoError(t, err)
+
+	started := time.Date(2019, 5, 21, 0, 0, 0, 0, time.UTC)
+	bundleWorkDir := filepath.Join(workdir, "bundle-0")
+	require.NoError(t, os.Mkdir(bundleWorkDir, dirPerm))
+	state := jsonMarshal(Bundle{ID: "bundle-0", Status: InProgress, Started: started})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bundleWorkDir, stateFileName), state, filePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bundleWorkDir, dataFileName), []byte(`OK`), filePerm))
+
+	bh, err := NewBundleHandler(workdir, nil, 5*time.Hour, collectorTimeout, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: started} // every read moves the clock one hour forward
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Get)
+
+	for _, expected := range []string{"4h0m0s", "3h0m0s"} {
+		req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		bundle := Bundle{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+		assert.Equal(t, InProgress, bundle.Status)
+		assert.Equal(t, expected, bundle.TimeRemaining)
+	}
+
+	rawState, err := ioutil.ReadFile(filepath.Join(bundleWorkDir, stateFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(rawState), "time_remaining")
+}
+
+func TestIfGetReturnsDecreasingTimeRemainingForRunningBundleWithoutReadingClock(t *testing.T) {
+	t.Parallel()
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	data, collected := io.Pipe()
+	collectors := []collector.Collector{MockCollector{name: "collector", rc: data}}
+	bh, err := NewBundleHandler(workdir, collectors, time.Hour, time.Hour, nil)
+	require.NoError(t, err)
+	started := time.Date(2019, 5, 21, 0, 0, 0, 0, time.UTC)
+	clock := &MockClock{now: started}
+	bh.clock = clock
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleEndpoint, bh.Get).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	getBundle := func() Bundle {
+		req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		bundle := Bundle{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+		return bundle
+	}
+
+	first, err := time.ParseDuration(getBundle().TimeRemaining)
+	require.NoError(t, err)
+	time.Sleep(time.Second)
+	second, err := time.ParseDuration(getBundle().TimeRemaining)
+	require.NoError(t, err)
+
+	assert.True(t, first <= time.Hour, first)
+	assert.True(t, second < first, "%s is not less than %s", second, first)
+	// only the start of the bundle was read from the clock
+	clock.Lock()
+	assert.Equal(t, started.Add(time.Hour), clock.now)
+	clock.Unlock()
+
+	require.NoError(t, collected.Close())
+	require.Eventually(t, func() bool {
+		return getBundle().Status == Done
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, getBundle().TimeRemaining)
+}
+
+func TestTimeRemaining(t *testing.T) {
+	started := time.Date(2019, 5, 21, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Hour, TimeRemaining(started, started, time.Hour))
+	assert.Equal(t, 30*time.Minute, TimeRemaining(started.Add(30*time.Minute+100*time.Millisecond), started, time.Hour))
+	assert.Equal(t, time.Duration(0), TimeRemaining(started.Add(2*time.Hour), started, time.Hour))
+}
+
+func TestIfCreateReturns409WhenBundleIsInProgress(t *testing.T) {
+	t.Parallel()
+	workdir, err := ioutil.TempDir("", "work-dir")
+	defer os.RemoveAll(workdir)
+	require.NoError(t, err)
+	bundleWorkDir := filepath.Join(workdir, "bundle-0")
+	err = os.Mkdir(bundleWorkDir, dirPerm)
+	require.NoError(t, err)
+	state := jsonMarshal(Bundle{ID: "bundle-0", Status: InProgress, Started: time.Now()})
+	err = ioutil.WriteFile(filepath.Join(bundleWorkDir, stateFileName), state, filePerm)
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Hour, collectorTimeout, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.JSONEq(t, `{"code":409,"error":"bundle bundle-0 already exists","reason":"bundle_already_exists"}`, rr.Body.String())
+	rawState, err := ioutil.ReadFile(filepath.Join(bundleWorkDir, stateFileName))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(state), string(rawState))
+}
+
 func TestIfCreateReturns507WhenCouldNotCreateWorkDir(t *testing.T) {
 	t.Parallel()
 	workdir, err := ioutil.TempDir("", "work-dir")
@@ -794,7 +971,7 @@ func TestIfCreateReturns507WhenCouldNotCreateWorkDir(t *testing.T) {
 	bundleWorkDir := filepath.Join(workdir, "bundle-0")
 	err = ioutil.WriteFile(bundleWorkDir, []byte{}, 0000)
 
-	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
@@ -810,6 +987,395 @@ func TestIfCreateReturns507WhenCouldNotCreateWorkDir(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), `{"code":507,"error":"could not create bundle bundle-0 workdir: `)
 }
 
+func TestIfCreateReturns400WhenExtraEndpointSchemeIsNotHTTP(t *testing.T) {
+	t.Parallel()
+	workdir, err := ioutil.TempDir("", "work-dir")
+	defer os.RemoveAll(workdir)
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0",
+		bytes.NewReader([]byte(`{"extra_endpoints": [{"url": "file:///etc/passwd", "filename": "passwd"}]}`)))
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code":400,"error":"invalid extra endpoint URL file:///etc/passwd: scheme must be http or https"}`, rr.Body.String())
+	assert.NoDirExists(t, filepath.Join(workdir, "bundle-0"))
+}
+
+func TestIfCreateReturns400WhenExtraEndpointIsNotAllowed(t *testing.T) {
+	t.Parallel()
+	workdir, err := ioutil.TempDir("", "work-dir")
+	defer os.RemoveAll(workdir)
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
+	require.NoError(t, err)
+	bh.WithEndpointAllowlist(collector.EndpointAllowlist{"*.mesos:*"})
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0",
+		bytes.NewReader([]byte(`{"extra_endpoints": [{"url": "http://169.254.169.254/latest", "filename": "metadata"}]}`)))
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code":400,"error":"invalid extra endpoint: endpoint URL http://169.254.169.254/latest is not allowed, `+
+		`169.254.169.254:80 does not match endpoint allowlist [*.mesos:*]"}`, rr.Body.String())
+	assert.NoDirExists(t, filepath.Join(workdir, "bundle-0"))
+}
+
+func TestIfCreateCollectsExtraEndpoints(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	extra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"extra": "content"}`))
+	}))
+	defer extra.Close()
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, time.Second, extra.Client())
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleEndpoint, bh.Get).Methods(http.MethodGet)
+
+	body := fmt.Sprintf(`{"extra_endpoints": [{"url": "%s/extra", "filename": "extra.json"}]}`, extra.URL)
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	for { // busy wait for bundle
+		bundle, err := bh.getBundleState("bundle-0")
+		require.NoError(t, err)
+		if bundle.Status == Done {
+			assert.Empty(t, bundle.Errors)
+			break
+		}
+	}
+
+	reader, err := zip.OpenReader(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.Len(t, reader.File, 3)
+	assert.Equal(t, "collector-1", reader.File[0].Name)
+	assert.Equal(t, "extra.json", reader.File[1].Name)
+	assert.Equal(t, manifestFileName, reader.File[2].Name)
+
+	rc, err := reader.File[1].Open()
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, `{"extra": "content"}`, string(content))
+}
+
+func TestIfCreateReturns400WhenLayoutIsInvalid(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", bytes.NewReader([]byte(`{"layout": "tar"}`)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code": 400, "error": "invalid layout tar"}`, rr.Body.String())
+	assert.False(t, bh.bundleExists("bundle-0"))
+}
+
+func TestIfCreateStoresBundleSource(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleEndpoint, bh.Get).Methods(http.MethodGet)
+	router.HandleFunc(bundlesEndpoint, bh.List).Methods(http.MethodGet)
+
+	for body, expected := range map[string]Source{
+		`{"source": "scheduled"}`:  SourceScheduled,
+		`{"source": "automation"}`: SourceAutomation,
+		`{}`:                       SourceManual,
+	} {
+		id := "bundle-" + string(expected)
+		req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/"+id, bytes.NewReader([]byte(body)))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var bundle Bundle
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+		assert.Equal(t, expected, bundle.Source, body)
+
+		req, err = http.NewRequest(http.MethodGet, bundlesEndpoint+"/"+id, nil)
+		require.NoError(t, err)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+		assert.Equal(t, expected, bundle.Source, body)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var bundles []Bundle
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundles))
+	sources := map[string]Source{}
+	for _, b := range bundles {
+		sources[b.ID] = b.Source
+	}
+	assert.Equal(t, map[string]Source{
+		"bundle-scheduled":  SourceScheduled,
+		"bundle-automation": SourceAutomation,
+		"bundle-manual":     SourceManual,
+	}, sources)
+
+	req, err = http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", bytes.NewReader([]byte(`{"source": "cron"}`)))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code": 400, "error": "invalid source cron, expected manual, scheduled or automation"}`, rr.Body.String())
+}
+
+func TestIfCreateWithDirectoryLayoutStoresPlainFiles(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		MockCollector{name: "logs/collector-2", rc: ioutil.NopCloser(bytes.NewReader([]byte("some logs")))},
+		MockCollector{name: "collector-3", err: fmt.Errorf("some error")},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleEndpoint, bh.Get).Methods(http.MethodGet)
+	router.HandleFunc(bundleEndpoint, bh.Delete).Methods(http.MethodDelete)
+	router.HandleFunc(bundleFileEndpoint, bh.GetFile).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", bytes.NewReader([]byte(`{"layout": "directory"}`)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var bundle Bundle
+	for { // busy wait for bundle
+		bundle, err = bh.getBundleState("bundle-0")
+		require.NoError(t, err)
+		if bundle.Status == Done {
+			break
+		}
+	}
+	assert.Equal(t, LayoutDirectory, bundle.Layout)
+	assert.Equal(t, []string{"could not collect collector-3: some error"}, bundle.Errors)
+	manifest := `{"entries":[{"name":"collector-1","size":2},{"name":"logs/collector-2","size":9},` +
+		`{"name":"summaryErrorsReport.txt","size":41}]}`
+	assert.Equal(t, int64(len("OK")+len("some logs")+len("could not collect collector-3: some error")+len(manifest)), bundle.Size)
+	assert.Empty(t, bundle.Checksum)
+
+	assert.NoFileExists(t, filepath.Join(workdir, "bundle-0", dataFileName))
+	var files []string
+	contents := filepath.Join(workdir, "bundle-0", contentsDir)
+	err = filepath.Walk(contents, func(path string, info os.FileInfo, err error) error {
+		if info.Mode().IsRegular() {
+			name, _ := filepath.Rel(contents, path)
+			files = append(files, filepath.ToSlash(name))
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"collector-1", "logs/collector-2", manifestFileName, summaryErrorsReportFileName}, files)
+
+	req, err = http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/file", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "attachment; filename=bundle-0.tar.gz", rr.Header().Get("Content-disposition"))
+
+	gzipReader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	archived := map[string]string{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tarReader)
+		require.NoError(t, err)
+		archived[header.Name] = string(content)
+	}
+	assert.Equal(t, map[string]string{
+		"collector-1":               "OK",
+		"logs/collector-2":          "some logs",
+		manifestFileName:            manifest,
+		summaryErrorsReportFileName: "could not collect collector-3: some error",
+	}, archived)
+
+	req, err = http.NewRequest(http.MethodDelete, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NoDirExists(t, contents)
+}
+
+func TestCollectAllUpdatesCollectorMetrics(t *testing.T) {
+	dataFile, err := ioutil.TempFile("", "*.zip")
+	require.NoError(t, err)
+	defer os.Remove(dataFile.Name())
+
+	collectors := []collector.Collector{
+		MockCollector{name: "metrics-ok", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		MockCollector{name: "metrics-failed", err: fmt.Errorf("some error")},
+		MockCollector{name: "metrics-optional", err: fmt.Errorf("some other error"), optional: true},
+	}
+
+	// metrics are global so only their changes made by this collection are checked
+	counterValue := func(name, result string) float64 {
+		m := &dto.Metric{}
+		require.NoError(t, collectorResultCounter.WithLabelValues(name, result).Write(m))
+		return m.GetCounter().GetValue()
+	}
+	sampleCount := func(name string) uint64 {
+		m := &dto.Metric{}
+		require.NoError(t, collectorDurationHistogram.WithLabelValues(name).(prometheus.Histogram).Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	counters := map[[2]string]float64{}
+	for _, labels := range [][2]string{
+		{"metrics-ok", collectorResultSuccess},
+		{"metrics-failed", collectorResultFailure},
+		{"metrics-optional", collectorResultOptionalFailure},
+		{"metrics-optional", collectorResultSuccess},
+	} {
+		counters[labels] = counterValue(labels[0], labels[1])
+	}
+	samples := map[string]uint64{}
+	for _, c := range collectors {
+		samples[c.Name()] = sampleCount(c.Name())
+	}
+
+	done := make(chan collectResult, 1)
+	collectAll(context.Background(), done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: time.Second})
+	assert.Equal(t, []string{"could not collect metrics-failed: some error"}, (<-done).errors)
+
+	delta := func(name, result string) float64 {
+		return counterValue(name, result) - counters[[2]string{name, result}]
+	}
+	assert.Equal(t, 1., delta("metrics-ok", collectorResultSuccess))
+	assert.Equal(t, 1., delta("metrics-failed", collectorResultFailure))
+	assert.Equal(t, 1., delta("metrics-optional", collectorResultOptionalFailure))
+	assert.Equal(t, 0., delta("metrics-optional", collectorResultSuccess))
+
+	for _, c := range collectors {
+		assert.Equal(t, uint64(1), sampleCount(c.Name())-samples[c.Name()], c.Name())
+	}
+}
+
+func TestCollectAllReportsOptionalCollectorFailuresAsWarnings(t *testing.T) {
+	dataFile, err := ioutil.TempFile("", "*.zip")
+	require.NoError(t, err)
+	defer os.Remove(dataFile.Name())
+
+	collectors := []collector.Collector{
+		MockCollector{name: "ok", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		MockCollector{name: "optional", err: fmt.Errorf("some error"), optional: true},
+		MockCollector{name: "optional-slow", rc: slowReader{delay: time.Millisecond}, optional: true},
+	}
+
+	done := make(chan collectResult, 1)
+	collectAll(context.Background(), done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: 10 * time.Millisecond})
+	assert.Equal(t, collectResult{
+		warnings: []string{
+			"could not collect optional: some error",
+			"could not copy optional-slow data to zip: context deadline exceeded",
+		},
+	}, <-done)
+
+	reader, err := zip.OpenReader(dataFile.Name())
+	require.NoError(t, err)
+	defer reader.Close()
+	for _, f := range reader.File {
+		assert.NotEqual(t, summaryErrorsReportFileName, f.Name)
+		if f.Name == "optional" {
+			assert.Equal(t, "some error", string(readZipFile(t, f)))
+		}
+	}
+}
+
+func TestCollectAllRunsHighPriorityCollectorsFirst(t *testing.T) {
+	dataFile, err := ioutil.TempFile("", "*.zip")
+	require.NoError(t, err)
+	defer os.Remove(dataFile.Name())
+
+	collectors := []collector.Collector{
+		MockCollector{name: "slow", rc: slowReader{delay: time.Millisecond}},
+		MockCollector{name: "low", rc: ioutil.NopCloser(bytes.NewReader([]byte("low")))},
+		collector.WithPriority(MockCollector{name: "high", rc: ioutil.NopCloser(bytes.NewReader([]byte("high")))}, collector.PriorityHigh),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan collectResult, 1)
+	collectAll(ctx, done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: time.Minute})
+	assert.Equal(t, []string{
+		"could not copy slow data to zip: context deadline exceeded",
+		"skipped low: context deadline exceeded",
+	}, (<-done).errors)
+
+	reader, err := zip.OpenReader(dataFile.Name())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.True(t, len(reader.File) >= 1)
+	assert.Equal(t, "high", reader.File[0].Name)
+	for _, f := range reader.File {
+		assert.NotEqual(t, "low", f.Name)
+	}
+}
+
 func TestIfE2E_(t *testing.T) {
 	workdir, err := ioutil.TempDir("", "work-dir")
 	require.NoError(t, err)
@@ -826,7 +1392,7 @@ func TestIfE2E_(t *testing.T) {
 		MockCollector{name: "collector-4", rc: slowReader{delay: time.Millisecond}},
 	}
 
-	bh, err := NewBundleHandler(workdir, collectors, time.Second, 100 * time.Millisecond)
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, 100*time.Millisecond, nil)
 	require.NoError(t, err)
 	bh.clock = &MockClock{now: now}
 
@@ -855,11 +1421,14 @@ func TestIfE2E_(t *testing.T) {
 		assert.Equal(t, &Bundle{
 			ID:      "bundle-0",
 			Type:    Local,
+			Source:  SourceManual,
 			Status:  Started,
 			Started: now.Add(time.Hour),
 		}, bundle)
 	})
 
+	var checksum string
+
 	t.Run("get bundle-0 status", func(t *testing.T) {
 		for { // busy wait for bundle
 			bundle, err := client.Status(context.TODO(), testServer.URL, "bundle-0")
@@ -871,18 +1440,23 @@ func TestIfE2E_(t *testing.T) {
 
 		bundle, err := client.Status(context.TODO(), testServer.URL, "bundle-0")
 		require.NoError(t, err)
+		assert.Len(t, bundle.Checksum, 64)
+		checksum = bundle.Checksum
 
 		assert.Equal(t, &Bundle{
-			ID:      "bundle-0",
-			Type:    Local,
-			Status:  Done,
-			Started: now.Add(time.Hour),
-			Stopped: now.Add(2 * time.Hour),
-			Size:    618,
+			ID:       "bundle-0",
+			Type:     Local,
+			Source:   SourceManual,
+			Status:   Done,
+			Started:  now.Add(time.Hour),
+			Stopped:  now.Add(2 * time.Hour),
+			Size:     867,
+			Checksum: checksum,
 			Errors: []string{
 				"could not collect collector-1: some error",
 				"could not copy collector-4 data to zip: context deadline exceeded",
 			},
+			Warnings: []string{"could not collect collector-3: some other error"},
 		}, bundle)
 	})
 
@@ -899,11 +1473,12 @@ func TestIfE2E_(t *testing.T) {
 		reader, err := zip.OpenReader(f.Name())
 		require.NoError(t, err)
 
-		require.Len(t, reader.File, 4)
+		require.Len(t, reader.File, 5)
 		assert.Equal(t, "collector-2", reader.File[0].Name)
 		assert.Equal(t, "collector-3", reader.File[1].Name)
 		assert.Equal(t, "collector-4", reader.File[2].Name)
 		assert.Equal(t, "summaryErrorsReport.txt", reader.File[3].Name)
+		assert.Equal(t, manifestFileName, reader.File[4].Name)
 
 		rc, err := reader.File[0].Open()
 		require.NoError(t, err)
@@ -932,6 +1507,9 @@ func TestIfE2E_(t *testing.T) {
 could not copy collector-4 data to zip: context deadline exceeded`, string(content))
 	})
 
+	// the download is the first clock reading after the bundle was stopped
+	lastAccessed := now.Add(3 * time.Hour)
+
 	t.Run("delete bundle-0", func(t *testing.T) {
 
 		req, err := http.NewRequest(http.MethodDelete, testServer.URL+bundlesEndpoint+"/bundle-0", nil)
@@ -946,16 +1524,20 @@ could not copy collector-4 data to zip: context deadline exceeded`, string(conte
 		require.NoError(t, err)
 
 		assert.JSONEq(t, string(jsonMarshal(Bundle{
-			ID:      "bundle-0",
-			Type:    Local,
-			Status:  Deleted,
-			Started: now.Add(time.Hour),
-			Stopped: now.Add(2 * time.Hour),
-			Size:    618,
-			Errors:  []string{
+			ID:           "bundle-0",
+			Type:         Local,
+			Source:       SourceManual,
+			Status:       Deleted,
+			Started:      now.Add(time.Hour),
+			Stopped:      now.Add(2 * time.Hour),
+			Size:         867,
+			Checksum:     checksum,
+			LastAccessed: &lastAccessed,
+			Errors: []string{
 				"could not collect collector-1: some error",
 				"could not copy collector-4 data to zip: context deadline exceeded",
 			},
+			Warnings: []string{"could not collect collector-3: some other error"},
 		})), string(body))
 	})
 
@@ -969,16 +1551,20 @@ could not copy collector-4 data to zip: context deadline exceeded`, string(conte
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rr.Code)
 		assert.JSONEq(t, string(jsonMarshal([]Bundle{{
-			ID:      "bundle-0",
-			Type:    Local,
-			Status:  Deleted,
-			Started: now.Add(time.Hour),
-			Stopped: now.Add(2 * time.Hour),
-			Size:    618,
-			Errors:  []string{
+			ID:           "bundle-0",
+			Type:         Local,
+			Source:       SourceManual,
+			Status:       Deleted,
+			Started:      now.Add(time.Hour),
+			Stopped:      now.Add(2 * time.Hour),
+			Size:         867,
+			Checksum:     checksum,
+			LastAccessed: &lastAccessed,
+			Errors: []string{
 				"could not collect collector-1: some error",
 				"could not copy collector-4 data to zip: context deadline exceeded",
 			},
+			Warnings: []string{"could not collect collector-3: some other error"},
 		}})), rr.Body.String())
 	})
 }
@@ -991,7 +1577,7 @@ func TestBundleHandlerWorkDirIsCreatedIfNotExists(t *testing.T) {
 	err = os.RemoveAll(workdir)
 	require.NoError(t, err)
 
-	_, err = NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout)
+	_, err = NewBundleHandler(workdir, nil, time.Millisecond, collectorTimeout, nil)
 	require.NoError(t, err)
 
 	assert.DirExists(t, workdir)
@@ -1004,16 +1590,19 @@ func TestBundleHandlerWorkDirInitFailsWhenFileExists(t *testing.T) {
 	workdir, err := ioutil.TempFile("", "work-dir")
 	require.NoError(t, err)
 
-	_, err = NewBundleHandler(workdir.Name(), nil, time.Millisecond, collectorTimeout)
+	_, err = NewBundleHandler(workdir.Name(), nil, time.Millisecond, collectorTimeout, nil)
 	assert.Error(t, err)
 }
 
 // MockClock is a monotonic clock. Every call to Now() adds one hour
 type MockClock struct {
+	sync.Mutex
 	now time.Time
 }
 
 func (m *MockClock) Now() time.Time {
+	m.Lock()
+	defer m.Unlock()
 	m.now = m.now.Add(time.Hour)
 	return m.now
 }
@@ -1049,3 +1638,386 @@ func (s slowReader) Read(p []byte) (n int, err error) {
 func (s slowReader) Close() error {
 	return nil
 }
+
+func TestIfCreateValidatesBundleID(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	cbh := ClusterBundleHandler{workDir: workdir, clock: &MockClock{}}
+
+	for _, tc := range []struct {
+		id    string
+		error string
+	}{
+		{id: "../escape", error: `invalid bundle ID "../escape": only letters, digits, dash and underscore are allowed`},
+		{id: "a/b", error: `invalid bundle ID "a/b": only letters, digits, dash and underscore are allowed`},
+		{id: "", error: `invalid bundle ID "": only letters, digits, dash and underscore are allowed`},
+		{id: strings.Repeat("a", maxBundleIDLength+1), error: "invalid bundle ID: longer than 128 characters"},
+	} {
+		for name, create := range map[string]http.HandlerFunc{"local": bh.Create, "cluster": cbh.Create} {
+			req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/id", nil)
+			require.NoError(t, err)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+
+			rr := httptest.NewRecorder()
+			create(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+			assert.JSONEq(t, string(jsonMarshal(ErrorResponse{Code: http.StatusBadRequest, Error: tc.error})), rr.Body.String(), name)
+		}
+	}
+
+	files, err := ioutil.ReadDir(workdir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.NoDirExists(t, filepath.Join(workdir, "..", "escape"))
+
+	assert.NoError(t, validateBundleID(strings.Repeat("a", maxBundleIDLength)))
+	assert.NoError(t, validateBundleID("bundle-2020_01-a1b2c3"))
+}
+
+// countingResponseWriter counts writes and remembers the largest one
+type countingResponseWriter struct {
+	*httptest.ResponseRecorder
+	writes  int
+	largest int
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if len(b) > w.largest {
+		w.largest = len(b)
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestIfListStreamsBundles(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	const count = 1000
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("bundle-%d", i)
+		require.NoError(t, os.MkdirAll(filepath.Join(workdir, id), dirPerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, stateFileName),
+			jsonMarshal(Bundle{ID: id, Type: Local, Status: Deleted}), filePerm))
+	}
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
+	require.NoError(t, err)
+
+	rr := &countingResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+	bh.List(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	// every bundle is written separately so the largest write is the size of a single bundle
+	assert.True(t, rr.writes > count, "expected more than %d writes, got %d", count, rr.writes)
+	assert.True(t, rr.largest < 256, "expected writes of single bundles, got %d bytes", rr.largest)
+
+	var bundles []Bundle
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundles))
+	assert.Len(t, bundles, count)
+}
+
+func TestCollectAllHandlesEmptyResults(t *testing.T) {
+	for _, tc := range []struct {
+		emptyResults EmptyResults
+		files        []string
+		manifest     string
+		warning      string
+	}{
+		{
+			emptyResults: EmptyResultsNote,
+			files:        []string{"ok", "empty", manifestFileName},
+			manifest: `{"entries":[{"name":"ok","size":2},
+				{"name":"empty","size":0,"note":"collector returned empty result"}]}`,
+			warning: "empty returned empty result",
+		},
+		{
+			emptyResults: EmptyResultsSkip,
+			files:        []string{"ok", manifestFileName},
+			manifest:     `{"entries":[{"name":"ok","size":2}]}`,
+			warning:      "empty returned empty result, skipped",
+		},
+	} {
+		t.Run(string(tc.emptyResults), func(t *testing.T) {
+			dataFile, err := ioutil.TempFile("", "*.zip")
+			require.NoError(t, err)
+			defer os.Remove(dataFile.Name())
+
+			collectors := []collector.Collector{
+				MockCollector{name: "ok", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+				MockCollector{name: "empty", rc: ioutil.NopCloser(bytes.NewReader(nil))},
+			}
+
+			done := make(chan collectResult, 1)
+			collectAll(context.Background(), done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: time.Second, emptyResults: tc.emptyResults})
+			result := <-done
+			assert.Empty(t, result.errors)
+			assert.Equal(t, []string{tc.warning}, result.warnings)
+
+			r, err := zip.OpenReader(dataFile.Name())
+			require.NoError(t, err)
+			defer r.Close()
+
+			var files []string
+			for _, f := range r.File {
+				files = append(files, f.Name)
+				if f.Name == manifestFileName {
+					rc, err := f.Open()
+					require.NoError(t, err)
+					manifest, err := ioutil.ReadAll(rc)
+					require.NoError(t, err)
+					rc.Close()
+					assert.JSONEq(t, tc.manifest, string(manifest))
+				}
+			}
+			assert.Equal(t, tc.files, files)
+		})
+	}
+}
+
+func TestCollectAllRecordsExitCodeOfFailingCommand(t *testing.T) {
+	dataFile, err := ioutil.TempFile("", "*.zip")
+	require.NoError(t, err)
+	defer os.Remove(dataFile.Name())
+
+	collectors := []collector.Collector{
+		collector.NewCmd("fail", true, []string{"sh", "-c", "echo partial; exit 3"}),
+	}
+
+	done := make(chan collectResult, 1)
+	collectAll(context.Background(), done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: time.Second})
+	result := <-done
+	assert.Empty(t, result.errors)
+	assert.Equal(t, []string{"could not collect fail: exit status 3"}, result.warnings)
+
+	r, err := zip.OpenReader(dataFile.Name())
+	require.NoError(t, err)
+	defer r.Close()
+	require.Len(t, r.File, 2)
+
+	rc, err := r.File[0].Open()
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	rc.Close()
+	assert.Regexp(t, "^exit status 3\n# exit code: 3, duration: .+\npartial\n$", string(output))
+
+	rc, err = r.File[1].Open()
+	require.NoError(t, err)
+	var manifest bundleManifest
+	require.NoError(t, json.NewDecoder(rc).Decode(&manifest))
+	rc.Close()
+	require.Len(t, manifest.Entries, 1)
+	require.NotNil(t, manifest.Entries[0].ExitCode)
+	assert.Equal(t, 3, *manifest.Entries[0].ExitCode)
+	assert.NotEmpty(t, manifest.Entries[0].Duration)
+	assert.Equal(t, int64(len(output)), manifest.Entries[0].Size)
+}
+
+func TestParseEmptyResults(t *testing.T) {
+	e, err := ParseEmptyResults("")
+	require.NoError(t, err)
+	assert.Equal(t, EmptyResultsNote, e)
+
+	e, err = ParseEmptyResults("skip")
+	require.NoError(t, err)
+	assert.Equal(t, EmptyResultsSkip, e)
+
+	_, err = ParseEmptyResults("drop")
+	assert.EqualError(t, err, "unknown empty results handling drop, expected note or skip")
+}
+
+func TestIfCreateRetriesDataFileCreationOnTransientError(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	attempts := 0
+	defer func() { createDataFile = os.Create }()
+	createDataFile = func(name string) (*os.File, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, fmt.Errorf("input/output error")
+		}
+		return os.Create(name)
+	}
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		// collector failures do not retry the bundle
+		MockCollector{name: "collector-2", optional: true, err: fmt.Errorf("some error")},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithSetupRetries(2)
+	bh.setupBackoff = time.Millisecond
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var bundle Bundle
+	require.Eventually(t, func() bool {
+		bundle, err = bh.getBundleState("bundle-0")
+		return err == nil && bundle.Status == Done
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, bundle.Errors)
+	assert.Equal(t, []string{"could not collect collector-2: some error"}, bundle.Warnings)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestIfCreateFailsBundleWhenDataFileCreationRetriesAreExhausted(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	attempts := 0
+	defer func() { createDataFile = os.Create }()
+	createDataFile = func(name string) (*os.File, error) {
+		attempts++
+		return nil, fmt.Errorf("input/output error")
+	}
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithSetupRetries(2)
+	bh.setupBackoff = time.Millisecond
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInsufficientStorage, rr.Code)
+	assert.Equal(t, 3, attempts)
+
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	assert.Equal(t, Failed, bundle.Status)
+	assert.Equal(t, []string{"input/output error"}, bundle.Errors)
+}
+
+func TestCreateWithFailFastSkipsCollectorsAfterRequiredFailure(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		// optional collectors never trigger fail fast
+		MockCollector{name: "optional", optional: true, err: fmt.Errorf("some error")},
+		MockCollector{name: "required", err: fmt.Errorf("some error")},
+		MockCollector{name: "collector-2", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		MockCollector{name: "collector-3", optional: true, rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", strings.NewReader(`{"fail_fast": true}`))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var bundle Bundle
+	require.Eventually(t, func() bool {
+		bundle, err = bh.getBundleState("bundle-0")
+		return err == nil && bundle.IsFinished()
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, Failed, bundle.Status)
+	assert.Equal(t, []string{
+		"could not collect required: some error",
+		"skipped collector-2: fail fast after required failed",
+		"skipped collector-3: fail fast after required failed",
+	}, bundle.Errors)
+	assert.Equal(t, []string{"could not collect optional: some error"}, bundle.Warnings)
+
+	reader, err := zip.OpenReader(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	defer reader.Close()
+	var names []string
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, "collector-1")
+	assert.NotContains(t, names, "collector-2")
+	assert.NotContains(t, names, "collector-3")
+}
+
+// fallbackEndpoint returns the output of an endpoint collected with the other scheme
+type fallbackEndpoint struct {
+	output *collector.EndpointOutput
+}
+
+func (fallbackEndpoint) Name() string {
+	return "health.json"
+}
+
+func (fallbackEndpoint) Optional() bool {
+	return true
+}
+
+func (f fallbackEndpoint) Collect(ctx context.Context) (io.ReadCloser, error) {
+	return f.output, nil
+}
+
+func TestCollectAllNotesSchemeEndpointWasCollectedWith(t *testing.T) {
+	dataFile, err := ioutil.TempFile("", "*.zip")
+	require.NoError(t, err)
+	defer os.Remove(dataFile.Name())
+
+	collectors := []collector.Collector{
+		fallbackEndpoint{output: &collector.EndpointOutput{
+			ReadCloser: ioutil.NopCloser(bytes.NewReader([]byte("OK"))),
+			URL:        "https://192.0.2.1:61001/system/health/v1",
+			Reason:     "unable to fetch http://192.0.2.1:61001/system/health/v1. Return code 400.",
+		}},
+	}
+
+	done := make(chan collectResult, 1)
+	collectAll(context.Background(), done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: time.Second})
+	result := <-done
+	assert.Empty(t, result.errors)
+	assert.Empty(t, result.warnings)
+
+	r, err := zip.OpenReader(dataFile.Name())
+	require.NoError(t, err)
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != manifestFileName {
+			continue
+		}
+		rc, err := f.Open()
+		require.NoError(t, err)
+		manifest, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		assert.JSONEq(t, `{"entries":[{"name":"health.json","size":2,
+			"note":"collected from https://192.0.2.1:61001/system/health/v1 after unable to fetch http://192.0.2.1:61001/system/health/v1. Return code 400."}]}`,
+			string(manifest))
+	}
+}
@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// indexFileName is a human readable index written at the root of local bundles when enabled
+const indexFileName = "index.html"
+
+// Categories entries of the index are grouped by
+const (
+	indexCategoryHealth  = "health"
+	indexCategoryLogs    = "logs"
+	indexCategoryNetwork = "network"
+	indexCategorySystem  = "system"
+)
+
+var indexCategories = []string{indexCategoryHealth, indexCategoryLogs, indexCategoryNetwork, indexCategorySystem}
+
+// Collection statuses of index entries
+const (
+	indexStatusOK     = "ok"
+	indexStatusEmpty  = "empty"
+	indexStatusFailed = "failed"
+)
+
+// indexEntry is a bundle entry shown in the index. Entries of required collectors that failed are not
+// stored in the bundle so they are listed without a link.
+type indexEntry struct {
+	manifestEntry
+	Status string
+	Stored bool
+}
+
+type indexCategory struct {
+	Name    string
+	Entries []indexEntry
+}
+
+// categorize returns the category of the bundle entry guessed from its name
+func categorize(name string) string {
+	lower := strings.ToLower(name)
+	base := path.Base(lower)
+	switch {
+	case strings.HasSuffix(base, ".log"), strings.HasSuffix(base, ".service"), strings.HasSuffix(base, ".socket"),
+		strings.HasSuffix(base, ".timer"), strings.HasPrefix(lower, "kubernetes/"), strings.Contains(base, "journal"):
+		return indexCategoryLogs
+	case strings.Contains(lower, "health"), strings.HasPrefix(lower, "time/"), lower == summaryErrorsReportFileName:
+		return indexCategoryHealth
+	case strings.Contains(base, "iptables"), strings.Contains(base, "nft"), strings.Contains(base, "net"),
+		strings.Contains(base, "dns"), strings.Contains(base, "resolv"), strings.Contains(base, "route"),
+		strings.HasPrefix(base, "ip_"), strings.HasPrefix(base, "ss_"):
+		return indexCategoryNetwork
+	}
+	return indexCategorySystem
+}
+
+// formatSize returns the size in bytes in a human readable form
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+var indexTemplate = template.Must(template.New(indexFileName).Funcs(template.FuncMap{
+	"size": formatSize,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DC/OS diagnostics bundle</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.2em 1em; border-bottom: 1px solid #ddd; }
+td.size { text-align: right; }
+.failed { color: #b00; }
+.empty { color: #888; }
+</style>
+</head>
+<body>
+<h1>DC/OS diagnostics bundle</h1>
+<p>Entries are listed in <a href="manifest.json">manifest.json</a>.</p>
+{{range .}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+<table>
+<tr><th>Entry</th><th>Size</th><th>Status</th><th>Note</th></tr>
+{{range .Entries}}<tr class="{{.Status}}"><td>{{if .Stored}}<a href="{{.Name}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td class="size">{{size .Size}}</td><td>{{.Status}}</td><td>{{.Note}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// writeIndex writes the HTML index of given entries grouped by category, categories without entries are omitted
+func writeIndex(w io.Writer, entries []indexEntry) error {
+	byCategory := make(map[string][]indexEntry, len(indexCategories))
+	for _, e := range entries {
+		c := categorize(e.Name)
+		byCategory[c] = append(byCategory[c], e)
+	}
+
+	categories := make([]indexCategory, 0, len(indexCategories))
+	for _, name := range indexCategories {
+		if len(byCategory[name]) == 0 {
+			continue
+		}
+		sort.Slice(byCategory[name], func(i, j int) bool { return byCategory[name][i].Name < byCategory[name][j].Name })
+		categories = append(categories, indexCategory{Name: name, Entries: byCategory[name]})
+	}
+	return indexTemplate.Execute(w, categories)
+}
+
+// writeIndexEntry adds the index of given entries to the bundle and returns its manifest entry
+func writeIndexEntry(dataWriter bundleWriter, entries []indexEntry) (manifestEntry, error) {
+	var buf bytes.Buffer
+	if err := writeIndex(&buf, entries); err != nil {
+		return manifestEntry{}, fmt.Errorf("could not render %s: %s", indexFileName, err)
+	}
+	f, err := dataWriter.Create(indexFileName)
+	if err != nil {
+		return manifestEntry{}, fmt.Errorf("could not create %s: %s", indexFileName, err)
+	}
+	size, err := io.Copy(f, &buf)
+	if err != nil {
+		return manifestEntry{}, fmt.Errorf("could not write %s: %s", indexFileName, err)
+	}
+	return manifestEntry{Name: indexFileName, Size: size}, nil
+}
@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectAllWritesHTMLIndex(t *testing.T) {
+	dataFile, err := ioutil.TempFile("", "*.zip")
+	require.NoError(t, err)
+	defer os.Remove(dataFile.Name())
+
+	collectors := []collector.Collector{
+		MockCollector{name: "dcos-diagnostics-health.json", rc: ioutil.NopCloser(strings.NewReader("{}"))},
+		MockCollector{name: "dcos-mesos-master.service", rc: ioutil.NopCloser(strings.NewReader("logs"))},
+		MockCollector{name: "iptables-save.output", optional: true, err: errors.New("not installed")},
+		MockCollector{name: "environment.json", rc: ioutil.NopCloser(strings.NewReader(""))},
+		MockCollector{name: "ps_aux.output", err: errors.New("failed")},
+	}
+
+	done := make(chan collectResult, 1)
+	collectAll(context.Background(), done, newZipBundleWriter(dataFile, CompressionDeflate), collectors, collectOptions{collectorTimeout: time.Second, htmlIndex: true})
+	<-done
+
+	r, err := zip.OpenReader(dataFile.Name())
+	require.NoError(t, err)
+	defer r.Close()
+
+	files := map[string]*zip.File{}
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	require.Contains(t, files, indexFileName)
+
+	rc, err := files[indexFileName].Open()
+	require.NoError(t, err)
+	index, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	rc.Close()
+
+	html := string(index)
+	assert.Contains(t, html, `<h2 id="health">health</h2>`)
+	assert.Contains(t, html, `<h2 id="logs">logs</h2>`)
+	assert.Contains(t, html, `<h2 id="network">network</h2>`)
+	assert.Contains(t, html, `<h2 id="system">system</h2>`)
+	assert.Contains(t, html, `<tr class="ok"><td><a href="dcos-diagnostics-health.json">dcos-diagnostics-health.json</a></td><td class="size">2 B</td><td>ok</td>`)
+	assert.Contains(t, html, `<a href="dcos-mesos-master.service">dcos-mesos-master.service</a>`)
+	assert.Contains(t, html, `<tr class="failed"><td><a href="iptables-save.output">iptables-save.output</a></td>`)
+	assert.Contains(t, html, `<tr class="empty"><td><a href="environment.json">environment.json</a></td>`)
+	// required collector that failed is not in the bundle so it is not linked
+	assert.Contains(t, html, `<tr class="failed"><td>ps_aux.output</td>`)
+	assert.Contains(t, html, `<a href="summaryErrorsReport.txt">summaryErrorsReport.txt</a>`)
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "http://")
+
+	rc, err = files[manifestFileName].Open()
+	require.NoError(t, err)
+	var manifest bundleManifest
+	require.NoError(t, json.NewDecoder(rc).Decode(&manifest))
+	rc.Close()
+	assert.Equal(t, indexFileName, manifest.Entries[len(manifest.Entries)-1].Name)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "0 B", formatSize(0))
+	assert.Equal(t, "1023 B", formatSize(1023))
+	assert.Equal(t, "1.0 KiB", formatSize(1024))
+	assert.Equal(t, "1.5 MiB", formatSize(3<<19))
+}
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// Report returns report.json with outcomes of nodes of the cluster bundle stored on this node
+// without transferring the whole bundle. Local bundles have no report.
+func (h BundleHandler) Report(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !h.bundleExists(id) {
+		writeDiagnosticsError(w, &DiagnosticsBundleNotFoundError{id: id})
+		return
+	}
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if bundle.Status == Deleted || bundle.Status == Canceled || bundle.Status == Failed {
+		writeJSONError(w, http.StatusGone, fmt.Errorf("bundle %s was %s", bundle.ID, bundle.Status))
+		return
+	}
+	if bundle.Status != Done {
+		writeJSONError(w, http.StatusNotFound,
+			fmt.Errorf("bundle %s is not done yet (status %s), try again later", bundle.ID, bundle.Status))
+		return
+	}
+
+	var report []byte
+	if bundle.Layout == LayoutDirectory {
+		report, err = ioutil.ReadFile(filepath.Join(h.workDir, id, contentsDir, reportFileName))
+	} else {
+		report, err = readZipFileEntry(filepath.Join(h.workDir, id, dataFileName), reportFileName)
+	}
+	if os.IsNotExist(err) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("bundle %s has no %s, only cluster bundles have reports", id, reportFileName))
+		return
+	}
+	if err != nil {
+		writeDiagnosticsError(w, &DiagnosticsBundleUnreadableError{id: id})
+		return
+	}
+
+	write(w, report)
+}
+
+// readZipFileEntry returns the content of the single entry of the zip file without extracting other entries.
+// It returns os.ErrNotExist when the zip has no such entry.
+func readZipFileEntry(zipPath string, name string) ([]byte, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read zip %s: %s", zipPath, err)
+	}
+	defer r.Close()
+	registerDecompressors(&r.Reader)
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := readZipEntry(f, &buf); err != nil {
+			return nil, fmt.Errorf("could not read %s: %s", name, err)
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, os.ErrNotExist
+}
@@ -0,0 +1,433 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// minUploadPartSize is the smallest part size accepted by S3 compatible object stores for all parts but the last
+	minUploadPartSize = 5 * 1024 * 1024
+	// maxUploadParts is the largest number of parts of a single multipart upload accepted by S3 compatible stores
+	maxUploadParts = 10000
+)
+
+// ObjectStore is an object storage supporting multipart uploads e.g., S3. Parts are numbered from 1.
+type ObjectStore interface {
+	CreateMultipartUpload(ctx context.Context, key string) (uploadID string, err error)
+	UploadPart(ctx context.Context, key string, uploadID string, number int, part io.Reader, size int64) (etag string, err error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) error
+}
+
+// UploadedPart is a part of the bundle stored in the object store
+type UploadedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// UploadProgress is recorded in the bundle state after every uploaded part so an interrupted upload e.g., by
+// the daemon restart continues from the last uploaded part
+type UploadProgress struct {
+	Key      string `json:"key"`
+	UploadID string `json:"upload_id"`
+	PartSize int64  `json:"part_size"`
+	// Size and Checksum of the uploaded data file, the upload is started over when the file changes e.g., on compaction
+	Size     int64          `json:"size"`
+	Checksum string         `json:"checksum,omitempty"`
+	Parts    []UploadedPart `json:"parts,omitempty"`
+	Done     bool           `json:"done,omitempty"`
+	// Error is the reason the last attempt to upload the bundle was interrupted, empty once it is done
+	Error string `json:"error,omitempty"`
+}
+
+// UploadOptions configure uploads of bundles
+type UploadOptions struct {
+	// PartSize is a size of every part but the last one, it is raised when the data file would not fit in maxUploadParts
+	PartSize int64
+	// PartRetries is a number of retries of a failed part before the upload is interrupted
+	PartRetries int
+	// PartTimeout limits a single attempt to upload a part, 0 disables the limit
+	PartTimeout time.Duration
+	// Backoff is the wait before the first retry of a part, it doubles with every retry
+	Backoff time.Duration
+}
+
+// Uploader stores finished local bundles in the object store. Bundles are stored under the prefix as <id>.zip.
+type Uploader struct {
+	store   ObjectStore
+	prefix  string
+	options UploadOptions
+	running *runningUploads
+}
+
+// NewUploader returns the uploader to the object store selected by the URL scheme. The s3 scheme e.g.,
+// s3://bucket/prefix?region=us-east-1&endpoint=https://minio:9000 uploads to the S3 compatible store with
+// credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, the endpoint
+// defaults to the AWS endpoint of the region. The file scheme e.g., file:///path/to/dir stores bundles in the local
+// directory e.g., a mounted network share.
+func NewUploader(rawURL string, options UploadOptions) (*Uploader, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload URL: %s", err)
+	}
+
+	uploader := &Uploader{
+		options: options,
+		running: &runningUploads{ids: map[string]bool{}},
+	}
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid upload URL %s: no bucket", rawURL)
+		}
+		region := u.Query().Get("region")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint, err := url.Parse(u.Query().Get("endpoint"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload endpoint: %s", err)
+		}
+		if endpoint.Host == "" {
+			endpoint = &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", region)}
+		}
+		credentials := S3Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+			return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to upload bundles to S3")
+		}
+		// parts are limited with PartTimeout, a client timeout would limit the whole part regardless of its size
+		uploader.store = newS3Store(endpoint, u.Host, region, credentials, &http.Client{})
+		uploader.prefix = strings.Trim(u.Path, "/")
+	case "file":
+		if u.Host != "" || !filepath.IsAbs(u.Path) {
+			return nil, fmt.Errorf("invalid upload URL %s: path must be absolute", rawURL)
+		}
+		if err := os.MkdirAll(u.Path, dirPerm); err != nil {
+			return nil, fmt.Errorf("could not create upload dir: %s", err)
+		}
+		uploader.store = fileStore{dir: u.Path}
+	default:
+		return nil, fmt.Errorf("unsupported upload URL scheme %q, use s3 or file", u.Scheme)
+	}
+	return uploader, nil
+}
+
+// key returns the object store key of the bundle
+func (u Uploader) key(id string) string {
+	return path.Join(u.prefix, id+".zip")
+}
+
+// runningUploads are IDs of bundles uploaded at the moment so every bundle is uploaded once at a time
+type runningUploads struct {
+	sync.Mutex
+	ids map[string]bool
+}
+
+// start marks the bundle as being uploaded, it returns false if it is uploaded already
+func (r *runningUploads) start(id string) bool {
+	r.Lock()
+	defer r.Unlock()
+	if r.ids[id] {
+		return false
+	}
+	r.ids[id] = true
+	return true
+}
+
+func (r *runningUploads) done(id string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.ids, id)
+}
+
+// WithUploader makes the handler upload bundles with the uploader on request, nil disables uploads
+func (h *BundleHandler) WithUploader(uploader *Uploader) *BundleHandler {
+	h.uploader = uploader
+	return h
+}
+
+// Upload starts the upload of the finished zip bundle to the object store and returns its state with 202 Accepted.
+// The progress and the error of the last attempt are reported in the upload field of the bundle state. Requesting
+// the upload of the bundle that was interrupted resumes it, the bundle that was uploaded is not uploaded again.
+func (h BundleHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if h.uploader == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("bundle upload is disabled"))
+		return
+	}
+	if !h.bundleExists(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if bundle.Status != Done {
+		writeJSONError(w, http.StatusConflict,
+			fmt.Errorf("bundle %s is not done (status %s), only done bundles could be uploaded", id, bundle.Status))
+		return
+	}
+	if bundle.Layout == LayoutDirectory {
+		writeJSONError(w, http.StatusBadRequest,
+			fmt.Errorf("bundle %s has %s layout, only zip bundles could be uploaded", id, bundle.Layout))
+		return
+	}
+
+	h.startUpload(id)
+
+	w.WriteHeader(http.StatusAccepted)
+	write(w, jsonMarshal(bundle))
+}
+
+// ResumeUploads continues uploads of bundles interrupted e.g., by the daemon restart. Bundles are uploaded one
+// by one so resumed uploads do not compete for the bandwidth.
+func (h BundleHandler) ResumeUploads(ctx context.Context) {
+	if h.uploader == nil {
+		return
+	}
+	ids, err := ioutil.ReadDir(h.workDir)
+	if err != nil {
+		logrus.WithError(err).Warn("Could not read work dir to resume bundle uploads")
+		return
+	}
+	for _, id := range ids {
+		if !id.IsDir() {
+			continue
+		}
+		bundle, err := h.getBundleState(id.Name())
+		if err != nil || bundle.Status != Done || bundle.Upload == nil || bundle.Upload.Done {
+			continue
+		}
+		if !h.uploader.running.start(bundle.ID) {
+			continue
+		}
+		h.uploadAndLog(ctx, bundle.ID)
+	}
+}
+
+// startUpload uploads the bundle in the background unless it is uploaded already
+func (h BundleHandler) startUpload(id string) {
+	if !h.uploader.running.start(id) {
+		return
+	}
+	go h.uploadAndLog(context.Background(), id)
+}
+
+func (h BundleHandler) uploadAndLog(ctx context.Context, id string) {
+	defer h.uploader.running.done(id)
+
+	log := logrus.WithField("ID", id).WithField("key", h.uploader.key(id))
+	if err := h.upload(ctx, id, h.uploader.key(id), h.uploader.store, h.uploader.options); err != nil {
+		log.WithError(err).Warn("Could not upload bundle")
+		return
+	}
+	log.Info("Bundle uploaded")
+}
+
+// upload stores the data file of the finished zip bundle in the object store under the key with a multipart upload.
+// Parts that failed are retried and the progress is recorded in the bundle state so the upload of the same bundle
+// to the same key continues from the last uploaded part. It does nothing when the bundle was already uploaded.
+func (h BundleHandler) upload(ctx context.Context, id string, key string, store ObjectStore, options UploadOptions) error {
+	// compaction rewrites the data file so it must not run while parts of it are uploaded
+	h.compactLock.Lock()
+	defer h.compactLock.Unlock()
+
+	if !h.bundleExists(id) {
+		return fmt.Errorf("bundle %s not found", id)
+	}
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		return err
+	}
+	if bundle.Status != Done {
+		return fmt.Errorf("bundle %s is not done (status %s)", id, bundle.Status)
+	}
+	if bundle.Layout == LayoutDirectory {
+		return fmt.Errorf("bundle %s has directory layout, only zip bundles could be uploaded", id)
+	}
+
+	f, err := os.Open(filepath.Join(h.workDir, id, dataFileName))
+	if err != nil {
+		return fmt.Errorf("could not open data file of bundle %s: %s", id, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat data file of bundle %s: %s", id, err)
+	}
+
+	var progress UploadProgress
+	if bundle.Upload != nil {
+		progress = *bundle.Upload
+	}
+	sameData := bundle.Upload != nil && progress.Key == key && progress.Size == info.Size() &&
+		progress.Checksum == bundle.Checksum
+	if sameData && progress.Done {
+		return nil
+	}
+	if sameData {
+		logrus.WithField("ID", id).WithField("parts", len(progress.Parts)).Info("Resuming bundle upload")
+	} else {
+		progress = UploadProgress{
+			Key:      key,
+			PartSize: uploadPartSize(info.Size(), options.PartSize),
+			Size:     info.Size(),
+			Checksum: bundle.Checksum,
+		}
+	}
+
+	err = h.uploadParts(ctx, id, f, &progress, store, options)
+	if _, ok := err.(noSuchUploadError); ok && sameData {
+		// the interrupted upload was aborted in the object store in the meantime
+		logrus.WithField("ID", id).WithError(err).Info("Starting bundle upload over")
+		progress.UploadID = ""
+		progress.Parts = nil
+		err = h.uploadParts(ctx, id, f, &progress, store, options)
+	}
+	if err != nil {
+		progress.Error = err.Error()
+		if e := h.recordUpload(id, progress); e != nil {
+			logrus.WithField("ID", id).WithError(e).Warn("Could not record upload failure")
+		}
+		return fmt.Errorf("could not upload bundle %s: %s", id, err)
+	}
+
+	progress.Done = true
+	progress.Error = ""
+	if err := h.recordUpload(id, progress); err != nil {
+		return fmt.Errorf("could not record upload of bundle %s: %s", id, err)
+	}
+	return nil
+}
+
+// uploadParts starts the multipart upload unless progress has one, uploads parts following the last uploaded one
+// and completes the upload. The progress is recorded after every part.
+func (h BundleHandler) uploadParts(ctx context.Context, id string, f *os.File, progress *UploadProgress, store ObjectStore,
+	options UploadOptions) error {
+	if progress.UploadID == "" {
+		uploadID, err := store.CreateMultipartUpload(ctx, progress.Key)
+		if err != nil {
+			return fmt.Errorf("could not start upload: %s", err)
+		}
+		progress.UploadID = uploadID
+		if err := h.recordUpload(id, *progress); err != nil {
+			return fmt.Errorf("could not record upload: %s", err)
+		}
+	}
+
+	// empty data file is uploaded as a single empty part
+	parts := int((progress.Size + progress.PartSize - 1) / progress.PartSize)
+	if parts == 0 {
+		parts = 1
+	}
+	for number := len(progress.Parts) + 1; number <= parts; number++ {
+		offset := int64(number-1) * progress.PartSize
+		size := progress.PartSize
+		if offset+size > progress.Size {
+			size = progress.Size - offset
+		}
+		etag, err := uploadPartWithRetry(ctx, store, progress, number, io.NewSectionReader(f, offset, size), size, options)
+		if err != nil {
+			if _, ok := err.(noSuchUploadError); ok {
+				return err
+			}
+			return fmt.Errorf("could not upload part %d: %s", number, err)
+		}
+		progress.Parts = append(progress.Parts, UploadedPart{Number: number, ETag: etag})
+		if err := h.recordUpload(id, *progress); err != nil {
+			return fmt.Errorf("could not record upload: %s", err)
+		}
+	}
+
+	if err := store.CompleteMultipartUpload(ctx, progress.Key, progress.UploadID, progress.Parts); err != nil {
+		if _, ok := err.(noSuchUploadError); ok {
+			return err
+		}
+		return fmt.Errorf("could not complete upload: %s", err)
+	}
+	return nil
+}
+
+// recordUpload stores the upload progress in the state of the bundle. The state is read again under the bundle
+// lock so changes made during the upload e.g., pinning are kept.
+func (h BundleHandler) recordUpload(id string, progress UploadProgress) error {
+	unlock := h.bundleLocks.lock(id)
+	defer unlock()
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		return err
+	}
+	progress.Parts = append([]UploadedPart(nil), progress.Parts...)
+	bundle.Upload = &progress
+	_, err = h.writeStateFile(bundle)
+	return err
+}
+
+// uploadPartSize returns the part size so the data file fits in maxUploadParts parts of at least minUploadPartSize
+func uploadPartSize(size int64, partSize int64) int64 {
+	if partSize < minUploadPartSize {
+		partSize = minUploadPartSize
+	}
+	if minimal := (size + maxUploadParts - 1) / maxUploadParts; partSize < minimal {
+		partSize = minimal
+	}
+	return partSize
+}
+
+func uploadPartWithRetry(ctx context.Context, store ObjectStore, progress *UploadProgress, number int,
+	part *io.SectionReader, size int64, options UploadOptions) (string, error) {
+	backoff := options.Backoff
+	for attempt := 0; ; attempt++ {
+		etag, err := uploadPart(ctx, store, progress, number, part, size, options.PartTimeout)
+		if err == nil {
+			return etag, nil
+		}
+		if _, ok := err.(noSuchUploadError); ok || attempt >= options.PartRetries {
+			return "", err
+		}
+		logrus.WithField("key", progress.Key).WithField("part", number).WithError(err).
+			Warnf("Could not upload part, retrying in %s", backoff)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+}
+
+func uploadPart(ctx context.Context, store ObjectStore, progress *UploadProgress, number int, part io.Reader,
+	size int64, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return store.UploadPart(ctx, progress.Key, progress.UploadID, number, part, size)
+}
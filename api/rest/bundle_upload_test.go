@@ -0,0 +1,417 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bundleUploadEndpoint = bundleEndpoint + "/upload"
+
+// mockObjectStore keeps uploaded parts in memory and fails parts listed in failures the given number of times.
+// Uploads listed in aborted do not exist anymore.
+type mockObjectStore struct {
+	uploads   int
+	parts     map[int][]byte
+	attempts  map[int]int
+	failures  map[int]int
+	aborted   map[string]bool
+	completed []byte
+}
+
+func newMockObjectStore() *mockObjectStore {
+	return &mockObjectStore{parts: map[int][]byte{}, attempts: map[int]int{}, failures: map[int]int{}, aborted: map[string]bool{}}
+}
+
+func (s *mockObjectStore) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	s.uploads++
+	return fmt.Sprintf("upload-%d", s.uploads), nil
+}
+
+func (s *mockObjectStore) UploadPart(ctx context.Context, key string, uploadID string, number int, part io.Reader, size int64) (string, error) {
+	s.attempts[number]++
+	if s.aborted[uploadID] {
+		return "", noSuchUploadError{uploadID: uploadID}
+	}
+	data, err := ioutil.ReadAll(part)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) != size {
+		return "", fmt.Errorf("part %d has %d bytes, expected %d", number, len(data), size)
+	}
+	if s.failures[number] > 0 {
+		s.failures[number]--
+		return "", fmt.Errorf("connection reset by peer")
+	}
+	s.parts[number] = data
+	return fmt.Sprintf("etag-%d", number), nil
+}
+
+func (s *mockObjectStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) error {
+	var data []byte
+	for i, p := range parts {
+		if p.Number != i+1 || p.ETag != fmt.Sprintf("etag-%d", p.Number) {
+			return fmt.Errorf("invalid part %+v", p)
+		}
+		data = append(data, s.parts[p.Number]...)
+	}
+	s.completed = data
+	return nil
+}
+
+// countingObjectStore counts attempts to upload parts to the store and fails parts listed in failures
+type countingObjectStore struct {
+	ObjectStore
+	sync.Mutex
+	attempts map[int]int
+	failures map[int]bool
+}
+
+func (s *countingObjectStore) UploadPart(ctx context.Context, key string, uploadID string, number int, part io.Reader, size int64) (string, error) {
+	s.Lock()
+	s.attempts[number]++
+	fail := s.failures[number]
+	s.Unlock()
+	if fail {
+		return "", fmt.Errorf("connection reset by peer")
+	}
+	return s.ObjectStore.UploadPart(ctx, key, uploadID, number, part, size)
+}
+
+// writeDoneBundle stores a done zip bundle with the data in the work dir
+func writeDoneBundle(t *testing.T, workdir string, id string, data []byte) {
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, id), dirPerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, stateFileName),
+		[]byte(fmt.Sprintf(`{"id":%q,"status":"Done"}`, id)), filePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, dataFileName), data, filePerm))
+}
+
+// uploadData returns data uploaded in two full parts of minUploadPartSize and a short one
+func uploadData() []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), (2*minUploadPartSize+1024)/16)
+}
+
+func TestUploadRetriesFailedPart(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	data := uploadData()
+	writeDoneBundle(t, workdir, "bundle-0", data)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	store := newMockObjectStore()
+	store.failures[2] = 1
+
+	err = bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store,
+		UploadOptions{PartSize: minUploadPartSize, PartRetries: 2, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[int]int{1: 1, 2: 2, 3: 1}, store.attempts)
+	assert.Equal(t, data, store.completed)
+
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	require.NotNil(t, bundle.Upload)
+	assert.True(t, bundle.Upload.Done)
+	assert.Equal(t, []UploadedPart{{1, "etag-1"}, {2, "etag-2"}, {3, "etag-3"}}, bundle.Upload.Parts)
+
+	// uploaded bundle is not uploaded again
+	require.NoError(t, bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, UploadOptions{}))
+	assert.Equal(t, 1, store.uploads)
+}
+
+func TestUploadResumesFromLastUploadedPartAfterRestart(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	data := uploadData()
+	writeDoneBundle(t, workdir, "bundle-0", data)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	store := newMockObjectStore()
+	store.failures[2] = 3
+	options := UploadOptions{PartSize: minUploadPartSize, PartRetries: 1, Backoff: time.Millisecond}
+
+	err = bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, options)
+	assert.EqualError(t, err, "could not upload bundle bundle-0: could not upload part 2: connection reset by peer")
+
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	require.NotNil(t, bundle.Upload)
+	assert.False(t, bundle.Upload.Done)
+	assert.Equal(t, []UploadedPart{{1, "etag-1"}}, bundle.Upload.Parts)
+	assert.Equal(t, "could not upload part 2: connection reset by peer", bundle.Upload.Error)
+
+	// a new handler reads the progress from the state file as the daemon does after a restart
+	restarted, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	require.NoError(t, restarted.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, options))
+
+	assert.Equal(t, 1, store.uploads)
+	assert.Equal(t, map[int]int{1: 1, 2: 4, 3: 1}, store.attempts)
+	assert.Equal(t, data, store.completed)
+}
+
+func TestUploadStartsOverWhenDataFileChanged(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	writeDoneBundle(t, workdir, "bundle-0", uploadData())
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	store := newMockObjectStore()
+	store.failures[2] = 1
+	options := UploadOptions{PartSize: minUploadPartSize, Backoff: time.Millisecond}
+	assert.Error(t, bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, options))
+
+	// e.g., the bundle was compacted in the meantime
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, "bundle-0", dataFileName), []byte("compacted"), filePerm))
+	require.NoError(t, bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, options))
+
+	assert.Equal(t, 2, store.uploads)
+	assert.Equal(t, []byte("compacted"), store.completed)
+}
+
+func TestUploadStartsOverWhenInterruptedUploadWasAborted(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	data := uploadData()
+	writeDoneBundle(t, workdir, "bundle-0", data)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	store := newMockObjectStore()
+	store.failures[2] = 1
+	options := UploadOptions{PartSize: minUploadPartSize, Backoff: time.Millisecond}
+	assert.Error(t, bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, options))
+
+	// e.g., by a lifecycle rule of the bucket
+	store.aborted["upload-1"] = true
+	require.NoError(t, bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", store, options))
+
+	assert.Equal(t, 2, store.uploads)
+	assert.Equal(t, data, store.completed)
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	assert.Equal(t, "upload-2", bundle.Upload.UploadID)
+	assert.True(t, bundle.Upload.Done)
+	assert.Empty(t, bundle.Upload.Error)
+}
+
+func TestUploadRejectsUnfinishedBundle(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, "bundle-0"), dirPerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, "bundle-0", stateFileName),
+		[]byte(`{"id":"bundle-0","status":"InProgress"}`), filePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, "bundle-0", dataFileName), nil, filePerm))
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	err = bh.upload(context.TODO(), "bundle-0", "bundles/bundle-0.zip", newMockObjectStore(), UploadOptions{})
+	assert.EqualError(t, err, "bundle bundle-0 is not done (status InProgress)")
+}
+
+func TestUploadPartSizeFitsMaxParts(t *testing.T) {
+	assert.EqualValues(t, minUploadPartSize, uploadPartSize(1024, 0))
+	assert.EqualValues(t, 8*1024*1024, uploadPartSize(1024, 8*1024*1024))
+	assert.EqualValues(t, 10*1024*1024, uploadPartSize(maxUploadParts*10*1024*1024, minUploadPartSize))
+}
+
+func TestUploadHandlerUploadsBundleToStoreSelectedByURL(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	storeDir, err := ioutil.TempDir("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(storeDir)
+	data := uploadData()
+	writeDoneBundle(t, workdir, "bundle-0", data)
+
+	uploader, err := NewUploader("file://"+storeDir, UploadOptions{PartSize: minUploadPartSize})
+	require.NoError(t, err)
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithUploader(uploader)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleUploadEndpoint, bh.Upload).Methods(http.MethodPost)
+
+	req, err := http.NewRequest(http.MethodPost, bundlesEndpoint+"/bundle-0/upload", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	require.Eventually(t, func() bool {
+		bundle, err := bh.getBundleState("bundle-0")
+		return err == nil && bundle.Upload != nil && bundle.Upload.Done
+	}, 5*time.Second, time.Millisecond)
+
+	uploaded, err := ioutil.ReadFile(filepath.Join(storeDir, "bundle-0.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, data, uploaded)
+	// parts are removed once the upload is completed
+	parts, err := ioutil.ReadDir(filepath.Join(storeDir, fileStoreUploadsDir))
+	require.NoError(t, err)
+	assert.Empty(t, parts)
+}
+
+func TestResumeUploadsContinuesFromLastUploadedPartAfterRestart(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	storeDir, err := ioutil.TempDir("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(storeDir)
+	data := uploadData()
+	writeDoneBundle(t, workdir, "bundle-0", data)
+	writeDoneBundle(t, workdir, "bundle-1", []byte("never uploaded"))
+
+	uploader, err := NewUploader("file://"+storeDir, UploadOptions{PartSize: minUploadPartSize})
+	require.NoError(t, err)
+	store := &countingObjectStore{ObjectStore: uploader.store, attempts: map[int]int{}, failures: map[int]bool{2: true}}
+	uploader.store = store
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithUploader(uploader)
+
+	assert.Error(t, bh.upload(context.TODO(), "bundle-0", uploader.key("bundle-0"), store, uploader.options))
+	interrupted, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	require.Len(t, interrupted.Upload.Parts, 1)
+
+	// a new handler and uploader read the progress from the state file as the daemon does after a restart
+	restartedUploader, err := NewUploader("file://"+storeDir, UploadOptions{PartSize: minUploadPartSize})
+	require.NoError(t, err)
+	restartedStore := &countingObjectStore{ObjectStore: restartedUploader.store, attempts: map[int]int{}}
+	restartedUploader.store = restartedStore
+	restarted, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	restarted.WithUploader(restartedUploader)
+
+	restarted.ResumeUploads(context.TODO())
+
+	assert.Equal(t, map[int]int{2: 1, 3: 1}, restartedStore.attempts)
+	bundle, err := restarted.getBundleState("bundle-0")
+	require.NoError(t, err)
+	assert.True(t, bundle.Upload.Done)
+	assert.Equal(t, interrupted.Upload.UploadID, bundle.Upload.UploadID)
+	uploaded, err := ioutil.ReadFile(filepath.Join(storeDir, "bundle-0.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, data, uploaded)
+
+	// only interrupted uploads are resumed
+	assert.NoFileExists(t, filepath.Join(storeDir, "bundle-1.zip"))
+}
+
+func TestUploadHandlerRejectsBundlesThatCouldNotBeUploaded(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	storeDir, err := ioutil.TempDir("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(storeDir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	for _, b := range []Bundle{
+		{ID: "in-progress", Status: InProgress},
+		{ID: "directory", Status: Done, Layout: LayoutDirectory},
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workdir, b.ID, contentsDir), dirPerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, b.ID, dataFileName), nil, filePerm))
+		_, err = bh.writeStateFile(b)
+		require.NoError(t, err)
+	}
+
+	upload := func(bh *BundleHandler, id string) *httptest.ResponseRecorder {
+		router := mux.NewRouter()
+		router.HandleFunc(bundleUploadEndpoint, bh.Upload).Methods(http.MethodPost)
+		req, err := http.NewRequest(http.MethodPost, bundlesEndpoint+"/"+id+"/upload", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := upload(bh, "directory")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"code":404,"error":"bundle upload is disabled"}`, rr.Body.String())
+
+	uploader, err := NewUploader("file://"+storeDir, UploadOptions{})
+	require.NoError(t, err)
+
+	for id, code := range map[string]int{
+		"missing":     http.StatusNotFound,
+		"in-progress": http.StatusConflict,
+		"directory":   http.StatusBadRequest,
+	} {
+		assert.Equal(t, code, upload(bh.WithUploader(uploader), id).Code, id)
+	}
+}
+
+func TestNewUploaderSelectsStoreByURLScheme(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(storeDir)
+
+	uploader, err := NewUploader("file://"+storeDir, UploadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, fileStore{dir: storeDir}, uploader.store)
+	assert.Equal(t, "bundle-0.zip", uploader.key("bundle-0"))
+
+	_, err = NewUploader("s3://bucket/bundles", UploadOptions{})
+	assert.EqualError(t, err, "AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to upload bundles to S3")
+
+	for name, value := range map[string]string{"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		require.NoError(t, os.Setenv(name, value))
+		defer os.Unsetenv(name)
+	}
+	uploader, err = NewUploader("s3://bucket/clusters/a/?region=eu-west-1", UploadOptions{})
+	require.NoError(t, err)
+	store, ok := uploader.store.(*s3Store)
+	require.True(t, ok)
+	assert.Equal(t, "https://s3.eu-west-1.amazonaws.com", store.endpoint.String())
+	assert.Equal(t, "bucket", store.bucket)
+	assert.Equal(t, "eu-west-1", store.region)
+	assert.Equal(t, "clusters/a/bundle-0.zip", uploader.key("bundle-0"))
+
+	uploader, err = NewUploader("s3://bucket?endpoint=http://127.0.0.1:9000", UploadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:9000", uploader.store.(*s3Store).endpoint.String())
+	assert.Equal(t, "us-east-1", uploader.store.(*s3Store).region)
+
+	for rawURL, expected := range map[string]string{
+		"ftp://host/dir":  `unsupported upload URL scheme "ftp", use s3 or file`,
+		"file://host/dir": "invalid upload URL file://host/dir: path must be absolute",
+		"s3:///prefix":    "invalid upload URL s3:///prefix: no bucket",
+	} {
+		_, err := NewUploader(rawURL, UploadOptions{})
+		assert.EqualError(t, err, expected, rawURL)
+	}
+}
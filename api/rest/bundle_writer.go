@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// bundleWriter is a sink for data gathered by collectors
+type bundleWriter interface {
+	// Create adds a file with given name to the bundle and returns a writer for its content.
+	// The writer is valid only until the next call to Create or Close.
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+// zipBundleWriter stores bundle content in a single zip file
+type zipBundleWriter struct {
+	*zip.Writer
+	file   io.Closer
+	method uint16
+}
+
+func newZipBundleWriter(file io.WriteCloser, compression Compression) *zipBundleWriter {
+	w := zip.NewWriter(file)
+	registerCompressors(w)
+	return &zipBundleWriter{Writer: w, file: file, method: compression.zipMethod()}
+}
+
+func (w *zipBundleWriter) Create(name string) (io.Writer, error) {
+	return w.Writer.CreateHeader(&zip.FileHeader{Name: name, Method: w.method})
+}
+
+func (w *zipBundleWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// dirBundleWriter stores bundle content as regular files in a directory
+type dirBundleWriter struct {
+	dir     string
+	current *os.File
+}
+
+func newDirBundleWriter(dir string) (*dirBundleWriter, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, err
+	}
+	return &dirBundleWriter{dir: dir}, nil
+}
+
+func (w *dirBundleWriter) Create(name string) (io.Writer, error) {
+	if err := w.closeCurrent(); err != nil {
+		return nil, err
+	}
+
+	// clean the name as it was absolute so it can't point outside of the bundle directory
+	path := filepath.Join(w.dir, filepath.Clean(string(filepath.Separator)+name))
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, filePerm)
+	if err != nil {
+		return nil, err
+	}
+	w.current = f
+	return f, nil
+}
+
+func (w *dirBundleWriter) Close() error {
+	return w.closeCurrent()
+}
+
+func (w *dirBundleWriter) closeCurrent() error {
+	if w.current == nil {
+		return nil
+	}
+	err := w.current.Close()
+	w.current = nil
+	return err
+}
+
+// dirSize returns a total size of regular files in the directory
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// writeTarGz writes all regular files from the directory into a gzipped tar archive.
+// Paths in the archive are relative to the directory.
+func writeTarGz(w io.Writer, dir string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(tarWriter, f); err != nil {
+			return fmt.Errorf("could not copy %s: %s", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+)
+
+// capabilitiesFileName is the bundle entry listing collectors degraded by capabilities the daemon does not have
+const capabilitiesFileName = "capabilities.json"
+
+// capabilityGap is a collector that may produce partial data because the daemon lacks some of its capabilities
+type capabilityGap struct {
+	Collector string                 `json:"collector"`
+	Missing   []collector.Capability `json:"missing"`
+}
+
+// capabilityReport is the result of the capability check done before the collection
+type capabilityReport struct {
+	// Effective is the effective capability mask of the daemon as reported by the kernel, empty when it could
+	// not be read
+	Effective string          `json:"effective,omitempty"`
+	Degraded  []capabilityGap `json:"degraded"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// checkCapabilities reads effective capabilities of the daemon from the status file and finds collectors
+// requiring capabilities that are missing
+func checkCapabilities(statusFile string, collectors []collector.Collector) capabilityReport {
+	report := capabilityReport{Degraded: []capabilityGap{}}
+	capabilities, err := collector.ReadEffectiveCapabilities(statusFile)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Effective = fmt.Sprintf("%016x", uint64(capabilities))
+	for _, c := range collectors {
+		if missing := capabilities.Missing(collector.RequiredCapabilities(c)); len(missing) > 0 {
+			report.Degraded = append(report.Degraded, capabilityGap{Collector: c.Name(), Missing: missing})
+		}
+	}
+	return report
+}
+
+// warning returns the summary of the report added to bundle warnings, empty when nothing is degraded
+func (r capabilityReport) warning() string {
+	if r.Error != "" {
+		return fmt.Sprintf("could not check capabilities: %s", r.Error)
+	}
+	if len(r.Degraded) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(r.Degraded))
+	for _, gap := range r.Degraded {
+		names = append(names, gap.Collector)
+	}
+	return fmt.Sprintf("collectors may be degraded by missing capabilities, see %s: %s",
+		capabilitiesFileName, strings.Join(names, ", "))
+}
+
+// capabilitiesCollector is a struct implementing Collector interface. It stores the capability report in the bundle.
+type capabilitiesCollector struct {
+	report capabilityReport
+}
+
+func (c capabilitiesCollector) Name() string {
+	return capabilitiesFileName
+}
+
+func (c capabilitiesCollector) Optional() bool {
+	return true
+}
+
+func (c capabilitiesCollector) Priority() int {
+	return collector.PriorityHigh
+}
+
+func (c capabilitiesCollector) Collect(ctx context.Context) (io.ReadCloser, error) {
+	data, err := json.MarshalIndent(c.report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal capabilities: %s", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// WithCapabilitiesCheck makes bundles check capabilities of the daemon read from statusFile
+// e.g., collector.ProcSelfStatus, before the collection and report collectors that may be degraded. Empty statusFile
+// disables the check.
+func (h *BundleHandler) WithCapabilitiesCheck(statusFile string) *BundleHandler {
+	h.capabilitiesFile = statusFile
+	return h
+}
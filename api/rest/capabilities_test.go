@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilitiesCheckReportsDegradedCollectors(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	// the daemon runs with CAP_DAC_READ_SEARCH only
+	statusFile := filepath.Join(workdir, "status")
+	require.NoError(t, ioutil.WriteFile(statusFile, []byte("Name:\tdcos-diagnostics\nCapEff:\t0000000000000004\n"), 0600))
+
+	collectors := []collector.Collector{
+		collector.WithCapabilities(MockCollector{name: "capture.pcap", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+			collector.CapNetRaw, collector.CapNetAdmin),
+		collector.WithCapabilities(MockCollector{name: "files.json", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+			collector.CapDacReadSearch),
+		MockCollector{name: "plain", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+
+	bh, err := NewBundleHandler(filepath.Join(workdir, "bundles"), collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithCapabilitiesCheck(statusFile)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var bundle Bundle
+	require.Eventually(t, func() bool {
+		bundle, err = bh.getBundleState("bundle-0")
+		return err == nil && bundle.IsFinished()
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, Done, bundle.Status)
+	assert.Empty(t, bundle.Errors)
+	assert.Equal(t, []string{
+		"collectors may be degraded by missing capabilities, see capabilities.json: capture.pcap",
+	}, bundle.Warnings)
+
+	reader, err := zip.OpenReader(filepath.Join(workdir, "bundles", "bundle-0", dataFileName))
+	require.NoError(t, err)
+	defer reader.Close()
+	var report capabilityReport
+	for _, f := range reader.File {
+		if f.Name != capabilitiesFileName {
+			continue
+		}
+		rc, err := f.Open()
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(rc).Decode(&report))
+		rc.Close()
+	}
+	assert.Equal(t, capabilityReport{
+		Effective: "0000000000000004",
+		Degraded: []capabilityGap{
+			{Collector: "capture.pcap", Missing: []collector.Capability{collector.CapNetRaw, collector.CapNetAdmin}},
+		},
+	}, report)
+}
+
+func TestCheckCapabilitiesReportsUnreadableStatus(t *testing.T) {
+	report := checkCapabilities(filepath.Join(os.TempDir(), "missing-status"), nil)
+	assert.Empty(t, report.Effective)
+	assert.Empty(t, report.Degraded)
+	assert.Contains(t, report.warning(), "could not check capabilities: could not read capabilities")
+
+	assert.Empty(t, capabilityReport{}.warning())
+}
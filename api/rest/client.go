@@ -3,11 +3,15 @@ package rest
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -28,10 +32,16 @@ type Client interface {
 	List(ctx context.Context, node string) ([]*Bundle, error)
 	// Delete will delete the bundle with the given id from the given node
 	Delete(ctx context.Context, node string, ID string) error
+	// GetReport returns report.json of the cluster bundle with the given ID stored on the given node
+	GetReport(ctx context.Context, node string, ID string) ([]byte, error)
 }
 
 type DiagnosticsClient struct {
 	client *http.Client
+	// maxFileSize limits the size of downloaded bundle files in bytes, 0 means unlimited
+	maxFileSize int64
+	// credentials authenticate requests to nodes stored in the request context with withNode
+	credentials NodeCredentials
 }
 
 // NewDiagnosticsClient constructs a diagnostics client
@@ -41,6 +51,19 @@ func NewDiagnosticsClient(client *http.Client) DiagnosticsClient {
 	}
 }
 
+// WithMaxFileSize returns a copy of the client that stops downloading bundle files larger than size bytes.
+// Zero means there is no limit.
+func (d DiagnosticsClient) WithMaxFileSize(size int64) DiagnosticsClient {
+	d.maxFileSize = size
+	return d
+}
+
+// WithCredentials returns a copy of the client that authenticates to nodes with their credentials
+func (d DiagnosticsClient) WithCredentials(credentials NodeCredentials) DiagnosticsClient {
+	d.credentials = credentials
+	return d
+}
+
 func (d DiagnosticsClient) CreateBundle(ctx context.Context, node string, ID string) (*Bundle, error) {
 	url := remoteURL(node, ID)
 
@@ -59,7 +82,8 @@ func (d DiagnosticsClient) CreateBundle(ctx context.Context, node string, ID str
 		return nil, err
 	}
 
-	request.WithContext(ctx)
+	request = request.WithContext(ctx)
+	d.credentials.authorize(ctx, request)
 
 	resp, err := d.client.Do(request)
 	if err != nil {
@@ -91,7 +115,8 @@ func (d DiagnosticsClient) Status(ctx context.Context, node string, ID string) (
 		return nil, err
 	}
 
-	request.WithContext(ctx)
+	request = request.WithContext(ctx)
+	d.credentials.authorize(ctx, request)
 
 	resp, err := d.client.Do(request)
 	if err != nil {
@@ -123,6 +148,9 @@ func (d DiagnosticsClient) GetFile(ctx context.Context, node string, ID string,
 	if err != nil {
 		return err
 	}
+	request = request.WithContext(ctx)
+	forwardPrincipal(ctx, request)
+	d.credentials.authorize(ctx, request)
 
 	resp, err := d.client.Do(request)
 	if err != nil {
@@ -135,20 +163,130 @@ func (d DiagnosticsClient) GetFile(ctx context.Context, node string, ID string,
 		return err
 	}
 
+	if d.maxFileSize > 0 && resp.ContentLength > d.maxFileSize {
+		return sizeExceededError(ID, d.maxFileSize)
+	}
+
 	destinationFile, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("could not create a file: %s", err)
 	}
 	defer destinationFile.Close()
 
-	_, err = io.Copy(destinationFile, resp.Body)
+	var dst io.Writer = destinationFile
+	if progress := progressFromContext(ctx); progress != nil {
+		dst = &progressWriter{w: dst, total: resp.ContentLength, progress: progress}
+	}
+	// the whole file is verified against the checksum the node computed when the bundle was done, nodes that do not
+	// send it e.g., older versions or directory bundles archived on the fly, are not verified
+	expectedChecksum := resp.Header.Get(checksumHeader)
+	hash := sha256.New()
+	if expectedChecksum != "" && resp.StatusCode == http.StatusOK {
+		dst = io.MultiWriter(dst, hash)
+	}
+	var src io.Reader = resp.Body
+	if d.maxFileSize > 0 {
+		// read one byte more than allowed to detect the bundle is too big without downloading all of it
+		src = io.LimitReader(resp.Body, d.maxFileSize+1)
+	}
+
+	n, err := io.Copy(dst, src)
 	if err != nil {
+		removePartialFile(destinationFile, path)
 		return err
 	}
+	if d.maxFileSize > 0 && n > d.maxFileSize {
+		removePartialFile(destinationFile, path)
+		return sizeExceededError(ID, d.maxFileSize)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		removePartialFile(destinationFile, path)
+		return &DiagnosticsBundleCorruptError{
+			id:     ID,
+			reason: fmt.Sprintf("incomplete download: got %d of %d bytes", n, resp.ContentLength),
+		}
+	}
+	if expectedChecksum != "" && resp.StatusCode == http.StatusOK {
+		if checksum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(checksum, expectedChecksum) {
+			removePartialFile(destinationFile, path)
+			return &DiagnosticsBundleCorruptError{
+				id:     ID,
+				reason: fmt.Sprintf("checksum mismatch: expected %s, got %s", expectedChecksum, checksum),
+			}
+		}
+	}
 
 	return nil
 }
 
+func (d DiagnosticsClient) GetReport(ctx context.Context, node string, ID string) ([]byte, error) {
+	url := fmt.Sprintf("%s/report", remoteURL(node, ID))
+
+	logrus.WithField("ID", ID).WithField("url", url).Debug("getting report of bundle from node")
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	forwardPrincipal(ctx, request)
+	d.credentials.authorize(ctx, request)
+
+	resp, err := d.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = handleErrorCode(resp, url, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func removePartialFile(f *os.File, path string) {
+	f.Close()
+	if e := os.Remove(path); e != nil {
+		logrus.WithError(e).WithField("path", path).Warn("Could not remove partially downloaded bundle")
+	}
+}
+
+// ProgressFunc is called while a bundle file is downloaded with the number of bytes written so far
+// and the total size advertised by the node, -1 when the size is unknown
+type ProgressFunc func(written int64, total int64)
+
+type progressKey struct{}
+
+// withProgress stores the callback in ctx so GetFile reports download progress to it
+func withProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	progress, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return progress
+}
+
+// progressWriter reports the number of bytes written through it
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.progress(p.written, p.total)
+	return n, err
+}
+
+func sizeExceededError(bundleID string, maxSize int64) error {
+	return fmt.Errorf("size exceeded: bundle %s is larger than %d bytes", bundleID, maxSize)
+}
+
 func (d DiagnosticsClient) List(ctx context.Context, node string) ([]*Bundle, error) {
 	url := fmt.Sprintf("%s%s", node, bundlesEndpoint)
 
@@ -158,6 +296,8 @@ func (d DiagnosticsClient) List(ctx context.Context, node string) ([]*Bundle, er
 	if err != nil {
 		return nil, err
 	}
+	request = request.WithContext(ctx)
+	d.credentials.authorize(ctx, request)
 
 	resp, err := d.client.Do(request)
 	if err != nil {
@@ -191,6 +331,9 @@ func (d DiagnosticsClient) Delete(ctx context.Context, node string, id string) e
 	if err != nil {
 		return err
 	}
+	request = request.WithContext(ctx)
+	forwardPrincipal(ctx, request)
+	d.credentials.authorize(ctx, request)
 
 	resp, err := d.client.Do(request)
 	if err != nil {
@@ -207,6 +350,10 @@ func handleErrorCode(resp *http.Response, url string, bundleID string) error {
 		return &DiagnosticsBundleNotFoundError{id: bundleID}
 	case resp.StatusCode == http.StatusInternalServerError:
 		return &DiagnosticsBundleUnreadableError{id: bundleID}
+	case resp.StatusCode == http.StatusConflict:
+		return &DiagnosticsBundleAlreadyExists{id: bundleID}
+	case resp.StatusCode == http.StatusGatewayTimeout:
+		return &DiagnosticsBundleTimeoutError{id: bundleID}
 	case resp.StatusCode != http.StatusOK:
 		body := make([]byte, 100)
 		resp.Body.Read(body)
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptingWriter flips a byte of the response body at the given offset like a flaky link would
+type corruptingWriter struct {
+	http.ResponseWriter
+	offset  int64
+	written int64
+}
+
+func (c *corruptingWriter) Write(b []byte) (int, error) {
+	if c.offset >= c.written && c.offset < c.written+int64(len(b)) {
+		b = append([]byte{}, b...)
+		b[c.offset-c.written] ^= 0xff
+	}
+	c.written += int64(len(b))
+	return c.ResponseWriter.Write(b)
+}
+
+func TestGetFileVerifiesChecksumOfNodeBundle(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader(bytes.Repeat([]byte("data"), 1<<14)))},
+	}
+	bh, err := NewBundleHandler(filepath.Join(workdir, "bundles"), collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleFileEndpoint, bh.GetFile).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	var bundle Bundle
+	require.Eventually(t, func() bool {
+		bundle, err = bh.getBundleState("bundle-0")
+		return err == nil && bundle.Status == Done
+	}, time.Second, time.Millisecond)
+	require.NotEmpty(t, bundle.Checksum)
+
+	corrupt := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if corrupt {
+			w = &corruptingWriter{ResponseWriter: w, offset: 100}
+		}
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	client := NewDiagnosticsClient(server.Client())
+	path := filepath.Join(workdir, "node-bundle.zip")
+
+	require.NoError(t, client.GetFile(context.TODO(), server.URL, "bundle-0", path))
+	checksum, err := fileChecksum(path)
+	require.NoError(t, err)
+	assert.Equal(t, bundle.Checksum, checksum)
+
+	corrupt = true
+	err = client.GetFile(context.TODO(), server.URL, "bundle-0", path)
+	require.Error(t, err)
+	assert.IsType(t, &DiagnosticsBundleCorruptError{}, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "checksum mismatch: expected "+bundle.Checksum), err.Error())
+	assert.NoFileExists(t, path)
+}
+
+func TestGetFileWithoutChecksumIsNotVerified(t *testing.T) {
+	client := NewDiagnosticsClient(&http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			ContentLength: 4,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(strings.NewReader("test")),
+			Request:       r,
+		}, nil
+	})})
+
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.RemoveAll(f.Name())
+
+	assert.NoError(t, client.GetFile(context.TODO(), "http://192.0.2.1", "bundle-0", f.Name()))
+}
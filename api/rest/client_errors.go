@@ -2,6 +2,25 @@ package rest
 
 import (
 	"fmt"
+	"net/http"
+)
+
+// DiagnosticsError is implemented by errors about bundles. It exposes a stable code clients could
+// rely on instead of the message and the HTTP status the error is reported with.
+type DiagnosticsError interface {
+	error
+	Code() string
+	Status() int
+}
+
+// Codes of DiagnosticsError
+const (
+	ErrorCodeNotFound      = "bundle_not_found"
+	ErrorCodeUnreadable    = "bundle_unreadable"
+	ErrorCodeAlreadyExists = "bundle_already_exists"
+	ErrorCodeCorrupt       = "bundle_corrupt"
+	ErrorCodeTimeout       = "bundle_timeout"
+	ErrorCodeNotLeader     = "not_leader"
 )
 
 type DiagnosticsBundleNotFoundError struct {
@@ -12,6 +31,14 @@ func (d *DiagnosticsBundleNotFoundError) Error() string {
 	return fmt.Sprintf("bundle %s not found", d.id)
 }
 
+func (d *DiagnosticsBundleNotFoundError) Code() string {
+	return ErrorCodeNotFound
+}
+
+func (d *DiagnosticsBundleNotFoundError) Status() int {
+	return http.StatusNotFound
+}
+
 type DiagnosticsBundleUnreadableError struct {
 	id string
 }
@@ -20,6 +47,14 @@ func (d *DiagnosticsBundleUnreadableError) Error() string {
 	return fmt.Sprintf("bundle %s not readable", d.id)
 }
 
+func (d *DiagnosticsBundleUnreadableError) Code() string {
+	return ErrorCodeUnreadable
+}
+
+func (d *DiagnosticsBundleUnreadableError) Status() int {
+	return http.StatusInternalServerError
+}
+
 type DiagnosticsBundleAlreadyExists struct {
 	id string
 }
@@ -27,3 +62,89 @@ type DiagnosticsBundleAlreadyExists struct {
 func (d *DiagnosticsBundleAlreadyExists) Error() string {
 	return fmt.Sprintf("bundle %s already exists", d.id)
 }
+
+func (d *DiagnosticsBundleAlreadyExists) Code() string {
+	return ErrorCodeAlreadyExists
+}
+
+func (d *DiagnosticsBundleAlreadyExists) Status() int {
+	return http.StatusConflict
+}
+
+// DiagnosticsBundleCorruptError is returned when the bundle exists but its content is damaged,
+// e.g., the download from the node was cut short
+type DiagnosticsBundleCorruptError struct {
+	id     string
+	reason string
+}
+
+func (d *DiagnosticsBundleCorruptError) Error() string {
+	return fmt.Sprintf("%s of bundle %s", d.reason, d.id)
+}
+
+func (d *DiagnosticsBundleCorruptError) Code() string {
+	return ErrorCodeCorrupt
+}
+
+func (d *DiagnosticsBundleCorruptError) Status() int {
+	return http.StatusBadGateway
+}
+
+// DiagnosticsBundleTimeoutError is returned when the node did not answer about the bundle in time
+type DiagnosticsBundleTimeoutError struct {
+	id string
+}
+
+func (d *DiagnosticsBundleTimeoutError) Error() string {
+	return fmt.Sprintf("timed out waiting for bundle %s", d.id)
+}
+
+func (d *DiagnosticsBundleTimeoutError) Code() string {
+	return ErrorCodeTimeout
+}
+
+func (d *DiagnosticsBundleTimeoutError) Status() int {
+	return http.StatusGatewayTimeout
+}
+
+// NotLeaderError is returned when a cluster bundle is created on a master other than the one set to coordinate
+// cluster bundles, the bundle should be created on the leader instead
+type NotLeaderError struct {
+	leader string
+}
+
+func (d *NotLeaderError) Error() string {
+	return fmt.Sprintf("cluster bundles are coordinated by the leader %s, create the bundle there", d.leader)
+}
+
+func (d *NotLeaderError) Code() string {
+	return ErrorCodeNotLeader
+}
+
+func (d *NotLeaderError) Status() int {
+	return http.StatusMisdirectedRequest
+}
+
+// errorStatus returns the HTTP status the error should be reported with, errors
+// not implementing DiagnosticsError are internal errors
+func errorStatus(err error) int {
+	if d, ok := err.(DiagnosticsError); ok {
+		return d.Status()
+	}
+	return http.StatusInternalServerError
+}
+
+// writeDiagnosticsError writes the error with the status it maps to
+func writeDiagnosticsError(w http.ResponseWriter, err error) {
+	writeJSONError(w, errorStatus(err), err)
+}
+
+// bundleFoundButFailed returns true if the error tells the bundle exists on the node but something
+// else was wrong with it so looking for it on other nodes is pointless
+func bundleFoundButFailed(err error) bool {
+	switch err.(type) {
+	case *DiagnosticsBundleUnreadableError, *DiagnosticsBundleCorruptError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsErrorsMapToStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		reason string
+	}{
+		{&DiagnosticsBundleNotFoundError{id: "bundle"}, http.StatusNotFound, ErrorCodeNotFound},
+		{&DiagnosticsBundleUnreadableError{id: "bundle"}, http.StatusInternalServerError, ErrorCodeUnreadable},
+		{&DiagnosticsBundleAlreadyExists{id: "bundle"}, http.StatusConflict, ErrorCodeAlreadyExists},
+		{&DiagnosticsBundleCorruptError{id: "bundle", reason: "truncated"}, http.StatusBadGateway, ErrorCodeCorrupt},
+		{&DiagnosticsBundleTimeoutError{id: "bundle"}, http.StatusGatewayTimeout, ErrorCodeTimeout},
+		{errors.New("some error"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.status, errorStatus(tt.err))
+
+			rr := httptest.NewRecorder()
+			writeDiagnosticsError(rr, tt.err)
+			assert.Equal(t, tt.status, rr.Code)
+
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, ErrorResponse{Code: tt.status, Error: tt.err.Error(), Reason: tt.reason}, resp)
+		})
+	}
+}
+
+func TestHandleErrorCodeReturnsTimeoutError(t *testing.T) {
+	err := handleErrorCode(&http.Response{StatusCode: http.StatusGatewayTimeout}, "http://192.0.2.1", "bundle")
+	assert.IsType(t, &DiagnosticsBundleTimeoutError{}, err)
+	assert.Equal(t, http.StatusGatewayTimeout, errorStatus(err))
+}
+
+func TestHandleErrorCodeReturnsAlreadyExistsError(t *testing.T) {
+	err := handleErrorCode(&http.Response{StatusCode: http.StatusConflict}, "http://192.0.2.1", "bundle")
+	assert.IsType(t, &DiagnosticsBundleAlreadyExists{}, err)
+}
@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"context"
+)
+
+// LimitedClient is a Client limiting the number of concurrent requests to other nodes. The same client is shared
+// by all cluster bundle operations so a burst of them could not overwhelm the cluster. Requests over the limit wait
+// for a free slot until their context is done.
+type LimitedClient struct {
+	client Client
+	slots  chan struct{}
+}
+
+// NewLimitedClient wraps client so at most limit requests are in flight at once. It returns client as it is when
+// limit is not positive.
+func NewLimitedClient(client Client, limit int) Client {
+	if limit <= 0 {
+		return client
+	}
+	return &LimitedClient{
+		client: client,
+		slots:  make(chan struct{}, limit),
+	}
+}
+
+func (c *LimitedClient) acquire(ctx context.Context) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *LimitedClient) release() {
+	<-c.slots
+}
+
+func (c *LimitedClient) CreateBundle(ctx context.Context, node string, ID string) (*Bundle, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.CreateBundle(ctx, node, ID)
+}
+
+func (c *LimitedClient) Status(ctx context.Context, node string, ID string) (*Bundle, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.Status(ctx, node, ID)
+}
+
+func (c *LimitedClient) GetFile(ctx context.Context, node string, ID string, path string) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+	defer c.release()
+	return c.client.GetFile(ctx, node, ID, path)
+}
+
+func (c *LimitedClient) List(ctx context.Context, node string) ([]*Bundle, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.List(ctx, node)
+}
+
+func (c *LimitedClient) Delete(ctx context.Context, node string, ID string) error {
+	if err := c.acquire(ctx); err != nil {
+		return err
+	}
+	defer c.release()
+	return c.client.Delete(ctx, node, ID)
+}
+
+func (c *LimitedClient) GetReport(ctx context.Context, node string, ID string) ([]byte, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.GetReport(ctx, node, ID)
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inFlightCounter records the highest number of concurrent calls
+type inFlightCounter struct {
+	sync.Mutex
+	current int
+	max     int
+}
+
+func (c *inFlightCounter) call(ctx context.Context) error {
+	c.Lock()
+	c.current++
+	if c.current > c.max {
+		c.max = c.current
+	}
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		c.current--
+		c.Unlock()
+	}()
+
+	select {
+	case <-time.After(10 * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestNewLimitedClientReturnsClientWithoutLimit(t *testing.T) {
+	client := &MockClient{}
+	assert.Equal(t, client, NewLimitedClient(client, 0))
+	assert.IsType(t, &LimitedClient{}, NewLimitedClient(client, 1))
+}
+
+func TestLimitedClientIsSharedAcrossClusterOperations(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{Role: "master", IP: "192.0.2.2"},
+		{Role: "master", IP: "192.0.2.4"},
+		{Role: "master", IP: "192.0.2.5"},
+		{Role: "master", IP: "192.0.2.6"},
+	}, nil)
+
+	counter := &inFlightCounter{}
+	client := &MockClient{
+		list: func(ctx context.Context, node string) ([]*Bundle, error) {
+			return []*Bundle{}, counter.call(ctx)
+		},
+		status: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			if err := counter.call(ctx); err != nil {
+				return nil, err
+			}
+			return nil, &DiagnosticsBundleNotFoundError{id: ID}
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			if err := counter.call(ctx); err != nil {
+				return err
+			}
+			return &DiagnosticsBundleNotFoundError{id: ID}
+		},
+	}
+
+	const limit = 3
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		client:     NewLimitedClient(client, limit),
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundlesEndpoint, bh.List).Methods(http.MethodGet)
+	router.HandleFunc(bundleEndpoint, bh.Status).Methods(http.MethodGet)
+	router.HandleFunc(bundleEndpoint, bh.Delete).Methods(http.MethodDelete)
+
+	requests := []struct {
+		method   string
+		url      string
+		expected int
+	}{
+		{method: http.MethodGet, url: bundlesEndpoint, expected: http.StatusOK},
+		{method: http.MethodGet, url: bundlesEndpoint + "/bundle-0", expected: http.StatusNotFound},
+		{method: http.MethodDelete, url: bundlesEndpoint + "/bundle-0", expected: http.StatusNotFound},
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		for _, r := range requests {
+			wg.Add(1)
+			go func(method string, url string, expected int) {
+				defer wg.Done()
+				req, err := http.NewRequest(method, url, nil)
+				require.NoError(t, err)
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+				assert.Equal(t, expected, rr.Code, "%s %s", method, url)
+			}(r.method, r.url, r.expected)
+		}
+	}
+	wg.Wait()
+
+	assert.True(t, counter.max <= limit, "%d requests were in flight, limit is %d", counter.max, limit)
+	assert.Equal(t, 0, counter.current)
+}
+
+func TestLimitedClientStopsWaitingWhenContextIsDone(t *testing.T) {
+	release := make(chan struct{})
+	client := NewLimitedClient(&MockClient{
+		list: func(ctx context.Context, node string) ([]*Bundle, error) {
+			<-release
+			return nil, nil
+		},
+	}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := client.List(context.Background(), "http://192.0.2.2")
+		assert.NoError(t, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// wait for the first request to take the only slot
+	time.Sleep(10 * time.Millisecond)
+	_, err := client.List(ctx, "http://192.0.2.4")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	close(release)
+	<-done
+}
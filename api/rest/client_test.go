@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -370,6 +372,44 @@ func TestDelete(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestClientRequestsAreCanceledWithContext(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	client := DiagnosticsClient{
+		client: testServer.Client(),
+	}
+
+	requests := map[string]func(ctx context.Context) error{
+		"create": func(ctx context.Context) error {
+			_, err := client.CreateBundle(ctx, testServer.URL, "bundle-0")
+			return err
+		},
+		"status": func(ctx context.Context) error {
+			_, err := client.Status(ctx, testServer.URL, "bundle-0")
+			return err
+		},
+		"list": func(ctx context.Context) error {
+			_, err := client.List(ctx, testServer.URL)
+			return err
+		},
+		"delete": func(ctx context.Context) error {
+			return client.Delete(ctx, testServer.URL, "bundle-0")
+		},
+	}
+	for name, request := range requests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := request(ctx)
+		cancel()
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), "context deadline exceeded", name)
+	}
+}
+
 func TestDeleteWhenBundleNotFound(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "/system/health/v1/node/diagnostics/bundle-0", r.URL.Path)
@@ -417,3 +457,118 @@ func TestDeleteWhenBundleUnreadable(t *testing.T) {
 	err := client.Delete(context.TODO(), testServer.URL, "bundle-0")
 	assert.IsType(t, &DiagnosticsBundleUnreadableError{}, err)
 }
+
+func TestGetFileIsAbortedWhenContextIsDone(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		// the rest of the body never comes
+		<-release
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	client := NewDiagnosticsClient(testServer.Client())
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bundle.zip")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- client.GetFile(ctx, testServer.URL, "bundle-0", path)
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download was not aborted when the context was done")
+	}
+	assert.Error(t, err)
+	assert.NoFileExists(t, path)
+}
+
+func TestGetFileReturnsErrorWhenFileExceedsMaxSize(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		// flush headers so the content length is unknown to the client
+		w.(http.Flusher).Flush()
+		w.Write([]byte("0123456789"))
+	}))
+	defer testServer.CloseClientConnections()
+
+	client := NewDiagnosticsClient(testServer.Client()).WithMaxFileSize(5)
+
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.RemoveAll(f.Name())
+
+	err = client.GetFile(context.TODO(), testServer.URL, "bundle-0", f.Name())
+	assert.EqualError(t, err, "size exceeded: bundle bundle-0 is larger than 5 bytes")
+	assert.NoFileExists(t, f.Name())
+
+	client = client.WithMaxFileSize(10)
+	err = client.GetFile(context.TODO(), testServer.URL, "bundle-0", f.Name())
+	require.NoError(t, err)
+	contents, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0123456789"), contents)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestGetFileReturnsErrorWhenResponseIsShorterThanContentLength(t *testing.T) {
+	client := NewDiagnosticsClient(&http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			ContentLength: 10,
+			Body:          ioutil.NopCloser(strings.NewReader("01234")),
+			Request:       r,
+		}, nil
+	})})
+
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.RemoveAll(f.Name())
+
+	err = client.GetFile(context.TODO(), "http://192.0.2.1", "bundle-0", f.Name())
+	assert.EqualError(t, err, "incomplete download: got 5 of 10 bytes of bundle bundle-0")
+	assert.IsType(t, &DiagnosticsBundleCorruptError{}, err)
+	assert.NoFileExists(t, f.Name())
+}
+
+func TestGetFileReportsProgress(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("0123456789"))
+	}))
+	defer testServer.CloseClientConnections()
+
+	client := NewDiagnosticsClient(testServer.Client())
+
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.RemoveAll(f.Name())
+
+	var written, total int64
+	ctx := withProgress(context.TODO(), func(w int64, t int64) {
+		written, total = w, t
+	})
+	err = client.GetFile(ctx, testServer.URL, "bundle-0", f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), written)
+	assert.Equal(t, int64(10), total)
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+// maxEstimateSamples is the number of the most recent finished cluster bundles sizes are taken from
+const maxEstimateSamples = 10
+
+// sizeEstimate is a ballpark size of a cluster bundle computed from sizes of recent cluster bundles divided by the
+// number of nodes they collected. Collectors enabled on nodes are not known to the coordinator so they are only
+// reflected by the history.
+type sizeEstimate struct {
+	// Estimate is always true so clients could not mistake the size for a measured one
+	Estimate bool `json:"estimate"`
+	Nodes    int  `json:"nodes"`
+	// SizePerNode is the average size of a cluster bundle per collected node in bytes
+	SizePerNode int64 `json:"size_per_node"`
+	// Size is the estimated size of the cluster bundle in bytes, 0 when there is no history to estimate from
+	Size int64 `json:"size"`
+	// Samples is the number of finished cluster bundles the estimate is based on
+	Samples int    `json:"samples"`
+	Note    string `json:"note,omitempty"`
+}
+
+// Estimate returns the ballpark size of a cluster bundle created with options from the request body. Nodes are
+// resolved as for the bundle creation and sizes of their bundles are taken from recent cluster bundles stored here.
+// It does not create anything.
+func (c *ClusterBundleHandler) Estimate(w http.ResponseWriter, r *http.Request) {
+	options, err := getOptionsFromRequest(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("could not parse request body %s", err))
+		return
+	}
+	if options.ExcludeSelf && options.OnlySelf {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("exclude_self and only_self could not be used together"))
+		return
+	}
+
+	nodes, err := c.targetNodes(options)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not resolve nodes: %s", err))
+		return
+	}
+
+	sizePerNode, samples := c.recentSizePerNode()
+	estimate := sizeEstimate{
+		Estimate:    true,
+		Nodes:       len(nodes),
+		SizePerNode: sizePerNode,
+		Size:        sizePerNode * int64(len(nodes)),
+		Samples:     samples,
+	}
+	if samples == 0 {
+		estimate.Note = "no finished cluster bundles to estimate from"
+	}
+
+	write(w, jsonMarshal(estimate))
+}
+
+// targetNodes returns nodes a cluster bundle created with the options would collect
+func (c *ClusterBundleHandler) targetNodes(options options) ([]node, error) {
+	var discovered []dcos.Node
+	if options.Masters {
+		masters, err := c.tools.GetMasterNodes()
+		if err != nil {
+			return nil, fmt.Errorf("error getting master nodes: %s", err)
+		}
+		discovered = append(discovered, masters...)
+	}
+	if options.Agents {
+		agents, err := c.tools.GetAgentNodes()
+		if err != nil {
+			return nil, fmt.Errorf("error getting agent nodes: %s", err)
+		}
+		discovered = append(discovered, agents...)
+	}
+
+	nodes := make([]node, 0, len(discovered))
+	for _, n := range discovered {
+		nodes = append(nodes, node{Role: n.Role, IP: net.ParseIP(n.IP), leader: n.Leader})
+	}
+	if options.ExcludeSelf || options.OnlySelf {
+		return c.selectSelf(nodes, options.OnlySelf)
+	}
+	return nodes, nil
+}
+
+// recentSizePerNode returns the average size of the most recent finished cluster bundles per collected node
+// and the number of bundles it was computed from
+func (c *ClusterBundleHandler) recentSizePerNode() (int64, int) {
+	ids, err := ioutil.ReadDir(c.workDir)
+	if err != nil {
+		return 0, 0
+	}
+	var bundles []Bundle
+	for _, info := range ids {
+		if !info.IsDir() {
+			continue
+		}
+		bundle, err := c.readStateFile(info.Name())
+		if err != nil || bundle.Type != Cluster || bundle.Status != Done {
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].Stopped.After(bundles[j].Stopped)
+	})
+
+	var size int64
+	var nodes, samples int
+	for _, bundle := range bundles {
+		if samples == maxEstimateSamples {
+			break
+		}
+		report, err := c.readReport(bundle.ID)
+		if err != nil {
+			continue
+		}
+		collected := 0
+		for _, n := range report.Nodes {
+			if n.Status == Done {
+				collected++
+			}
+		}
+		info, err := os.Stat(filepath.Join(c.workDir, bundle.ID, dataFileName))
+		if err != nil || collected == 0 {
+			continue
+		}
+		size += info.Size()
+		nodes += collected
+		samples++
+	}
+	if nodes == 0 {
+		return 0, 0
+	}
+	return size / int64(nodes), samples
+}
+
+// readReport reads report.json stored in the directory of the cluster bundle
+func (c *ClusterBundleHandler) readReport(id string) (bundleReport, error) {
+	var report bundleReport
+	raw, err := ioutil.ReadFile(filepath.Join(c.workDir, id, reportFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, fmt.Errorf("bundle %s has no report", id)
+		}
+		return report, err
+	}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return report, fmt.Errorf("could not parse report of bundle %s: %s", id, err)
+	}
+	return report, nil
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClusterBundle stores a finished cluster bundle with a report of collected nodes and a data file of given size
+func writeClusterBundle(t *testing.T, workdir string, id string, stopped time.Time, nodes []string, size int) {
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, id), dirPerm))
+	bundle := Bundle{ID: id, Type: Cluster, Status: Done, Stopped: stopped}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, stateFileName), jsonMarshal(bundle), filePerm))
+
+	report := bundleReport{ID: id, Nodes: map[string]nodeBundleReport{}}
+	for _, ip := range nodes {
+		report.Nodes[ip] = nodeBundleReport{Status: Done}
+	}
+	report.Nodes["192.0.2.99"] = nodeBundleReport{Status: Failed, Err: "some error"}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, reportFileName), jsonMarshal(report), filePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, dataFileName), bytes.Repeat([]byte("x"), size), filePerm))
+}
+
+func estimate(t *testing.T, bh *ClusterBundleHandler, body string) sizeEstimate {
+	req, err := http.NewRequest(http.MethodPost, "/diagnostics-estimate", strings.NewReader(body))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	bh.Estimate(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var e sizeEstimate
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &e))
+	return e
+}
+
+func TestEstimateScalesWithNodeCount(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	// 3000 bytes of 3 nodes and 1000 bytes of 1 node give 1000 bytes per node
+	now := time.Now()
+	writeClusterBundle(t, workdir, "bundle-0", now.Add(-time.Hour), []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, 3000)
+	writeClusterBundle(t, workdir, "bundle-1", now, []string{"192.0.2.2"}, 1000)
+	// unfinished bundles are ignored
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, "bundle-2"), dirPerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, "bundle-2", stateFileName),
+		jsonMarshal(Bundle{ID: "bundle-2", Type: Cluster, Status: InProgress}), filePerm))
+
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("192.0.2.2", nil)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Leader: true, Role: "master", IP: "192.0.2.2"}}, nil)
+	agents := make([]dcos.Node, 0, 5)
+	for i := 0; i < 5; i++ {
+		agents = append(agents, dcos.Node{Role: "agent", IP: fmt.Sprintf("192.0.2.%d", 10+i)})
+	}
+	tools.On("GetAgentNodes").Return(agents, nil)
+
+	bh := &ClusterBundleHandler{workDir: workdir, tools: tools}
+
+	all := estimate(t, bh, `{}`)
+	assert.Equal(t, sizeEstimate{Estimate: true, Nodes: 6, SizePerNode: 1000, Size: 6000, Samples: 2}, all)
+
+	masters := estimate(t, bh, `{"masters": true, "agents": false}`)
+	assert.Equal(t, sizeEstimate{Estimate: true, Nodes: 1, SizePerNode: 1000, Size: 1000, Samples: 2}, masters)
+
+	agentsOnly := estimate(t, bh, `{"exclude_self": true}`)
+	assert.Equal(t, 5, agentsOnly.Nodes)
+	assert.EqualValues(t, 5000, agentsOnly.Size)
+}
+
+func TestEstimateWithoutHistory(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Leader: true, Role: "master", IP: "192.0.2.2"}}, nil)
+	tools.On("GetAgentNodes").Return([]dcos.Node{{Role: "agent", IP: "192.0.2.1"}}, nil)
+
+	bh := &ClusterBundleHandler{workDir: workdir, tools: tools}
+
+	assert.Equal(t, sizeEstimate{Estimate: true, Nodes: 2, Note: "no finished cluster bundles to estimate from"},
+		estimate(t, bh, `{}`))
+}
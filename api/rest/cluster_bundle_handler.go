@@ -2,6 +2,8 @@ package rest
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +21,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// createRetryWindow is how long after a bundle was started its creation could be retried without a conflict
+const createRetryWindow = 5 * time.Minute
+
 // ClusterBundleHandler is a handler that will create and manage cluster-wide
 // diagnostics bundles
 type ClusterBundleHandler struct {
@@ -29,6 +34,16 @@ type ClusterBundleHandler struct {
 	timeout    time.Duration
 	clock      Clock
 	urlBuilder dcos.NodeURLBuilder
+	// leader is an IP of the master used to coordinate cluster operations instead of the one reported by exhibitor
+	leader string
+	// tempDir holds bundles downloaded from other masters, system temp dir when not set
+	tempDir string
+	// maxDuration is a ceiling of the whole cluster collection, 0 disables it
+	maxDuration time.Duration
+	// catalog is an index of bundles updated with every state write, nil when disabled
+	catalog *Catalog
+	// duplicateNodes tells how distinct nodes sharing an IP are collected, disambiguated when not set
+	duplicateNodes DuplicateNodes
 }
 
 func NewClusterBundleHandler(c Coordinator, client Client, tools dcos.Tooler, workDir string, timeout time.Duration,
@@ -49,23 +64,103 @@ func NewClusterBundleHandler(c Coordinator, client Client, tools dcos.Tooler, wo
 	}, nil
 }
 
+// SetLeader sets an IP of the master used to coordinate cluster operations. It overrides the leader
+// reported by exhibitor which could be wrong during elections. Empty IP restores the detection.
+func (c *ClusterBundleHandler) SetLeader(ip string) {
+	c.leader = ip
+}
+
+// SetTempDir sets a directory for bundles downloaded from other masters before they are served.
+// Empty dir uses the system temp dir.
+func (c *ClusterBundleHandler) SetTempDir(dir string) {
+	c.tempDir = dir
+}
+
+// SetMaxDuration sets a ceiling of the wall-clock time of the whole cluster collection independent of the timeout
+// of node bundles. When it is reached the bundle is finished with nodes collected so far and the rest of nodes
+// is reported as failed with a timeout. Zero duration disables the ceiling.
+func (c *ClusterBundleHandler) SetMaxDuration(d time.Duration) {
+	c.maxDuration = d
+}
+
+// SetDuplicateNodes sets how distinct nodes presenting the same IP are collected
+func (c *ClusterBundleHandler) SetDuplicateNodes(duplicates DuplicateNodes) {
+	c.duplicateNodes = duplicates
+}
+
 // Create will send the initial creation request for the bundle to all nodes. The created
 // bundle will exist on the called master node
 func (c *ClusterBundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	if err := validateBundleID(id); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	options, err := getOptionsFromRequest(r)
 	if err != nil {
 		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("could not parse request body %s", err))
 		return
 	}
 
+	source, err := parseSource(options.Source)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if options.ExcludeSelf && options.OnlySelf {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("exclude_self and only_self could not be used together"))
+		return
+	}
+
+	if options.ExpectNodes < 0 {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("expect_nodes could not be negative"))
+		return
+	}
+
+	options.Source = source
+	optionsHash := options.hash()
+
 	if c.bundleExists(id) {
-		writeJSONError(w, http.StatusConflict, fmt.Errorf("bundle %s already exists", id))
+		if bundle, ok := c.retriedCreate(id, optionsHash); ok {
+			logrus.WithField("ID", id).Info("Bundle creation was retried, returning the bundle in progress")
+			write(w, jsonMarshal(bundle))
+			return
+		}
+		writeDiagnosticsError(w, &DiagnosticsBundleAlreadyExists{id: id})
 		return
 	}
 
+	leader := options.Leader
+	if leader == "" {
+		leader = c.leader
+	}
+	if leader != "" {
+		masters, err := c.getMasterNodes()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("unable to get list of masters: %s", err))
+			return
+		}
+		master, err := coordinatingMaster(masters, leader)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		// the bundle is coordinated by the leader only so it is not collected twice by different masters
+		self, err := c.tools.DetectIP()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not detect IP: %s", err))
+			return
+		}
+		if !master.IP.Equal(net.ParseIP(self)) {
+			writeDiagnosticsError(w, &NotLeaderError{leader: master.IP.String()})
+			return
+		}
+	}
+
 	bundleWorkDir := filepath.Join(c.workDir, id)
 	err = os.MkdirAll(bundleWorkDir, dirPerm)
 	if err != nil {
@@ -74,10 +169,12 @@ func (c *ClusterBundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	bundle := Bundle{
-		ID:      id,
-		Type:    Cluster,
-		Started: c.clock.Now(),
-		Status:  Started,
+		ID:          id,
+		Type:        Cluster,
+		Source:      source,
+		Started:     c.clock.Now(),
+		Status:      Started,
+		OptionsHash: optionsHash,
 	}
 
 	bundleStatus, err := c.writeStateFile(bundle)
@@ -85,6 +182,7 @@ func (c *ClusterBundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusInsufficientStorage, err)
 		return
 	}
+	recordAudit(c.workDir, id, AuditCreate, r, bundle.Started)
 
 	dataFile, err := os.Create(filepath.Join(c.workDir, id, dataFileName))
 	if err != nil {
@@ -131,16 +229,81 @@ func (c *ClusterBundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		nodes = append(nodes, node{
-			Role:    n.Role,
-			IP:      ip,
-			baseURL: url,
+			Role:     n.Role,
+			IP:       ip,
+			baseURL:  url,
+			leader:   n.Leader,
+			mesosID:  n.MesosID,
+			hostname: n.Host,
 		})
 	}
 
+	if leader != "" {
+		markLeader(nodes, leader)
+	}
+
+	nodes, warnings := resolveDuplicateNodes(nodes, c.duplicateNodes)
+	if len(warnings) > 0 {
+		logrus.WithField("ID", id).WithField("warnings", warnings).Warn("Nodes share IPs")
+		bundle.Errors = append(bundle.Errors, warnings...)
+		if bundleStatus, err = c.writeStateFile(bundle); err != nil {
+			logrus.WithField("ID", id).WithError(err).Error("Could not update state file.")
+		}
+	}
+
+	if options.ExcludeSelf || options.OnlySelf {
+		nodes, err = c.selectSelf(nodes, options.OnlySelf)
+		if err != nil {
+			dataFile.Close()
+			if e := c.failed(bundle, err); e != nil {
+				logrus.WithField("ID", bundle.ID).Error(e.Error())
+			}
+			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not select nodes for bundle %s: %s", id, err))
+			return
+		}
+	}
+
+	if options.Relay {
+		if err := c.relayAgents(nodes, leader); err != nil {
+			dataFile.Close()
+			if e := c.failed(bundle, err); e != nil {
+				logrus.WithField("ID", bundle.ID).Error(e.Error())
+			}
+			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("could not relay agents for bundle %s: %s", id, err))
+			return
+		}
+	}
+
+	if len(nodes) == 0 {
+		dataFile.Close()
+		err := fmt.Errorf("no matching nodes for bundle %s", id)
+		if e := c.failed(bundle, err); e != nil {
+			logrus.WithField("ID", bundle.ID).Error(e.Error())
+		}
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	//TODO(janisz): use context cancel function to cancel bundle creation https://jira.mesosphere.com/browse/DCOS_OSS-5222
 	//nolint:govet
 	ctx, _ := context.WithTimeout(context.Background(), c.timeout)
 
+	if options.ExpectNodes > 0 {
+		expectation := nodesExpectation{Expected: options.ExpectNodes, Resolved: len(nodes), Matched: len(nodes) == options.ExpectNodes}
+		if warning := expectation.warning(); warning != "" {
+			logrus.WithField("ID", id).Warn(warning)
+			bundle.Errors = append(bundle.Errors, warning)
+			if bundleStatus, err = c.writeStateFile(bundle); err != nil {
+				logrus.WithField("ID", id).WithError(err).Error("Could not update state file.")
+			}
+		}
+		ctx = withNodesExpectation(ctx, expectation)
+	}
+
+	if c.maxDuration > 0 {
+		ctx = withCollectionCeiling(ctx, c.maxDuration, nodes)
+	}
+
 	localBundleID, err := uuid.NewUUID()
 	if err != nil {
 		if e := c.failed(bundle, err); e != nil {
@@ -149,6 +312,7 @@ func (c *ClusterBundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("unable to create local bundle id for bundle %s: %s", id, err))
 		return
 	}
+	ctx = withClusterBundleID(ctx, id)
 	statuses := c.coord.CreateBundle(ctx, localBundleID.String(), nodes)
 
 	go c.waitAndCollectRemoteBundle(ctx, bundle, len(nodes), dataFile, statuses)
@@ -159,6 +323,18 @@ func (c *ClusterBundleHandler) Create(w http.ResponseWriter, r *http.Request) {
 type options struct {
 	Masters bool `json:"masters"`
 	Agents  bool `json:"agents"`
+	// Relay makes agents to be called through the leading master when they are not directly reachable
+	Relay bool `json:"relay"`
+	// Leader is an IP of the master used instead of the leader reported by exhibitor
+	Leader string `json:"leader"`
+	// Source tells who requested the bundle, manual when not set
+	Source Source `json:"source"`
+	// ExcludeSelf drops the node handling the request from collected nodes
+	ExcludeSelf bool `json:"exclude_self"`
+	// OnlySelf collects only the node handling the request
+	OnlySelf bool `json:"only_self"`
+	// ExpectNodes is a number of nodes the bundle should cover, fewer nodes found are reported as a warning
+	ExpectNodes int `json:"expect_nodes"`
 }
 
 var defaultOptions = options{
@@ -166,6 +342,12 @@ var defaultOptions = options{
 	Agents:  true,
 }
 
+// hash returns a hex encoded SHA-256 of the options, options should be normalized first e.g., with the source parsed
+func (o options) hash() string {
+	sum := sha256.Sum256(jsonMarshal(o))
+	return hex.EncodeToString(sum[:])
+}
+
 func getOptionsFromRequest(r *http.Request) (options, error) {
 	o := defaultOptions
 	if r.Body != nil {
@@ -203,7 +385,8 @@ func (c *ClusterBundleHandler) waitAndCollectRemoteBundle(ctx context.Context, b
 		return
 	}
 
-	_, err = io.Copy(dataFile, bundleFile)
+	checksum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(dataFile, checksum), bundleFile)
 	if err != nil {
 		logrus.WithError(err).WithField("ID", bundle.ID).Error("unable to copy bundle from temp dir working directory")
 		if e := c.failed(bundle, err); e != nil {
@@ -214,6 +397,7 @@ func (c *ClusterBundleHandler) waitAndCollectRemoteBundle(ctx context.Context, b
 
 	bundle.Stopped = c.clock.Now()
 	bundle.Status = Done
+	bundle.Checksum = hex.EncodeToString(checksum.Sum(nil))
 
 	_, err = c.writeStateFile(bundle)
 	if err != nil {
@@ -222,14 +406,68 @@ func (c *ClusterBundleHandler) waitAndCollectRemoteBundle(ctx context.Context, b
 	}
 }
 
+// retriedCreate returns the existing bundle when it is still in progress, was created with options of the given
+// hash and was started less than createRetryWindow ago. This happens when a client retries the creation after
+// the response was lost, so the retry gets the same response instead of a conflict.
+func (c *ClusterBundleHandler) retriedCreate(id string, optionsHash string) (Bundle, bool) {
+	bundle, err := c.readStateFile(id)
+	if err != nil || bundle.IsFinished() || bundle.OptionsHash != optionsHash {
+		return bundle, false
+	}
+	return bundle, c.clock.Now().Sub(bundle.Started) < createRetryWindow
+}
+
+func (c *ClusterBundleHandler) readStateFile(id string) (Bundle, error) {
+	var bundle Bundle
+	rawState, err := ioutil.ReadFile(filepath.Join(c.workDir, id, stateFileName))
+	if err != nil {
+		return bundle, fmt.Errorf("could not read state file %s: %s", id, err)
+	}
+	if err := json.Unmarshal(rawState, &bundle); err != nil {
+		return bundle, fmt.Errorf("could not parse state file %s: %s", id, err)
+	}
+	return bundle, nil
+}
+
 func (c *ClusterBundleHandler) writeStateFile(bundle Bundle) ([]byte, error) {
 	stateFilePath := filepath.Join(c.workDir, bundle.ID, stateFileName)
 	bundleStatus := jsonMarshal(bundle)
-	err := ioutil.WriteFile(stateFilePath, bundleStatus, filePerm)
+	// the state is replaced atomically so the status polled while the bundle is created is never torn
+	err := writeFileAtomically(stateFilePath, bundleStatus)
 	if err != nil {
-		err = fmt.Errorf("could not update state file %s: %s", bundle.ID, err)
+		return bundleStatus, fmt.Errorf("could not update state file %s: %s", bundle.ID, err)
 	}
-	return bundleStatus, err
+	recordInCatalog(c.catalog, c.workDir, bundle)
+	return bundleStatus, nil
+}
+
+// CancelNode cancels the collection of a single node of the cluster bundle in progress on this master.
+// The node is reported as Canceled and the rest of nodes is collected.
+func (c *ClusterBundleHandler) CancelNode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	ip := net.ParseIP(vars["node"])
+	if ip == nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid node IP %s", vars["node"]))
+		return
+	}
+
+	err := c.coord.CancelNode(id, ip.String())
+	switch err.(type) {
+	case nil:
+	case nodeNotInBundleError:
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	case nodeAlreadyCollectedError:
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	default:
+		writeDiagnosticsError(w, err)
+		return
+	}
+
+	logrus.WithField("ID", id).WithField("IP", ip).Info("Canceled collection of node")
+	write(w, jsonMarshal(nodeBundleReport{Status: Canceled, Err: nodeCanceledError{}.Error()}))
 }
 
 // List will get a list of all bundles available across all masters
@@ -244,7 +482,7 @@ func (c *ClusterBundleHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	bundles := []*Bundle{}
 	for _, n := range masters {
-		nodeBundles, err := c.client.List(ctx, n.baseURL)
+		nodeBundles, err := c.client.List(withNode(ctx, n), n.baseURL)
 		if err != nil {
 			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("unable to get list of bundles from all masters: %s", err))
 			return
@@ -271,12 +509,10 @@ func (c *ClusterBundleHandler) Status(w http.ResponseWriter, r *http.Request) {
 	// TODO: parallelize this
 	// TODO: it's very possible that we can have duplicate node IDs for the local bundles that will be generated on the master
 	for _, n := range masters {
-		bundle, err := c.client.Status(ctx, n.baseURL, id)
-		if err != nil {
-			if _, ok := err.(*DiagnosticsBundleUnreadableError); ok {
-				writeJSONError(w, http.StatusInternalServerError, err)
-				return
-			}
+		bundle, err := c.client.Status(withNode(ctx, n), n.baseURL, id)
+		if bundleFoundButFailed(err) {
+			writeDiagnosticsError(w, err)
+			return
 		}
 
 		if err == nil {
@@ -289,8 +525,39 @@ func (c *ClusterBundleHandler) Status(w http.ResponseWriter, r *http.Request) {
 	writeJSONError(w, http.StatusNotFound, fmt.Errorf("bundle %s did not exist on any masters", id))
 }
 
+// Report returns report.json of the given bundle from the master that stores it
+// without downloading the whole bundle
+func (c *ClusterBundleHandler) Report(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	masters, err := c.getMasterNodes()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("unable to get list of master nodes: %s", err))
+		return
+	}
+
+	ctx := withPrincipal(r.Context(), principal(r))
+
+	for _, n := range masters {
+		report, err := c.client.GetReport(withNode(ctx, n), n.baseURL, id)
+		if bundleFoundButFailed(err) {
+			writeDiagnosticsError(w, err)
+			return
+		}
+
+		if err == nil {
+			write(w, report)
+			return
+		}
+	}
+
+	writeJSONError(w, http.StatusNotFound, fmt.Errorf("report of bundle %s did not exist on any masters", id))
+}
+
 // Delete will delete a given bundle, proxying the call if the given bundle exists
-// on a different master
+// on a different master. Masters are called concurrently and the first Unreadable
+// error cancels the remaining calls.
 func (c *ClusterBundleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -301,21 +568,35 @@ func (c *ClusterBundleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(withPrincipal(r.Context(), principal(r)))
+	defer cancel()
 
-	found := false
+	errs := make(chan error, len(masters))
+	workers := make(chan struct{}, numberOfWorkers)
 	for _, n := range masters {
-		err = c.client.Delete(ctx, n.baseURL, id)
-		if err != nil {
-			// some errors tell us the bundle was found on a master but something else was wrong so we end and return an error status
-			// but a NotFound error means it should keep going
-			if _, ok := err.(*DiagnosticsBundleUnreadableError); ok {
-				writeJSONError(w, http.StatusInternalServerError, err)
-				return
+		go func(n node) {
+			select {
+			case workers <- struct{}{}:
+				defer func() { <-workers }()
+				errs <- c.client.Delete(withNode(ctx, n), n.baseURL, id)
+			case <-ctx.Done():
+				errs <- ctx.Err()
 			}
-		}
+		}(n)
+	}
+
+	found := false
+	for range masters {
+		err := <-errs
 		if err == nil {
 			found = true
+			continue
+		}
+		// some errors tell us the bundle was found on a master but something else was wrong so we end and return an error status
+		// but a NotFound error means it should keep going
+		if bundleFoundButFailed(err) {
+			writeDiagnosticsError(w, err)
+			return
 		}
 	}
 
@@ -342,15 +623,13 @@ func (c *ClusterBundleHandler) Download(w http.ResponseWriter, r *http.Request)
 	var masterWithBundle node
 	found := false
 	for _, n := range masters {
-		bundle, statusErr := c.client.Status(ctx, n.baseURL, id)
-		if statusErr != nil {
-			switch statusErr.(type) {
-			case *DiagnosticsBundleUnreadableError:
-				writeJSONError(w, http.StatusInternalServerError, statusErr)
-				return
-			case *DiagnosticsBundleNotFoundError:
-				continue
-			}
+		bundle, statusErr := c.client.Status(withNode(ctx, n), n.baseURL, id)
+		if bundleFoundButFailed(statusErr) {
+			writeDiagnosticsError(w, statusErr)
+			return
+		}
+		if _, ok := statusErr.(*DiagnosticsBundleNotFoundError); ok {
+			continue
 		}
 
 		if bundle.Status == Done {
@@ -360,11 +639,11 @@ func (c *ClusterBundleHandler) Download(w http.ResponseWriter, r *http.Request)
 		}
 	}
 	if !found {
-		writeJSONError(w, http.StatusNotFound, &DiagnosticsBundleNotFoundError{id: id})
+		writeDiagnosticsError(w, &DiagnosticsBundleNotFoundError{id: id})
 		return
 	}
 
-	bundleDir, err := ioutil.TempDir("", "bundle-")
+	bundleDir, err := ioutil.TempDir(c.tempDir, "bundle-")
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("error opening temp file to download bundle %s", err))
 		return
@@ -373,8 +652,13 @@ func (c *ClusterBundleHandler) Download(w http.ResponseWriter, r *http.Request)
 
 	bundleFilename := filepath.Join(bundleDir, "bundle.zip")
 
-	err = c.client.GetFile(ctx, masterWithBundle.baseURL, id, bundleFilename)
+	// the download is recorded by the master with the bundle on behalf of the caller
+	err = c.client.GetFile(withNode(withPrincipal(ctx, principal(r)), masterWithBundle), masterWithBundle.baseURL, id, bundleFilename)
 	if err != nil {
+		if _, ok := err.(DiagnosticsError); ok {
+			writeDiagnosticsError(w, err)
+			return
+		}
 		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("error downloading bundle: %s", err))
 		return
 	}
@@ -383,6 +667,77 @@ func (c *ClusterBundleHandler) Download(w http.ResponseWriter, r *http.Request)
 	http.ServeFile(w, r, bundleFilename)
 }
 
+// relayAgents replaces base URLs of agents so they are called through the coordinating master
+func (c *ClusterBundleHandler) relayAgents(nodes []node, leader string) error {
+	masters, err := c.getMasterNodes()
+	if err != nil {
+		return fmt.Errorf("unable to get list of masters: %s", err)
+	}
+	if len(masters) == 0 {
+		return fmt.Errorf("no master to relay through")
+	}
+	master, err := coordinatingMaster(masters, leader)
+	if err != nil {
+		return err
+	}
+
+	for i, n := range nodes {
+		if n.Role == dcos.MasterRole {
+			continue
+		}
+		nodes[i].baseURL = RelayURL(master.baseURL, n.IP)
+	}
+	return nil
+}
+
+// selectSelf returns only the node handling the request when only is true, otherwise all nodes except it
+func (c *ClusterBundleHandler) selectSelf(nodes []node, only bool) ([]node, error) {
+	detected, err := c.tools.DetectIP()
+	if err != nil {
+		return nil, fmt.Errorf("could not detect IP: %s", err)
+	}
+	ip := net.ParseIP(detected)
+	selected := make([]node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.IP.Equal(ip) == only {
+			selected = append(selected, n)
+		}
+	}
+	return selected, nil
+}
+
+// coordinatingMaster returns the master with the given leader IP. When the leader is not set it returns
+// the leader reported by exhibitor or the first master if none of them is marked as the leader.
+func coordinatingMaster(masters []node, leader string) (node, error) {
+	if leader != "" {
+		ip := net.ParseIP(leader)
+		for _, m := range masters {
+			if ip != nil && m.IP.Equal(ip) {
+				return m, nil
+			}
+		}
+		return node{}, fmt.Errorf("leader %s is not a master of this cluster", leader)
+	}
+
+	for _, m := range masters {
+		if m.leader {
+			return m, nil
+		}
+	}
+	if len(masters) == 0 {
+		return node{}, fmt.Errorf("no master found")
+	}
+	return masters[0], nil
+}
+
+// markLeader marks the master with the leader IP as the leader instead of the one reported by exhibitor
+func markLeader(nodes []node, leader string) {
+	ip := net.ParseIP(leader)
+	for i, n := range nodes {
+		nodes[i].leader = n.Role == dcos.MasterRole && n.IP.Equal(ip)
+	}
+}
+
 func (c *ClusterBundleHandler) getMasterNodes() ([]node, error) {
 	masters, err := c.tools.GetMasterNodes()
 	if err != nil {
@@ -400,6 +755,7 @@ func (c *ClusterBundleHandler) getMasterNodes() ([]node, error) {
 			Role:    n.Role,
 			IP:      ip,
 			baseURL: url,
+			leader:  n.Leader,
 		})
 	}
 
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -84,6 +85,118 @@ func TestRemoteBundleCreationErrorWhenOptionsAreInvalid(t *testing.T) {
 	assert.JSONEq(t, `{"code":400,"error":"could not parse request body invalid character 'i' looking for beginning of value"}`, rr.Body.String())
 }
 
+func TestRemoteBundleCreationErrorWhenLeaderIsNotMaster(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{IP: "192.0.2.10", Role: dcos.MasterRole, Leader: true}}, nil)
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      new(mockCoordinator),
+		tools:      tools,
+		timeout:    time.Second,
+		urlBuilder: MockURLBuilder{},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", strings.NewReader(`{"leader": "192.0.2.1"}`))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code":400,"error":"leader 192.0.2.1 is not a master of this cluster"}`, rr.Body.String())
+	assert.NoDirExists(t, filepath.Join(workdir, "bundle-0"))
+}
+
+func TestRemoteBundleCreationIsRejectedWhenLeaderIsNotSelf(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("192.0.2.10", nil)
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{IP: "192.0.2.10", Role: dcos.MasterRole, Leader: true},
+		{IP: "192.0.2.11", Role: dcos.MasterRole},
+	}, nil)
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      new(mockCoordinator),
+		tools:      tools,
+		timeout:    time.Second,
+		urlBuilder: MockURLBuilder{},
+	}
+	bh.SetLeader("192.0.2.11")
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusMisdirectedRequest, rr.Code)
+	assert.JSONEq(t, `{"code":421,"reason":"not_leader",`+
+		`"error":"cluster bundles are coordinated by the leader 192.0.2.11, create the bundle there"}`, rr.Body.String())
+	assert.NoDirExists(t, filepath.Join(workdir, "bundle-0"))
+}
+
+func TestRemoteBundleCreationMarksOverriddenLeader(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("192.0.2.11", nil)
+	masters := []dcos.Node{
+		{IP: "192.0.2.10", Role: dcos.MasterRole, Leader: true},
+		{IP: "192.0.2.11", Role: dcos.MasterRole},
+	}
+	tools.On("GetMasterNodes").Return(masters, nil)
+	tools.On("GetAgentNodes").Return([]dcos.Node{{IP: "192.0.2.1", Role: dcos.AgentRole}}, nil)
+
+	coord := recordingCoordinator{nodes: make(chan []node, 1)}
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      coord,
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+	bh.SetLeader("192.0.2.11")
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	leaders := map[string]bool{}
+	for _, n := range <-coord.nodes {
+		leaders[n.IP.String()+"_"+n.Role] = n.leader
+	}
+	assert.Equal(t, map[string]bool{
+		"192.0.2.10_master": false,
+		"192.0.2.11_master": true,
+		"192.0.2.1_agent":   false,
+	}, leaders)
+}
+
 func TestDeleteBundle(t *testing.T) {
 	workdir, err := ioutil.TempDir("", "work-dir")
 	require.NoError(t, err)
@@ -109,13 +222,11 @@ func TestDeleteBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Delete", ctx, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
-	client.On("Delete", ctx, "http://192.0.2.4", id).Return(nil)
-	client.On("Delete", ctx, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.4", id).Return(nil)
+	client.On("Delete", mock.Anything, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -165,13 +276,11 @@ func TestDeleteAlreadyDeletedBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Delete", ctx, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
-	client.On("Delete", ctx, "http://192.0.2.4", id).Return(nil)
-	client.On("Delete", ctx, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.4", id).Return(nil)
+	client.On("Delete", mock.Anything, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -221,13 +330,11 @@ func TestDeleteBundleThatIsntFound(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Delete", ctx, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
-	client.On("Delete", ctx, "http://192.0.2.4", id).Return(&DiagnosticsBundleNotFoundError{id: id})
-	client.On("Delete", ctx, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.4", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -277,13 +384,11 @@ func TestDeleteUnreadableBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Delete", ctx, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
-	client.On("Delete", ctx, "http://192.0.2.4", id).Return(&DiagnosticsBundleUnreadableError{id: id})
-	client.On("Delete", ctx, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.2", id).Return(&DiagnosticsBundleNotFoundError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.4", id).Return(&DiagnosticsBundleUnreadableError{id: id})
+	client.On("Delete", mock.Anything, "http://192.0.2.5", id).Return(&DiagnosticsBundleNotFoundError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -308,6 +413,145 @@ func TestDeleteUnreadableBundle(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 }
 
+func TestDeleteBundleCallsMastersConcurrently(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{Role: "master", IP: "192.0.2.2"},
+		{Role: "master", IP: "192.0.2.4"},
+		{Role: "master", IP: "192.0.2.5"},
+		{Role: "master", IP: "192.0.2.6"},
+	}, nil)
+
+	const delay = 100 * time.Millisecond
+	id := "bundle-0"
+
+	tests := []struct {
+		name      string
+		responses map[string]error
+		expected  int
+	}{
+		{
+			name: "not found on some masters and deleted on one",
+			responses: map[string]error{
+				"http://192.0.2.2": &DiagnosticsBundleNotFoundError{id: id},
+				"http://192.0.2.4": nil,
+				"http://192.0.2.5": &DiagnosticsBundleNotFoundError{id: id},
+				"http://192.0.2.6": &DiagnosticsBundleNotFoundError{id: id},
+			},
+			expected: http.StatusOK,
+		},
+		{
+			name: "not found on any master",
+			responses: map[string]error{
+				"http://192.0.2.2": &DiagnosticsBundleNotFoundError{id: id},
+				"http://192.0.2.4": &DiagnosticsBundleNotFoundError{id: id},
+				"http://192.0.2.5": &DiagnosticsBundleNotFoundError{id: id},
+				"http://192.0.2.6": &DiagnosticsBundleNotFoundError{id: id},
+			},
+			expected: http.StatusNotFound,
+		},
+		{
+			name: "unreadable on one master",
+			responses: map[string]error{
+				"http://192.0.2.2": &DiagnosticsBundleNotFoundError{id: id},
+				"http://192.0.2.4": nil,
+				"http://192.0.2.5": &DiagnosticsBundleUnreadableError{id: id},
+				"http://192.0.2.6": &DiagnosticsBundleNotFoundError{id: id},
+			},
+			expected: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &MockClient{
+				delete: func(ctx context.Context, node string, ID string) error {
+					select {
+					case <-time.After(delay):
+						return tc.responses[node]
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				},
+			}
+
+			bh := ClusterBundleHandler{
+				workDir:    workdir,
+				client:     client,
+				tools:      tools,
+				timeout:    time.Second,
+				clock:      &MockClock{},
+				urlBuilder: MockURLBuilder{},
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc(bundleEndpoint, bh.Delete).Methods(http.MethodDelete)
+
+			req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/"+id, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			start := time.Now()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expected, rr.Code)
+			assert.True(t, time.Since(start) < 3*delay, "masters should be called concurrently")
+		})
+	}
+}
+
+func TestDeleteBundleStopsWhenRequestIsCanceled(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{Role: "master", IP: "192.0.2.2"},
+		{Role: "master", IP: "192.0.2.4"},
+	}, nil)
+
+	client := &MockClient{
+		delete: func(ctx context.Context, node string, ID string) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		client:     client,
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Delete).Methods(http.MethodDelete)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Delete did not return after request was canceled")
+	}
+}
+
 func TestStatusForBundle(t *testing.T) {
 	workdir, err := ioutil.TempDir("", "work-dir")
 	require.NoError(t, err)
@@ -333,18 +577,16 @@ func TestStatusForBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	client := new(TestifyMockClient)
-	client.On("Status", ctx, "http://192.0.2.2", "bundle-0").Return(nil, fmt.Errorf("asdf"))
-	client.On("Status", ctx, "http://192.0.2.4", "bundle-0").Return(&Bundle{
+	client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", "bundle-0").Return(nil, fmt.Errorf("asdf"))
+	client.On("Status", nodeContext("192.0.2.4"), "http://192.0.2.4", "bundle-0").Return(&Bundle{
 		ID:      "bundle-0",
 		Type:    Cluster,
 		Status:  Done,
 		Started: now,
 		Stopped: now.Add(1 * time.Hour),
 	}, nil)
-	client.On("Status", ctx, "http://192.0.2.5", "bundle-0").Return(nil, fmt.Errorf("asdf"))
+	client.On("Status", nodeContext("192.0.2.5"), "http://192.0.2.5", "bundle-0").Return(nil, fmt.Errorf("asdf"))
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -401,13 +643,11 @@ func TestStatusOnMissingBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Status", ctx, "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.5", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.4"), "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.5"), "http://192.0.2.5", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -449,11 +689,9 @@ func TestStatusForUnreadableBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Status", ctx, "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleUnreadableError{id: id})
+	client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleUnreadableError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -513,23 +751,21 @@ func TestDownloadBundle(t *testing.T) {
 	expectedBytes, err := ioutil.ReadFile(bundleZip)
 	require.NoError(t, err)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Status", ctx, "http://192.0.2.1", id).Return(&Bundle{
+	client.On("Status", nodeContext("192.0.2.1"), "http://192.0.2.1", id).Return(&Bundle{
 		ID:     "bundle-0",
 		Type:   Cluster,
 		Status: Unknown,
 	}, nil)
-	client.On("Status", ctx, "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.5", id).Return(&Bundle{
+	client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.4"), "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.5"), "http://192.0.2.5", id).Return(&Bundle{
 		ID:     "bundle-0",
 		Type:   Cluster,
 		Status: Done,
 	}, nil)
-	client.On("GetFile", ctx, "http://192.0.2.5", id, mock.AnythingOfType("string")).Return(func(ctx context.Context, url string, id string, tempZipFile string) error {
+	client.On("GetFile", nodeContextWithPrincipal("192.0.2.5", anonymousPrincipal), "http://192.0.2.5", id, mock.AnythingOfType("string")).Return(func(ctx context.Context, url string, id string, tempZipFile string) error {
 		// copy the testdata zip to the location Client is expected to put the downloaded zip
 		f, err := os.Create(tempZipFile)
 		require.NoError(t, err)
@@ -562,6 +798,52 @@ func TestDownloadBundle(t *testing.T) {
 	assert.Equal(t, bytes.NewBuffer(expectedBytes), rr.Body)
 }
 
+func TestDownloadBundleUsesTempDir(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+	tempDir, err := ioutil.TempDir("", "temp-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Role: "master", IP: "192.0.2.1"}}, nil)
+
+	id := "bundle-0"
+	var downloadedTo string
+	client := new(TestifyMockClient)
+	client.On("Status", nodeContext("192.0.2.1"), "http://192.0.2.1", id).Return(&Bundle{ID: id, Type: Cluster, Status: Done}, nil)
+	client.On("GetFile", nodeContextWithPrincipal("192.0.2.1", anonymousPrincipal), "http://192.0.2.1", id, mock.AnythingOfType("string")).Return(func(ctx context.Context, url string, id string, tempZipFile string) error {
+		downloadedTo = tempZipFile
+		return ioutil.WriteFile(tempZipFile, []byte("zip"), filePerm)
+	})
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      new(mockCoordinator),
+		client:     client,
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+	bh.SetTempDir(tempDir)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleFileEndpoint, bh.Download).Methods(http.MethodGet)
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/"+id+"/file", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "zip", rr.Body.String())
+	assert.True(t, strings.HasPrefix(downloadedTo, tempDir+string(filepath.Separator)), downloadedTo)
+	// the downloaded bundle is removed once it is served
+	_, err = os.Stat(downloadedTo)
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestDownloadMissingBundle(t *testing.T) {
 	workdir, err := ioutil.TempDir("", "work-dir")
 	require.NoError(t, err)
@@ -587,13 +869,11 @@ func TestDownloadMissingBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Status", ctx, "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.5", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.4"), "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.5"), "http://192.0.2.5", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -643,13 +923,11 @@ func TestDownloadUnreadableBundle(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	id := "bundle-0"
 	client := new(TestifyMockClient)
-	client.On("Status", ctx, "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
-	client.On("Status", ctx, "http://192.0.2.5", id).Return(nil, &DiagnosticsBundleUnreadableError{id: id})
+	client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.4"), "http://192.0.2.4", id).Return(nil, &DiagnosticsBundleNotFoundError{id: id})
+	client.On("Status", nodeContext("192.0.2.5"), "http://192.0.2.5", id).Return(nil, &DiagnosticsBundleUnreadableError{id: id})
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -699,8 +977,6 @@ func TestListWithBundlesOnMultipleMasters(t *testing.T) {
 		},
 	}, nil)
 
-	ctx := context.TODO()
-
 	expectedBundles := []*Bundle{
 		{
 			ID:   "bundle-0",
@@ -717,9 +993,9 @@ func TestListWithBundlesOnMultipleMasters(t *testing.T) {
 	}
 
 	client := new(TestifyMockClient)
-	client.On("List", ctx, "http://192.0.2.2").Return([]*Bundle{expectedBundles[0]}, nil)
-	client.On("List", ctx, "http://192.0.2.4").Return([]*Bundle{expectedBundles[1]}, nil)
-	client.On("List", ctx, "http://192.0.2.5").Return([]*Bundle{expectedBundles[2]}, nil)
+	client.On("List", nodeContext("192.0.2.2"), "http://192.0.2.2").Return([]*Bundle{expectedBundles[0]}, nil)
+	client.On("List", nodeContext("192.0.2.4"), "http://192.0.2.4").Return([]*Bundle{expectedBundles[1]}, nil)
+	client.On("List", nodeContext("192.0.2.5"), "http://192.0.2.5").Return([]*Bundle{expectedBundles[2]}, nil)
 
 	coord := new(mockCoordinator)
 	bh := ClusterBundleHandler{
@@ -789,12 +1065,14 @@ func TestRemoteBundleCreationShouldFailWhenCantFindMasters(t *testing.T) {
 	assert.JSONEq(t, `{
 	  "id":"bundle-0",
 	  "type":"Cluster",
+	  "source":"manual",
 	  "status":"Failed",
 	  "started_at":"2015-08-05T09:40:51.62Z",
 	  "stopped_at":"2015-08-05T10:40:51.62Z",
 	  "errors":[
 		"some error"
-	  ]
+	  ],
+	  "options_hash":"`+requestOptionsHash(t, "")+`"
 	}`, string(state))
 }
 
@@ -843,12 +1121,14 @@ func TestRemoteBundleCreationShouldFailWhenCantFindAgents(t *testing.T) {
 	assert.JSONEq(t, `{
 	  "id":"bundle-0",
 	  "type":"Cluster",
+	  "source":"manual",
 	  "status":"Failed",
 	  "started_at":"2015-08-05T09:40:51.62Z",
 	  "stopped_at":"2015-08-05T10:40:51.62Z",
 	  "errors":[
 		"some error"
-	  ]
+	  ],
+	  "options_hash":"`+requestOptionsHash(t, "")+`"
 	}`, string(state))
 }
 
@@ -896,24 +1176,22 @@ func TestRemoteBundleCreation(t *testing.T) {
 			expectedBytes, err := ioutil.ReadFile(bundleZip)
 			require.NoError(t, err)
 
-			ctx := context.TODO()
-
 			client := new(TestifyMockClient)
-			client.On("Status", ctx, "http://192.0.2.2", "bundle-0").Return(&Bundle{
+			client.On("Status", nodeContext("192.0.2.2"), "http://192.0.2.2", "bundle-0").Return(&Bundle{
 				ID:      "bundle-0",
 				Type:    Cluster,
 				Started: now.Add(time.Hour),
 				Stopped: now.Add(2 * time.Hour),
 				Status:  Done,
 			}, nil)
-			client.On("GetFile", ctx, "http://192.0.2.2", "bundle-0", mock.AnythingOfType("string")).Return(func(_ context.Context, _ string, _ string, tempZipFile string) error {
+			client.On("GetFile", nodeContextWithPrincipal("192.0.2.2", anonymousPrincipal), "http://192.0.2.2", "bundle-0", mock.AnythingOfType("string")).Return(func(_ context.Context, _ string, _ string, tempZipFile string) error {
 				// copy the testdata zip to the location Client is expected to put the downloaded zip
 				f, err := os.Create(tempZipFile)
 				require.NoError(t, err)
 				io.Copy(f, bytes.NewBuffer(expectedBytes))
 				return nil
 			})
-			client.On("Delete", ctx, "http://192.0.2.2", "bundle-0").Return(nil)
+			client.On("Delete", mock.Anything, "http://192.0.2.2", "bundle-0").Return(nil)
 
 			coord := new(mockCoordinator)
 			bh := ClusterBundleHandler{
@@ -940,11 +1218,14 @@ func TestRemoteBundleCreation(t *testing.T) {
 				router.ServeHTTP(rr, req)
 
 				assert.Equal(t, http.StatusOK, rr.Code)
+				// all bodies are the default options
 				assert.JSONEq(t, string(jsonMarshal(Bundle{
-					ID:      "bundle-0",
-					Type:    Cluster,
-					Status:  Started,
-					Started: now.Add(time.Hour),
+					ID:          "bundle-0",
+					Type:        Cluster,
+					Source:      SourceManual,
+					Status:      Started,
+					Started:     now.Add(time.Hour),
+					OptionsHash: requestOptionsHash(t, ""),
 				})), rr.Body.String())
 
 			})
@@ -1075,16 +1356,88 @@ func TestRemoteBundleCreationWithFiltering(t *testing.T) {
 
 			assert.Equal(t, http.StatusOK, rr.Code)
 			assert.JSONEq(t, string(jsonMarshal(Bundle{
-				ID:      "bundle-0",
-				Type:    Cluster,
-				Status:  Started,
-				Started: now.Add(time.Hour),
+				ID:          "bundle-0",
+				Type:        Cluster,
+				Source:      SourceManual,
+				Status:      Started,
+				Started:     now.Add(time.Hour),
+				OptionsHash: requestOptionsHash(t, tc.body),
 			})), rr.Body.String())
 			tools.AssertNotCalled(t, tc.notCalled)
 		})
 	}
 }
 
+func TestRemoteBundleCreationFailsWhenNoNodesMatch(t *testing.T) {
+	for _, tc := range []struct {
+		body    string
+		masters []dcos.Node
+		agents  []dcos.Node
+	}{
+		{body: `{"masters": false, "agents": false}`},
+		{body: `{"masters": false}`, agents: []dcos.Node{}},
+		{body: `{}`, masters: []dcos.Node{}, agents: []dcos.Node{}},
+	} {
+		t.Run(tc.body, func(t *testing.T) {
+			workdir, err := ioutil.TempDir("", "work-dir")
+			require.NoError(t, err)
+			defer os.RemoveAll(workdir)
+
+			now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+			require.NoError(t, err)
+
+			tools := new(MockedTools)
+			tools.On("GetMasterNodes").Return(tc.masters, nil)
+			tools.On("GetAgentNodes").Return(tc.agents, nil)
+
+			coord := new(mockCoordinator)
+			bh := ClusterBundleHandler{
+				workDir:    workdir,
+				coord:      coord,
+				tools:      tools,
+				timeout:    time.Second,
+				clock:      &MockClock{now: now},
+				urlBuilder: MockURLBuilder{},
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+			req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.JSONEq(t, `{"code":400,"error":"no matching nodes for bundle bundle-0"}`, rr.Body.String())
+
+			state, err := ioutil.ReadFile(filepath.Join(workdir, "bundle-0", stateFileName))
+			require.NoError(t, err)
+			assert.JSONEq(t, string(jsonMarshal(Bundle{
+				ID:          "bundle-0",
+				Type:        Cluster,
+				Source:      SourceManual,
+				Status:      Failed,
+				Started:     now.Add(time.Hour),
+				Stopped:     now.Add(2 * time.Hour),
+				Errors:      []string{"no matching nodes for bundle bundle-0"},
+				OptionsHash: requestOptionsHash(t, tc.body),
+			})), string(state))
+		})
+	}
+}
+
+// requestOptionsHash returns the hash of options of the manual cluster bundle created with the request body
+func requestOptionsHash(t *testing.T, body string) string {
+	o := defaultOptions
+	if body != "" {
+		require.NoError(t, json.Unmarshal([]byte(body), &o))
+	}
+	o.Source = SourceManual
+	return o.hash()
+}
+
 func TestClusterBundleHandlerWorkDirIsCreatedIfNotExists(t *testing.T) {
 	t.Parallel()
 
@@ -1139,8 +1492,309 @@ func (c mockCoordinator) CollectBundle(ctx context.Context, id string, numBundle
 	return filepath.Abs(filepath.Join("testdata", "combined.zip"))
 }
 
+func (c mockCoordinator) CancelNode(bundleID string, ip string) error {
+	return &DiagnosticsBundleNotFoundError{id: bundleID}
+}
+
 type MockURLBuilder struct{}
 
 func (m MockURLBuilder) BaseURL(ip net.IP, _ string) (string, error) {
 	return fmt.Sprintf("http://%s", ip), nil
 }
+
+// recordingCoordinator remembers nodes bundles were created on
+type recordingCoordinator struct {
+	mockCoordinator
+	nodes chan []node
+}
+
+func (c recordingCoordinator) CreateBundle(ctx context.Context, id string, nodes []node) <-chan BundleStatus {
+	c.nodes <- nodes
+	return c.mockCoordinator.CreateBundle(ctx, id, nodes)
+}
+
+func TestRemoteBundleCreationSelectsSelf(t *testing.T) {
+	for _, tc := range []struct {
+		body     string
+		expected []string
+	}{
+		{body: `{}`, expected: []string{"192.0.2.2", "192.0.2.1", "192.0.2.3"}},
+		{body: `{"exclude_self": true}`, expected: []string{"192.0.2.1", "192.0.2.3"}},
+		{body: `{"only_self": true}`, expected: []string{"192.0.2.2"}},
+	} {
+		t.Run(tc.body, func(t *testing.T) {
+			workdir, err := ioutil.TempDir("", "work-dir")
+			require.NoError(t, err)
+			defer os.RemoveAll(workdir)
+
+			tools := new(MockedTools)
+			tools.On("DetectIP").Return("192.0.2.2", nil)
+			tools.On("GetMasterNodes").Return([]dcos.Node{
+				{Leader: true, Role: "master", IP: "192.0.2.2"},
+			}, nil)
+			tools.On("GetAgentNodes").Return([]dcos.Node{
+				{Role: "agent", IP: "192.0.2.1"},
+				{Role: "agent", IP: "192.0.2.3"},
+			}, nil)
+
+			coord := recordingCoordinator{nodes: make(chan []node, 1)}
+			bh := ClusterBundleHandler{
+				workDir:    workdir,
+				coord:      coord,
+				tools:      tools,
+				timeout:    time.Second,
+				clock:      &MockClock{},
+				urlBuilder: MockURLBuilder{},
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+			req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var ips []string
+			for _, n := range <-coord.nodes {
+				ips = append(ips, n.IP.String())
+			}
+			assert.Equal(t, tc.expected, ips)
+		})
+	}
+}
+
+func TestRemoteBundleCreationRejectsExcludeAndOnlySelf(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh := ClusterBundleHandler{workDir: workdir, clock: &MockClock{}}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0",
+		strings.NewReader(`{"exclude_self": true, "only_self": true}`))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code":400,"error":"exclude_self and only_self could not be used together"}`, rr.Body.String())
+	assert.NoDirExists(t, filepath.Join(workdir, "bundle-0"))
+}
+
+func TestRemoteBundleCreationRetryReturnsBundleInProgress(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Leader: true, Role: "master", IP: "192.0.2.2"}}, nil)
+	tools.On("GetAgentNodes").Return([]dcos.Node{{Role: "agent", IP: "192.0.2.1"}}, nil)
+
+	coord := blockingCoordinator{release: make(chan struct{})}
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      coord,
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &realClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", strings.NewReader(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := create(`{"agents": true}`)
+	require.Equal(t, http.StatusOK, first.Code)
+
+	// options equal after defaults are applied are the same request
+	retry := create(`{"masters": true, "source": "manual"}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	// a creation with other options is not a retry even if the bundle is in progress
+	other := create(`{"agents": false}`)
+	assert.Equal(t, http.StatusConflict, other.Code)
+	assert.JSONEq(t, `{"code":409,"error":"bundle bundle-0 already exists","reason":"bundle_already_exists"}`, other.Body.String())
+
+	close(coord.release)
+	require.Eventually(t, func() bool { return bh.bundleFinished("bundle-0") }, time.Second, 10*time.Millisecond)
+
+	finished := create("")
+	assert.Equal(t, http.StatusConflict, finished.Code)
+	assert.JSONEq(t, `{"code":409,"error":"bundle bundle-0 already exists","reason":"bundle_already_exists"}`, finished.Body.String())
+}
+
+func TestRemoteBundleCreationWarnsWhenFewerNodesThanExpected(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Leader: true, Role: "master", IP: "192.0.2.2"}}, nil)
+	tools.On("GetAgentNodes").Return([]dcos.Node{{Role: "agent", IP: "192.0.2.1"}}, nil)
+
+	client := &MockClient{
+		createBundle: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return &Bundle{ID: ID, Status: Started}, nil
+		},
+		status: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return &Bundle{ID: ID, Status: Done}, nil
+		},
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      NewParallelCoordinator(client, time.Millisecond, workdir),
+		client:     client,
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &realClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", bytes.NewReader([]byte(`{"expect_nodes": 50}`)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	warning := "expected 50 nodes but only 2 were found, discovery might be incomplete"
+	assert.Contains(t, rr.Body.String(), warning)
+
+	require.Eventually(t, func() bool { return bh.bundleFinished("bundle-0") }, time.Second, 10*time.Millisecond)
+	bundle, err := bh.readStateFile("bundle-0")
+	require.NoError(t, err)
+	assert.Equal(t, Done, bundle.Status)
+	assert.Equal(t, []string{warning}, bundle.Errors)
+
+	reader, err := zip.OpenReader(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, reportFileName, reader.File[0].Name)
+	rc, err := reader.File[0].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+
+	var report bundleReport
+	require.NoError(t, json.NewDecoder(rc).Decode(&report))
+	assert.Equal(t, &nodesExpectation{Expected: 50, Resolved: 2}, report.ExpectedNodes)
+	assert.Equal(t, []string{warning}, report.Warnings)
+}
+
+func TestRemoteBundleCreationRejectsNegativeExpectedNodes(t *testing.T) {
+	bh := ClusterBundleHandler{workDir: "testdata", clock: &realClock{}}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", bytes.NewReader([]byte(`{"expect_nodes": -1}`)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"code":400,"error":"expect_nodes could not be negative"}`, rr.Body.String())
+}
+
+func TestReportReturnsReportOfBundleFromMasterWithoutDownloadingIt(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	id := "bundle-0"
+	report := `{"id":"bundle-0","nodes":{"192.0.2.2":{"status":"Done"},"192.0.2.3":{"status":"Failed","error":"timeout"}}}`
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, id), dirPerm))
+	require.NoError(t, writeTestZip(filepath.Join(workdir, id, dataFileName), reportFileName, report))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, id, stateFileName),
+		jsonMarshal(Bundle{ID: id, Type: Cluster, Status: Done}), filePerm))
+
+	local := "local-0"
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, local), dirPerm))
+	require.NoError(t, writeTestZip(filepath.Join(workdir, local, dataFileName), "summaryReport.txt", "OK"))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, local, stateFileName),
+		jsonMarshal(Bundle{ID: local, Type: Local, Status: Done}), filePerm))
+
+	masterHandler, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	masterRouter := mux.NewRouter()
+	masterRouter.HandleFunc(bundlesEndpoint+"/{id}/report", masterHandler.Report).Methods(http.MethodGet)
+	master := httptest.NewServer(masterRouter)
+	defer master.Close()
+
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Role: "master", IP: "192.0.2.1"}}, nil)
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      new(mockCoordinator),
+		client:     NewDiagnosticsClient(http.DefaultClient),
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{},
+		urlBuilder: staticURLBuilder(master.URL),
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint+"/report", bh.Report).Methods(http.MethodGet)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, bundlesEndpoint+"/"+id+"/report", nil)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, report, rr.Body.String())
+
+	for _, missing := range []string{local, "not-existing"} {
+		rr = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, bundlesEndpoint+"/"+missing+"/report", nil)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code, missing)
+		assert.Contains(t, rr.Body.String(), "report of bundle "+missing+" did not exist on any masters")
+	}
+}
+
+func TestCancelNodeOfClusterBundle(t *testing.T) {
+	bh := ClusterBundleHandler{coord: mockCoordinator{}}
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint+"/nodes/{node}", bh.CancelNode).Methods(http.MethodDelete)
+
+	for _, tc := range []struct {
+		node string
+		code int
+	}{
+		{node: "not-an-ip", code: http.StatusBadRequest},
+		{node: "192.0.2.1", code: http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(http.MethodDelete, bundlesEndpoint+"/bundle-0/nodes/"+tc.node, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, tc.code, rr.Code, tc.node)
+	}
+}
@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a codec used to compress entries of zip bundles
+type Compression string
+
+const (
+	// CompressionDeflate is a standard zip compression supported by all tools. This is the default.
+	CompressionDeflate Compression = "deflate"
+	// CompressionZstd is faster and gives better ratio for logs but requires zip tools supporting zstd
+	CompressionZstd Compression = "zstd"
+)
+
+// zipMethodZstd is a zip compression method ID assigned to zstd (APPNOTE.TXT 4.4.5)
+const zipMethodZstd uint16 = 93
+
+// ParseCompression returns compression with given name. Empty name means the default compression.
+func ParseCompression(name string) (Compression, error) {
+	switch Compression(name) {
+	case "", CompressionDeflate:
+		return CompressionDeflate, nil
+	case CompressionZstd:
+		return CompressionZstd, nil
+	}
+	return "", fmt.Errorf("unknown compression %s, expected %s or %s", name, CompressionDeflate, CompressionZstd)
+}
+
+// zipMethod returns zip compression method ID for the compression
+func (c Compression) zipMethod() uint16 {
+	if c == CompressionZstd {
+		return zipMethodZstd
+	}
+	return zip.Deflate
+}
+
+// registerCompressors registers compressors for all supported codecs on the writer
+func registerCompressors(w *zip.Writer) {
+	w.RegisterCompressor(zipMethodZstd, func(out io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(out, zstd.WithEncoderConcurrency(1))
+	})
+}
+
+// registerDecompressors registers decompressors for all supported codecs on the reader
+func registerDecompressors(r *zip.Reader) {
+	r.RegisterDecompressor(zipMethodZstd, func(in io.Reader) io.ReadCloser {
+		decoder, err := zstd.NewReader(in, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return errorReadCloser{err}
+		}
+		return decoder.IOReadCloser()
+	})
+}
+
+// errorReadCloser fails all reads with the error
+type errorReadCloser struct {
+	err error
+}
+
+func (e errorReadCloser) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+func (e errorReadCloser) Close() error {
+	return nil
+}
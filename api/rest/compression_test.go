@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompression(t *testing.T) {
+	for name, expected := range map[string]Compression{
+		"":        CompressionDeflate,
+		"deflate": CompressionDeflate,
+		"zstd":    CompressionZstd,
+	} {
+		c, err := ParseCompression(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, c)
+	}
+
+	_, err := ParseCompression("lz4")
+	assert.EqualError(t, err, "unknown compression lz4, expected deflate or zstd")
+}
+
+func TestZstdBundleRoundTrip(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	nodeBundle := filepath.Join(workdir, "192.0.2.1_master.zip")
+	dataFile, err := os.Create(nodeBundle)
+	require.NoError(t, err)
+
+	content := sampleLog(100)
+	w := newZipBundleWriter(dataFile, CompressionZstd)
+	f, err := w.Create("dcos-diagnostics.log")
+	require.NoError(t, err)
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	t.Run("read node bundle", func(t *testing.T) {
+		r, err := zip.OpenReader(nodeBundle)
+		require.NoError(t, err)
+		defer r.Close()
+		registerDecompressors(&r.Reader)
+
+		require.Len(t, r.File, 1)
+		assert.Equal(t, zipMethodZstd, r.File[0].Method)
+		assert.Equal(t, content, readZipFile(t, r.File[0]))
+	})
+
+	t.Run("merge node bundles", func(t *testing.T) {
+		merged, err := mergeZips(bundleReport{ID: "bundle"}, []string{nodeBundle}, "", workdir, DuplicateEntriesRename)
+		require.NoError(t, err)
+
+		r, err := zip.OpenReader(merged)
+		require.NoError(t, err)
+		defer r.Close()
+
+		for _, f := range r.File {
+			if f.Name == "192.0.2.1_master/dcos-diagnostics.log" {
+				assert.Equal(t, zip.Deflate, f.Method)
+				assert.Equal(t, content, readZipFile(t, f))
+				return
+			}
+		}
+		t.Fatal("merged bundle does not contain node data")
+	})
+}
+
+func BenchmarkZipBundleWriter(b *testing.B) {
+	content := sampleLog(10000)
+
+	for _, compression := range []Compression{CompressionDeflate, CompressionZstd} {
+		b.Run(string(compression), func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			var size int
+			for i := 0; i < b.N; i++ {
+				buf := &bytes.Buffer{}
+				w := newZipBundleWriter(nopCloser{buf}, compression)
+				f, err := w.Create("sample.log")
+				require.NoError(b, err)
+				_, err = f.Write(content)
+				require.NoError(b, err)
+				require.NoError(b, w.Close())
+				size = buf.Len()
+			}
+			b.Logf("%s compressed %d bytes to %d bytes", compression, len(content), size)
+		})
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// sampleLog returns journal like log with given number of lines
+func sampleLog(lines int) []byte {
+	buf := &bytes.Buffer{}
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(buf, "Jan 02 15:04:%02d master-%d dcos-diagnostics[%d]: level=info msg=\"Collecting endpoint\" url=http://192.0.2.%d:1050/system/health/v1\n",
+			i%60, i%3, 1000+i%7, i%255)
+	}
+	return buf.Bytes()
+}
+
+func readZipFile(t *testing.T, f *zip.File) []byte {
+	rc, err := f.Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	return data
+}
@@ -8,11 +8,16 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/dcos/dcos-diagnostics/collector"
+	"github.com/dcos/dcos-diagnostics/dcos"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,6 +25,12 @@ const numberOfWorkers = 10
 const contextDoneErrMsg = "bundle creation context finished before bundle creation finished"
 const reportFileName = "report.json"
 
+// maxConflictRetries limits how many fresh local bundle IDs are tried on a node that already has a bundle with the ID
+const maxConflictRetries = 3
+
+// progressCheckpointInterval limits how often the report is persisted while node bundles are downloaded
+const progressCheckpointInterval = time.Second
+
 // BundleStatus tracks the status of local bundle creation requests
 type BundleStatus struct {
 	id   string
@@ -39,6 +50,9 @@ type Coordinator interface {
 	// CollectBundle waits until all the nodes' bundles have finished, downloads,
 	// and merges them. The resulting bundle zip file path is returned.
 	CollectBundle(ctx context.Context, bundleID string, numBundles int, statuses <-chan BundleStatus) (string, error)
+	// CancelNode cancels the collection of the node with given IP of the cluster bundle in progress,
+	// the node is reported as Canceled and the rest of nodes is collected.
+	CancelNode(bundleID string, ip string) error
 }
 
 // ParallelCoordinator implements Coordinator interface to coordinate bundle
@@ -50,6 +64,18 @@ type ParallelCoordinator struct {
 	// be checked
 	statusCheckInterval time.Duration
 	workDir             string
+	// tempDir holds node bundles downloaded before they are merged and the merged bundle
+	tempDir string
+	// conflictRetry makes creation rejected because the node already has a bundle with the ID retried with a fresh ID
+	conflictRetry bool
+	// urlBuilder resolves URLs of nodes with the other role when they could not be reached, nil disables the fallback
+	urlBuilder dcos.NodeURLBuilder
+	// reportOrder is the order of the node list of the report
+	reportOrder ReportOrder
+	// duplicateEntries tells how entries of node bundles stored under the same path are merged
+	duplicateEntries DuplicateEntries
+	// cancels tracks nodes of cluster bundles in progress so they could be canceled one by one
+	cancels *nodeCancels
 }
 
 // NewParallelCoordinator creates and returns a new ParallelCoordinator
@@ -58,20 +84,150 @@ func NewParallelCoordinator(client Client, interval time.Duration, workDir strin
 		client:              client,
 		statusCheckInterval: interval,
 		workDir:             workDir,
+		tempDir:             workDir,
+		reportOrder:         ReportOrderIP,
+		duplicateEntries:    DuplicateEntriesRename,
+		cancels:             newNodeCancels(),
 	}
 }
 
+// WithTempDir sets a directory for node bundles downloaded before they are merged and the merged bundle
+// so they could be stored on a larger disk than the work dir. Empty dir keeps them in the work dir.
+func (c *ParallelCoordinator) WithTempDir(dir string) *ParallelCoordinator {
+	if dir != "" {
+		c.tempDir = dir
+	}
+	return c
+}
+
+// WithConflictRetry makes the coordinator retry creation of node bundles rejected because the node already has
+// a bundle with the same ID using a fresh local bundle ID. Otherwise the conflict is reported for the node.
+func (c *ParallelCoordinator) WithConflictRetry(retry bool) *ParallelCoordinator {
+	c.conflictRetry = retry
+	return c
+}
+
+// WithRoleFallback makes the coordinator call nodes that could not be reached on the URL of their discovered role
+// on the URL of the other role, so nodes promoted or demoted after the discovery are still collected.
+// The role change is recorded in the report.
+func (c *ParallelCoordinator) WithRoleFallback(urlBuilder dcos.NodeURLBuilder) *ParallelCoordinator {
+	c.urlBuilder = urlBuilder
+	return c
+}
+
+// WithReportOrder sets the order of nodes in the node list of the report
+func (c *ParallelCoordinator) WithReportOrder(order ReportOrder) *ParallelCoordinator {
+	c.reportOrder = order
+	return c
+}
+
+// WithDuplicateEntries sets how entries of node bundles that would be stored under the same path of the cluster
+// bundle are handled
+func (c *ParallelCoordinator) WithDuplicateEntries(duplicates DuplicateEntries) *ParallelCoordinator {
+	c.duplicateEntries = duplicates
+	return c
+}
+
+// creationConflictError is reported for nodes that already have a bundle with the requested ID
+type creationConflictError struct {
+	id string
+}
+
+func (e creationConflictError) Error() string {
+	return fmt.Sprintf("could not create bundle: node already has bundle %s", e.id)
+}
+
 type bundleReport struct {
 	ID    string                      `json:"id"`
 	Nodes map[string]nodeBundleReport `json:"nodes"`
+	// NodeList holds the nodes of the map sorted in the configured order so reports are stable and could be diffed
+	NodeList []orderedNodeReport `json:"node_list,omitempty"`
+	// roles of nodes by IP, or IP with the node identity for nodes sharing it, used to sort the node list
+	roles map[string]string
+	// ExpectedNodes is set when the client told how many nodes the bundle should cover
+	ExpectedNodes *nodesExpectation `json:"expected_nodes,omitempty"`
+	Warnings      []string          `json:"warnings,omitempty"`
+}
+
+// nodesExpectation compares the number of nodes the client expected with the number of nodes resolved for the bundle
+type nodesExpectation struct {
+	Expected int  `json:"expected"`
+	Resolved int  `json:"resolved"`
+	Matched  bool `json:"matched"`
+}
+
+// warning returns the message reported when fewer nodes were resolved than expected, empty otherwise
+func (e nodesExpectation) warning() string {
+	if e.Resolved >= e.Expected {
+		return ""
+	}
+	return fmt.Sprintf("expected %d nodes but only %d were found, discovery might be incomplete", e.Expected, e.Resolved)
+}
+
+type nodesExpectationKey struct{}
+
+// withNodesExpectation stores the expectation in ctx so CollectBundle records it in the report
+func withNodesExpectation(ctx context.Context, e nodesExpectation) context.Context {
+	return context.WithValue(ctx, nodesExpectationKey{}, e)
+}
+
+func nodesExpectationFromContext(ctx context.Context) *nodesExpectation {
+	e, ok := ctx.Value(nodesExpectationKey{}).(nodesExpectation)
+	if !ok {
+		return nil
+	}
+	return &e
+}
+
+// collectionCeiling is a hard limit of the wall-clock time of the whole cluster collection. When it is reached
+// the bundle is finalized with nodes collected so far and the rest of nodes is reported as failed.
+type collectionCeiling struct {
+	maxDuration time.Duration
+	deadline    time.Time
+	nodes       []node
+}
+
+// ceilingExceededError is reported for nodes that were not collected before the collection ceiling was reached
+type ceilingExceededError struct {
+	maxDuration time.Duration
+}
+
+func (e ceilingExceededError) Error() string {
+	return fmt.Sprintf("timeout: cluster collection exceeded max duration of %s", e.maxDuration)
+}
+
+type collectionCeilingKey struct{}
+
+// withCollectionCeiling stores the ceiling of collection of given nodes in ctx so CollectBundle finalizes
+// the bundle when it is reached
+func withCollectionCeiling(ctx context.Context, maxDuration time.Duration, nodes []node) context.Context {
+	return context.WithValue(ctx, collectionCeilingKey{}, collectionCeiling{
+		maxDuration: maxDuration,
+		deadline:    time.Now().Add(maxDuration),
+		nodes:       nodes,
+	})
+}
+
+func collectionCeilingFromContext(ctx context.Context) *collectionCeiling {
+	c, ok := ctx.Value(collectionCeilingKey{}).(collectionCeiling)
+	if !ok {
+		return nil
+	}
+	return &c
 }
 
 type nodeBundleReport struct {
 	Status Status `json:"status"`
 	Err    string `json:"error,omitempty"`
+	// Downloaded and Size are set while the node bundle is downloaded, Size is -1 when it is unknown
+	Downloaded int64 `json:"downloaded,omitempty"`
+	Size       int64 `json:"size,omitempty"`
+	// RoleChange is set when the node was collected with a different role than it was discovered with
+	RoleChange *roleChange `json:"role_change,omitempty"`
 }
 
 type bundleToDelete struct {
+	node          node
 	baseURL       string
 	localBundleID string
 }
@@ -104,6 +260,11 @@ func (c ParallelCoordinator) CreateBundle(ctx context.Context, id string, nodes
 	jobs := make(chan job, len(nodes))
 	statuses := make(chan BundleStatus, len(nodes))
 
+	bundleID := clusterBundleIDFromContext(ctx)
+	if bundleID != "" {
+		c.cancels.start(bundleID, nodes)
+	}
+
 	for i := 0; i < numberOfWorkers; i++ {
 		go worker(ctx, jobs, statuses)
 	}
@@ -111,8 +272,10 @@ func (c ParallelCoordinator) CreateBundle(ctx context.Context, id string, nodes
 	for _, n := range nodes {
 		// necessary to prevent the closure from giving the same node to all the calls
 		tmpNode := n
-		jobs <- func(ctx context.Context) BundleStatus {
-			return c.createBundle(ctx, tmpNode, id, jobs)
+		// jobs of the node run with its own context so it could be canceled without the rest of nodes
+		nodeCtx := c.cancels.withCancel(ctx, bundleID, n.id())
+		jobs <- func(context.Context) BundleStatus {
+			return c.createBundle(nodeCtx, tmpNode, id, jobs)
 		}
 	}
 
@@ -125,63 +288,206 @@ func (c ParallelCoordinator) CollectBundle(ctx context.Context, bundleID string,
 
 	// holds the paths to the downloaded local bundles before merging
 	var bundlePaths []string
+	// holds the path to the bundle of the leading master, the cluster provenance is taken from it
+	var leaderBundlePath string
 
 	report := bundleReport{
-		ID:    bundleID,
-		Nodes: make(map[string]nodeBundleReport, numBundles),
+		ID:            bundleID,
+		Nodes:         make(map[string]nodeBundleReport, numBundles),
+		roles:         make(map[string]string, numBundles),
+		ExpectedNodes: nodesExpectationFromContext(ctx),
+	}
+	if report.ExpectedNodes != nil {
+		if warning := report.ExpectedNodes.warning(); warning != "" {
+			report.Warnings = append(report.Warnings, warning)
+		}
 	}
 
-	var bundlesToDelete = make([]bundleToDelete, numBundles)
+	var bundlesToDelete = make([]bundleToDelete, 0, numBundles)
+	defer c.cancels.finish(bundleID)
+
+	// downloads are limited by the ceiling so a slow download could not delay the bundle past it
+	downloadCtx := ctx
+	var ceilingReached <-chan time.Time
+	ceiling := collectionCeilingFromContext(ctx)
+	if ceiling != nil {
+		var cancel context.CancelFunc
+		downloadCtx, cancel = context.WithDeadline(ctx, ceiling.deadline)
+		defer cancel()
+		timer := time.NewTimer(time.Until(ceiling.deadline))
+		defer timer.Stop()
+		ceilingReached = timer.C
+	}
 
-	for finishedBundles := 0; finishedBundles < numBundles; {
+	finishedBundles := 0
+collect:
+	for finishedBundles < numBundles {
 
-		s := <-statuses
+		var s BundleStatus
+		select {
+		case s = <-statuses:
+		case <-ceilingReached:
+			c.finalizeOnCeiling(&report, *ceiling)
+			// nodes still collecting finish on their own, their bundles are removed once they do
+			go c.deleteLateBundles(statuses, numBundles-finishedBundles)
+			break collect
+		}
 
 		if !s.done {
 			logrus.WithError(s.err).WithField("IP", s.node.IP).WithField("ID", s.id).Info("Got status update. Bundle not ready.")
 			continue
 		}
 
-		bundlesToDelete[finishedBundles] = bundleToDelete{baseURL: s.node.baseURL, localBundleID: s.id}
+		// the conflicting bundle was not created by this request so it must not be deleted
+		if _, ok := s.err.(creationConflictError); !ok {
+			bundlesToDelete = append(bundlesToDelete, bundleToDelete{node: s.node, baseURL: s.node.baseURL, localBundleID: s.id})
+		}
 		// even if the bundle finished with an error, it's now finished so increment finishedBundles
 		finishedBundles++
+		report.roles[s.node.id()] = s.node.Role
 		if s.err != nil {
-			report.Nodes[s.node.IP.String()] = nodeBundleReport{Status: Failed, Err: s.err.Error()}
+			if c.cancels.finishNode(bundleID, s.node.id()) {
+				c.reportCanceled(report, s.node)
+				continue
+			}
+			report.Nodes[s.node.id()] = nodeBundleReport{Status: Failed, Err: s.err.Error(), RoleChange: s.node.roleChange()}
 			logrus.WithError(s.err).WithField("IP", s.node.IP).WithField("ID", s.id).Warn("Bundle errored")
+			c.checkpointReport(report)
 			continue
 		}
 
-		bundlePath := filepath.Join(c.workDir, nodeBundleFilename(s.node))
-		err := c.client.GetFile(ctx, s.node.baseURL, s.id, bundlePath)
+		bundlePath := filepath.Join(c.tempDir, nodeBundleFilename(s.node))
+		nodeCtx := c.cancels.withCancel(downloadCtx, bundleID, s.node.id())
+		err := c.client.GetFile(withNode(withProgress(nodeCtx, c.downloadProgress(report, s.node)), s.node), s.node.baseURL, s.id, bundlePath)
+		if c.cancels.finishNode(bundleID, s.node.id()) {
+			c.reportCanceled(report, s.node)
+			continue
+		}
+		if err != nil && ceiling != nil && downloadCtx.Err() == context.DeadlineExceeded {
+			err = ceilingExceededError{maxDuration: ceiling.maxDuration}
+		}
 		if err != nil {
-			report.Nodes[s.node.IP.String()] = nodeBundleReport{Status: Failed, Err: err.Error()}
+			report.Nodes[s.node.id()] = nodeBundleReport{Status: Failed, Err: err.Error(), RoleChange: s.node.roleChange()}
 			logrus.WithError(err).WithField("IP", s.node.IP).WithField("ID", s.id).Warn("Could not download file")
+			c.checkpointReport(report)
 			continue
 		}
 
 		logrus.WithError(s.err).WithField("IP", s.node.IP).WithField("ID", s.id).Info("Got status update. Bundle READY.")
-		report.Nodes[s.node.IP.String()] = nodeBundleReport{Status: Done}
+		report.Nodes[s.node.id()] = nodeBundleReport{Status: Done, RoleChange: s.node.roleChange()}
 		bundlePaths = append(bundlePaths, bundlePath)
+		if s.node.leader {
+			leaderBundlePath = bundlePath
+		}
+		c.checkpointReport(report)
 	}
 
 	// Run cleanup in separated goroutine so it will not block bundle generation process
 	go func() {
 		for _, b := range bundlesToDelete {
-			// Using context.Background prevents interruptions during cleanup
-			err := c.client.Delete(context.Background(), b.baseURL, b.localBundleID)
-			if err != nil {
-				logrus.WithError(err).WithField("URL", b.baseURL).
-					WithField("ID", b.localBundleID).Warn("Could not delete local bundle")
-			}
+			c.deleteLocalBundle(b)
 		}
 	}()
 
-	return mergeZips(report, bundlePaths, c.workDir)
+	return mergeZips(report.withNodeList(c.reportOrder), bundlePaths, leaderBundlePath, c.tempDir, c.duplicateEntries)
+}
+
+// CancelNode cancels the collection of the node with given IP of the cluster bundle in progress.
+// Only bundles created with the ID stored in the context of CreateBundle could be canceled.
+func (c ParallelCoordinator) CancelNode(bundleID string, ip string) error {
+	return c.cancels.cancel(bundleID, ip)
+}
+
+// reportCanceled marks the node as canceled in the report
+func (c ParallelCoordinator) reportCanceled(report bundleReport, n node) {
+	logrus.WithField("IP", n.IP).WithField("ID", report.ID).Warn("Node collection canceled")
+	report.Nodes[n.id()] = nodeBundleReport{Status: Canceled, Err: nodeCanceledError{}.Error(), RoleChange: n.roleChange()}
+	c.checkpointReport(report)
+}
+
+// finalizeOnCeiling marks nodes of the ceiling that were not collected yet as failed and warns about it in the report
+func (c ParallelCoordinator) finalizeOnCeiling(report *bundleReport, ceiling collectionCeiling) {
+	err := ceilingExceededError{maxDuration: ceiling.maxDuration}
+	notCollected := 0
+	for _, n := range ceiling.nodes {
+		id := n.id()
+		if r, ok := report.Nodes[id]; ok && r.Status != InProgress {
+			continue
+		}
+		notCollected++
+		report.roles[id] = n.Role
+		report.Nodes[id] = nodeBundleReport{Status: Failed, Err: err.Error()}
+	}
+	logrus.WithField("ID", report.ID).WithField("nodes", notCollected).Warn("Cluster collection exceeded max duration")
+	report.Warnings = append(report.Warnings, fmt.Sprintf("%s, %d nodes were not collected", err, notCollected))
+	c.checkpointReport(*report)
+}
+
+// deleteLateBundles waits for given number of node bundles finished after the bundle was finalized and deletes them
+func (c ParallelCoordinator) deleteLateBundles(statuses <-chan BundleStatus, remaining int) {
+	for remaining > 0 {
+		s := <-statuses
+		if !s.done {
+			continue
+		}
+		remaining--
+		if _, ok := s.err.(creationConflictError); !ok {
+			c.deleteLocalBundle(bundleToDelete{node: s.node, baseURL: s.node.baseURL, localBundleID: s.id})
+		}
+	}
+}
+
+func (c ParallelCoordinator) deleteLocalBundle(b bundleToDelete) {
+	// Using context.Background prevents interruptions during cleanup
+	err := c.client.Delete(withNode(context.Background(), b.node), b.baseURL, b.localBundleID)
+	if err != nil {
+		logrus.WithError(err).WithField("URL", b.baseURL).
+			WithField("ID", b.localBundleID).Warn("Could not delete local bundle")
+	}
+}
+
+// downloadProgress returns a callback updating the node download progress in the report.
+// The report is checkpointed at most once per progressCheckpointInterval.
+func (c ParallelCoordinator) downloadProgress(report bundleReport, n node) ProgressFunc {
+	var last time.Time
+	return func(written int64, total int64) {
+		report.Nodes[n.id()] = nodeBundleReport{Status: InProgress, Downloaded: written, Size: total, RoleChange: n.roleChange()}
+		if time.Since(last) >= progressCheckpointInterval {
+			last = time.Now()
+			c.checkpointReport(report)
+		}
+	}
 }
 
-func mergeZips(report bundleReport, bundlePaths []string, workDir string) (string, error) {
+// checkpointReport persists the report with statuses of already finished nodes to the bundle directory
+// so after the coordinator restarts only nodes that are not Done have to be collected again.
+// The bundle directory is created by the cluster bundle handler, when it is missing the report is not persisted.
+func (c ParallelCoordinator) checkpointReport(report bundleReport) {
+	reportPath := filepath.Join(c.workDir, report.ID, reportFileName)
+	if err := writeFileAtomically(reportPath, jsonMarshal(report.withNodeList(c.reportOrder))); err != nil {
+		logrus.WithError(err).WithField("ID", report.ID).Warn("Could not checkpoint bundle report")
+	}
+}
 
-	bundlePath := filepath.Join(workDir, fmt.Sprintf("bundle-%s.zip", report.ID))
+// writeFileAtomically writes data to a temporary file and renames it so readers never see a partial file
+func writeFileAtomically(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, filePerm); err != nil {
+		return fmt.Errorf("could not write %s: %s", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("could not rename %s to %s: %s", tmpPath, path, err)
+	}
+	return nil
+}
+
+// mergeZips merges node bundles into a single cluster bundle. Provenance of the cluster is copied
+// to the root of the cluster bundle from the leader bundle when it is given. Entries that would be stored under
+// the same path are handled according to duplicates.
+func mergeZips(report bundleReport, bundlePaths []string, leaderBundlePath string, dir string,
+	duplicates DuplicateEntries) (string, error) {
+
+	bundlePath := filepath.Join(dir, fmt.Sprintf("bundle-%s.zip", report.ID))
 	mergedZip, err := os.Create(bundlePath)
 	if err != nil {
 		return "", err
@@ -200,10 +506,22 @@ func mergeZips(report bundleReport, bundlePaths []string, workDir string) (strin
 		return "", fmt.Errorf("could not copy file %s to zip: %s", reportFileName, err)
 	}
 
+	// files written to the root of the cluster bundle are reserved so no node entry could replace them
+	entries := newZipEntries(duplicates)
+	entries.add(reportFileName, report.ID)
+	entries.add(collector.ProvenanceFileName, report.ID)
+	entries.add(summaryErrorsReportFileName, report.ID)
+
+	if leaderBundlePath != "" {
+		if err := copyProvenance(zipWriter, leaderBundlePath); err != nil {
+			logrus.WithError(err).WithField("ID", report.ID).Warn("Could not copy provenance to the bundle")
+		}
+	}
+
 	errorBuffer := bytes.NewBuffer(nil)
 
 	for _, p := range bundlePaths {
-		rc, e := appendToZip(zipWriter, p)
+		rc, e := appendToZip(zipWriter, p, entries)
 		if e != nil {
 			return "", e
 		}
@@ -213,6 +531,11 @@ func mergeZips(report bundleReport, bundlePaths []string, workDir string) (strin
 		}
 	}
 
+	for _, renamed := range entries.renamed {
+		logrus.WithField("ID", report.ID).Warn(renamed)
+		fmt.Fprintln(errorBuffer, renamed)
+	}
+
 	if errorBuffer.Len() > 0 {
 		summaryErrorsReportFile, err := zipWriter.Create(summaryErrorsReportFileName)
 		if err != nil {
@@ -227,13 +550,14 @@ func mergeZips(report bundleReport, bundlePaths []string, workDir string) (strin
 	return mergedZip.Name(), nil
 }
 
-func appendToZip(writer *zip.Writer, path string) (io.ReadCloser, error) {
+func appendToZip(writer *zip.Writer, path string, entries *zipEntries) (io.ReadCloser, error) {
 	rc := ioutil.NopCloser(bytes.NewReader(nil))
 	r, err := zip.OpenReader(path)
 	if err != nil {
 		return nil, fmt.Errorf("could not open %s: %s", path, err)
 	}
 	defer r.Close()
+	registerDecompressors(&r.Reader)
 
 	base := strings.TrimSuffix(filepath.Base(path), ".zip")
 
@@ -252,7 +576,7 @@ func appendToZip(writer *zip.Writer, path string) (io.ReadCloser, error) {
 			rc = ioutil.NopCloser(buf)
 			continue
 		}
-		if err := addFileToZip(writer, f, base); err != nil {
+		if err := addFileToZip(writer, f, base, entries); err != nil {
 			return nil, err
 		}
 	}
@@ -260,7 +584,37 @@ func appendToZip(writer *zip.Writer, path string) (io.ReadCloser, error) {
 	return rc, nil
 }
 
-func addFileToZip(writer *zip.Writer, f *zip.File, base string) error {
+// copyProvenance copies the provenance file from the node bundle at path to the root of the writer
+func copyProvenance(writer *zip.Writer, path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", path, err)
+	}
+	defer r.Close()
+	registerDecompressors(&r.Reader)
+
+	for _, f := range r.File {
+		if f.Name != collector.ProvenanceFileName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("could not open %s from zip: %s", f.Name, err)
+		}
+		defer rc.Close()
+		file, err := writer.Create(collector.ProvenanceFileName)
+		if err != nil {
+			return fmt.Errorf("could not create file %s: %s", collector.ProvenanceFileName, err)
+		}
+		if _, err := io.Copy(file, rc); err != nil {
+			return fmt.Errorf("could not copy file %s to zip: %s", collector.ProvenanceFileName, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s not found in %s", collector.ProvenanceFileName, path)
+}
+
+func addFileToZip(writer *zip.Writer, f *zip.File, base string, entries *zipEntries) error {
 	rc, err := f.Open()
 	if err != nil {
 		return fmt.Errorf("could not open %s from zip: %s", f.Name, err)
@@ -271,6 +625,10 @@ func addFileToZip(writer *zip.Writer, f *zip.File, base string) error {
 	if err != nil {
 		return err
 	}
+	fileName, err = entries.add(fileName, base+".zip")
+	if err != nil {
+		return err
+	}
 
 	file, err := writer.Create(fileName)
 	if err != nil {
@@ -294,7 +652,31 @@ func sanitizeExtractPath(filePath string, destination string) (string, error) {
 }
 
 func (c ParallelCoordinator) createBundle(ctx context.Context, node node, id string, jobs chan<- job) BundleStatus {
-	_, err := c.client.CreateBundle(ctx, node.baseURL, id)
+	localID := id
+	_, err := c.client.CreateBundle(withNode(ctx, node), node.baseURL, localID)
+	if isConnectionError(err) {
+		if fallback, ok := c.fallbackNode(node); ok {
+			logrus.WithField("IP", node.IP).WithField("role", node.Role).WithError(err).
+				Warnf("Could not reach node, retrying as %s", fallback.Role)
+			if _, e := c.client.CreateBundle(withNode(ctx, fallback), fallback.baseURL, localID); !isConnectionError(e) {
+				node, err = fallback, e
+			}
+		}
+	}
+	for attempt := 1; c.conflictRetry && isCreationConflict(err) && attempt <= maxConflictRetries; attempt++ {
+		logrus.WithField("IP", node.IP).WithField("ID", localID).Warn("Node already has bundle with the ID, retrying with a fresh ID")
+		localID = fmt.Sprintf("%s-retry-%d", id, attempt)
+		_, err = c.client.CreateBundle(withNode(ctx, node), node.baseURL, localID)
+	}
+	if isCreationConflict(err) {
+		logrus.WithField("IP", node.IP).WithField("ID", localID).Warn("Node already has bundle with the ID")
+		return BundleStatus{
+			id:   localID,
+			node: node,
+			done: true,
+			err:  creationConflictError{id: localID},
+		}
+	}
 	if err != nil {
 		// Return done status with error. To mark node as errored so file will not be downloaded
 		return BundleStatus{
@@ -306,12 +688,56 @@ func (c ParallelCoordinator) createBundle(ctx context.Context, node node, id str
 	}
 
 	// Schedule bundle status check
-	jobs <- func(ctx context.Context) BundleStatus {
-		return c.waitForDone(ctx, node, id, jobs)
+	jobs <- func(context.Context) BundleStatus {
+		return c.waitForDone(ctx, node, localID, jobs)
 	}
 
 	// Return undone status with no error.
-	return BundleStatus{id: id, node: node}
+	return BundleStatus{id: localID, node: node}
+}
+
+// fallbackNode returns the node with the other role and its URL. Nodes called through a relay or without
+// a known role have no fallback.
+func (c ParallelCoordinator) fallbackNode(n node) (node, bool) {
+	if c.urlBuilder == nil {
+		return n, false
+	}
+	var role string
+	switch n.Role {
+	case dcos.MasterRole:
+		role = dcos.AgentRole
+	case dcos.AgentRole, dcos.AgentPublicRole:
+		role = dcos.MasterRole
+	default:
+		return n, false
+	}
+	if baseURL, err := c.urlBuilder.BaseURL(n.IP, n.Role); err != nil || baseURL != n.baseURL {
+		return n, false
+	}
+	baseURL, err := c.urlBuilder.BaseURL(n.IP, role)
+	if err != nil {
+		return n, false
+	}
+	fallback := n
+	fallback.initialRole = n.Role
+	fallback.Role = role
+	fallback.baseURL = baseURL
+	return fallback, true
+}
+
+// isConnectionError returns true if the node could not be reached at all e.g., nothing listens on the port
+func isConnectionError(err error) bool {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return false
+	}
+	_, ok = urlErr.Err.(*net.OpError)
+	return ok
+}
+
+func isCreationConflict(err error) bool {
+	_, ok := err.(*DiagnosticsBundleAlreadyExists)
+	return ok
 }
 
 func (c ParallelCoordinator) waitForDone(ctx context.Context, node node, id string, jobs chan<- job) BundleStatus {
@@ -327,14 +753,14 @@ func (c ParallelCoordinator) waitForDone(ctx context.Context, node node, id stri
 	}
 
 	statusCheck := func() {
-		jobs <- func(ctx context.Context) BundleStatus {
+		jobs <- func(context.Context) BundleStatus {
 			return c.waitForDone(ctx, node, id, jobs)
 		}
 	}
 
 	logrus.WithField("IP", node.IP).Info("Checking bundle status on node.")
 	// Check bundle status
-	bundle, err := c.client.Status(ctx, node.baseURL, id)
+	bundle, err := c.client.Status(withNode(ctx, node), node.baseURL, id)
 	// If error
 	if err != nil {
 		logrus.WithField("IP", node.IP).WithError(err).Error("Error occurred checking bundle status, continuing")
@@ -359,5 +785,5 @@ func (c ParallelCoordinator) waitForDone(ctx context.Context, node node, id stri
 }
 
 func nodeBundleFilename(n node) string {
-	return fmt.Sprintf("%s_%s.zip", n.IP, n.Role)
+	return fmt.Sprintf("%s_%s.zip", n.id(), n.Role)
 }
@@ -2,16 +2,24 @@ package rest
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/dcos/dcos-diagnostics/dcos"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -40,8 +48,8 @@ func TestCoordinator_CreatorShouldCreateAbundleAndReturnUpdateChan(t *testing.T)
 	expected := []BundleStatus{}
 
 	for _, n := range testNodes {
-		client.On("CreateBundle", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
-		client.On("Status", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Done}, nil)
+		client.On("CreateBundle", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
+		client.On("Status", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Done}, nil)
 
 		expected = append(expected,
 			BundleStatus{id: localBundleID, node: n},
@@ -138,7 +146,10 @@ func TestCoordinatorCreateAndCollect(t *testing.T) {
 		filepath.Join("192.0.2.2_master", "test.txt"):       "test\n",
 		filepath.Join("192.0.2.3_public_agent", "test.txt"): "test\n",
 		summaryErrorsReportFileName: "errorerrorerror",
-		reportFileName: `{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"},"192.0.2.2":{"status":"Done"},"192.0.2.3":{"status":"Done"},"192.0.2.4":{"status":"Failed","error":"some error"},"192.0.2.5":{"status":"Failed","error":"bundle creation context finished before bundle creation finished"}}}`,
+		reportFileName: `{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"},"192.0.2.2":{"status":"Done"},"192.0.2.3":{"status":"Done"},"192.0.2.4":{"status":"Failed","error":"some error"},"192.0.2.5":{"status":"Failed","error":"bundle creation context finished before bundle creation finished"}},` +
+			`"node_list":[{"ip":"192.0.2.1","role":"agent","status":"Done"},{"ip":"192.0.2.2","role":"master","status":"Done"},` +
+			`{"ip":"192.0.2.3","role":"public_agent","status":"Done"},{"ip":"192.0.2.4","role":"public_agent","status":"Failed","error":"some error"},` +
+			`{"ip":"192.0.2.5","role":"public_agent","status":"Failed","error":"bundle creation context finished before bundle creation finished"}]}`,
 	}
 
 	files := map[string]string{}
@@ -211,7 +222,7 @@ func TestAppendToZipErrorsWithMalformedZip(t *testing.T) {
 	defer zipWriter.Close()
 
 	invalidZipPath := filepath.Join(testDataDir, "not_a_zip.txt")
-	rc, err := appendToZip(zipWriter, invalidZipPath)
+	rc, err := appendToZip(zipWriter, invalidZipPath, newZipEntries(DuplicateEntriesRename))
 	assert.Nil(t, rc)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "zip: not a valid zip file")
@@ -230,11 +241,11 @@ func TestHandlingForBundleUpdateInProgress(t *testing.T) {
 
 	n := node{IP: net.ParseIP("127.0.0.1"), Role: "master", baseURL: "http://127.0.0.1"}
 
-	client.On("CreateBundle", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
+	client.On("CreateBundle", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
 
 	// The `Once`s here are necessary for it to find the calls in the expected order
-	client.On("Status", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: InProgress}, nil).Once()
-	client.On("Status", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Done}, nil).Once()
+	client.On("Status", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: InProgress}, nil).Once()
+	client.On("Status", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Done}, nil).Once()
 
 	statuses := c.CreateBundle(ctx, localBundleID, []node{n})
 
@@ -280,7 +291,7 @@ func TestErrorHandlingFromClientCreateBundle(t *testing.T) {
 	n := node{IP: net.ParseIP("127.0.0.1"), Role: "master", baseURL: "http://127.0.0.1"}
 
 	expectedErr := errors.New("this stands in for any of the possible errors CreateBundle could throw")
-	client.On("CreateBundle", ctx, n.baseURL, localBundleID).Return(nil, expectedErr)
+	client.On("CreateBundle", withNode(ctx, n), n.baseURL, localBundleID).Return(nil, expectedErr)
 
 	s := c.CreateBundle(ctx, localBundleID, []node{n})
 
@@ -310,11 +321,11 @@ func TestErrorHandlingFromClientStatus(t *testing.T) {
 
 	expectedErr := errors.New("this stands in for any of the possible errors Status could throw")
 
-	client.On("CreateBundle", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
+	client.On("CreateBundle", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
 
 	// The `Once`s here are necessary for it to find the calls in the expected order
-	client.On("Status", ctx, n.baseURL, localBundleID).Return(nil, expectedErr).Once()
-	client.On("Status", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Done}, nil).Once()
+	client.On("Status", withNode(ctx, n), n.baseURL, localBundleID).Return(nil, expectedErr).Once()
+	client.On("Status", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Done}, nil).Once()
 
 	statuses := c.CreateBundle(ctx, localBundleID, []node{n})
 
@@ -361,10 +372,10 @@ func TestHandlingForCanceledContext(t *testing.T) {
 
 	n := node{IP: net.ParseIP("127.0.0.1"), Role: "master", baseURL: "http://127.0.0.1"}
 
-	client.On("CreateBundle", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
+	client.On("CreateBundle", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: Started}, nil)
 
 	// stay in progress forever until the context is canceled
-	client.On("Status", ctx, n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: InProgress}, nil)
+	client.On("Status", withNode(ctx, n), n.baseURL, localBundleID).Return(&Bundle{ID: localBundleID, Status: InProgress}, nil)
 
 	statuses := c.CreateBundle(ctx, localBundleID, []node{n})
 
@@ -403,3 +414,854 @@ func TestHandlingForCanceledContext(t *testing.T) {
 		assert.Contains(t, expected, s)
 	}
 }
+
+func TestCoordinatorCheckpointsReportAsNodesFinish(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	bundleID := "bundle-0"
+	localBundleID := "bundle-local"
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, bundleID), dirPerm))
+
+	node1 := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"}
+	node2 := node{IP: net.ParseIP("192.0.2.2"), Role: "master", baseURL: "http://192.0.2.2"}
+
+	downloading := make(chan bool)
+	proceed := make(chan bool)
+	client := &MockClient{
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			if node == node2.baseURL {
+				downloading <- true
+				<-proceed
+			}
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	statuses := make(chan BundleStatus, 2)
+	statuses <- BundleStatus{id: localBundleID, node: node1, done: true}
+	statuses <- BundleStatus{id: localBundleID, node: node2, done: true}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+
+	collected := make(chan error)
+	go func() {
+		_, err := c.CollectBundle(context.Background(), bundleID, 2, statuses)
+		collected <- err
+	}()
+
+	reportPath := filepath.Join(workDir, bundleID, reportFileName)
+
+	<-downloading
+	report, err := ioutil.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"}},`+
+		`"node_list":[{"ip":"192.0.2.1","role":"agent","status":"Done"}]}`, string(report))
+
+	close(proceed)
+	require.NoError(t, <-collected)
+
+	report, err = ioutil.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"},"192.0.2.2":{"status":"Done"}},`+
+		`"node_list":[{"ip":"192.0.2.1","role":"agent","status":"Done"},{"ip":"192.0.2.2","role":"master","status":"Done"}]}`, string(report))
+}
+
+func writeTestZip(path string, name string, content string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	entry, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write([]byte(content)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func TestCoordinatorMarksNodeExceedingMaxSizeAsFailed(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	smallBundle := filepath.Join(workDir, "small.zip")
+	require.NoError(t, writeTestZip(smallBundle, "test.txt", "test\n"))
+	bigBundle := filepath.Join(workDir, "big.zip")
+	require.NoError(t, writeTestZip(bigBundle, "test.txt", string(sampleLog(10000))))
+
+	serve := func(bundlePath string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				http.ServeFile(w, r, bundlePath)
+			}
+		}))
+	}
+	small := serve(smallBundle)
+	defer small.Close()
+	big := serve(bigBundle)
+	defer big.Close()
+
+	stat, err := os.Stat(smallBundle)
+	require.NoError(t, err)
+
+	node1 := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: small.URL}
+	node2 := node{IP: net.ParseIP("192.0.2.2"), Role: "agent", baseURL: big.URL}
+
+	statuses := make(chan BundleStatus, 2)
+	statuses <- BundleStatus{id: "bundle-local", node: node1, done: true}
+	statuses <- BundleStatus{id: "bundle-local", node: node2, done: true}
+
+	client := NewDiagnosticsClient(http.DefaultClient).WithMaxFileSize(stat.Size())
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+
+	bundlePath, err := c.CollectBundle(context.Background(), "bundle-0", 2, statuses)
+	require.NoError(t, err)
+
+	zipReader, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer zipReader.Close()
+
+	files := map[string]string{}
+	for _, f := range zipReader.File {
+		files[f.Name] = string(readZipFile(t, f))
+	}
+	assert.Equal(t, map[string]string{
+		"192.0.2.1_agent/test.txt": "test\n",
+		reportFileName: `{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"},` +
+			`"192.0.2.2":{"status":"Failed","error":"size exceeded: bundle bundle-local is larger than ` +
+			fmt.Sprint(stat.Size()) + ` bytes"}},"node_list":[{"ip":"192.0.2.1","role":"agent","status":"Done"},` +
+			`{"ip":"192.0.2.2","role":"agent","status":"Failed","error":"size exceeded: bundle bundle-local is larger than ` +
+			fmt.Sprint(stat.Size()) + ` bytes"}]}`,
+	}, files)
+}
+
+func TestCoordinatorStoresIntermediateBundlesInTempDir(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+	tempDir, err := ioutil.TempDir("", "temp-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	var downloaded []string
+	client := &MockClient{
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			downloaded = append(downloaded, path)
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	node1 := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"}
+	statuses := make(chan BundleStatus, 1)
+	statuses <- BundleStatus{id: "bundle-local", node: node1, done: true}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir).WithTempDir(tempDir)
+	bundlePath, err := c.CollectBundle(context.Background(), "bundle-0", 1, statuses)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(tempDir, "192.0.2.1_agent.zip")}, downloaded)
+	assert.Equal(t, filepath.Join(tempDir, "bundle-bundle-0.zip"), bundlePath)
+	files, err := ioutil.ReadDir(workDir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestCoordinatorCopiesLeaderProvenanceToBundleRoot(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	client := &MockClient{
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			return writeTestZip(path, "provenance.json", `{"cluster_id":"`+node+`"}`)
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	agent := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"}
+	leader := node{IP: net.ParseIP("192.0.2.2"), Role: "master", baseURL: "http://192.0.2.2", leader: true}
+	statuses := make(chan BundleStatus, 2)
+	statuses <- BundleStatus{id: "bundle-local", node: agent, done: true}
+	statuses <- BundleStatus{id: "bundle-local", node: leader, done: true}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+	bundlePath, err := c.CollectBundle(context.Background(), "bundle-0", 2, statuses)
+	require.NoError(t, err)
+	defer os.Remove(bundlePath)
+
+	r, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	contents := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		contents[f.Name] = string(raw)
+	}
+	assert.Equal(t, `{"cluster_id":"http://192.0.2.2"}`, contents["provenance.json"])
+	assert.Equal(t, `{"cluster_id":"http://192.0.2.1"}`, contents["192.0.2.1_agent/provenance.json"])
+	assert.Equal(t, `{"cluster_id":"http://192.0.2.2"}`, contents["192.0.2.2_master/provenance.json"])
+}
+
+func TestCoordinatorReportsCreationConflict(t *testing.T) {
+	n := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"}
+	client := &MockClient{
+		createBundle: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return nil, &DiagnosticsBundleAlreadyExists{id: ID}
+		},
+	}
+
+	c := NewParallelCoordinator(client, time.Millisecond, "testdata")
+	s := <-c.CreateBundle(context.Background(), "bundle-0", []node{n})
+
+	assert.Equal(t, BundleStatus{id: "bundle-0", node: n, done: true, err: creationConflictError{id: "bundle-0"}}, s)
+	assert.EqualError(t, s.err, "could not create bundle: node already has bundle bundle-0")
+}
+
+func TestCoordinatorRetriesCreationConflictWithFreshID(t *testing.T) {
+	n := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"}
+
+	var created []string
+	client := &MockClient{
+		createBundle: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			created = append(created, ID)
+			if ID == "bundle-0" {
+				return nil, &DiagnosticsBundleAlreadyExists{id: ID}
+			}
+			return &Bundle{ID: ID, Status: Started}, nil
+		},
+		status: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return &Bundle{ID: ID, Status: Done}, nil
+		},
+	}
+
+	c := NewParallelCoordinator(client, time.Millisecond, "testdata").WithConflictRetry(true)
+	statuses := c.CreateBundle(context.Background(), "bundle-0", []node{n})
+
+	assert.Equal(t, BundleStatus{id: "bundle-0-retry-1", node: n}, <-statuses)
+	assert.Equal(t, BundleStatus{id: "bundle-0-retry-1", node: n, done: true}, <-statuses)
+	assert.Equal(t, []string{"bundle-0", "bundle-0-retry-1"}, created)
+}
+
+func TestCoordinatorDoesNotDeleteConflictingBundle(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	deleted := make(chan string, 2)
+	client := &MockClient{
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			deleted <- node + "/" + ID
+			return nil
+		},
+	}
+
+	node1 := node{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"}
+	node2 := node{IP: net.ParseIP("192.0.2.2"), Role: "agent", baseURL: "http://192.0.2.2"}
+	statuses := make(chan BundleStatus, 2)
+	statuses <- BundleStatus{id: "bundle-0", node: node1, done: true, err: creationConflictError{id: "bundle-0"}}
+	statuses <- BundleStatus{id: "bundle-0", node: node2, done: true}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+	_, err = c.CollectBundle(context.Background(), "bundle-0", 2, statuses)
+	require.NoError(t, err)
+
+	assert.Equal(t, "http://192.0.2.2/bundle-0", <-deleted)
+	select {
+	case d := <-deleted:
+		t.Errorf("conflicting bundle must not be deleted: %s", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCoordinatorFallsBackToOtherRoleWhenNodeIsNotReachable(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+	require.NoError(t, os.Mkdir(filepath.Join(workDir, "bundle-0"), dirPerm))
+
+	urlBuilder := dcos.NewURLBuilder(61001, 1050, false)
+	// the node was discovered as an agent but it was promoted to a master so it listens on the master port
+	n := node{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: "http://192.0.2.1:61001"}
+	master := "http://192.0.2.1:1050"
+
+	var called []string
+	client := &MockClient{
+		createBundle: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			called = append(called, node)
+			if node != master {
+				return nil, &url.Error{Op: "Put", URL: node, Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+			}
+			return &Bundle{ID: ID, Status: Started}, nil
+		},
+		status: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			if node != master {
+				return nil, fmt.Errorf("unexpected node %s", node)
+			}
+			return &Bundle{ID: ID, Status: Done}, nil
+		},
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			if node != master {
+				return fmt.Errorf("unexpected node %s", node)
+			}
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir).WithRoleFallback(&urlBuilder)
+	ctx := context.Background()
+	statuses := c.CreateBundle(ctx, "bundle-0", []node{n})
+	_, err = c.CollectBundle(ctx, "bundle-0", 1, statuses)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"http://192.0.2.1:61001", master}, called)
+
+	data, err := ioutil.ReadFile(filepath.Join(workDir, "bundle-0", reportFileName))
+	require.NoError(t, err)
+	var report bundleReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, nodeBundleReport{
+		Status:     Done,
+		RoleChange: &roleChange{From: dcos.AgentRole, To: dcos.MasterRole},
+	}, report.Nodes["192.0.2.1"])
+}
+
+func TestCoordinatorDoesNotFallBackForRelayedNodes(t *testing.T) {
+	urlBuilder := dcos.NewURLBuilder(61001, 1050, false)
+	c := NewParallelCoordinator(&MockClient{}, time.Millisecond, "testdata").WithRoleFallback(&urlBuilder)
+
+	relayed := node{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: RelayURL("http://192.0.2.2:1050", net.ParseIP("192.0.2.1"))}
+	_, ok := c.fallbackNode(relayed)
+	assert.False(t, ok)
+
+	master := node{IP: net.ParseIP("192.0.2.2"), Role: dcos.MasterRole, baseURL: "http://192.0.2.2:1050"}
+	fallback, ok := c.fallbackNode(master)
+	assert.True(t, ok)
+	assert.Equal(t, "http://192.0.2.2:61001", fallback.baseURL)
+	assert.Equal(t, &roleChange{From: dcos.MasterRole, To: dcos.AgentRole}, fallback.roleChange())
+}
+
+func TestCoordinatorFinalizesBundleWhenCollectionCeilingIsReached(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	fast := node{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: "http://192.0.2.1"}
+	slowDownload := node{IP: net.ParseIP("192.0.2.2"), Role: dcos.AgentRole, baseURL: "http://192.0.2.2"}
+	slow := node{IP: net.ParseIP("192.0.2.3"), Role: dcos.AgentRole, baseURL: "http://192.0.2.3"}
+	neverFinished := node{IP: net.ParseIP("192.0.2.4"), Role: dcos.MasterRole, baseURL: "http://192.0.2.4"}
+	nodes := []node{fast, slowDownload, slow, neverFinished}
+
+	deleted := make(chan string, len(nodes))
+	client := &MockClient{
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			if node == slowDownload.baseURL {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			deleted <- node
+			return nil
+		},
+	}
+
+	statuses := make(chan BundleStatus, len(nodes))
+	statuses <- BundleStatus{id: "bundle-local", node: fast, done: true}
+	statuses <- BundleStatus{id: "bundle-local", node: slowDownload, done: true}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+	maxDuration := 100 * time.Millisecond
+	ctx := withCollectionCeiling(context.Background(), maxDuration, nodes)
+
+	start := time.Now()
+	bundlePath, err := c.CollectBundle(ctx, "bundle-0", len(nodes), statuses)
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= maxDuration)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	zipReader, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer zipReader.Close()
+
+	files := map[string][]byte{}
+	for _, f := range zipReader.File {
+		files[f.Name] = readZipFile(t, f)
+	}
+	assert.Equal(t, "test\n", string(files["192.0.2.1_agent/test.txt"]))
+
+	var report bundleReport
+	require.NoError(t, json.Unmarshal(files[reportFileName], &report))
+	timeout := "timeout: cluster collection exceeded max duration of 100ms"
+	assert.Equal(t, map[string]nodeBundleReport{
+		"192.0.2.1": {Status: Done},
+		"192.0.2.2": {Status: Failed, Err: timeout},
+		"192.0.2.3": {Status: Failed, Err: timeout},
+		"192.0.2.4": {Status: Failed, Err: timeout},
+	}, report.Nodes)
+	assert.Equal(t, []string{timeout + ", 2 nodes were not collected"}, report.Warnings)
+	assert.Equal(t, dcos.MasterRole, report.NodeList[3].Role)
+
+	// bundles of nodes finished after the ceiling are deleted
+	statuses <- BundleStatus{id: "bundle-local", node: slow, done: true}
+	statuses <- BundleStatus{id: "bundle-local", node: neverFinished, done: true, err: fmt.Errorf("context finished")}
+	var deletedNodes []string
+	for range nodes {
+		select {
+		case n := <-deleted:
+			deletedNodes = append(deletedNodes, n)
+		case <-time.After(time.Second):
+			t.Fatalf("bundles deleted: %v", deletedNodes)
+		}
+	}
+	assert.ElementsMatch(t, []string{fast.baseURL, slowDownload.baseURL, slow.baseURL, neverFinished.baseURL}, deletedNodes)
+}
+
+// newNodesServer returns a server acting as dcos-diagnostics of nodes with base URLs server.URL+"/"+IP. It creates
+// bundles that are done right away. Downloads of bundles of stalled nodes send a part of the file, tell the IP of
+// the node on downloading unless it is nil and hang until release is closed.
+func newNodesServer(t *testing.T, stalled map[string]bool, downloading chan<- string, release <-chan struct{}) *httptest.Server {
+	data := &bytes.Buffer{}
+	w := zip.NewWriter(data)
+	entry, err := w.Create("test.txt")
+	require.NoError(t, err)
+	_, err = entry.Write([]byte("test\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		ip, path := parts[0], "/"+parts[1]
+		id := strings.TrimPrefix(path, bundlesEndpoint+"/")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(path, "/file"):
+			w.Header().Set("Content-Length", fmt.Sprint(data.Len()))
+			if !stalled[ip] {
+				w.Write(data.Bytes())
+				return
+			}
+			w.Write(data.Bytes()[:data.Len()/2])
+			w.(http.Flusher).Flush()
+			if downloading != nil {
+				downloading <- ip
+			}
+			<-release
+		case r.Method == http.MethodPut:
+			json.NewEncoder(w).Encode(Bundle{ID: id, Status: Started})
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(Bundle{ID: id, Status: Done})
+		}
+	}))
+}
+
+func TestCoordinatorAbortsDownloadsWhenCollectionCeilingIsReached(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	release := make(chan struct{})
+	server := newNodesServer(t, map[string]bool{"192.0.2.2": true}, nil, release)
+	defer server.Close()
+	defer close(release)
+
+	fast := node{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: server.URL + "/192.0.2.1"}
+	stalled := node{IP: net.ParseIP("192.0.2.2"), Role: dcos.AgentRole, baseURL: server.URL + "/192.0.2.2"}
+	nodes := []node{fast, stalled}
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "bundle-0"), dirPerm))
+
+	statuses := make(chan BundleStatus, len(nodes))
+	for _, n := range nodes {
+		statuses <- BundleStatus{id: "bundle-local", node: n, done: true}
+	}
+
+	c := NewParallelCoordinator(NewDiagnosticsClient(server.Client()), time.Millisecond, workDir)
+	maxDuration := 100 * time.Millisecond
+	ctx := withCollectionCeiling(context.Background(), maxDuration, nodes)
+
+	collected := make(chan string)
+	go func() {
+		bundlePath, err := c.CollectBundle(ctx, "bundle-0", len(nodes), statuses)
+		assert.NoError(t, err)
+		collected <- bundlePath
+	}()
+
+	var bundlePath string
+	select {
+	case bundlePath = <-collected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collection did not finish when the stalled download reached the ceiling")
+	}
+	defer os.Remove(bundlePath)
+
+	r, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var report bundleReport
+	for _, f := range r.File {
+		if f.Name == reportFileName {
+			require.NoError(t, json.Unmarshal(readZipFile(t, f), &report))
+		}
+	}
+	assert.Equal(t, map[string]nodeBundleReport{
+		"192.0.2.1": {Status: Done},
+		"192.0.2.2": {Status: Failed, Err: "timeout: cluster collection exceeded max duration of 100ms"},
+	}, report.Nodes)
+}
+
+func TestMergeZipsKeepsDuplicateEntries(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	// two node bundles with the same name e.g., the same node collected twice, both with the same root file
+	var bundlePaths []string
+	for i, content := range []string{"first", "second"} {
+		dir := filepath.Join(workDir, fmt.Sprintf("node-%d", i))
+		require.NoError(t, os.MkdirAll(dir, dirPerm))
+		path := filepath.Join(dir, "192.0.2.1_agent.zip")
+		require.NoError(t, writeTestZip(path, "dcos-diagnostics.log", content))
+		bundlePaths = append(bundlePaths, path)
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		merged, err := mergeZips(bundleReport{ID: "bundle-rename"}, bundlePaths, "", workDir, DuplicateEntriesRename)
+		require.NoError(t, err)
+
+		r, err := zip.OpenReader(merged)
+		require.NoError(t, err)
+		defer r.Close()
+
+		contents := map[string]string{}
+		for _, f := range r.File {
+			contents[f.Name] = string(readZipFile(t, f))
+		}
+		assert.Equal(t, "first", contents["192.0.2.1_agent/dcos-diagnostics.log"])
+		assert.Equal(t, "second", contents["192.0.2.1_agent/dcos-diagnostics.duplicate-1.log"])
+		assert.Equal(t, "duplicate entry 192.0.2.1_agent/dcos-diagnostics.log from 192.0.2.1_agent.zip stored as "+
+			"192.0.2.1_agent/dcos-diagnostics.duplicate-1.log\n", contents[summaryErrorsReportFileName])
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := mergeZips(bundleReport{ID: "bundle-fail"}, bundlePaths, "", workDir, DuplicateEntriesFail)
+		assert.EqualError(t, err, "duplicate entry 192.0.2.1_agent/dcos-diagnostics.log from 192.0.2.1_agent.zip")
+	})
+}
+
+func TestParseDuplicateEntries(t *testing.T) {
+	duplicates, err := ParseDuplicateEntries("")
+	require.NoError(t, err)
+	assert.Equal(t, DuplicateEntriesRename, duplicates)
+
+	duplicates, err = ParseDuplicateEntries("fail")
+	require.NoError(t, err)
+	assert.Equal(t, DuplicateEntriesFail, duplicates)
+
+	_, err = ParseDuplicateEntries("overwrite")
+	assert.EqualError(t, err, "unknown duplicate entries handling overwrite, expected rename or fail")
+}
+
+func TestCoordinatorCancelsSingleNode(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	bundleID := "bundle-0"
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, bundleID), dirPerm))
+
+	nodes := []node{
+		{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1"},
+		{IP: net.ParseIP("192.0.2.2"), Role: "master", baseURL: "http://192.0.2.2"},
+		{IP: net.ParseIP("192.0.2.3"), Role: "agent", baseURL: "http://192.0.2.3"},
+	}
+	hanging := nodes[2]
+
+	checked := make(chan struct{}, 1)
+	client := &MockClient{
+		createBundle: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return &Bundle{ID: ID, Status: Started}, nil
+		},
+		status: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			if node == hanging.baseURL {
+				select {
+				case checked <- struct{}{}:
+				default:
+				}
+				return &Bundle{ID: ID, Status: InProgress}, nil
+			}
+			return &Bundle{ID: ID, Status: Done}, nil
+		},
+		getFile: func(ctx context.Context, node string, ID string, path string) error {
+			return writeTestZip(path, "test.txt", "test\n")
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+	ctx := withClusterBundleID(context.Background(), bundleID)
+	statuses := c.CreateBundle(ctx, "bundle-local", nodes)
+
+	collected := make(chan string)
+	go func() {
+		bundlePath, err := c.CollectBundle(ctx, bundleID, len(nodes), statuses)
+		assert.NoError(t, err)
+		collected <- bundlePath
+	}()
+
+	<-checked
+	assert.IsType(t, nodeNotInBundleError{}, c.CancelNode(bundleID, "192.0.2.4"))
+	require.NoError(t, c.CancelNode(bundleID, hanging.IP.String()))
+
+	var bundlePath string
+	select {
+	case bundlePath = <-collected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("collection did not finish after the hanging node was canceled")
+	}
+	defer os.Remove(bundlePath)
+
+	r, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var report bundleReport
+	for _, f := range r.File {
+		if f.Name == reportFileName {
+			require.NoError(t, json.Unmarshal(readZipFile(t, f), &report))
+		}
+	}
+	assert.Equal(t, map[string]nodeBundleReport{
+		"192.0.2.1": {Status: Done},
+		"192.0.2.2": {Status: Done},
+		"192.0.2.3": {Status: Canceled, Err: "collection of the node was canceled"},
+	}, report.Nodes)
+
+	// the bundle is finished so its nodes could not be canceled anymore
+	assert.IsType(t, &DiagnosticsBundleNotFoundError{}, c.CancelNode(bundleID, hanging.IP.String()))
+}
+
+func TestCoordinatorCancelsNodeDuringDownload(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	bundleID := "bundle-0"
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, bundleID), dirPerm))
+
+	downloading := make(chan string, 1)
+	release := make(chan struct{})
+	server := newNodesServer(t, map[string]bool{"192.0.2.2": true}, downloading, release)
+	defer server.Close()
+	defer close(release)
+
+	nodes := []node{
+		{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: server.URL + "/192.0.2.1"},
+		{IP: net.ParseIP("192.0.2.2"), Role: "agent", baseURL: server.URL + "/192.0.2.2"},
+		{IP: net.ParseIP("192.0.2.3"), Role: "master", baseURL: server.URL + "/192.0.2.3"},
+	}
+
+	c := NewParallelCoordinator(NewDiagnosticsClient(server.Client()), time.Millisecond, workDir)
+	ctx := withClusterBundleID(context.Background(), bundleID)
+	statuses := c.CreateBundle(ctx, "bundle-local", nodes)
+
+	collected := make(chan string)
+	go func() {
+		bundlePath, err := c.CollectBundle(ctx, bundleID, len(nodes), statuses)
+		assert.NoError(t, err)
+		collected <- bundlePath
+	}()
+
+	select {
+	case ip := <-downloading:
+		require.NoError(t, c.CancelNode(bundleID, ip))
+	case <-time.After(5 * time.Second):
+		t.Fatal("download of the stalled node did not start")
+	}
+
+	var bundlePath string
+	select {
+	case bundlePath = <-collected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collection did not finish after the node was canceled during its download")
+	}
+	defer os.Remove(bundlePath)
+
+	r, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var report bundleReport
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		files[f.Name] = readZipFile(t, f)
+	}
+	require.NoError(t, json.Unmarshal(files[reportFileName], &report))
+	assert.Equal(t, map[string]nodeBundleReport{
+		"192.0.2.1": {Status: Done},
+		"192.0.2.2": {Status: Canceled, Err: "collection of the node was canceled"},
+		"192.0.2.3": {Status: Done},
+	}, report.Nodes)
+	assert.Equal(t, "test\n", string(files["192.0.2.1_agent/test.txt"]))
+	assert.Equal(t, "test\n", string(files["192.0.2.3_master/test.txt"]))
+}
+
+func TestNodeCancelsRejectsFinishedNode(t *testing.T) {
+	cancels := newNodeCancels()
+	n := node{IP: net.ParseIP("192.0.2.1")}
+	cancels.start("bundle-0", []node{n})
+
+	ctx := cancels.withCancel(context.Background(), "bundle-0", "192.0.2.1")
+	assert.False(t, cancels.finishNode("bundle-0", "192.0.2.1"))
+	assert.EqualError(t, cancels.cancel("bundle-0", "192.0.2.1"), "node 192.0.2.1 of bundle bundle-0 is already finished")
+	assert.NoError(t, ctx.Err())
+
+	cancels.finish("bundle-0")
+	assert.Error(t, ctx.Err())
+	assert.EqualError(t, cancels.cancel("bundle-0", "192.0.2.1"), "bundle bundle-0 not found")
+}
+
+func TestCoordinatorStoresNodesSharingIPInDistinctDirectories(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	nodes, warnings := resolveDuplicateNodes([]node{
+		{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1", mesosID: "S1"},
+		{IP: net.ParseIP("192.0.2.1"), Role: "agent", baseURL: "http://192.0.2.1", mesosID: "S2"},
+		{IP: net.ParseIP("192.0.2.2"), Role: "master", baseURL: "http://192.0.2.2", mesosID: "M1"},
+	}, DuplicateNodesDisambiguate)
+	assert.Equal(t, []string{
+		"nodes S1, S2 share IP 192.0.2.1, they were collected as 192.0.2.1-S1, 192.0.2.1-S2",
+	}, warnings)
+
+	client := &MockClient{
+		createBundle: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return &Bundle{ID: ID, Status: Started}, nil
+		},
+		status: func(ctx context.Context, node string, ID string) (*Bundle, error) {
+			return &Bundle{ID: ID, Status: Done}, nil
+		},
+		getFile: func(ctx context.Context, _ string, ID string, path string) error {
+			n := ctx.Value(nodeKey{}).(node)
+			return writeTestZip(path, "mesos-id.txt", n.mesosID)
+		},
+		delete: func(ctx context.Context, node string, ID string) error {
+			return nil
+		},
+	}
+
+	c := NewParallelCoordinator(client, time.Millisecond, workDir)
+	statuses := c.CreateBundle(context.Background(), "bundle-local", nodes)
+	bundlePath, err := c.CollectBundle(context.Background(), "bundle-0", len(nodes), statuses)
+	require.NoError(t, err)
+	defer os.Remove(bundlePath)
+
+	r, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	contents := map[string]string{}
+	var report bundleReport
+	for _, f := range r.File {
+		switch f.Name {
+		case reportFileName:
+			require.NoError(t, json.Unmarshal(readZipFile(t, f), &report))
+		case summaryErrorsReportFileName:
+		default:
+			contents[f.Name] = string(readZipFile(t, f))
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"192.0.2.1-S1_agent/mesos-id.txt": "S1",
+		"192.0.2.1-S2_agent/mesos-id.txt": "S2",
+		"192.0.2.2_master/mesos-id.txt":   "M1",
+	}, contents)
+	assert.Equal(t, map[string]nodeBundleReport{
+		"192.0.2.1-S1": {Status: Done},
+		"192.0.2.1-S2": {Status: Done},
+		"192.0.2.2":    {Status: Done},
+	}, report.Nodes)
+	var listed []string
+	for _, n := range report.NodeList {
+		listed = append(listed, n.IP)
+	}
+	assert.Equal(t, []string{"192.0.2.1-S1", "192.0.2.1-S2", "192.0.2.2"}, listed)
+}
+
+func TestResolveDuplicateNodes(t *testing.T) {
+	nodes := func() []node {
+		return []node{
+			{IP: net.ParseIP("192.0.2.1"), Role: "agent", mesosID: "S1"},
+			// the same node listed twice is collected once
+			{IP: net.ParseIP("192.0.2.1"), Role: "agent", mesosID: "S1"},
+			{IP: net.ParseIP("192.0.2.1"), Role: "agent", hostname: "agent-2.example.com"},
+			{IP: net.ParseIP("192.0.2.2"), Role: "agent"},
+		}
+	}
+
+	resolved, warnings := resolveDuplicateNodes(nodes(), DuplicateNodesDisambiguate)
+	var ids []string
+	for _, n := range resolved {
+		ids = append(ids, n.id())
+	}
+	assert.Equal(t, []string{"192.0.2.1-S1", "192.0.2.1-agent-2.example.com", "192.0.2.2"}, ids)
+	assert.Len(t, warnings, 1)
+
+	resolved, warnings = resolveDuplicateNodes(nodes(), DuplicateNodesSkip)
+	ids = nil
+	for _, n := range resolved {
+		ids = append(ids, n.id())
+	}
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, ids)
+	assert.Equal(t, []string{"nodes S1, agent-2.example.com share IP 192.0.2.1, only S1 was collected"}, warnings)
+
+	// nodes without identities are numbered
+	resolved, _ = resolveDuplicateNodes([]node{
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("192.0.2.1")},
+	}, DuplicateNodesDisambiguate)
+	assert.Equal(t, "192.0.2.1-1", resolved[0].id())
+	assert.Equal(t, "192.0.2.1-2", resolved[1].id())
+}
+
+func TestParseDuplicateNodes(t *testing.T) {
+	for name, expected := range map[string]DuplicateNodes{
+		"":             DuplicateNodesDisambiguate,
+		"disambiguate": DuplicateNodesDisambiguate,
+		"skip":         DuplicateNodesSkip,
+	} {
+		d, err := ParseDuplicateNodes(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, d)
+	}
+	_, err := ParseDuplicateNodes("merge")
+	assert.EqualError(t, err, "unknown duplicate nodes handling merge, expected disambiguate or skip")
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// redacted replaces credentials whenever they could end up in logs
+const redacted = "<redacted>"
+
+// NodeCredentials are values of the Authorization header sent to nodes by their IP or role. They are used in
+// clusters where masters and agents require different authentication so a single shared transport could not
+// authenticate to all nodes. The IP takes precedence over the role.
+type NodeCredentials struct {
+	byNode map[string]string
+}
+
+// ParseNodeCredentials returns credentials given as role=file or ip=file. The file holds the whole value of
+// the Authorization header e.g., "token=eyJhbGciOi..." so credentials never appear in flags or process list.
+func ParseNodeCredentials(entries []string) (NodeCredentials, error) {
+	credentials := NodeCredentials{byNode: make(map[string]string, len(entries))}
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return NodeCredentials{}, fmt.Errorf("invalid node credential %q, expected role=file or ip=file", e)
+		}
+		key, path := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if _, ok := credentials.byNode[key]; ok {
+			return NodeCredentials{}, fmt.Errorf("duplicated node credential %s", key)
+		}
+		value, err := ioutil.ReadFile(path)
+		if err != nil {
+			return NodeCredentials{}, fmt.Errorf("could not read credential of %s: %s", key, err)
+		}
+		credential := strings.TrimSpace(string(value))
+		if credential == "" {
+			return NodeCredentials{}, fmt.Errorf("credential of %s in %s is empty", key, path)
+		}
+		credentials.byNode[key] = credential
+	}
+	return credentials, nil
+}
+
+// String lists nodes credentials are configured for, the credentials are redacted
+func (c NodeCredentials) String() string {
+	keys := make([]string, 0, len(c.byNode))
+	for k := range c.byNode {
+		keys = append(keys, k+"="+redacted)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// forNode returns the credential of the node with given IP and role
+func (c NodeCredentials) forNode(n node) (string, bool) {
+	if credential, ok := c.byNode[n.IP.String()]; ok {
+		return credential, true
+	}
+	credential, ok := c.byNode[n.Role]
+	return credential, ok
+}
+
+type nodeKey struct{}
+
+// withNode stores the node requests are sent to in ctx so the client could select its credential
+func withNode(ctx context.Context, n node) context.Context {
+	return context.WithValue(ctx, nodeKey{}, n)
+}
+
+// authorize sets the credential of the node stored in ctx on the request. Requests to nodes without credentials
+// are sent as they are and authenticated by the transport, if at all.
+func (c NodeCredentials) authorize(ctx context.Context, request *http.Request) {
+	n, ok := ctx.Value(nodeKey{}).(node)
+	if !ok {
+		return
+	}
+	if credential, ok := c.forNode(n); ok {
+		logrus.WithField("IP", n.IP).WithField("role", n.Role).Debug("Using node credential")
+		request.Header.Set("Authorization", credential)
+	}
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+// nodeContext matches contexts of requests sent to the node with given IP
+func nodeContext(ip string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		n, ok := ctx.Value(nodeKey{}).(node)
+		return ok && n.IP.String() == ip
+	})
+}
+
+// nodeContextWithPrincipal matches contexts of requests sent to the node with given IP on behalf of the principal
+func nodeContextWithPrincipal(ip, principal string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		n, ok := ctx.Value(nodeKey{}).(node)
+		return ok && n.IP.String() == ip && ctx.Value(principalKey{}) == principal
+	})
+}
+
+func writeCredential(t *testing.T, dir, name, value string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(value), 0600))
+	return path
+}
+
+func TestParseNodeCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	master := writeCredential(t, dir, "master", "token=master-secret\n")
+	agent := writeCredential(t, dir, "agent", "token=agent-secret")
+	empty := writeCredential(t, dir, "empty", " \n")
+
+	credentials, err := ParseNodeCredentials([]string{"master=" + master, "10.0.0.3=" + agent})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.3=<redacted>, master=<redacted>", credentials.String())
+	assert.NotContains(t, credentials.String(), "secret")
+
+	credential, ok := credentials.forNode(node{IP: net.ParseIP("10.0.0.1"), Role: dcos.MasterRole})
+	assert.True(t, ok)
+	assert.Equal(t, "token=master-secret", credential)
+
+	// the IP takes precedence over the role
+	credential, ok = credentials.forNode(node{IP: net.ParseIP("10.0.0.3"), Role: dcos.MasterRole})
+	assert.True(t, ok)
+	assert.Equal(t, "token=agent-secret", credential)
+
+	_, ok = credentials.forNode(node{IP: net.ParseIP("10.0.0.2"), Role: dcos.AgentRole})
+	assert.False(t, ok)
+
+	for _, entries := range [][]string{
+		{"master"},
+		{"=" + master},
+		{"master=" + master, "master=" + agent},
+		{"master=" + filepath.Join(dir, "missing")},
+		{"master=" + empty},
+	} {
+		_, err := ParseNodeCredentials(entries)
+		assert.Error(t, err, entries)
+	}
+}
+
+func TestClientSendsCredentialOfNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	credentials, err := ParseNodeCredentials([]string{
+		"master=" + writeCredential(t, dir, "master", "token=master-secret"),
+		"agent=" + writeCredential(t, dir, "agent", "token=agent-secret"),
+	})
+	require.NoError(t, err)
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+		w.Write(jsonMarshal(Bundle{ID: "bundle", Status: Done}))
+	}))
+	defer server.Close()
+
+	client := NewDiagnosticsClient(server.Client()).WithCredentials(credentials)
+	masterNode := node{IP: net.ParseIP("10.0.0.1"), Role: dcos.MasterRole, baseURL: server.URL}
+	agentNode := node{IP: net.ParseIP("10.0.0.2"), Role: dcos.AgentRole, baseURL: server.URL}
+	publicNode := node{IP: net.ParseIP("10.0.0.3"), Role: dcos.AgentPublicRole, baseURL: server.URL}
+
+	_, err = client.Status(withNode(context.TODO(), agentNode), agentNode.baseURL, "bundle")
+	require.NoError(t, err)
+	_, err = client.Status(withNode(context.TODO(), masterNode), masterNode.baseURL, "bundle")
+	require.NoError(t, err)
+	_, err = client.Status(withNode(context.TODO(), publicNode), publicNode.baseURL, "bundle")
+	require.NoError(t, err)
+	_, err = client.Status(context.TODO(), server.URL, "bundle")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"token=agent-secret", "token=master-secret", "", ""}, received)
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DuplicateEntries tells how entries of node bundles that would be stored under the same path of the cluster
+// bundle are handled during the merge e.g., when a node bundle contains the same file twice or two node bundles
+// end up with the same name. Entries are never silently overwritten.
+type DuplicateEntries string
+
+const (
+	// DuplicateEntriesRename stores the duplicate with a numbered suffix before the extension. This is the default.
+	DuplicateEntriesRename DuplicateEntries = "rename"
+	// DuplicateEntriesFail fails the merge on the first duplicate
+	DuplicateEntriesFail DuplicateEntries = "fail"
+)
+
+// ParseDuplicateEntries returns the duplicate entries handling with given name. Empty name means the default.
+func ParseDuplicateEntries(name string) (DuplicateEntries, error) {
+	switch DuplicateEntries(name) {
+	case "", DuplicateEntriesRename:
+		return DuplicateEntriesRename, nil
+	case DuplicateEntriesFail:
+		return DuplicateEntriesFail, nil
+	}
+	return "", fmt.Errorf("unknown duplicate entries handling %s, expected %s or %s",
+		name, DuplicateEntriesRename, DuplicateEntriesFail)
+}
+
+// zipEntries keeps names of entries written to the cluster bundle so duplicates are detected
+type zipEntries struct {
+	duplicates DuplicateEntries
+	names      map[string]bool
+	// renamed lists duplicates stored under a different name, they are reported in the summary errors report
+	renamed []string
+}
+
+func newZipEntries(duplicates DuplicateEntries) *zipEntries {
+	return &zipEntries{duplicates: duplicates, names: map[string]bool{}}
+}
+
+// add reserves the name of the entry copied from the source bundle and returns the name it should be stored under
+func (e *zipEntries) add(name string, source string) (string, error) {
+	if !e.names[name] {
+		e.names[name] = true
+		return name, nil
+	}
+	if e.duplicates == DuplicateEntriesFail {
+		return "", fmt.Errorf("duplicate entry %s from %s", name, source)
+	}
+
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		renamed := fmt.Sprintf("%s.duplicate-%d%s", stem, i, ext)
+		if !e.names[renamed] {
+			e.names[renamed] = true
+			e.renamed = append(e.renamed, fmt.Sprintf("duplicate entry %s from %s stored as %s", name, source, renamed))
+			return renamed, nil
+		}
+	}
+}
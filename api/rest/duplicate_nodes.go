@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DuplicateNodes tells how distinct nodes presenting the same IP e.g., briefly in some overlay networks, are collected.
+// Their node bundles would be merged under the same <ip>_<role> directory otherwise.
+type DuplicateNodes string
+
+const (
+	// DuplicateNodesDisambiguate collects all nodes and stores them under the IP suffixed with the Mesos ID or
+	// the hostname of the node. This is the default.
+	DuplicateNodesDisambiguate DuplicateNodes = "disambiguate"
+	// DuplicateNodesSkip collects only the first node with the IP, the rest is reported in bundle errors
+	DuplicateNodesSkip DuplicateNodes = "skip"
+)
+
+// ParseDuplicateNodes returns the duplicate nodes handling with given name. Empty name means the default.
+func ParseDuplicateNodes(name string) (DuplicateNodes, error) {
+	switch DuplicateNodes(name) {
+	case "", DuplicateNodesDisambiguate:
+		return DuplicateNodesDisambiguate, nil
+	case DuplicateNodesSkip:
+		return DuplicateNodesSkip, nil
+	}
+	return "", fmt.Errorf("unknown duplicate nodes handling %s, expected %s or %s",
+		name, DuplicateNodesDisambiguate, DuplicateNodesSkip)
+}
+
+// identity returns what distinguishes the node from other nodes with the same IP, empty when it is not known
+func (n node) identity() string {
+	if n.mesosID != "" {
+		return n.mesosID
+	}
+	return n.hostname
+}
+
+// resolveDuplicateNodes finds distinct nodes sharing an IP and handles them as configured. Entries of the same
+// node listed more than once are dropped. It returns nodes to collect and warnings explaining what was done.
+func resolveDuplicateNodes(nodes []node, duplicates DuplicateNodes) ([]node, []string) {
+	byIP := map[string][]int{}
+	var ips []string
+	for i, n := range nodes {
+		ip := n.IP.String()
+		if _, ok := byIP[ip]; !ok {
+			ips = append(ips, ip)
+		}
+		byIP[ip] = append(byIP[ip], i)
+	}
+
+	drop := map[int]bool{}
+	var warnings []string
+	for _, ip := range ips {
+		indexes := byIP[ip]
+		if len(indexes) == 1 {
+			continue
+		}
+
+		// the same node could be listed twice e.g., by a stale discovery, only distinct identities are kept
+		seen := map[string]bool{}
+		var distinct []int
+		for _, i := range indexes {
+			identity := nodes[i].identity()
+			if identity != "" && seen[identity] {
+				drop[i] = true
+				continue
+			}
+			seen[identity] = true
+			distinct = append(distinct, i)
+		}
+		if len(distinct) == 1 {
+			continue
+		}
+
+		var names []string
+		for j, i := range distinct {
+			identity := nodes[i].identity()
+			if identity == "" {
+				identity = fmt.Sprintf("%d", j+1)
+			}
+			names = append(names, identity)
+		}
+
+		if duplicates == DuplicateNodesSkip {
+			for _, i := range distinct[1:] {
+				drop[i] = true
+			}
+			warnings = append(warnings, fmt.Sprintf("nodes %s share IP %s, only %s was collected",
+				strings.Join(names, ", "), ip, names[0]))
+			continue
+		}
+
+		var keys []string
+		for j, i := range distinct {
+			nodes[i].key = ip + "-" + names[j]
+			keys = append(keys, nodes[i].key)
+		}
+		warnings = append(warnings, fmt.Sprintf("nodes %s share IP %s, they were collected as %s",
+			strings.Join(names, ", "), ip, strings.Join(keys, ", ")))
+	}
+
+	if len(drop) == 0 {
+		return nodes, warnings
+	}
+	resolved := make([]node, 0, len(nodes)-len(drop))
+	for i, n := range nodes {
+		if !drop[i] {
+			resolved = append(resolved, n)
+		}
+	}
+	return resolved, warnings
+}
@@ -0,0 +1,180 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// eventsBufferSize is a number of events buffered for a subscriber, events are dropped for slower subscribers
+const eventsBufferSize = 128
+
+// CollectorEventStatus tells what happened to the collector
+type CollectorEventStatus string
+
+const (
+	CollectorStarted CollectorEventStatus = "started"
+	CollectorDone    CollectorEventStatus = "done"
+	CollectorFailed  CollectorEventStatus = "failed"
+)
+
+// CollectorEvent is sent to subscribers of a bundle in progress when its collector starts or finishes
+type CollectorEvent struct {
+	Collector string               `json:"collector"`
+	Status    CollectorEventStatus `json:"status"`
+	Size      int64                `json:"size,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// eventHub distributes collector events of bundles in progress to subscribers.
+// It is shared by all copies of the BundleHandler.
+type eventHub struct {
+	sync.Mutex
+	bundles map[string]*bundleEvents
+}
+
+type bundleEvents struct {
+	events      []CollectorEvent
+	subscribers map[chan CollectorEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{bundles: map[string]*bundleEvents{}}
+}
+
+// start begins recording events of the bundle
+func (h *eventHub) start(id string) {
+	h.Lock()
+	defer h.Unlock()
+	h.bundles[id] = &bundleEvents{subscribers: map[chan CollectorEvent]struct{}{}}
+}
+
+// publisher returns a function publishing events of the bundle, nil hub returns nil
+func (h *eventHub) publisher(id string) func(CollectorEvent) {
+	if h == nil {
+		return nil
+	}
+	return func(e CollectorEvent) {
+		h.publish(id, e)
+	}
+}
+
+func (h *eventHub) publish(id string, e CollectorEvent) {
+	h.Lock()
+	defer h.Unlock()
+	b, ok := h.bundles[id]
+	if !ok {
+		return
+	}
+	b.events = append(b.events, e)
+	for s := range b.subscribers {
+		select {
+		case s <- e:
+		default:
+			logrus.WithField("ID", id).Warn("Bundle events subscriber is too slow, dropping event")
+		}
+	}
+}
+
+// finish closes channels of all subscribers and forgets the bundle events
+func (h *eventHub) finish(id string) {
+	h.Lock()
+	defer h.Unlock()
+	b, ok := h.bundles[id]
+	if !ok {
+		return
+	}
+	for s := range b.subscribers {
+		close(s)
+	}
+	delete(h.bundles, id)
+}
+
+// subscribe returns events published so far and a channel of next events closed when the bundle is finished.
+// It returns false when the bundle is not in progress.
+func (h *eventHub) subscribe(id string) ([]CollectorEvent, chan CollectorEvent, bool) {
+	h.Lock()
+	defer h.Unlock()
+	b, ok := h.bundles[id]
+	if !ok {
+		return nil, nil, false
+	}
+	s := make(chan CollectorEvent, eventsBufferSize)
+	b.subscribers[s] = struct{}{}
+	return append([]CollectorEvent{}, b.events...), s, true
+}
+
+func (h *eventHub) unsubscribe(id string, s chan CollectorEvent) {
+	h.Lock()
+	defer h.Unlock()
+	if b, ok := h.bundles[id]; ok {
+		delete(b.subscribers, s)
+	}
+}
+
+// WithEvents enables streaming collector events of bundles in progress with Events
+func (h *BundleHandler) WithEvents(enabled bool) *BundleHandler {
+	h.events = nil
+	if enabled {
+		h.events = newEventHub()
+	}
+	return h
+}
+
+// Events streams collector events of the bundle in progress as Server-Sent Events.
+// The stream ends when the bundle is finished or the client disconnects.
+func (h BundleHandler) Events(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if h.events == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("bundle events are disabled"))
+		return
+	}
+	if !h.bundleExists(id) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("bundle %s not found", id))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	past, events, ok := h.events.subscribe(id)
+	if !ok {
+		writeJSONError(w, http.StatusConflict, fmt.Errorf("bundle %s is not in progress", id))
+		return
+	}
+	defer h.events.unsubscribe(id, events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range past {
+		writeEvent(w, "collector", jsonMarshal(e))
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				writeEvent(w, "done", []byte(fmt.Sprintf(`{"id":%q}`, id)))
+				flusher.Flush()
+				return
+			}
+			writeEvent(w, "collector", jsonMarshal(e))
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data []byte) {
+	write(w, []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data)))
+}
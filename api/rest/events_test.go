@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bundleEventsEndpoint = bundleEndpoint + "/events"
+
+// blockingReader returns no data until it is released
+type blockingReader struct {
+	release chan struct{}
+}
+
+func (r blockingReader) Read(p []byte) (int, error) {
+	<-r.release
+	return 0, io.EOF
+}
+
+func TestIfEventsStreamsCollectorEventsOfBundleInProgress(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	release := make(chan struct{})
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		MockCollector{name: "collector-2", rc: ioutil.NopCloser(blockingReader{release: release})},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Minute, time.Minute, nil)
+	require.NoError(t, err)
+	bh.WithEvents(true)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleEventsEndpoint, bh.Events).Methods(http.MethodGet)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPut, server.URL+bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + bundlesEndpoint + "/bundle-0/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := bufio.NewScanner(resp.Body)
+	next := func() string {
+		var lines []string
+		for events.Scan() && events.Text() != "" {
+			lines = append(lines, events.Text())
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	assert.Equal(t, "event: collector\ndata: {\"collector\":\"collector-1\",\"status\":\"started\"}", next())
+	assert.Equal(t, "event: collector\ndata: {\"collector\":\"collector-1\",\"status\":\"done\",\"size\":2}", next())
+	assert.Equal(t, "event: collector\ndata: {\"collector\":\"collector-2\",\"status\":\"started\"}", next())
+
+	close(release)
+	assert.Equal(t, "event: collector\ndata: {\"collector\":\"collector-2\",\"status\":\"done\"}", next())
+	assert.Equal(t, "event: done\ndata: {\"id\":\"bundle-0\"}", next())
+	assert.False(t, events.Scan(), "stream should be closed when the bundle is finished")
+
+	resp, err = http.Get(server.URL + bundlesEndpoint + "/bundle-0/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestIfEventsAreNotFoundWhenDisabled(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Minute, time.Minute, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEventsEndpoint, bh.Events).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/events", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"code":404,"error":"bundle events are disabled"}`, rr.Body.String())
+}
@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// statInodes returns the number of all and free inodes of the filesystem holding the path, replaced in tests
+var statInodes = statfsInodes
+
+// insufficientInodesError is returned when the work dir filesystem has not enough free inodes to create a bundle
+type insufficientInodesError struct {
+	dir      string
+	free     uint64
+	required uint64
+}
+
+func (e insufficientInodesError) Error() string {
+	return fmt.Sprintf("not enough free inodes in %s: %d free, %d required by bundle-min-free-inodes", e.dir, e.free, e.required)
+}
+
+// checkFreeInodes refuses bundle creation when the work dir filesystem has less free inodes than required. Bundles
+// create many small files e.g., with directory layout and running out of inodes in the middle of collection fails
+// them with errors that do not tell the cause. Filesystems allocating inodes dynamically report no inodes at all
+// and are not checked, neither are filesystems that could not be checked.
+func (h BundleHandler) checkFreeInodes() error {
+	if h.minFreeInodes == 0 {
+		return nil
+	}
+	total, free, err := statInodes(h.workDir)
+	if err != nil {
+		logrus.WithError(err).Warnf("Could not get free inodes of %s, they are not checked", h.workDir)
+		return nil
+	}
+	if total == 0 {
+		return nil
+	}
+	if free < h.minFreeInodes {
+		return insufficientInodesError{dir: h.workDir, free: free, required: h.minFreeInodes}
+	}
+	return nil
+}
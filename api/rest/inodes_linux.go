@@ -0,0 +1,12 @@
+package rest
+
+import "syscall"
+
+// statfsInodes returns the number of all and free inodes reported by statfs
+func statfsInodes(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Files, stat.Ffree, nil
+}
@@ -0,0 +1,15 @@
+package rest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatfsInodes(t *testing.T) {
+	total, free, err := statfsInodes(os.TempDir())
+	require.NoError(t, err)
+	assert.True(t, free <= total)
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIfCreateReturns507WhenFreeInodesAreBelowThreshold(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	defer func() { statInodes = statfsInodes }()
+	statInodes = func(path string) (uint64, uint64, error) {
+		assert.Equal(t, workdir, path)
+		return 1000000, 42, nil
+	}
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, collectorTimeout, nil)
+	require.NoError(t, err)
+	bh.WithMinFreeInodes(1000)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInsufficientStorage, rr.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"code":507,"error":"not enough free inodes in %s: 42 free, 1000 required by bundle-min-free-inodes"}`, workdir),
+		rr.Body.String())
+	// nothing is created for the refused bundle
+	assert.False(t, bh.bundleExists("bundle-0"))
+}
+
+func TestCheckFreeInodes(t *testing.T) {
+	defer func() { statInodes = statfsInodes }()
+
+	bh := BundleHandler{workDir: "/var/lib/dcos/dcos-diagnostics/diag-bundles", minFreeInodes: 1000}
+
+	for _, tc := range []struct {
+		name        string
+		total, free uint64
+		err         error
+		refused     bool
+	}{
+		{name: "enough free inodes", total: 1000000, free: 1000},
+		{name: "low free inodes", total: 1000000, free: 999, refused: true},
+		{name: "dynamic inodes are not checked", total: 0, free: 0},
+		{name: "statfs failure is not fatal", err: fmt.Errorf("permission denied")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			statInodes = func(string) (uint64, uint64, error) { return tc.total, tc.free, tc.err }
+			err := bh.checkFreeInodes()
+			if tc.refused {
+				assert.IsType(t, insufficientInodesError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// the check is disabled by default
+	statInodes = func(string) (uint64, uint64, error) { return 1000000, 0, nil }
+	assert.NoError(t, BundleHandler{}.checkFreeInodes())
+}
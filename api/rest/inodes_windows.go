@@ -0,0 +1,8 @@
+package rest
+
+import "fmt"
+
+// statfsInodes returns an error as NTFS has no fixed number of inodes
+func statfsInodes(path string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("inodes are not supported on Windows")
+}
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// manifestFileName is a file written at the end of every local bundle listing all its entries
+const manifestFileName = "manifest.json"
+
+// bundleManifest lists entries written to the bundle so its consistency could be validated later
+type bundleManifest struct {
+	Entries []manifestEntry `json:"entries"`
+}
+
+type manifestEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Note explains unusual entries e.g., collectors that returned no data
+	Note string `json:"note,omitempty"`
+	// ExitCode and Duration are recorded for command collectors
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// emptyResultNote marks manifest entries of collectors that succeeded without returning any data
+const emptyResultNote = "collector returned empty result"
+
+// EmptyResults tells how results of collectors that succeeded without returning any data are stored
+type EmptyResults string
+
+const (
+	// EmptyResultsNote stores an empty entry noted in the manifest and reports a warning. This is the default.
+	EmptyResultsNote EmptyResults = "note"
+	// EmptyResultsSkip does not store empty entries and reports a warning
+	EmptyResultsSkip EmptyResults = "skip"
+)
+
+// ParseEmptyResults returns handling of empty results with given name. Empty name means the default.
+func ParseEmptyResults(name string) (EmptyResults, error) {
+	switch EmptyResults(name) {
+	case "", EmptyResultsNote:
+		return EmptyResultsNote, nil
+	case EmptyResultsSkip:
+		return EmptyResultsSkip, nil
+	}
+	return "", fmt.Errorf("unknown empty results handling %s, expected %s or %s", name, EmptyResultsNote, EmptyResultsSkip)
+}
+
+// emptyResultError is a warning returned when the collector succeeded without returning any data
+type emptyResultError struct {
+	name    string
+	skipped bool
+}
+
+func (e emptyResultError) Error() string {
+	if e.skipped {
+		return fmt.Sprintf("%s returned empty result, skipped", e.name)
+	}
+	return fmt.Sprintf("%s returned empty result", e.name)
+}
+
+// writeManifest adds the manifest listing given entries to the bundle
+func writeManifest(dataWriter bundleWriter, entries []manifestEntry) error {
+	if entries == nil {
+		entries = []manifestEntry{}
+	}
+	f, err := dataWriter.Create(manifestFileName)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %s", manifestFileName, err)
+	}
+	if _, err := f.Write(jsonMarshal(bundleManifest{Entries: entries})); err != nil {
+		return fmt.Errorf("could not write %s: %s", manifestFileName, err)
+	}
+	return nil
+}
+
+// fileChecksum returns hex encoded SHA-256 of the file content
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("could not read %s: %s", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
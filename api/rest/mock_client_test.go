@@ -73,6 +73,33 @@ func (_m *TestifyMockClient) GetFile(ctx context.Context, node string, ID string
 	return r0
 }
 
+// GetReport provides a mock function with given fields: ctx, node, ID
+func (_m *TestifyMockClient) GetReport(ctx context.Context, node string, ID string) ([]byte, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	ret := _m.Called(ctx, node, ID)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []byte); ok {
+		r0 = rf(ctx, node, ID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, node, ID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // List provides a mock function with given fields: ctx, node
 func (_m *TestifyMockClient) List(ctx context.Context, node string) ([]*Bundle, error) {
 	if ctx.Err() != nil {
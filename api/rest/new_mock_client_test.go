@@ -8,6 +8,7 @@ type MockClient struct {
 	getFile      func(ctx context.Context, node string, ID string, path string) (err error)
 	list         func(ctx context.Context, node string) ([]*Bundle, error)
 	delete       func(ctx context.Context, node string, ID string) error
+	getReport    func(ctx context.Context, node string, ID string) ([]byte, error)
 }
 
 func (_m *MockClient) CreateBundle(ctx context.Context, node string, ID string) (*Bundle, error) {
@@ -29,3 +30,7 @@ func (_m *MockClient) List(ctx context.Context, node string) ([]*Bundle, error)
 func (_m *MockClient) Status(ctx context.Context, node string, ID string) (*Bundle, error) {
 	return _m.status(ctx, node, ID)
 }
+
+func (_m *MockClient) GetReport(ctx context.Context, node string, ID string) ([]byte, error) {
+	return _m.getReport(ctx, node, ID)
+}
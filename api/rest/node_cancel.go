@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// nodeCanceledError is reported for nodes whose collection was canceled while the cluster bundle was in progress
+type nodeCanceledError struct{}
+
+func (e nodeCanceledError) Error() string {
+	return "collection of the node was canceled"
+}
+
+// nodeNotInBundleError is returned when the node to cancel is not collected by the cluster bundle
+type nodeNotInBundleError struct {
+	id string
+	ip string
+}
+
+func (e nodeNotInBundleError) Error() string {
+	return fmt.Sprintf("node %s is not collected by bundle %s", e.ip, e.id)
+}
+
+// nodeAlreadyCollectedError is returned when the node to cancel has already finished
+type nodeAlreadyCollectedError struct {
+	id string
+	ip string
+}
+
+func (e nodeAlreadyCollectedError) Error() string {
+	return fmt.Sprintf("node %s of bundle %s is already finished", e.ip, e.id)
+}
+
+type clusterBundleIDKey struct{}
+
+// withClusterBundleID stores the ID of the cluster bundle in ctx so node bundles created for it could be canceled
+func withClusterBundleID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clusterBundleIDKey{}, id)
+}
+
+func clusterBundleIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(clusterBundleIDKey{}).(string)
+	return id
+}
+
+// nodeCollection is the state of the collection of a single node of a cluster bundle in progress
+type nodeCollection struct {
+	cancels  []context.CancelFunc
+	canceled bool
+	finished bool
+}
+
+// nodeCancels tracks cancel functions of nodes of cluster bundles in progress so a single misbehaving node could be
+// dropped without aborting the whole collection. Nodes are keyed by IP, nodes sharing an IP by their keys.
+type nodeCancels struct {
+	sync.Mutex
+	bundles map[string]map[string]*nodeCollection
+}
+
+func newNodeCancels() *nodeCancels {
+	return &nodeCancels{bundles: map[string]map[string]*nodeCollection{}}
+}
+
+// start registers nodes of the cluster bundle
+func (r *nodeCancels) start(id string, nodes []node) {
+	r.Lock()
+	defer r.Unlock()
+	collections := make(map[string]*nodeCollection, len(nodes))
+	for _, n := range nodes {
+		collections[n.id()] = &nodeCollection{}
+	}
+	r.bundles[id] = collections
+}
+
+// withCancel returns a context that is done when the collection of the node is canceled. Nodes that are not
+// registered get ctx as it is.
+func (r *nodeCancels) withCancel(ctx context.Context, id string, ip string) context.Context {
+	r.Lock()
+	defer r.Unlock()
+	n, ok := r.bundles[id][ip]
+	if !ok {
+		return ctx
+	}
+	nodeCtx, cancel := context.WithCancel(ctx)
+	if n.canceled {
+		cancel()
+	}
+	n.cancels = append(n.cancels, cancel)
+	return nodeCtx
+}
+
+// cancel cancels the collection of the node of the cluster bundle
+func (r *nodeCancels) cancel(id string, ip string) error {
+	r.Lock()
+	defer r.Unlock()
+	nodes, ok := r.bundles[id]
+	if !ok {
+		return &DiagnosticsBundleNotFoundError{id: id}
+	}
+	n, ok := nodes[ip]
+	if !ok {
+		return nodeNotInBundleError{id: id, ip: ip}
+	}
+	if n.finished {
+		return nodeAlreadyCollectedError{id: id, ip: ip}
+	}
+	n.canceled = true
+	for _, cancel := range n.cancels {
+		cancel()
+	}
+	return nil
+}
+
+// finishNode marks the node as finished so it could not be canceled anymore. It returns true when the node
+// was canceled before.
+func (r *nodeCancels) finishNode(id string, ip string) bool {
+	r.Lock()
+	defer r.Unlock()
+	n, ok := r.bundles[id][ip]
+	if !ok {
+		return false
+	}
+	n.finished = true
+	return n.canceled
+}
+
+// finish releases contexts of all nodes of the cluster bundle
+func (r *nodeCancels) finish(id string) {
+	r.Lock()
+	defer r.Unlock()
+	for _, n := range r.bundles[id] {
+		for _, cancel := range n.cancels {
+			cancel()
+		}
+	}
+	delete(r.bundles, id)
+}
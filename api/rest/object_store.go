@@ -0,0 +1,408 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// unsignedPayload is used instead of the checksum of uploaded parts so they are read only once
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// emptyPayloadHash is the SHA256 of the empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// noSuchUploadError is returned by object stores when the multipart upload does not exist anymore e.g., it was
+// aborted by a lifecycle rule, so the upload could only be started over
+type noSuchUploadError struct {
+	uploadID string
+}
+
+func (e noSuchUploadError) Error() string {
+	return fmt.Sprintf("upload %s does not exist", e.uploadID)
+}
+
+// S3Credentials are AWS credentials used to sign requests to S3 compatible object stores
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials only
+	SessionToken string
+}
+
+// s3Store is an S3 compatible object store addressed with path-style URLs so it works with both AWS and
+// self-hosted stores e.g., MinIO. Requests are signed with AWS Signature Version 4.
+type s3Store struct {
+	endpoint    *url.URL
+	bucket      string
+	region      string
+	credentials S3Credentials
+	client      *http.Client
+	clock       Clock
+}
+
+func newS3Store(endpoint *url.URL, bucket string, region string, credentials S3Credentials, client *http.Client) *s3Store {
+	return &s3Store{
+		endpoint:    endpoint,
+		bucket:      bucket,
+		region:      region,
+		credentials: credentials,
+		client:      client,
+		clock:       &realClock{},
+	}
+}
+
+type s3InitiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func (e s3Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (s *s3Store) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, key, map[string]string{"uploads": ""}, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result s3InitiateMultipartUploadResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("could not parse response of %s: %s", resp.Request.URL, err)
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("no upload ID returned by %s", resp.Request.URL)
+	}
+	return result.UploadID, nil
+}
+
+func (s *s3Store) UploadPart(ctx context.Context, key string, uploadID string, number int, part io.Reader, size int64) (string, error) {
+	query := map[string]string{"partNumber": strconv.Itoa(number), "uploadId": uploadID}
+	resp, err := s.do(ctx, http.MethodPut, key, query, part, size, unsignedPayload)
+	if err != nil {
+		if e, ok := err.(s3Error); ok && e.Code == "NoSuchUpload" {
+			return "", noSuchUploadError{uploadID: uploadID}
+		}
+		return "", err
+	}
+	resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("no ETag of part %d returned by %s", number, resp.Request.URL)
+	}
+	return etag, nil
+}
+
+func (s *s3Store) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) error {
+	complete := s3CompleteMultipartUpload{Parts: make([]s3CompletedPart, 0, len(parts))}
+	for _, p := range parts {
+		complete.Parts = append(complete.Parts, s3CompletedPart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(body)
+
+	resp, err := s.do(ctx, http.MethodPost, key, map[string]string{"uploadId": uploadID}, bytes.NewReader(body),
+		int64(len(body)), hex.EncodeToString(hash[:]))
+	if err != nil {
+		if e, ok := err.(s3Error); ok && e.Code == "NoSuchUpload" {
+			return noSuchUploadError{uploadID: uploadID}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 could report the failure with 200 OK after it started sending the response
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response of %s: %s", resp.Request.URL, err)
+	}
+	var e s3Error
+	if xml.Unmarshal(response, &e) == nil {
+		return e
+	}
+	return nil
+}
+
+// do sends the signed request for the object with the key and returns the response if it succeeded.
+// Errors returned by the store are returned as s3Error.
+func (s *s3Store) do(ctx context.Context, method string, key string, query map[string]string, body io.Reader,
+	size int64, payloadHash string) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = path.Join("/", s.endpoint.Path, s.bucket, key)
+	u.RawPath = awsURIEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.ContentLength = size
+	}
+	signV4(req, payloadHash, s.credentials, s.region, s.clock.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		response, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var e s3Error
+		if xml.Unmarshal(response, &e) == nil && e.Code != "" {
+			return nil, e
+		}
+		return nil, fmt.Errorf("unexpected status code %d from %s: %s", resp.StatusCode, u.String(), response)
+	}
+	return resp, nil
+}
+
+// signV4 signs the request with AWS Signature Version 4 adding the Authorization header. The host and all
+// headers of the request are signed.
+func signV4(req *http.Request, payloadHash string, credentials S3Credentials, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, "s3", "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query sorted by names with names and values encoded as AWS requires
+func canonicalQuery(query map[string]string) string {
+	params := make([]string, 0, len(query))
+	for name, value := range query {
+		params = append(params, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsURIEncode encodes all bytes but unreserved characters, slashes are kept unless encodeSlash is set
+func awsURIEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// fileStore is an object store keeping objects as files in the directory e.g., a mounted network share.
+// Parts of multipart uploads are kept in the directory of the upload until it is completed.
+type fileStore struct {
+	dir string
+}
+
+// fileStoreUploadsDir is the directory of the file store keeping parts of multipart uploads in progress
+const fileStoreUploadsDir = ".uploads"
+
+func (s fileStore) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	if _, err := s.objectPath(key); err != nil {
+		return "", err
+	}
+	uploadID := uuid.New().String()
+	if err := os.MkdirAll(filepath.Join(s.dir, fileStoreUploadsDir, uploadID), dirPerm); err != nil {
+		return "", fmt.Errorf("could not create upload dir: %s", err)
+	}
+	return uploadID, nil
+}
+
+func (s fileStore) UploadPart(ctx context.Context, key string, uploadID string, number int, part io.Reader, size int64) (string, error) {
+	uploadDir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	// the part is written to a temporary file so a failed attempt never leaves a truncated part
+	tmp, err := ioutil.TempFile(uploadDir, "part")
+	if err != nil {
+		return "", fmt.Errorf("could not create part %d: %s", number, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), part)
+	if err != nil {
+		return "", fmt.Errorf("could not write part %d: %s", number, err)
+	}
+	if n != size {
+		return "", fmt.Errorf("part %d has %d bytes, expected %d", number, n, size)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("could not write part %d: %s", number, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(uploadDir, strconv.Itoa(number))); err != nil {
+		return "", fmt.Errorf("could not store part %d: %s", number, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s fileStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) error {
+	uploadDir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), dirPerm); err != nil {
+		return fmt.Errorf("could not create dir of %s: %s", key, err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(objectPath), filepath.Base(objectPath))
+	if err != nil {
+		return fmt.Errorf("could not create %s: %s", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	for _, p := range parts {
+		if err := appendPart(tmp, filepath.Join(uploadDir, strconv.Itoa(p.Number)), p.ETag); err != nil {
+			return fmt.Errorf("could not append part %d: %s", p.Number, err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %s", key, err)
+	}
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		return fmt.Errorf("could not store %s: %s", key, err)
+	}
+	return os.RemoveAll(uploadDir)
+}
+
+// appendPart copies the part to w and checks its checksum matches the ETag returned when it was uploaded
+func appendPart(w io.Writer, partPath string, etag string) error {
+	f, err := os.Open(partPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), f); err != nil {
+		return err
+	}
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != etag {
+		return fmt.Errorf("part was changed, its checksum is %s, expected %s", checksum, etag)
+	}
+	return nil
+}
+
+func (s fileStore) uploadDir(uploadID string) (string, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", noSuchUploadError{uploadID: uploadID}
+	}
+	dir := filepath.Join(s.dir, fileStoreUploadsDir, uploadID)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", noSuchUploadError{uploadID: uploadID}
+	}
+	return dir, nil
+}
+
+// objectPath returns the path of the object with the key, keys must not leave the store directory
+func (s fileStore) objectPath(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || strings.HasPrefix(cleaned, "/"+fileStoreUploadsDir+"/") || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PostHook is a site specific command run after a local bundle is collected e.g., to notify a chat bot or move
+// the bundle. It is run with the bundle ID and the path of the bundle data as arguments.
+type PostHook struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewPostHook returns the hook running the executable at path for at most timeout. The path must come from
+// the daemon configuration, never from requests, and it is checked with collector.CheckTrustedExecutable.
+func NewPostHook(path string, timeout time.Duration) (*PostHook, error) {
+	if err := collector.CheckTrustedExecutable("post-collection hook", path); err != nil {
+		return nil, err
+	}
+	return &PostHook{path: path, timeout: timeout}, nil
+}
+
+// run executes the hook for the bundle and logs its output. The returned error is recorded as a bundle warning,
+// it never changes the bundle status.
+func (p PostHook) run(id string, bundlePath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	// output goes to a file, not a pipe, so children of the hook left running could not block it after the timeout
+	output, err := ioutil.TempFile("", "post-hook-output")
+	if err != nil {
+		return fmt.Errorf("could not create post-collection hook output file: %s", err)
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, p.path, id, bundlePath)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	log := logrus.WithFields(logrus.Fields{"ID": id, "hook": p.path, "duration": time.Since(start).String()})
+	if out, e := ioutil.ReadFile(output.Name()); e == nil && len(bytes.TrimSpace(out)) > 0 {
+		log = log.WithField("output", string(bytes.TrimSpace(out)))
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Warn("Post-collection hook timed out")
+		return fmt.Errorf("post-collection hook %s killed after %s", p.path, p.timeout)
+	}
+	if err != nil {
+		log.WithError(err).Warn("Post-collection hook failed")
+		return fmt.Errorf("post-collection hook %s failed: %s", p.path, err)
+	}
+	log.Info("Post-collection hook finished")
+	return nil
+}
+
+// bundleDataPath returns the path of the zip file or the directory holding data of the bundle
+func (h BundleHandler) bundleDataPath(bundle Bundle) string {
+	if bundle.Layout == LayoutDirectory {
+		return filepath.Join(h.workDir, bundle.ID, contentsDir)
+	}
+	return filepath.Join(h.workDir, bundle.ID, dataFileName)
+}
+
+// WithPostHook makes the handler run the hook after every local bundle is collected, nil disables it
+func (h *BundleHandler) WithPostHook(hook *PostHook) *BundleHandler {
+	h.postHook = hook
+	return h
+}
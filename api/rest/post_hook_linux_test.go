@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeHookScript(t *testing.T, dir string, script string) string {
+	path := filepath.Join(dir, "hook.sh")
+	require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700))
+	return path
+}
+
+// createBundleWithHook creates bundle-0 with the hook and returns its final state
+func createBundleWithHook(t *testing.T, workdir string, hook *PostHook, finished func(Bundle) bool) Bundle {
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+	bh, err := NewBundleHandler(filepath.Join(workdir, "bundles"), collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.WithPostHook(hook)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var bundle Bundle
+	require.Eventually(t, func() bool {
+		bundle, err = bh.getBundleState("bundle-0")
+		return err == nil && finished(bundle)
+	}, 5*time.Second, 10*time.Millisecond)
+	return bundle
+}
+
+func TestPostHookIsRunWithBundleIDAndPath(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	args := filepath.Join(workdir, "args")
+	hook, err := NewPostHook(writeHookScript(t, workdir, "echo \"$1 $2\" > "+args+"\n"), time.Second)
+	require.NoError(t, err)
+
+	bundle := createBundleWithHook(t, workdir, hook, func(b Bundle) bool {
+		_, err := os.Stat(args)
+		return b.IsFinished() && err == nil
+	})
+	assert.Equal(t, Done, bundle.Status)
+	assert.Empty(t, bundle.Warnings)
+
+	data, err := ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.Equal(t, "bundle-0 "+filepath.Join(workdir, "bundles", "bundle-0", dataFileName)+"\n", string(data))
+}
+
+func TestPostHookFailureIsRecordedAsWarning(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	path := writeHookScript(t, workdir, "echo could not notify\nexit 2\n")
+	hook, err := NewPostHook(path, time.Second)
+	require.NoError(t, err)
+
+	bundle := createBundleWithHook(t, workdir, hook, func(b Bundle) bool {
+		return b.IsFinished() && len(b.Warnings) > 0
+	})
+	assert.Equal(t, Done, bundle.Status)
+	assert.Equal(t, []string{"post-collection hook " + path + " failed: exit status 2"}, bundle.Warnings)
+}
+
+func TestPostHookIsKilledAfterTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "post-hook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeHookScript(t, dir, "sleep 10\n")
+	hook, err := NewPostHook(path, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.EqualError(t, hook.run("bundle-0", dir), "post-collection hook "+path+" killed after 50ms")
+}
+
+func TestNewPostHookRejectsUntrustedExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "post-hook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewPostHook("hook.sh", time.Second)
+	assert.EqualError(t, err, "post-collection hook hook.sh is not an absolute path")
+
+	path := writeHookScript(t, dir, "exit 0\n")
+	require.NoError(t, os.Chmod(path, 0777))
+	_, err = NewPostHook(path, time.Second)
+	assert.EqualError(t, err, "post-collection hook "+path+" is writable by group or others")
+}
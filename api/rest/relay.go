@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// relayEndpoint prefixes the local bundle API path of an agent to call it through a master
+const relayEndpoint = "/system/health/v1/relay"
+
+// RelayURL returns a base URL used to call the local bundle API of the agent with given IP through the master.
+// It should be passed to Client methods instead of the agent base URL when the agent is not directly reachable.
+func RelayURL(master string, agent net.IP) string {
+	return fmt.Sprintf("%s%s/%s", master, relayEndpoint, agent)
+}
+
+// RelayHandler proxies local bundle requests to agents of the cluster. It lets masters
+// collect bundles from agents that are reachable only from other masters.
+type RelayHandler struct {
+	tools      dcos.Tooler
+	urlBuilder dcos.NodeURLBuilder
+	transport  http.RoundTripper
+}
+
+// NewRelayHandler creates a handler proxying requests to agents with the given transport
+func NewRelayHandler(tools dcos.Tooler, urlBuilder dcos.NodeURLBuilder, transport http.RoundTripper) *RelayHandler {
+	return &RelayHandler{
+		tools:      tools,
+		urlBuilder: urlBuilder,
+		transport:  transport,
+	}
+}
+
+// Relay proxies the request to the agent given in the path. Only agents known to the cluster could be called.
+func (h *RelayHandler) Relay(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ip := net.ParseIP(vars["node"])
+	if ip == nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid node IP %s", vars["node"]))
+		return
+	}
+
+	agents, err := h.tools.GetAgentNodes()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("unable to get list of agents: %s", err))
+		return
+	}
+
+	role := ""
+	for _, a := range agents {
+		if ip.Equal(net.ParseIP(a.IP)) {
+			role = a.Role
+			break
+		}
+	}
+	if role == "" {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("node %s is not an agent of this cluster", ip))
+		return
+	}
+
+	baseURL, err := h.urlBuilder.BaseURL(ip, role)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("unable to build base URL for node %s: %s", ip, err))
+		return
+	}
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("invalid base URL for node %s: %s", ip, err))
+		return
+	}
+
+	prefix := fmt.Sprintf("%s/%s", relayEndpoint, vars["node"])
+	director := func(req *http.Request) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
+		req.Host = target.Host
+	}
+	proxy := &httputil.ReverseProxy{
+		Director:  director,
+		Transport: h.transport,
+	}
+
+	logrus.WithField("node", ip).WithField("path", r.URL.Path).Debug("Relaying request to agent")
+	proxy.ServeHTTP(w, r)
+}
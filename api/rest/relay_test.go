@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+	"github.com/dcos/dcos-diagnostics/dcos"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	relayBundleEndpoint     = relayEndpoint + "/{node}" + bundleEndpoint
+	relayBundleFileEndpoint = relayEndpoint + "/{node}" + bundleFileEndpoint
+)
+
+type staticURLBuilder string
+
+func (s staticURLBuilder) BaseURL(net.IP, string) (string, error) {
+	return string(s), nil
+}
+
+func TestCoordinatorCollectsAgentBundleThroughRelay(t *testing.T) {
+	agentDir, err := ioutil.TempDir("", "agent-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(agentDir)
+	masterDir, err := ioutil.TempDir("", "master-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(masterDir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+	}
+	bh, err := NewBundleHandler(agentDir, collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	agentRouter := mux.NewRouter()
+	agentRouter.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	agentRouter.HandleFunc(bundleEndpoint, bh.Get).Methods(http.MethodGet)
+	agentRouter.HandleFunc(bundleEndpoint, bh.Delete).Methods(http.MethodDelete)
+	agentRouter.HandleFunc(bundleFileEndpoint, bh.GetFile).Methods(http.MethodGet)
+	agent := httptest.NewServer(agentRouter)
+	defer agent.Close()
+
+	agentIP := net.ParseIP("192.0.2.1")
+	tools := new(MockedTools)
+	tools.On("GetAgentNodes").Return([]dcos.Node{{IP: agentIP.String(), Role: dcos.AgentRole}}, nil)
+
+	var relayed int32
+	rh := NewRelayHandler(tools, staticURLBuilder(agent.URL), http.DefaultTransport)
+	relay := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&relayed, 1)
+		rh.Relay(w, r)
+	}
+	masterRouter := mux.NewRouter()
+	masterRouter.HandleFunc(relayBundleEndpoint, relay).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+	masterRouter.HandleFunc(relayBundleFileEndpoint, relay).Methods(http.MethodGet)
+	master := httptest.NewServer(masterRouter)
+	defer master.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nodes := []node{{IP: agentIP, Role: dcos.AgentRole, baseURL: RelayURL(master.URL, agentIP)}}
+	c := NewParallelCoordinator(NewDiagnosticsClient(master.Client()), time.Millisecond, masterDir)
+	statuses := c.CreateBundle(ctx, "bundle-local", nodes)
+
+	bundlePath, err := c.CollectBundle(ctx, "bundle-0", len(nodes), statuses)
+	require.NoError(t, err)
+
+	reader, err := zip.OpenReader(bundlePath)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	files := map[string]string{}
+	for _, f := range reader.File {
+		files[f.Name] = string(readZipFile(t, f))
+	}
+	assert.Equal(t, "OK", files["192.0.2.1_agent/collector-1"])
+	assert.Equal(t, `{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"}},`+
+		`"node_list":[{"ip":"192.0.2.1","role":"agent","status":"Done"}]}`, files[reportFileName])
+	// create, at least one status check and download
+	assert.True(t, atomic.LoadInt32(&relayed) >= 3)
+}
+
+func TestRelayRefusesUnknownNodes(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("GetAgentNodes").Return([]dcos.Node{{IP: "192.0.2.1", Role: dcos.AgentRole}}, nil)
+
+	rh := NewRelayHandler(tools, staticURLBuilder("http://192.0.2.1"), http.DefaultTransport)
+	router := mux.NewRouter()
+	router.HandleFunc(relayBundleEndpoint, rh.Relay).Methods(http.MethodGet)
+
+	for path, expected := range map[string]string{
+		relayEndpoint + "/192.0.2.2" + bundlesEndpoint + "/bundle-0": `{"code":400,"error":"node 192.0.2.2 is not an agent of this cluster"}`,
+		relayEndpoint + "/invalid" + bundlesEndpoint + "/bundle-0":   `{"code":400,"error":"invalid node IP invalid"}`,
+	} {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, expected, rr.Body.String())
+	}
+}
+
+func TestRelayAgentsUsesFirstMaster(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{IP: "192.0.2.10", Role: dcos.MasterRole}, {IP: "192.0.2.11", Role: dcos.MasterRole}}, nil)
+
+	c := &ClusterBundleHandler{tools: tools, urlBuilder: MockURLBuilder{}}
+
+	nodes := []node{
+		{IP: net.ParseIP("192.0.2.10"), Role: dcos.MasterRole, baseURL: "http://192.0.2.10"},
+		{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: "http://192.0.2.1"},
+		{IP: net.ParseIP("192.0.2.2"), Role: dcos.AgentPublicRole, baseURL: "http://192.0.2.2"},
+	}
+	require.NoError(t, c.relayAgents(nodes, ""))
+
+	assert.Equal(t, "http://192.0.2.10", nodes[0].baseURL)
+	assert.Equal(t, "http://192.0.2.10/system/health/v1/relay/192.0.2.1", nodes[1].baseURL)
+	assert.Equal(t, "http://192.0.2.10/system/health/v1/relay/192.0.2.2", nodes[2].baseURL)
+}
+
+func TestRelayAgentsUsesLeader(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{IP: "192.0.2.10", Role: dcos.MasterRole},
+		{IP: "192.0.2.11", Role: dcos.MasterRole, Leader: true},
+	}, nil)
+
+	c := &ClusterBundleHandler{tools: tools, urlBuilder: MockURLBuilder{}}
+
+	for leader, expected := range map[string]string{
+		"":           "http://192.0.2.11/system/health/v1/relay/192.0.2.1",
+		"192.0.2.10": "http://192.0.2.10/system/health/v1/relay/192.0.2.1",
+	} {
+		nodes := []node{{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: "http://192.0.2.1"}}
+		require.NoError(t, c.relayAgents(nodes, leader))
+		assert.Equal(t, expected, nodes[0].baseURL, leader)
+	}
+
+	nodes := []node{{IP: net.ParseIP("192.0.2.1"), Role: dcos.AgentRole, baseURL: "http://192.0.2.1"}}
+	assert.EqualError(t, c.relayAgents(nodes, "192.0.2.1"), "leader 192.0.2.1 is not a master of this cluster")
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+// ReportOrder tells how nodes are sorted in the node list of cluster bundle reports. The nodes map is kept
+// for compatibility but its order is not defined so reports compared with each other should use the list.
+type ReportOrder string
+
+const (
+	// ReportOrderIP sorts nodes by IP address. This is the default.
+	ReportOrderIP ReportOrder = "ip"
+	// ReportOrderRole sorts masters first, then agents and public agents, nodes with the same role by IP address
+	ReportOrderRole ReportOrder = "role"
+)
+
+// ParseReportOrder returns the report order with given name. Empty name means the default order.
+func ParseReportOrder(name string) (ReportOrder, error) {
+	switch ReportOrder(name) {
+	case "", ReportOrderIP:
+		return ReportOrderIP, nil
+	case ReportOrderRole:
+		return ReportOrderRole, nil
+	}
+	return "", fmt.Errorf("unknown report order %s, expected %s or %s", name, ReportOrderIP, ReportOrderRole)
+}
+
+// rolesOrder lists roles in the order of ReportOrderRole, unknown roles go last
+var rolesOrder = map[string]int{
+	dcos.MasterRole:      0,
+	dcos.AgentRole:       1,
+	dcos.AgentPublicRole: 2,
+}
+
+// orderedNodeReport is an entry of the node list of the report
+type orderedNodeReport struct {
+	IP   string `json:"ip"`
+	Role string `json:"role,omitempty"`
+	nodeBundleReport
+}
+
+// withNodeList returns the report with the node list built from the nodes map in the given order
+func (r bundleReport) withNodeList(order ReportOrder) bundleReport {
+	list := make([]orderedNodeReport, 0, len(r.Nodes))
+	for ip, n := range r.Nodes {
+		list = append(list, orderedNodeReport{IP: ip, Role: r.roles[ip], nodeBundleReport: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if order == ReportOrderRole && list[i].Role != list[j].Role {
+			return roleRank(list[i].Role) < roleRank(list[j].Role)
+		}
+		return lessIP(list[i].IP, list[j].IP)
+	})
+	r.NodeList = list
+	return r
+}
+
+func roleRank(role string) int {
+	if rank, ok := rolesOrder[role]; ok {
+		return rank
+	}
+	return len(rolesOrder)
+}
+
+// lessIP compares addresses numerically so 10.0.0.9 goes before 10.0.0.10, invalid addresses go last.
+// Keys of nodes sharing an IP are compared by the IP and then by the node identity.
+func lessIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(keyIP(a)).To16(), net.ParseIP(keyIP(b)).To16()
+	switch {
+	case ipA == nil && ipB == nil:
+		return a < b
+	case ipA == nil:
+		return false
+	case ipB == nil:
+		return true
+	}
+	if c := bytes.Compare(ipA, ipB); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// keyIP returns the IP of the node key
+func keyIP(key string) string {
+	return strings.SplitN(key, "-", 2)[0]
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReportOrder(t *testing.T) {
+	for name, expected := range map[string]ReportOrder{"": ReportOrderIP, "ip": ReportOrderIP, "role": ReportOrderRole} {
+		order, err := ParseReportOrder(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, order)
+	}
+
+	_, err := ParseReportOrder("name")
+	assert.EqualError(t, err, "unknown report order name, expected ip or role")
+}
+
+func testReport() bundleReport {
+	return bundleReport{
+		ID: "bundle-0",
+		Nodes: map[string]nodeBundleReport{
+			"10.0.0.10":  {Status: Done},
+			"10.0.0.9":   {Status: Failed, Err: "timeout"},
+			"10.0.1.1":   {Status: Done},
+			"192.0.2.1":  {Status: Done},
+			"not-an-ip":  {Status: Failed},
+			"10.0.0.100": {Status: Done},
+		},
+		roles: map[string]string{
+			"10.0.0.10":  dcos.AgentRole,
+			"10.0.0.9":   dcos.AgentPublicRole,
+			"10.0.1.1":   dcos.MasterRole,
+			"192.0.2.1":  dcos.MasterRole,
+			"not-an-ip":  dcos.AgentRole,
+			"10.0.0.100": dcos.AgentRole,
+		},
+	}
+}
+
+func nodeListIPs(report bundleReport) []string {
+	var ips []string
+	for _, n := range report.NodeList {
+		ips = append(ips, n.IP)
+	}
+	return ips
+}
+
+func TestWithNodeListSortsNodesByIP(t *testing.T) {
+	report := testReport().withNodeList(ReportOrderIP)
+	assert.Equal(t, []string{"10.0.0.9", "10.0.0.10", "10.0.0.100", "10.0.1.1", "192.0.2.1", "not-an-ip"}, nodeListIPs(report))
+	assert.Equal(t, orderedNodeReport{IP: "10.0.0.9", Role: dcos.AgentPublicRole, nodeBundleReport: nodeBundleReport{Status: Failed, Err: "timeout"}},
+		report.NodeList[0])
+}
+
+func TestWithNodeListSortsNodesByRoleAndIP(t *testing.T) {
+	report := testReport().withNodeList(ReportOrderRole)
+	assert.Equal(t, []string{"10.0.1.1", "192.0.2.1", "10.0.0.10", "10.0.0.100", "not-an-ip", "10.0.0.9"}, nodeListIPs(report))
+}
+
+func TestWithNodeListIsDeterministic(t *testing.T) {
+	first := jsonMarshal(testReport().withNodeList(ReportOrderRole))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, string(first), string(jsonMarshal(testReport().withNodeList(ReportOrderRole))))
+	}
+
+	// the map is kept for compatibility
+	var report map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(first, &report))
+	assert.Contains(t, report, "nodes")
+	assert.Contains(t, report, "node_list")
+}
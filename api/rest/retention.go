@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Retention configures automatic removal of local bundles data
+type Retention struct {
+	MaxAge   time.Duration // bundles finished earlier than MaxAge ago are removed, 0 disables the limit
+	MaxCount int           // only MaxCount newest bundles are kept, 0 disables the limit
+	Grace    time.Duration // bundles finished or downloaded less than Grace ago are never removed
+}
+
+// Enabled returns true if any of retention limits is set
+func (r Retention) Enabled() bool {
+	return r.MaxAge > 0 || r.MaxCount > 0
+}
+
+// RunRetention removes bundles exceeding retention limits every interval until the context is done
+func (h BundleHandler) RunRetention(ctx context.Context, interval time.Duration, retention Retention) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := h.sweep(retention)
+			if err != nil {
+				logrus.WithError(err).Error("Could not apply bundles retention")
+			}
+			if len(removed) > 0 {
+				logrus.WithField("bundles", removed).Info("Removed bundles exceeding retention")
+			}
+		}
+	}
+}
+
+// sweep removes data of bundles exceeding retention limits and returns their IDs.
+// Only finished bundles with data are considered. Pinned bundles are always kept
+// and do not count towards MaxCount. Bundles within the grace window are kept but still count.
+func (h BundleHandler) sweep(retention Retention) ([]string, error) {
+	ids, err := ioutil.ReadDir(h.workDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read work dir: %s", err)
+	}
+
+	bundles := make([]Bundle, 0, len(ids))
+	for _, id := range ids {
+		if !id.IsDir() {
+			continue
+		}
+		bundle, err := h.getBundleState(id.Name())
+		if err != nil {
+			logrus.WithField("ID", id.Name()).WithError(err).Warn("There is a problem with the bundle")
+			continue
+		}
+		if bundle.Status != Done || bundle.Pinned {
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+
+	// newest first
+	sort.SliceStable(bundles, func(i, j int) bool {
+		return bundles[i].Stopped.After(bundles[j].Stopped)
+	})
+
+	now := h.clock.Now()
+	var removed []string
+	for i, bundle := range bundles {
+		expired := retention.MaxAge > 0 && now.Sub(bundle.Stopped) > retention.MaxAge
+		overLimit := retention.MaxCount > 0 && i >= retention.MaxCount
+		if !expired && !overLimit {
+			continue
+		}
+		if retention.Grace > 0 && now.Sub(lastActivity(bundle)) < retention.Grace {
+			continue
+		}
+
+		if h.removeUnpinned(bundle.ID) {
+			removed = append(removed, bundle.ID)
+		}
+	}
+
+	return removed, nil
+}
+
+// removeUnpinned removes data of the done bundle and returns true if it was removed. The state is read again
+// under the bundle lock so the bundle pinned or deleted since it was listed is kept.
+func (h BundleHandler) removeUnpinned(id string) bool {
+	unlock := h.bundleLocks.lock(id)
+	defer unlock()
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		logrus.WithField("ID", id).WithError(err).Warn("There is a problem with the bundle")
+		return false
+	}
+	if bundle.Status != Done || bundle.Pinned {
+		return false
+	}
+
+	if err := h.removeData(bundle); err != nil {
+		logrus.WithField("ID", id).WithError(err).Warn("Could not remove bundle data")
+		return false
+	}
+	bundle.Status = Deleted
+	if _, err := h.writeStateFile(bundle); err != nil {
+		logrus.WithField("ID", id).WithError(err).Warn("Bundle was removed but state could not be updated")
+	}
+	return true
+}
+
+// lastActivity returns when the bundle was finished or downloaded, whichever is later
+func lastActivity(bundle Bundle) time.Time {
+	if bundle.LastAccessed != nil && bundle.LastAccessed.After(bundle.Stopped) {
+		return *bundle.LastAccessed
+	}
+	return bundle.Stopped
+}
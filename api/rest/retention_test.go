@@ -0,0 +1,280 @@
+package rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createDoneBundle(t *testing.T, bh *BundleHandler, id string, stopped time.Time, pinned bool) {
+	require.NoError(t, os.Mkdir(filepath.Join(bh.workDir, id), dirPerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bh.workDir, id, dataFileName), []byte("OK"), filePerm))
+	_, err := bh.writeStateFile(Bundle{
+		ID:      id,
+		Status:  Done,
+		Started: stopped.Add(-time.Minute),
+		Stopped: stopped,
+		Pinned:  pinned,
+	})
+	require.NoError(t, err)
+}
+
+func TestRetentionSweepKeepsPinnedBundles(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: now.Add(-time.Hour)}
+
+	createDoneBundle(t, bh, "newest", now.Add(-time.Hour), false)
+	createDoneBundle(t, bh, "old", now.Add(-48*time.Hour), false)
+	createDoneBundle(t, bh, "old-pinned", now.Add(-72*time.Hour), true)
+	createDoneBundle(t, bh, "older", now.Add(-96*time.Hour), false)
+
+	removed, err := bh.sweep(Retention{MaxAge: 24 * time.Hour, MaxCount: 1})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"old", "older"}, removed)
+
+	for id, status := range map[string]Status{"newest": Done, "old-pinned": Done, "old": Deleted, "older": Deleted} {
+		bundle, err := bh.getBundleState(id)
+		assert.NoError(t, err)
+		assert.Equal(t, status, bundle.Status, id)
+		if status == Deleted {
+			assert.NoFileExists(t, filepath.Join(workdir, id, dataFileName))
+		} else {
+			assert.FileExists(t, filepath.Join(workdir, id, dataFileName))
+		}
+	}
+}
+
+func TestRetentionSweepKeepsBundlePinnedAfterItWasListed(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: now.Add(-time.Hour)}
+
+	createDoneBundle(t, bh, "old", now.Add(-48*time.Hour), false)
+
+	// bundle is pinned while the sweep waits for its lock
+	unlock := bh.bundleLocks.lock("old")
+	done := make(chan bool)
+	go func() {
+		done <- bh.removeUnpinned("old")
+	}()
+	bundle, err := bh.getBundleState("old")
+	require.NoError(t, err)
+	bundle.Pinned = true
+	_, err = bh.writeStateFile(bundle)
+	require.NoError(t, err)
+	unlock()
+
+	assert.False(t, <-done)
+	bundle, err = bh.getBundleState("old")
+	require.NoError(t, err)
+	assert.Equal(t, Done, bundle.Status)
+	assert.True(t, bundle.Pinned)
+	assert.FileExists(t, filepath.Join(workdir, "old", dataFileName))
+}
+
+func TestRetentionSweepWithMaxCountOnly(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: now}
+
+	for i := 0; i < 4; i++ {
+		createDoneBundle(t, bh, fmt.Sprintf("bundle-%d", i), now.Add(-time.Duration(i)*time.Minute), i == 3)
+	}
+
+	removed, err := bh.sweep(Retention{MaxCount: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bundle-2"}, removed)
+}
+
+func TestPinAndUnpin(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	createDoneBundle(t, bh, "bundle-0", now, false)
+
+	createDoneBundle(t, bh, "bundle-1", now, false)
+	_, err = bh.writeStateFile(Bundle{ID: "bundle-1", Status: InProgress})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint+"/pin", bh.Pin).Methods(http.MethodPost)
+	router.HandleFunc(bundleEndpoint+"/unpin", bh.Unpin).Methods(http.MethodPost)
+
+	do := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(bundlesEndpoint + "/bundle-0/pin")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{
+		"id": "bundle-0",
+		"type": "Local",
+		"status": "Done",
+		"size": 2,
+		"pinned": true,
+		"started_at": "2015-08-05T08:39:51.62Z",
+		"stopped_at": "2015-08-05T08:40:51.62Z"}`, rr.Body.String())
+
+	removed, err := bh.sweep(Retention{MaxAge: time.Nanosecond})
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	rr = do(bundlesEndpoint + "/bundle-0/unpin")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	assert.False(t, bundle.Pinned)
+
+	removed, err = bh.sweep(Retention{MaxAge: time.Nanosecond})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bundle-0"}, removed)
+
+	rr = do(bundlesEndpoint + "/bundle-1/pin")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.JSONEq(t, `{"code": 409, "error": "bundle bundle-1 is not finished yet (status InProgress), try again later"}`, rr.Body.String())
+
+	rr = do(bundlesEndpoint + "/not-existing/pin")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestRetentionSweepSparesRecentlyDownloadedBundles(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	createDoneBundle(t, bh, "downloaded", now.Add(-48*time.Hour), false)
+	createDoneBundle(t, bh, "untouched", now.Add(-48*time.Hour), false)
+
+	// download sets the last access time using the handler clock
+	bh.clock = &MockClock{now: now.Add(-2 * time.Hour)}
+	router := mux.NewRouter()
+	router.HandleFunc(bundleFileEndpoint, bh.GetFile)
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/downloaded/file", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	bundle, err := bh.getBundleState("downloaded")
+	require.NoError(t, err)
+	require.NotNil(t, bundle.LastAccessed)
+	assert.Equal(t, now.Add(-time.Hour), *bundle.LastAccessed)
+
+	bh.clock = &MockClock{now: now.Add(-time.Hour)}
+	removed, err := bh.sweep(Retention{MaxAge: 24 * time.Hour, Grace: 2 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"untouched"}, removed)
+
+	bundle, err = bh.getBundleState("downloaded")
+	require.NoError(t, err)
+	assert.Equal(t, Done, bundle.Status)
+}
+
+func TestDownloadDoesNotRewriteState(t *testing.T) {
+	t.Parallel()
+
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+	bh.clock = &MockClock{now: now}
+	createDoneBundle(t, bh, "bundle-0", now.Add(-time.Hour), false)
+	stateFilePath := filepath.Join(workdir, "bundle-0", stateFileName)
+	rawState, err := ioutil.ReadFile(stateFilePath)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleFileEndpoint, bh.GetFile)
+	router.HandleFunc(bundleEndpoint+"/pin", bh.Pin).Methods(http.MethodPost)
+
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/file", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	afterDownload, err := ioutil.ReadFile(stateFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, string(rawState), string(afterDownload))
+
+	// the pin is not lost by the following download
+	req, err = http.NewRequest(http.MethodPost, bundlesEndpoint+"/bundle-0/pin", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	req, err = http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/file", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	bundle, err := bh.getBundleState("bundle-0")
+	require.NoError(t, err)
+	assert.True(t, bundle.Pinned)
+	require.NotNil(t, bundle.LastAccessed)
+	assert.True(t, bundle.LastAccessed.After(now))
+}
@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Schedule configures periodic creation of cluster bundles
+type Schedule struct {
+	Interval time.Duration // time between scheduled bundles, 0 disables the schedule
+	options  options
+}
+
+// NewSchedule returns a schedule creating cluster bundles every interval. Nodes of scheduled bundles
+// are selected with rawOptions, a JSON body of the cluster bundle create request. Empty rawOptions
+// selects all nodes.
+func NewSchedule(interval time.Duration, rawOptions string) (Schedule, error) {
+	o := defaultOptions
+	if rawOptions != "" {
+		if err := json.Unmarshal([]byte(rawOptions), &o); err != nil {
+			return Schedule{}, fmt.Errorf("could not parse schedule options: %s", err)
+		}
+	}
+	o.Source = SourceScheduled
+	return Schedule{Interval: interval, options: o}, nil
+}
+
+// Enabled returns true if bundles should be created periodically
+func (s Schedule) Enabled() bool {
+	return s.Interval > 0
+}
+
+// RunSchedule creates cluster bundles every schedule interval until the context is done. Bundles are created
+// only on the master coordinating cluster operations. A tick is skipped when the bundle created by
+// the previous tick is not finished yet.
+func (c *ClusterBundleHandler) RunSchedule(ctx context.Context, schedule Schedule) {
+	ticker := time.NewTicker(schedule.Interval)
+	defer ticker.Stop()
+
+	running := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			running = c.scheduledCreate(schedule, running)
+		}
+	}
+}
+
+// scheduledCreate creates a scheduled bundle unless the running one is not finished and returns
+// the ID of the scheduled bundle in progress
+func (c *ClusterBundleHandler) scheduledCreate(schedule Schedule, running string) string {
+	if running != "" && !c.bundleFinished(running) {
+		logrus.WithField("ID", running).Warn("Previous scheduled bundle is still in progress, skipping")
+		return running
+	}
+
+	coordinator, err := c.isCoordinator()
+	if err != nil {
+		logrus.WithError(err).Error("Could not check if this master should create scheduled bundle")
+		return ""
+	}
+	if !coordinator {
+		return ""
+	}
+
+	id := fmt.Sprintf("scheduled-%d", c.clock.Now().Unix())
+	req, err := http.NewRequest(http.MethodPut, "", bytes.NewReader(jsonMarshal(schedule.options)))
+	if err != nil {
+		logrus.WithField("ID", id).WithError(err).Error("Could not create scheduled bundle")
+		return ""
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	req.Header.Set(PrincipalHeader, "scheduler")
+
+	rr := httptest.NewRecorder()
+	c.Create(rr, req)
+	if rr.Code != http.StatusOK {
+		logrus.WithField("ID", id).WithField("status", rr.Code).Errorf("Could not create scheduled bundle: %s", rr.Body)
+		return ""
+	}
+	logrus.WithField("ID", id).Info("Created scheduled bundle")
+	return id
+}
+
+// bundleFinished returns true if the local bundle is finished. Bundles with unreadable state
+// e.g., removed by retention, are considered finished.
+func (c *ClusterBundleHandler) bundleFinished(id string) bool {
+	bundle, err := c.readStateFile(id)
+	if err != nil {
+		return true
+	}
+	return bundle.IsFinished()
+}
+
+// isCoordinator returns true if this node is the master coordinating cluster operations
+func (c *ClusterBundleHandler) isCoordinator() (bool, error) {
+	ip, err := c.tools.DetectIP()
+	if err != nil {
+		return false, fmt.Errorf("could not detect IP: %s", err)
+	}
+	masters, err := c.getMasterNodes()
+	if err != nil {
+		return false, fmt.Errorf("unable to get list of masters: %s", err)
+	}
+	m, err := coordinatingMaster(masters, c.leader)
+	if err != nil {
+		return false, err
+	}
+	return m.IP.Equal(net.ParseIP(ip)), nil
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingCoordinator collects bundles only when released
+type blockingCoordinator struct {
+	mockCoordinator
+	release chan struct{}
+}
+
+func (c blockingCoordinator) CollectBundle(ctx context.Context, id string, numBundles int, statuses <-chan BundleStatus) (string, error) {
+	<-c.release
+	return c.mockCoordinator.CollectBundle(ctx, id, numBundles, statuses)
+}
+
+func TestScheduledCreateSkipsTicksWhenPreviousBundleIsRunning(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	now, err := time.Parse(time.RFC3339, "2015-08-05T08:40:51.620Z")
+	require.NoError(t, err)
+
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("192.0.2.1", nil)
+	tools.On("GetMasterNodes").Return([]dcos.Node{{Role: dcos.MasterRole, IP: "192.0.2.1", Leader: true}}, nil)
+
+	coord := blockingCoordinator{release: make(chan struct{})}
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      coord,
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{now: now},
+		urlBuilder: MockURLBuilder{},
+	}
+
+	schedule, err := NewSchedule(time.Minute, `{"agents": false}`)
+	require.NoError(t, err)
+
+	first := bh.scheduledCreate(schedule, "")
+	require.NotEmpty(t, first)
+	state, err := ioutil.ReadFile(filepath.Join(workdir, first, stateFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(state), `"source":"scheduled"`)
+
+	assert.Equal(t, first, bh.scheduledCreate(schedule, first), "overlapping tick must be skipped")
+	ids, err := ioutil.ReadDir(workdir)
+	require.NoError(t, err)
+	assert.Len(t, ids, 1)
+
+	close(coord.release)
+	require.Eventually(t, func() bool { return bh.bundleFinished(first) }, time.Second, 10*time.Millisecond)
+
+	second := bh.scheduledCreate(schedule, first)
+	assert.NotEmpty(t, second)
+	assert.NotEqual(t, first, second)
+	require.Eventually(t, func() bool { return bh.bundleFinished(second) }, time.Second, 10*time.Millisecond)
+}
+
+func TestScheduledCreateOnlyOnCoordinatingMaster(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("192.0.2.2", nil)
+	tools.On("GetMasterNodes").Return([]dcos.Node{
+		{Role: dcos.MasterRole, IP: "192.0.2.1", Leader: true},
+		{Role: dcos.MasterRole, IP: "192.0.2.2"},
+	}, nil)
+
+	bh := ClusterBundleHandler{
+		workDir:    workdir,
+		coord:      mockCoordinator{},
+		tools:      tools,
+		timeout:    time.Second,
+		clock:      &MockClock{},
+		urlBuilder: MockURLBuilder{},
+	}
+
+	schedule, err := NewSchedule(time.Minute, "")
+	require.NoError(t, err)
+
+	assert.Empty(t, bh.scheduledCreate(schedule, ""))
+	ids, err := ioutil.ReadDir(workdir)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestNewScheduleValidatesOptions(t *testing.T) {
+	_, err := NewSchedule(time.Minute, `{"masters": "yes"}`)
+	assert.Error(t, err)
+
+	s, err := NewSchedule(0, "")
+	require.NoError(t, err)
+	assert.False(t, s.Enabled())
+	assert.Equal(t, SourceScheduled, s.options.Source)
+}
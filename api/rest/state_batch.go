@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stateBatcher coalesces state file updates of unfinished bundles. The first update of a bundle is delayed by
+// the interval and updates made in the meantime replace it, so only the latest one is written. Writes of the
+// authoritative state e.g., on completion, are never delayed and drop the pending update so it could not
+// overwrite them. Readers see the last written state which might be stale by up to the interval but it is
+// always complete. Zero interval or nil batcher writes every update immediately.
+type stateBatcher struct {
+	sync.Mutex
+	interval time.Duration
+	pending  map[string]*pendingState
+}
+
+type pendingState struct {
+	bundle Bundle
+	write  func(Bundle) ([]byte, error)
+	timer  *time.Timer
+}
+
+func newStateBatcher(interval time.Duration) *stateBatcher {
+	return &stateBatcher{
+		interval: interval,
+		pending:  make(map[string]*pendingState),
+	}
+}
+
+// update records the state of the bundle to be written after the interval unless it is updated again
+func (b *stateBatcher) update(bundle Bundle, write func(Bundle) ([]byte, error)) error {
+	if b == nil || b.interval <= 0 {
+		_, err := write(bundle)
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if p, ok := b.pending[bundle.ID]; ok {
+		p.bundle = bundle
+		p.write = write
+		return nil
+	}
+	b.pending[bundle.ID] = &pendingState{
+		bundle: bundle,
+		write:  write,
+		timer:  time.AfterFunc(b.interval, func() { b.flush(bundle.ID) }),
+	}
+	return nil
+}
+
+// flush writes the pending update of the bundle if there is one
+func (b *stateBatcher) flush(id string) {
+	b.Lock()
+	defer b.Unlock()
+	p, ok := b.pending[id]
+	if !ok {
+		return
+	}
+	delete(b.pending, id)
+	if _, err := p.write(p.bundle); err != nil {
+		logrus.WithField("ID", id).WithError(err).Warn("Could not write batched state file")
+	}
+}
+
+// writeNow writes the state of the bundle immediately and drops its pending update
+func (b *stateBatcher) writeNow(bundle Bundle, write func(Bundle) ([]byte, error)) ([]byte, error) {
+	if b == nil {
+		return write(bundle)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if p, ok := b.pending[bundle.ID]; ok {
+		p.timer.Stop()
+		delete(b.pending, bundle.ID)
+	}
+	return write(bundle)
+}
@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingStateWriter struct {
+	sync.Mutex
+	written []Bundle
+}
+
+func (r *recordingStateWriter) write(bundle Bundle) ([]byte, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.written = append(r.written, bundle)
+	return jsonMarshal(bundle), nil
+}
+
+func (r *recordingStateWriter) get() []Bundle {
+	r.Lock()
+	defer r.Unlock()
+	return append([]Bundle{}, r.written...)
+}
+
+func TestStateBatcherCoalescesRapidUpdates(t *testing.T) {
+	w := &recordingStateWriter{}
+	b := newStateBatcher(50 * time.Millisecond)
+
+	for i := int64(1); i <= 100; i++ {
+		require.NoError(t, b.update(Bundle{ID: "bundle", Status: InProgress, Checkpoint: i}, w.write))
+	}
+
+	assert.Eventually(t, func() bool { return len(w.get()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(100), w.get()[0].Checkpoint)
+
+	raw, err := b.writeNow(Bundle{ID: "bundle", Status: Done, Checkpoint: 200}, w.write)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"status":"Done"`)
+
+	written := w.get()
+	require.Len(t, written, 2)
+	assert.Equal(t, Done, written[1].Status)
+}
+
+func TestStateBatcherFinalWriteDropsPendingUpdate(t *testing.T) {
+	w := &recordingStateWriter{}
+	b := newStateBatcher(20 * time.Millisecond)
+
+	require.NoError(t, b.update(Bundle{ID: "bundle", Status: InProgress, Checkpoint: 1}, w.write))
+	_, err := b.writeNow(Bundle{ID: "bundle", Status: Done}, w.write)
+	require.NoError(t, err)
+
+	// pending update must not overwrite the final state
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, []Bundle{{ID: "bundle", Status: Done}}, w.get())
+}
+
+func TestStateBatcherWritesImmediatelyWhenDisabled(t *testing.T) {
+	w := &recordingStateWriter{}
+
+	var b *stateBatcher
+	require.NoError(t, b.update(Bundle{ID: "a", Checkpoint: 1}, w.write))
+	require.NoError(t, newStateBatcher(0).update(Bundle{ID: "a", Checkpoint: 2}, w.write))
+
+	assert.Len(t, w.get(), 2)
+}
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"container/list"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultStateCacheSize is a number of parsed bundle states kept in memory by default
+const DefaultStateCacheSize = 1000
+
+// stateCache keeps parsed bundle states so listing bundles does not read and unmarshal every state file.
+// An entry is valid as long as the state file modification time and size did not change.
+// The least recently used entries are evicted when the cache is full. Zero size or nil cache disables caching.
+type stateCache struct {
+	sync.Mutex
+	size    int
+	order   *list.List // of *cachedState, the most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedState struct {
+	id      string
+	modTime time.Time
+	size    int64
+	bundle  Bundle
+}
+
+func newStateCache(size int) *stateCache {
+	return &stateCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached state of the bundle if it was cached for the state file described by info
+func (c *stateCache) get(id string, info os.FileInfo) (Bundle, bool) {
+	if c == nil {
+		return Bundle{}, false
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[id]
+	if !ok {
+		return Bundle{}, false
+	}
+	cached := e.Value.(*cachedState)
+	if !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+		c.order.Remove(e)
+		delete(c.entries, id)
+		return Bundle{}, false
+	}
+	c.order.MoveToFront(e)
+
+	return copyBundle(cached.bundle), true
+}
+
+// put caches the state of the bundle read from the state file described by info
+func (c *stateCache) put(id string, info os.FileInfo, bundle Bundle) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	entry := &cachedState{id: id, modTime: info.ModTime(), size: info.Size(), bundle: copyBundle(bundle)}
+	if e, ok := c.entries[id]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[id] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedState).id)
+	}
+}
+
+// remove drops the cached state of the bundle
+func (c *stateCache) remove(id string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.entries[id]; ok {
+		c.order.Remove(e)
+		delete(c.entries, id)
+	}
+}
+
+// copyBundle copies slices and pointers of the bundle so callers could modify them without changing the cache
+func copyBundle(bundle Bundle) Bundle {
+	bundle.Errors = append([]string(nil), bundle.Errors...)
+	bundle.Warnings = append([]string(nil), bundle.Warnings...)
+	if bundle.LastAccessed != nil {
+		lastAccessed := *bundle.LastAccessed
+		bundle.LastAccessed = &lastAccessed
+	}
+	return bundle
+}
@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateCacheIsInvalidatedWhenStateFileChanges(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	bundle := Bundle{ID: "bundle-0", Status: Deleted}
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, bundle.ID), dirPerm))
+	_, err = bh.writeStateFile(bundle)
+	require.NoError(t, err)
+
+	got, err := bh.getBundleState(bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, got)
+
+	// changed by the handler
+	bundle.Errors = []string{"some error"}
+	_, err = bh.writeStateFile(bundle)
+	require.NoError(t, err)
+
+	got, err = bh.getBundleState(bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, got)
+
+	// changed by someone else
+	bundle.Status = Canceled
+	stateFilePath := filepath.Join(workdir, bundle.ID, stateFileName)
+	require.NoError(t, ioutil.WriteFile(stateFilePath, jsonMarshal(bundle), filePerm))
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(stateFilePath, future, future))
+
+	got, err = bh.getBundleState(bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, got)
+
+	// cached values are not changed by callers
+	got.Errors[0] = "changed"
+	got, err = bh.getBundleState(bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"some error"}, got.Errors)
+}
+
+func TestStateCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workdir, stateFileName), nil, filePerm))
+	info, err := os.Stat(filepath.Join(workdir, stateFileName))
+	require.NoError(t, err)
+
+	c := newStateCache(2)
+	c.put("bundle-0", info, Bundle{ID: "bundle-0"})
+	c.put("bundle-1", info, Bundle{ID: "bundle-1"})
+	_, ok := c.get("bundle-0", info)
+	assert.True(t, ok)
+
+	c.put("bundle-2", info, Bundle{ID: "bundle-2"})
+	_, ok = c.get("bundle-1", info)
+	assert.False(t, ok)
+	_, ok = c.get("bundle-0", info)
+	assert.True(t, ok)
+	_, ok = c.get("bundle-2", info)
+	assert.True(t, ok)
+
+	c.remove("bundle-0")
+	_, ok = c.get("bundle-0", info)
+	assert.False(t, ok)
+
+	disabled := newStateCache(0)
+	disabled.put("bundle-0", info, Bundle{ID: "bundle-0"})
+	_, ok = disabled.get("bundle-0", info)
+	assert.False(t, ok)
+}
+
+func BenchmarkList(b *testing.B) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(b, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(b, err)
+
+	for i := 0; i < 500; i++ {
+		bundle := Bundle{ID: fmt.Sprintf("bundle-%d", i), Status: Deleted, Errors: []string{"some error"}}
+		require.NoError(b, os.MkdirAll(filepath.Join(workdir, bundle.ID), dirPerm))
+		_, err := bh.writeStateFile(bundle)
+		require.NoError(b, err)
+	}
+
+	for name, size := range map[string]int{"cached": DefaultStateCacheSize, "uncached": 0} {
+		b.Run(name, func(b *testing.B) {
+			bh.WithStateCacheSize(size)
+			req, err := http.NewRequest(http.MethodGet, bundlesEndpoint, nil)
+			require.NoError(b, err)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rr := httptest.NewRecorder()
+				bh.List(rr, req)
+				require.Equal(b, http.StatusOK, rr.Code)
+			}
+		})
+	}
+}
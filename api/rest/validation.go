@@ -0,0 +1,183 @@
+package rest
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ValidationReport is a result of checking the stored bundle integrity.
+// The bundle is Valid when no errors were found.
+type ValidationReport struct {
+	ID      string   `json:"id"`
+	Valid   bool     `json:"valid"`
+	Entries int      `json:"entries"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Validate checks the stored bundle is internally consistent: the zip could be read, every entry listed in manifests
+// and report exists and the data file matches the checksum recorded in the state. It never modifies the bundle.
+func (h BundleHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !h.bundleExists(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	bundle, err := h.getBundleState(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if bundle.Status == Deleted || bundle.Status == Canceled || bundle.Status == Failed {
+		writeJSONError(w, http.StatusGone, fmt.Errorf("bundle %s was %s", bundle.ID, bundle.Status))
+		return
+	}
+	if bundle.Status != Done {
+		writeJSONError(w, http.StatusNotFound,
+			fmt.Errorf("bundle %s is not done yet (status %s), try again later", bundle.ID, bundle.Status))
+		return
+	}
+	if bundle.Layout == LayoutDirectory {
+		writeJSONError(w, http.StatusBadRequest,
+			fmt.Errorf("bundle %s has %s layout, only zip bundles could be validated", bundle.ID, bundle.Layout))
+		return
+	}
+
+	report := validateZipBundle(filepath.Join(h.workDir, id, dataFileName), bundle.Checksum)
+	report.ID = id
+
+	write(w, jsonMarshal(report))
+}
+
+// validateZipBundle reads all entries of the zip bundle and checks they match
+// its manifests, report and given checksum. Empty checksum is not verified.
+func validateZipBundle(bundlePath string, checksum string) ValidationReport {
+	report := ValidationReport{}
+
+	r, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not read zip central directory: %s", err))
+		return report
+	}
+	defer r.Close()
+	registerDecompressors(&r.Reader)
+
+	report.Entries = len(r.File)
+	entries := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		entries[f.Name] = f
+		if err := readZipEntry(f, ioutil.Discard); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("entry %s is corrupted: %s", f.Name, err))
+		}
+	}
+
+	for _, f := range r.File {
+		switch {
+		case path.Base(f.Name) == manifestFileName:
+			report.Errors = append(report.Errors, validateManifest(f, entries)...)
+		case f.Name == reportFileName:
+			report.Errors = append(report.Errors, validateReport(f, entries)...)
+		}
+	}
+
+	if checksum != "" {
+		actual, err := fileChecksum(bundlePath)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		} else if actual != checksum {
+			report.Errors = append(report.Errors, fmt.Sprintf("checksum mismatch: expected %s but got %s", checksum, actual))
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+// validateManifest checks all entries listed in the manifest exist next to it and have expected sizes
+func validateManifest(f *zip.File, entries map[string]*zip.File) []string {
+	var m bundleManifest
+	if err := readZipJSON(f, &m); err != nil {
+		return []string{err.Error()}
+	}
+
+	var errors []string
+	dir := path.Dir(f.Name)
+	for _, e := range m.Entries {
+		name := path.Join(dir, e.Name)
+		entry, ok := entries[name]
+		if !ok {
+			errors = append(errors, fmt.Sprintf("%s lists %s but it is missing", f.Name, name))
+			continue
+		}
+		if entry.UncompressedSize64 != uint64(e.Size) {
+			errors = append(errors, fmt.Sprintf("%s lists %s with size %d but it has %d",
+				f.Name, name, e.Size, entry.UncompressedSize64))
+		}
+	}
+	return errors
+}
+
+// validateReport checks there is data for every node reported as done
+func validateReport(f *zip.File, entries map[string]*zip.File) []string {
+	var report bundleReport
+	if err := readZipJSON(f, &report); err != nil {
+		return []string{err.Error()}
+	}
+
+	var errors []string
+	for ip, node := range report.Nodes {
+		if node.Status != Done {
+			continue
+		}
+		if !hasEntryWithPrefix(entries, ip+"_") {
+			errors = append(errors, fmt.Sprintf("%s lists node %s as %s but there is no data for it", f.Name, ip, node.Status))
+		}
+	}
+	// nodes are stored in a map so sort errors to keep the report stable
+	sort.Strings(errors)
+	return errors
+}
+
+func hasEntryWithPrefix(entries map[string]*zip.File, prefix string) bool {
+	for name := range entries {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func readZipJSON(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", f.Name, err)
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("could not parse %s: %s", f.Name, err)
+	}
+	return nil
+}
+
+// readZipEntry copies the whole entry to w. Reading it to the end verifies its CRC-32.
+func readZipEntry(f *zip.File, w io.Writer) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
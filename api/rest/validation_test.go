@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bundleValidateEndpoint = bundleEndpoint + "/validate"
+
+func TestIfValidateReportsValidBundle(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	collectors := []collector.Collector{
+		MockCollector{name: "collector-1", rc: ioutil.NopCloser(bytes.NewReader([]byte("OK")))},
+		MockCollector{name: "logs/collector-2", rc: ioutil.NopCloser(bytes.NewReader(sampleLog(100)))},
+	}
+
+	bh, err := NewBundleHandler(workdir, collectors, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleEndpoint, bh.Create).Methods(http.MethodPut)
+	router.HandleFunc(bundleValidateEndpoint, bh.Validate).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodPut, bundlesEndpoint+"/bundle-0", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	for { // busy wait for bundle
+		bundle, err := bh.getBundleState("bundle-0")
+		require.NoError(t, err)
+		if bundle.Status == Done {
+			require.Empty(t, bundle.Errors)
+			assert.NotEmpty(t, bundle.Checksum)
+			break
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/validate", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var report ValidationReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, ValidationReport{ID: "bundle-0", Valid: true, Entries: 3}, report)
+}
+
+func TestIfValidateReportsMissingAndModifiedEntries(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, "bundle-0"), dirPerm))
+	_, err = bh.writeStateFile(Bundle{ID: "bundle-0", Status: Done, Checksum: "invalid"})
+	require.NoError(t, err)
+
+	dataFile, err := os.Create(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+	w := zip.NewWriter(dataFile)
+	writeZipEntry(t, w, reportFileName,
+		`{"id":"bundle-0","nodes":{"192.0.2.1":{"status":"Done"},"192.0.2.2":{"status":"Done"},"192.0.2.3":{"status":"Failed"}}}`)
+	writeZipEntry(t, w, "192.0.2.1_master/dcos-diagnostics.log", "OK")
+	writeZipEntry(t, w, "192.0.2.1_master/"+manifestFileName,
+		`{"entries":[{"name":"dcos-diagnostics.log","size":10},{"name":"missing.log","size":2}]}`)
+	require.NoError(t, w.Close())
+	require.NoError(t, dataFile.Close())
+
+	checksum, err := fileChecksum(filepath.Join(workdir, "bundle-0", dataFileName))
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleValidateEndpoint, bh.Validate).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/validate", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var report ValidationReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, ValidationReport{
+		ID:      "bundle-0",
+		Valid:   false,
+		Entries: 3,
+		Errors: []string{
+			"report.json lists node 192.0.2.2 as Done but there is no data for it",
+			"192.0.2.1_master/manifest.json lists 192.0.2.1_master/dcos-diagnostics.log with size 10 but it has 2",
+			"192.0.2.1_master/manifest.json lists 192.0.2.1_master/missing.log but it is missing",
+			"checksum mismatch: expected invalid but got " + checksum,
+		},
+	}, report)
+}
+
+func TestIfValidateReportsCorruptedZip(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bundlePath := filepath.Join(workdir, dataFileName)
+	require.NoError(t, ioutil.WriteFile(bundlePath, []byte("not a zip"), filePerm))
+
+	report := validateZipBundle(bundlePath, "")
+	assert.False(t, report.Valid)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], "could not read zip central directory")
+}
+
+func TestIfValidateReturns410WhenBundleIsDeleted(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	bh, err := NewBundleHandler(workdir, nil, time.Second, time.Second, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(workdir, "bundle-0"), dirPerm))
+	_, err = bh.writeStateFile(Bundle{ID: "bundle-0", Status: Deleted})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(bundleValidateEndpoint, bh.Validate).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodGet, bundlesEndpoint+"/bundle-0/validate", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.JSONEq(t, `{"code": 410, "error": "bundle bundle-0 was Deleted"}`, rr.Body.String())
+}
+
+func writeZipEntry(t *testing.T, w *zip.Writer, name string, content string) {
+	f, err := w.Create(name)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(content))
+	require.NoError(t, err)
+}
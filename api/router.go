@@ -26,6 +26,36 @@ const nodeBundleEndpoint = nodeBundlesEndpoint + "/{id}"
 // Endpoint to download bundle file
 const nodeBundleFileEndpoint = nodeBundleEndpoint + "/file"
 
+// Endpoints to exempt bundle from retention and to revert it
+const nodeBundlePinEndpoint = nodeBundleEndpoint + "/pin"
+const nodeBundleUnpinEndpoint = nodeBundleEndpoint + "/unpin"
+
+// Endpoint streaming collector events of bundle in progress as Server-Sent Events
+const nodeBundleEventsEndpoint = nodeBundleEndpoint + "/events"
+
+// Endpoint to check integrity of stored bundle, cluster bundles stored on this node included
+const nodeBundleValidateEndpoint = nodeBundleEndpoint + "/validate"
+
+// Endpoint to rewrite stored bundle to a smaller one
+const nodeBundleCompactEndpoint = nodeBundleEndpoint + "/compact"
+
+// Endpoint to upload stored bundle to the object store
+const nodeBundleUploadEndpoint = nodeBundleEndpoint + "/upload"
+
+// Endpoint returning report.json of cluster bundle stored on this node
+const nodeBundleReportEndpoint = nodeBundleEndpoint + "/report"
+
+// Endpoint to download multiple bundles stored on this node as a single zip
+const nodeBundlesArchiveEndpoint = baseRoute + "/node/diagnostics-archive"
+
+// Endpoint to search the catalog of bundles created by this node
+const nodeBundlesCatalogEndpoint = baseRoute + "/node/diagnostics-catalog"
+
+// Endpoints relaying local bundle API calls to agents through this master
+const relayNodeEndpoint = baseRoute + "/relay/{node}"
+const relayNodeBundleEndpoint = relayNodeEndpoint + nodeBundleEndpoint
+const relayNodeBundleFileEndpoint = relayNodeEndpoint + nodeBundleFileEndpoint
+
 // Endpoint for listing all cluster bundles
 const clusterBundlesEndpoint = baseRoute + "/diagnostics"
 
@@ -35,6 +65,15 @@ const clusterBundleEndpoint = clusterBundlesEndpoint + "/{id}"
 // Endpoint to download cluster bundle file
 const clusterBundleFileEndpoint = clusterBundleEndpoint + "/file"
 
+// Endpoint returning report.json of cluster bundle without downloading it
+const clusterBundleReportEndpoint = clusterBundleEndpoint + "/report"
+
+// Endpoint to cancel collection of a single node of cluster bundle in progress
+const clusterBundleNodeEndpoint = clusterBundleEndpoint + "/nodes/{node}"
+
+// Endpoint estimating size of cluster bundle before it is created
+const clusterBundleEstimateEndpoint = baseRoute + "/diagnostics-estimate"
+
 type routeHandler struct {
 	url                 string
 	handler             http.HandlerFunc
@@ -105,10 +144,13 @@ func getRoutes(dt *Dt) []routeHandler {
 		job:                dt.DtDiagnosticsJob,
 		systemdUnits:       dt.SystemdUnits,
 		monitoringResponse: dt.MR,
+		bundleHandler:      dt.BundleHandler,
+		collectorsClient:   dt.CollectorsClient,
 	}
 
 	bh := dt.BundleHandler
 	cbh := dt.ClusterBundleHandler
+	rh := dt.RelayHandler
 
 	routes := []routeHandler{
 		{
@@ -201,6 +243,17 @@ func getRoutes(dt *Dt) []routeHandler {
 			},
 			gzip: true,
 		},
+		{
+			// /system/health/v1/logs/stream?unit=<unitid>&since=<duration>
+			url:     baseRoute + "/logs/stream",
+			handler: h.streamUnitsLogsHandler,
+			headers: []header{
+				{
+					name:  "Content-type",
+					value: "application/x-ndjson",
+				},
+			},
+		},
 		//---------------------------------------------------------------------
 		// 					V2 REST API for bundles CRUD
 		//---- Node level API
@@ -229,6 +282,57 @@ func getRoutes(dt *Dt) []routeHandler {
 			handler: bh.GetFile,
 			methods: []string{"GET"},
 		},
+		{
+			url:     nodeBundlePinEndpoint,
+			handler: bh.Pin,
+			methods: []string{"POST"},
+		},
+		{
+			url:     nodeBundleUnpinEndpoint,
+			handler: bh.Unpin,
+			methods: []string{"POST"},
+		},
+		{
+			url:     nodeBundleValidateEndpoint,
+			handler: bh.Validate,
+			methods: []string{"GET"},
+		},
+		{
+			url:     nodeBundleCompactEndpoint,
+			handler: bh.Compact,
+			methods: []string{"POST"},
+		},
+		{
+			url:     nodeBundleUploadEndpoint,
+			handler: bh.Upload,
+			methods: []string{"POST"},
+		},
+		{
+			url:     nodeBundleReportEndpoint,
+			handler: bh.Report,
+			methods: []string{"GET"},
+		},
+		{
+			url:     nodeBundleEventsEndpoint,
+			handler: bh.Events,
+			methods: []string{"GET"},
+			headers: []header{
+				{
+					name:  "Content-type",
+					value: "text/event-stream",
+				},
+			},
+		},
+		{
+			url:     nodeBundlesArchiveEndpoint,
+			handler: bh.Archive,
+			methods: []string{"GET"},
+		},
+		{
+			url:     nodeBundlesCatalogEndpoint,
+			handler: bh.Catalog,
+			methods: []string{"GET"},
+		},
 		//---- Cluster level API
 		{
 			url:     clusterBundleEndpoint,
@@ -255,6 +359,31 @@ func getRoutes(dt *Dt) []routeHandler {
 			handler: cbh.Download,
 			methods: []string{"GET"},
 		},
+		{
+			url:     clusterBundleReportEndpoint,
+			handler: cbh.Report,
+			methods: []string{"GET"},
+		},
+		{
+			url:     clusterBundleEstimateEndpoint,
+			handler: cbh.Estimate,
+			methods: []string{"POST"},
+		},
+		{
+			url:     clusterBundleNodeEndpoint,
+			handler: cbh.CancelNode,
+			methods: []string{"DELETE"},
+		},
+		{
+			url:     relayNodeBundleEndpoint,
+			handler: rh.Relay,
+			methods: []string{"GET", "PUT", "DELETE"},
+		},
+		{
+			url:     relayNodeBundleFileEndpoint,
+			handler: rh.Relay,
+			methods: []string{"GET"},
+		},
 		//---------------------------------------------------------------------
 		{
 			// /system/health/v1/report/diagnostics
@@ -296,12 +425,39 @@ func getRoutes(dt *Dt) []routeHandler {
 				},
 			},
 		},
+		{
+			// /system/health/v1/report/diagnostics/disk-usage
+			url:     baseRoute + "/report/diagnostics/disk-usage",
+			handler: h.diskUsageHandler,
+		},
 		{
 			// /system/health/v1/report/diagnostics/delete/<file>
 			url:     baseRoute + "/report/diagnostics/delete/{file}",
 			handler: h.deleteBundleHandler,
 			methods: []string{"POST"},
 		},
+		{
+			// /system/health/v1/providers/reload
+			url:     baseRoute + "/providers/reload",
+			handler: h.reloadProvidersHandler,
+			methods: []string{"POST"},
+		},
+		{
+			// /system/health/v1/providers/reload/validate
+			url:     baseRoute + "/providers/reload/validate",
+			handler: h.validateProvidersHandler,
+			methods: []string{"POST"},
+		},
+		{
+			// /system/health/v1/selftest/info
+			url:     baseRoute + "/selftest/info",
+			handler: h.selfTestHandler,
+		},
+		{
+			// /system/health/v1/selftest/routes
+			url:     baseRoute + "/selftest/routes",
+			handler: routesHandler(dt),
+		},
 		{
 			url:     "/metrics",
 			handler: promhttp.Handler().ServeHTTP,
@@ -394,16 +550,16 @@ func wrapHandler(handler http.Handler, route routeHandler, dt *Dt) http.Handler
 	if route.canFlushCache {
 		h = noCacheMiddleware(h, dt)
 	}
+	if dt.Authz != nil {
+		h = authzMiddleware(h, route.url, dt.Authz)
+	}
 
 	return metricMiddleware(h)
 }
 
 func loadRoutes(router *mux.Router, dt *Dt) *mux.Router {
 	for _, route := range getRoutes(dt) {
-		if len(route.methods) == 0 {
-			route.methods = []string{"GET"}
-		}
-		router.Handle(route.url, wrapHandler(route.handler, route, dt)).Methods(route.methods...)
+		router.Handle(route.url, wrapHandler(route.handler, route, dt)).Methods(routeMethods(route)...)
 	}
 	return router
 }
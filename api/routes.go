@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dcos/dcos-diagnostics/config"
+)
+
+// routeInfo describes a route registered by the router
+type routeInfo struct {
+	URL     string   `json:"url"`
+	Methods []string `json:"methods"`
+}
+
+// routeMethods returns methods the route is registered with, routes without methods are registered for GET
+func routeMethods(route routeHandler) []string {
+	if len(route.methods) == 0 {
+		return []string{http.MethodGet}
+	}
+	return route.methods
+}
+
+// registeredRoutes lists routes NewRouter registers for the configuration in the order they are matched
+func registeredRoutes(dt *Dt) []routeInfo {
+	routes := getRoutes(dt)
+	infos := make([]routeInfo, 0, len(routes))
+	for _, route := range routes {
+		infos = append(infos, routeInfo{URL: route.url, Methods: routeMethods(route)})
+	}
+	return infos
+}
+
+// routesHandler returns a handler listing routes registered by the daemon so it could be checked why a request
+// does not match any of them e.g., debug routes are listed only when the daemon runs with debug enabled
+func routesHandler(dt *Dt) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewEncoder(w).Encode(registeredRoutes(dt)); err != nil {
+			log.Errorf("Failed to encode responses to json: %s", err)
+		}
+	}
+}
+
+// routesCollector is a struct implementing Collector interface. It collects routes registered by the daemon
+// with the configuration to routes.json.
+type routesCollector struct {
+	name     string
+	optional bool
+	cfg      *config.Config
+}
+
+func newRoutesCollector(name string, optional bool, cfg *config.Config) *routesCollector {
+	return &routesCollector{
+		name:     name,
+		optional: optional,
+		cfg:      cfg,
+	}
+}
+
+func (c routesCollector) Name() string {
+	return c.name
+}
+
+func (c routesCollector) Optional() bool {
+	return c.optional
+}
+
+func (c routesCollector) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	// routes depend only on the configuration, handlers are not called
+	data, err := json.MarshalIndent(registeredRoutes(&Dt{Cfg: c.cfg}), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal routes: %s", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// walkRoutes lists routes registered in the router
+func walkRoutes(t *testing.T, router *mux.Router) []routeInfo {
+	var routes []routeInfo
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		url, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		routes = append(routes, routeInfo{URL: url, Methods: methods})
+		return nil
+	})
+	require.NoError(t, err)
+	return routes
+}
+
+func TestRoutesHandlerListsRegisteredRoutes(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		cfg := testCfg()
+		defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+		cfg.FlagDebug = debug
+
+		router := NewRouter(&Dt{
+			Cfg:         cfg,
+			DtDCOSTools: &fakeDCOSTools{},
+			MR:          &MonitoringResponse{},
+		})
+
+		body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/selftest/routes", http.MethodGet, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+
+		var routes []routeInfo
+		require.NoError(t, json.Unmarshal(body, &routes))
+		assert.Equal(t, walkRoutes(t, router), routes)
+		assert.Contains(t, routes, routeInfo{URL: baseRoute + "/providers/reload", Methods: []string{http.MethodPost}})
+
+		pprofIndex := routeInfo{URL: baseRoute + "/debug/pprof/", Methods: []string{http.MethodGet}}
+		if debug {
+			assert.Contains(t, routes, pprofIndex)
+		} else {
+			assert.NotContains(t, routes, pprofIndex)
+		}
+	}
+}
+
+func TestRoutesCollector(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+	cfg.FlagDebug = true
+
+	c := newRoutesCollector(routesFileName, true, cfg)
+	assert.Equal(t, "routes.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	var routes []routeInfo
+	require.NoError(t, json.Unmarshal(data, &routes))
+	assert.Equal(t, walkRoutes(t, NewRouter(&Dt{Cfg: cfg})), routes)
+}
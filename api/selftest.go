@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SelfTestStatus is a status of a single self-test check.
+type SelfTestStatus string
+
+const (
+	SelfTestPassed SelfTestStatus = "passed"
+	SelfTestFailed SelfTestStatus = "failed"
+)
+
+// SelfTestCheckResult is a result of a single self-test check.
+type SelfTestCheckResult struct {
+	Name     string         `json:"name"`
+	Critical bool           `json:"critical"`
+	Status   SelfTestStatus `json:"status"`
+	Message  string         `json:"message,omitempty"`
+}
+
+// SelfTestResult is a result of all self-test checks. The node is Healthy when none of critical checks failed.
+type SelfTestResult struct {
+	Healthy bool                  `json:"healthy"`
+	Checks  []SelfTestCheckResult `json:"checks"`
+}
+
+// selfTestCheck is a named check run by the self-test. Failing critical check makes the node unhealthy.
+type selfTestCheck struct {
+	name     string
+	critical bool
+	run      func() error
+}
+
+// runSelfTest runs all checks in order and aggregates their results.
+func runSelfTest(checks []selfTestCheck) SelfTestResult {
+	result := SelfTestResult{
+		Healthy: true,
+		Checks:  make([]SelfTestCheckResult, 0, len(checks)),
+	}
+
+	for _, check := range checks {
+		checkResult := SelfTestCheckResult{
+			Name:     check.name,
+			Critical: check.critical,
+			Status:   SelfTestPassed,
+		}
+		if err := check.run(); err != nil {
+			checkResult.Status = SelfTestFailed
+			checkResult.Message = err.Error()
+			if check.critical {
+				result.Healthy = false
+			}
+		}
+		result.Checks = append(result.Checks, checkResult)
+	}
+
+	return result
+}
+
+// selfTestChecks returns checks verifying dcos-diagnostics is able to create bundles on this node.
+func (h *handler) selfTestChecks() []selfTestCheck {
+	return []selfTestCheck{
+		{
+			name:     "node-role",
+			critical: true,
+			run: func() error {
+				_, err := h.tools.GetNodeRole()
+				return err
+			},
+		},
+		{
+			name:     "bundle-dir-writable",
+			critical: true,
+			run: func() error {
+				return checkDirWritable(h.cfg.FlagDiagnosticsBundleDir)
+			},
+		},
+		{
+			name:     "endpoint-config",
+			critical: true,
+			run: func() error {
+				_, err := loadExternalProviders(h.cfg.FlagDiagnosticsBundleEndpointsConfigFiles)
+				return err
+			},
+		},
+		{
+			name: "systemd-units",
+			run: func() error {
+				_, err := h.tools.GetUnitNames()
+				return err
+			},
+		},
+	}
+}
+
+// checkDirWritable returns an error if a file could not be created in the dir.
+func checkDirWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".selftest-")
+	if err != nil {
+		return fmt.Errorf("could not create file in %s: %s", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// A handler function to run the self-test. It responds with 503 when any critical check fails.
+func (h *handler) selfTestHandler(w http.ResponseWriter, _ *http.Request) {
+	result := runSelfTest(h.selfTestChecks())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Healthy {
+		log.WithField("checks", result.Checks).Warn("Self-test failed")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Errorf("Failed to encode responses to json: %s", err)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSelfTestIsHealthyWhenOnlyNonCriticalCheckFails(t *testing.T) {
+	result := runSelfTest([]selfTestCheck{
+		{name: "critical", critical: true, run: func() error { return nil }},
+		{name: "optional", run: func() error { return errors.New("some error") }},
+	})
+
+	assert.Equal(t, SelfTestResult{
+		Healthy: true,
+		Checks: []SelfTestCheckResult{
+			{Name: "critical", Critical: true, Status: SelfTestPassed},
+			{Name: "optional", Status: SelfTestFailed, Message: "some error"},
+		},
+	}, result)
+}
+
+func TestSelfTestHandler(t *testing.T) {
+	cfg := testCfg()
+	defer os.RemoveAll(cfg.FlagDiagnosticsBundleDir)
+
+	router := NewRouter(&Dt{
+		Cfg:         cfg,
+		DtDCOSTools: &fakeDCOSTools{},
+		MR:          &MonitoringResponse{},
+	})
+
+	body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/selftest/info", http.MethodGet, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var result SelfTestResult
+	require.NoError(t, json.Unmarshal(body, &result))
+	assert.Equal(t, SelfTestResult{
+		Healthy: true,
+		Checks: []SelfTestCheckResult{
+			{Name: "node-role", Critical: true, Status: SelfTestPassed},
+			{Name: "bundle-dir-writable", Critical: true, Status: SelfTestPassed},
+			{Name: "endpoint-config", Critical: true, Status: SelfTestPassed},
+			{Name: "systemd-units", Critical: false, Status: SelfTestPassed},
+		},
+	}, result)
+
+	t.Run("failing critical check returns 503", func(t *testing.T) {
+		cfg.FlagDiagnosticsBundleDir = filepath.Join(cfg.FlagDiagnosticsBundleDir, "not-existing")
+
+		body, code, err := MakeHTTPRequest(t, router, "/system/health/v1/selftest/info", http.MethodGet, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		var result SelfTestResult
+		require.NoError(t, json.Unmarshal(body, &result))
+		assert.False(t, result.Healthy)
+		require.Len(t, result.Checks, 4)
+		assert.Equal(t, "bundle-dir-writable", result.Checks[1].Name)
+		assert.Equal(t, SelfTestFailed, result.Checks[1].Status)
+		assert.Contains(t, result.Checks[1].Message, "could not create file in "+cfg.FlagDiagnosticsBundleDir)
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/dcos/dcos-diagnostics/dcos"
+)
+
+// sourceFileName identifies the node every local bundle was collected on
+const sourceFileName = "source.json"
+
+// nodeSource describes the node the bundle was collected on, fields that could not be read are left empty
+type nodeSource struct {
+	IP          string    `json:"ip,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	Hostname    string    `json:"hostname,omitempty"`
+	CollectedAt time.Time `json:"collected_at"`
+}
+
+// sourceCollector is a struct implementing Collector interface. It collects the IP, role and hostname of the node
+// along with the collection time so bundles stay traceable to their origin when they are combined manually.
+type sourceCollector struct {
+	name     string
+	optional bool
+	tools    dcos.Tooler
+	now      func() time.Time
+}
+
+func newSourceCollector(name string, optional bool, tools dcos.Tooler) *sourceCollector {
+	return &sourceCollector{
+		name:     name,
+		optional: optional,
+		tools:    tools,
+		now:      time.Now,
+	}
+}
+
+func (c sourceCollector) Name() string {
+	return c.name
+}
+
+func (c sourceCollector) Optional() bool {
+	return c.optional
+}
+
+func (c sourceCollector) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	source := nodeSource{CollectedAt: c.now().UTC()}
+	var errs []string
+
+	ip, err := c.tools.DetectIP()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("could not detect IP: %s", err))
+	}
+	source.IP = ip
+
+	role, err := c.tools.GetNodeRole()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("could not get role: %s", err))
+	}
+	source.Role = role
+
+	hostname, err := c.tools.GetHostname()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("could not get hostname: %s", err))
+	}
+	source.Hostname = hostname
+
+	if len(errs) == 3 {
+		return nil, fmt.Errorf("could not identify the node: %s", strings.Join(errs, "; "))
+	}
+
+	data, err := json.MarshalIndent(source, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal source: %s", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
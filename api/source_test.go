@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dcos/dcos-diagnostics/collector"
+)
+
+func TestSourceCollectorIsCollector(t *testing.T) {
+	assert.Implements(t, (*collector.Collector)(nil), new(sourceCollector))
+}
+
+func TestSourceCollectorIdentifiesTheNode(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("192.0.2.1", nil)
+	tools.On("GetNodeRole").Return("agent", nil)
+	tools.On("GetHostname").Return("agent-1", nil)
+
+	c := newSourceCollector(sourceFileName, true, tools)
+	c.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+	assert.Equal(t, "source.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"ip":"192.0.2.1","role":"agent","hostname":"agent-1","collected_at":"2020-01-02T03:04:05Z"}`, string(data))
+}
+
+func TestSourceCollectorLeavesOutFieldsThatCouldNotBeRead(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("", fmt.Errorf("no ip"))
+	tools.On("GetNodeRole").Return("master", nil)
+	tools.On("GetHostname").Return("", fmt.Errorf("no hostname"))
+
+	c := newSourceCollector(sourceFileName, true, tools)
+	c.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	r, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"role":"master","collected_at":"2020-01-02T03:04:05Z"}`, string(data))
+}
+
+func TestSourceCollectorFailsWhenTheNodeCouldNotBeIdentified(t *testing.T) {
+	tools := new(MockedTools)
+	tools.On("DetectIP").Return("", fmt.Errorf("no ip"))
+	tools.On("GetNodeRole").Return("", fmt.Errorf("no role"))
+	tools.On("GetHostname").Return("", fmt.Errorf("no hostname"))
+
+	_, err := newSourceCollector(sourceFileName, true, tools).Collect(context.Background())
+	assert.EqualError(t, err, "could not identify the node: could not detect IP: no ip; could not get role: no role; "+
+		"could not get hostname: no hostname")
+}
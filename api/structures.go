@@ -1,6 +1,8 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/dcos/dcos-diagnostics/api/rest"
 	"github.com/dcos/dcos-diagnostics/config"
 	"github.com/dcos/dcos-diagnostics/dcos"
@@ -76,10 +78,15 @@ type Dt struct {
 	DtDiagnosticsJob     *DiagnosticsJob
 	BundleHandler        rest.BundleHandler
 	ClusterBundleHandler *rest.ClusterBundleHandler
+	RelayHandler         *rest.RelayHandler
 	RunPullerChan        chan bool
 	RunPullerDoneChan    chan bool
 	SystemdUnits         *SystemdUnits
 	MR                   *MonitoringResponse
+	// Authz restricts access to the API, nil allows everything
+	Authz *Authorizer
+	// CollectorsClient is used by collectors loaded on providers reload, nil disables the reload
+	CollectorsClient *http.Client
 }
 
 type bundle struct {
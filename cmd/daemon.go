@@ -15,6 +15,8 @@
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -24,7 +26,9 @@ import (
 
 	"github.com/dcos/dcos-diagnostics/api"
 	"github.com/dcos/dcos-diagnostics/api/rest"
+	"github.com/dcos/dcos-diagnostics/collector"
 	diagDcos "github.com/dcos/dcos-diagnostics/dcos"
+	"github.com/dcos/dcos-diagnostics/units"
 	"github.com/dcos/dcos-diagnostics/util"
 
 	"github.com/dcos/dcos-go/dcos"
@@ -41,6 +45,12 @@ const (
 	diagnosticsBundleDir      = "/var/run/dcos/dcos-diagnostics/diagnostic_bundles"
 	diagnosticsEndpointConfig = "/opt/mesosphere/etc/endpoints_config.json"
 	exhibitorURL              = "http://127.0.0.1:8181/exhibitor/v1/cluster/status"
+	acceleratorInfoCommand    = "nvidia-smi -q -x"
+	mesosAttributesFile       = "/var/lib/dcos/mesos-slave-common"
+	mesosWorkDir              = "/var/lib/mesos/slave"
+	bundleRetentionInterval   = 10 * time.Minute
+	bundleUploadBackoff       = time.Second
+	megabyte                  = 1 << 20
 )
 
 // daemonCmd represents the daemon command
@@ -67,12 +77,16 @@ func startDiagnosticsDaemon() {
 		logrus.Fatal("workers-count must be greater than 0")
 	}
 
+	units.SetMaxJournalReaders(defaultConfig.FlagJournalMaxReaders)
+
 	DCOSTools := &diagDcos.Tools{
 		ExhibitorURL: defaultConfig.FlagExhibitorClusterStatusURL,
 		ForceTLS:     defaultConfig.FlagForceTLS,
 		Role:         defaultConfig.FlagRole,
 		NodeInfo:     nodeInfo,
 		Transport:    tr,
+		DNSTimeout:   time.Duration(defaultConfig.FlagDiscoveryDNSTimeoutSec) * time.Second,
+		MesosTimeout: time.Duration(defaultConfig.FlagDiscoveryMesosTimeoutSec) * time.Second,
 	}
 
 	// Create and init diagnostics job, do not hard fail on error
@@ -93,29 +107,156 @@ func startDiagnosticsDaemon() {
 
 	client := util.NewHTTPClient(defaultConfig.GetSingleEntryTimeout(), tr)
 
+	if err := api.ValidateCollectorsHostname(defaultConfig); err != nil {
+		logrus.WithError(err).Fatal("Local endpoints could not be collected")
+	}
+
 	collectors, err := api.LoadCollectors(defaultConfig, DCOSTools, client)
 	if err != nil {
 		logrus.Fatalf("Could not init collectors properly: %s", err)
 	}
 
+	compression, err := rest.ParseCompression(defaultConfig.FlagBundleCompression)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle compression")
+	}
+	compactFilters, err := rest.ParseCompactFilters(defaultConfig.FlagBundleCompactFilters)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle compact filters")
+	}
+	emptyResults, err := rest.ParseEmptyResults(defaultConfig.FlagBundleEmptyResults)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle empty results handling")
+	}
+	endpointAllowlist, err := collector.ParseEndpointAllowlist(defaultConfig.FlagEndpointAllowlist)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid endpoint allowlist")
+	}
+	var catalog *rest.Catalog
+	if defaultConfig.FlagBundleCatalog != "" {
+		catalog, err = rest.NewCatalog(defaultConfig.FlagBundleCatalog)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not load bundle catalog")
+		}
+	}
+	capabilitiesFile := ""
+	if defaultConfig.FlagBundleCapabilitiesCheck {
+		capabilitiesFile = collector.ProcSelfStatus
+	}
+	var postHook *rest.PostHook
+	if defaultConfig.FlagBundlePostHook != "" {
+		postHook, err = rest.NewPostHook(defaultConfig.FlagBundlePostHook,
+			time.Duration(defaultConfig.FlagBundlePostHookTimeoutSec)*time.Second)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid bundle post-collection hook")
+		}
+	}
+	var uploader *rest.Uploader
+	if defaultConfig.FlagBundleUploadURL != "" {
+		uploader, err = rest.NewUploader(defaultConfig.FlagBundleUploadURL, rest.UploadOptions{
+			PartSize:    int64(defaultConfig.FlagBundleUploadPartSizeMB) * megabyte,
+			PartRetries: defaultConfig.FlagBundleUploadPartRetries,
+			PartTimeout: time.Duration(defaultConfig.FlagBundleUploadPartTimeoutSec) * time.Second,
+			Backoff:     bundleUploadBackoff,
+		})
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid bundle upload URL")
+		}
+	}
+
 	bundleTimeout := time.Minute * time.Duration(defaultConfig.FlagDiagnosticsJobTimeoutMinutes)
 	bundleHandler, err := rest.NewBundleHandler(
 		defaultConfig.FlagDiagnosticsBundleDir,
 		collectors,
 		bundleTimeout,
 		defaultConfig.GetSingleEntryTimeout(),
+		client,
 	)
 	if err != nil {
 		logrus.WithError(err).Fatal("BundleHandler could not be created")
 	}
-	diagClient := rest.NewDiagnosticsClient(client)
-	coord := rest.NewParallelCoordinator(diagClient, time.Minute, defaultConfig.FlagDiagnosticsBundleDir)
+	bundleHandler = bundleHandler.
+		WithCompression(compression).
+		WithIncremental(defaultConfig.FlagBundleIncremental).
+		WithHTMLIndex(defaultConfig.FlagBundleHTMLIndex).
+		WithSetupRetries(defaultConfig.FlagBundleSetupRetries).
+		WithCompactFilters(compactFilters).
+		WithEmptyResults(emptyResults).
+		WithEndpointAllowlist(endpointAllowlist).
+		WithEvents(defaultConfig.FlagBundleEvents).
+		WithMinFreeInodes(uint64(defaultConfig.FlagBundleMinFreeInodes)).
+		WithStateCacheSize(defaultConfig.FlagBundleStateCacheSize).
+		WithStateWriteInterval(time.Duration(defaultConfig.FlagBundleStateWriteIntervalSec) * time.Second).
+		WithCatalog(catalog).
+		WithCapabilitiesCheck(capabilitiesFile).
+		WithPostHook(postHook).
+		WithUploader(uploader)
+	go bundleHandler.ResumeUploads(context.Background())
+	retention := rest.Retention{
+		MaxAge:   time.Duration(defaultConfig.FlagBundleRetentionMaxAgeHours) * time.Hour,
+		MaxCount: defaultConfig.FlagBundleRetentionMaxCount,
+		Grace:    time.Duration(defaultConfig.FlagBundleRetentionGraceMinutes) * time.Minute,
+	}
+	if retention.Enabled() {
+		go bundleHandler.RunRetention(context.Background(), bundleRetentionInterval, retention)
+	}
+	nodeCredentials, err := rest.ParseNodeCredentials(defaultConfig.FlagNodeCredentials)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid node credentials")
+	}
+	if len(defaultConfig.FlagNodeCredentials) > 0 {
+		logrus.WithField("credentials", nodeCredentials.String()).Info("Using per-node credentials")
+	}
+	// the limit is shared by the coordinator and the handler so it covers all cluster bundle operations
+	diagClient := rest.NewLimitedClient(rest.NewDiagnosticsClient(client).
+		WithMaxFileSize(int64(defaultConfig.FlagBundleNodeMaxSizeMB)*megabyte).
+		WithCredentials(nodeCredentials), defaultConfig.FlagBundleClusterMaxRequests)
 	urlBuilder := diagDcos.NewURLBuilder(defaultConfig.FlagAgentPort, defaultConfig.FlagMasterPort, defaultConfig.FlagForceTLS)
+	reportOrder, err := rest.ParseReportOrder(defaultConfig.FlagBundleReportOrder)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle report order")
+	}
+	duplicateEntries, err := rest.ParseDuplicateEntries(defaultConfig.FlagBundleDuplicateEntries)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle duplicate entries handling")
+	}
+	coord := rest.NewParallelCoordinator(diagClient, time.Minute, defaultConfig.FlagDiagnosticsBundleDir).
+		WithTempDir(defaultConfig.FlagBundleTempDir).
+		WithConflictRetry(defaultConfig.FlagBundleConflictRetry).
+		WithRoleFallback(&urlBuilder).
+		WithReportOrder(reportOrder).
+		WithDuplicateEntries(duplicateEntries)
 	clusterBundleHandler, err := rest.NewClusterBundleHandler(coord, diagClient, DCOSTools, defaultConfig.FlagDiagnosticsBundleDir,
 		bundleTimeout, &urlBuilder)
 	if err != nil {
 		logrus.WithError(err).Fatal("ClusterBundleHandler could not be created")
 	}
+	clusterBundleHandler.SetMaxDuration(time.Duration(defaultConfig.FlagBundleClusterMaxDurationMinutes) * time.Minute)
+	clusterBundleHandler.SetLeader(defaultConfig.FlagLeader)
+	clusterBundleHandler.SetTempDir(defaultConfig.FlagBundleTempDir)
+	clusterBundleHandler.SetCatalog(catalog)
+	duplicateNodes, err := rest.ParseDuplicateNodes(defaultConfig.FlagBundleDuplicateNodes)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle duplicate nodes handling")
+	}
+	clusterBundleHandler.SetDuplicateNodes(duplicateNodes)
+	schedule, err := rest.NewSchedule(time.Duration(defaultConfig.FlagBundleScheduleIntervalMinutes)*time.Minute,
+		defaultConfig.FlagBundleScheduleOptions)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid bundle schedule")
+	}
+	if schedule.Enabled() {
+		go clusterBundleHandler.RunSchedule(context.Background(), schedule)
+	}
+	relayHandler := rest.NewRelayHandler(DCOSTools, &urlBuilder, tr)
+
+	var authz *api.Authorizer
+	if defaultConfig.FlagAuthzConfig != "" {
+		authz, err = api.LoadAuthorizer(defaultConfig.FlagAuthzConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid authorization config")
+		}
+	}
 
 	// Inject dependencies used for running dcos-diagnostics.
 	dt := &api.Dt{
@@ -124,10 +265,13 @@ func startDiagnosticsDaemon() {
 		DtDiagnosticsJob:     diagnosticsJob,
 		BundleHandler:        *bundleHandler,
 		ClusterBundleHandler: clusterBundleHandler,
+		RelayHandler:         relayHandler,
 		RunPullerChan:        make(chan bool),
 		RunPullerDoneChan:    make(chan bool),
 		SystemdUnits:         &api.SystemdUnits{},
 		MR:                   &api.MonitoringResponse{},
+		Authz:                authz,
+		CollectorsClient:     client,
 	}
 
 	// start diagnostic server and expose endpoints.
@@ -180,13 +324,44 @@ func initTransport() (http.RoundTripper, error) {
 	if defaultConfig.FlagCACertFile != "" {
 		transportOptions = append(transportOptions, transport.OptionCaCertificatePath(defaultConfig.FlagCACertFile))
 	}
-	if defaultConfig.FlagIAMConfig != "" {
-		transportOptions = append(transportOptions, transport.OptionIAMConfigPath(defaultConfig.FlagIAMConfig))
-	}
 	tr, err := transport.NewTransport(transportOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize HTTP transport: %s", err)
 	}
 
+	if defaultConfig.FlagInsecureSkipVerify {
+		httpTransport, ok := tr.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("unable to disable TLS verification on %T", tr)
+		}
+		if httpTransport.TLSClientConfig == nil {
+			httpTransport.TLSClientConfig = &tls.Config{}
+		}
+		httpTransport.TLSClientConfig.InsecureSkipVerify = true
+		logrus.Warn("TLS certificate verification is DISABLED with insecure-skip-verify. " +
+			"Connections to other nodes could be intercepted, never use it in production clusters.")
+	}
+
+	proxy, err := util.NewProxyFunc(defaultConfig.FlagHTTPProxy, defaultConfig.FlagHTTPSProxy, defaultConfig.FlagNoProxy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize HTTP proxy: %s", err)
+	}
+	if proxy != nil {
+		httpTransport, ok := tr.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("unable to set HTTP proxy on %T", tr)
+		}
+		httpTransport.Proxy = proxy
+	}
+
+	// IAM round tripper is added on top of the transport so the proxy is also used for authorized requests
+	if defaultConfig.FlagIAMConfig != "" {
+		withIAM, err := transport.NewRoundTripper(tr, transport.OptionReadIAMConfig(defaultConfig.FlagIAMConfig))
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize HTTP transport: %s", err)
+		}
+		return withIAM, nil
+	}
+
 	return tr, nil
 }
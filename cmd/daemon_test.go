@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,3 +48,78 @@ func Test_getNodeInfo(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "192.0.2.1", ip.String())
 }
+
+func Test_initTransportUsesProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte("OK"))
+	}))
+	defer proxy.Close()
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+
+	defaultConfig.FlagHTTPProxy = proxy.URL
+	defaultConfig.FlagNoProxy = []string{"127.0.0.1"}
+	defer func() {
+		defaultConfig.FlagHTTPProxy = ""
+		defaultConfig.FlagNoProxy = nil
+	}()
+
+	tr, err := initTransport()
+	require.NoError(t, err)
+	client := http.Client{Transport: tr}
+
+	resp, err := client.Get("http://agent.invalid:61001/system/health/v1")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "OK", string(body))
+	assert.Equal(t, []string{"http://agent.invalid:61001/system/health/v1"}, proxied)
+
+	resp, err = client.Get(direct.URL)
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "direct", string(body))
+	assert.Len(t, proxied, 1)
+}
+
+func Test_initTransportReturnsErrorOnInvalidProxy(t *testing.T) {
+	defaultConfig.FlagHTTPSProxy = "proxy:3128"
+	defer func() { defaultConfig.FlagHTTPSProxy = "" }()
+
+	_, err := initTransport()
+	assert.EqualError(t, err, "unable to initialize HTTP proxy: invalid https proxy URL proxy:3128: scheme and host are required")
+}
+
+func Test_initTransportSkipsTLSVerificationOnlyWhenEnabled(t *testing.T) {
+	logs := bytes.NewBuffer(nil)
+	logrus.SetOutput(logs)
+	defer logrus.SetOutput(os.Stderr)
+
+	defaultConfig.FlagCACertFile = filepath.Join("testdata", "ca.crt")
+	defer func() { defaultConfig.FlagCACertFile = "" }()
+
+	tr, err := initTransport()
+	require.NoError(t, err)
+	require.IsType(t, &http.Transport{}, tr)
+	assert.False(t, tr.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+	assert.Empty(t, logs.String())
+
+	defaultConfig.FlagInsecureSkipVerify = true
+	defer func() { defaultConfig.FlagInsecureSkipVerify = false }()
+
+	tr, err = initTransport()
+	require.NoError(t, err)
+	require.IsType(t, &http.Transport{}, tr)
+	assert.True(t, tr.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+	assert.NotNil(t, tr.(*http.Transport).TLSClientConfig.RootCAs)
+	assert.Contains(t, logs.String(), "level=warning")
+	assert.Contains(t, logs.String(), "TLS certificate verification is DISABLED")
+}
@@ -19,6 +19,8 @@ import (
 	"os"
 
 	"github.com/dcos/dcos-diagnostics/api"
+	"github.com/dcos/dcos-diagnostics/api/rest"
+	"github.com/dcos/dcos-diagnostics/collector"
 	"github.com/dcos/dcos-diagnostics/config"
 	"github.com/dcos/dcos-diagnostics/dcos"
 	"github.com/sirupsen/logrus"
@@ -88,10 +90,16 @@ func init() {
 		defaultConfig.FlagDisableUnixSocket, "Disable use unix socket provided by systemd activation.")
 	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagMasterPort, "master-port", diagnosticsTCPPort,
 		"Use TCP port to connect to masters.")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagMasterRediscoveries, "master-rediscoveries", 1,
+		"Re-discover masters up to given number of times when some of them failed during cluster operations e.g., on a leader election (0 disables)")
 	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagAgentPort, "agent-port", diagnosticsTCPPort,
 		"Use TCP port to connect to agents.")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagNodeCredentials, "node-credentials", nil,
+		"Authenticate to nodes with the Authorization header read from a file given as role=file or ip=file e.g., master=/run/master-token, the IP takes precedence over the role")
 	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagCommandExecTimeoutSec, "command-exec-timeout",
 		50, "Set command executing timeout")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagCommandElevationWrapper, "command-elevation-wrapper",
+		"sudo -n", "Run commands marked as Elevated in endpoints config with given privilege elevation wrapper (empty runs them as is)")
 	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagPull, "pull", defaultConfig.FlagPull,
 		"Try to pull runner from DC/OS hosts.")
 	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagPullInterval, "pull-interval", 60,
@@ -105,22 +113,52 @@ func init() {
 		"Use Exhibitor URL to discover master nodes.")
 	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagForceTLS, "force-tls", defaultConfig.FlagForceTLS,
 		"Use HTTPS to do all requests.")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagInsecureSkipVerify, "insecure-skip-verify", false,
+		"Do not verify TLS certificates of other nodes even if ca-cert is given. Use only in lab clusters with self-signed certificates.")
 	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagDebug, "debug", defaultConfig.FlagDebug,
 		"Enable pprof debugging endpoints.")
 	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagIAMConfig, "iam-config",
 		defaultConfig.FlagIAMConfig, "A path to identity and access management config")
 	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagHostname, "hostname",
 		defaultConfig.FlagHostname, "A host name (by default it uses system hostname)")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagCollectorsHostname, "collectors-hostname",
+		defaultConfig.FlagCollectorsHostname, "A host name or IP used to collect local endpoints (by default it uses hostname)")
 	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagIPDiscoveryCommandLocation, "ip-discovery-command-location",
 		defaultConfig.FlagIPDiscoveryCommandLocation, "A command used to get local IP address")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagDiscoveryDNSTimeoutSec, "discovery-dns-timeout", 5,
+		"Set a timeout in seconds for resolving Mesos DNS records when discovering nodes")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagDiscoveryMesosTimeoutSec, "discovery-mesos-timeout", 10,
+		"Set a timeout in seconds for requests to Exhibitor and Mesos when discovering nodes")
+	// proxy flags
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagHTTPProxy, "http-proxy",
+		defaultConfig.FlagHTTPProxy, "Use HTTP proxy for plain HTTP requests to other nodes")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagHTTPSProxy, "https-proxy",
+		defaultConfig.FlagHTTPSProxy, "Use HTTP proxy for HTTPS requests to other nodes")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagNoProxy, "no-proxy",
+		defaultConfig.FlagNoProxy, "Hosts, domains (starting with a dot) or CIDRs that should be reached without proxy")
 	// diagnostics job flags
 	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagDiagnosticsBundleDir,
 		"diagnostics-bundle-dir", diagnosticsBundleDir, "Set a path to store diagnostic bundles")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagDiagnosticsBundleMinFreeSpaceMB,
+		"diagnostics-bundle-min-free-space", 0,
+		"Refuse to start diagnostics jobs with 507 when diagnostics-bundle-dir has less than given number of megabytes free (0 disables)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagDiagnosticsBundleEstimateSpace,
+		"diagnostics-bundle-estimate-space", false,
+		"Refuse to start diagnostics jobs with 507 when diagnostics-bundle-dir has less space free than the largest bundle stored there")
 	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagDiagnosticsBundleEndpointsConfigFiles,
 		"endpoint-config", []string{diagnosticsEndpointConfig},
 		"Use endpoints_config.json")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagEndpointAllowlist,
+		"endpoint-allowlist", nil,
+		"Fetch only endpoints with host:port matching one of given glob patterns e.g., *.mesos:*, configured and requested extra endpoints are rejected otherwise (empty allows any)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagEndpointSchemeFallback,
+		"endpoint-scheme-fallback", false,
+		"Fetch configured endpoints again with the other scheme when the TLS handshake fails or a plain request reaches a TLS port e.g., during partial TLS rollouts, the URL used is noted in the manifest. Certificate errors never fall back and node URLs of cluster bundles are not retried")
 	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagDiagnosticsBundleUnitsLogsSinceString,
 		"diagnostics-units-since", "24h", "Collect systemd units logs since")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagDiagnosticsBundleUnitsDenylist,
+		"diagnostics-units-denylist", defaultConfig.FlagDiagnosticsBundleUnitsDenylist,
+		"Never collect logs of systemd units matching given names or glob patterns")
 	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagDiagnosticsJobTimeoutMinutes,
 		"diagnostics-job-timeout", 720,
 		"Set a global diagnostics job timeout")
@@ -130,6 +168,210 @@ func init() {
 	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagDiagnosticsBundleFetchersCount,
 		"fetchers-count", 1,
 		"Set a number of concurrent fetchers gathering nodes logs")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagDiagnosticsBundleFetcherConcurrency,
+		"fetcher-concurrency", 1,
+		"Set a number of endpoints every fetcher gets at the same time, fetched data is written to the bundle one entry at a time")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagAcceleratorInfoCommand,
+		"accelerator-info-command", acceleratorInfoCommand,
+		"Set a command collecting accelerators information on agents with GPU attributes")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagMesosAttributesFile,
+		"mesos-attributes-file", mesosAttributesFile,
+		"Set a path to the file with MESOS_ATTRIBUTES used to detect agents with GPUs")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagMesosWorkDir,
+		"mesos-work-dir", mesosWorkDir,
+		"Set a path to the Mesos agent work dir listed on agents to show existing sandboxes (empty disables)")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagFileSymlinks,
+		"file-symlinks", string(collector.SymlinkFollow),
+		"Set how collected files behind symbolic links are handled: follow, skip or metadata (records the link target only)")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagFileSymlinksRoots,
+		"file-symlinks-roots", nil,
+		"Follow symbolic links of collected files only when they point inside given directories (empty allows any)")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagLeader,
+		"leader", "",
+		"Use a master with given IP to coordinate cluster bundles instead of the leader reported by Exhibitor, other masters reject cluster bundle creation")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagAuthzConfig,
+		"authz-config", "",
+		"Restrict access to the API with permissions of principals set by the admin router read from given JSON file (empty disables)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagKubernetesLogs,
+		"kubernetes-logs", false,
+		"Collect kubelet and container runtime logs on nodes where kubelet is installed")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagSelfDiagnostics,
+		"self-diagnostics", false,
+		"Add goroutines dump and heap profile of the daemon itself to local bundles")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagProcessesTop,
+		"processes-top", 20,
+		"Add given number of processes using the most CPU and the most memory to local bundles (0 disables)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagStorageInfo,
+		"storage-info", false,
+		"Add disk, LVM topology and SMART data of Linux nodes collected with storage-commands to local bundles under storage/")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagStorageCommands,
+		"storage-commands", collector.DefaultStorageCommands,
+		"Set commands collected with storage-info as name=command, "+collector.StorageDiskPlaceholder+" runs the command for every disk")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagHealthAnalysis,
+		"health-analysis", false,
+		"Add unhealthy units of the node with remediation hints matching their health messages to local bundles")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagHealthHintsFile,
+		"health-hints-file", "",
+		"Set a JSON file with remediation hints used by health-analysis instead of the built-in ones")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagHealthProbeScript,
+		"health-probe-script", "",
+		"Run the executable at given absolute path, not writable by group or others, and add its output and exit code to local bundles as custom/health-probe.txt")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagHealthProbeTimeoutSec,
+		"health-probe-timeout", 30,
+		"Kill the health-probe-script after given number of seconds")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagHealthProbeMaxSizeKB,
+		"health-probe-max-size", 1024,
+		"Keep at most given number of kilobytes of each of stdout and stderr of the health-probe-script")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagTLSCertFiles,
+		"tls-cert-files", nil,
+		"Add subject, issuer, SANs and expiry of certificates stored in given PEM or DER files to local bundles as tls/certs.json, private keys are never read")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagContainerEventsPeriodMinutes,
+		"container-events-period", 0,
+		"Add container events e.g., OOM kills and restarts reported by docker in given number of minutes before the collection to local bundles of agents as containers/events.json (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagContainerEventsMax,
+		"container-events-max", 1000,
+		"Keep only given number of the latest container events (0 keeps all)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagNetworkCapture,
+		"network-capture", false,
+		"Add a short tcpdump capture of network-capture-interface to local bundles as network/capture.pcap, bounded by the network-capture limits")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagNetworkCaptureInterface,
+		"network-capture-interface", "any",
+		"Capture packets on given interface when network-capture is enabled")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagNetworkCaptureFilter,
+		"network-capture-filter", "",
+		"Capture only packets matching given tcpdump expression when network-capture is enabled (empty captures all packets)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagNetworkCaptureDurationSec,
+		"network-capture-duration", 5,
+		"Stop the network capture after given number of seconds, at most 60")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagNetworkCapturePackets,
+		"network-capture-packets", 1000,
+		"Stop the network capture after given number of packets, at most 100000")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagNetworkCaptureSnapLen,
+		"network-capture-snaplen", 256,
+		"Capture at most given number of bytes of every packet, at most 65535")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagNetworkCaptureMaxSizeMB,
+		"network-capture-max-size", 10,
+		"Keep at most given number of megabytes of the network capture, at most 100")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagNetworkInterfaces,
+		"network-interfaces", false,
+		"Add counters, link state and driver statistics of network interfaces to local bundles as network/interfaces.json")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagNetworkInterfacesEthtool,
+		"network-interfaces-ethtool", "ethtool",
+		"Read driver statistics of every interface with given command run with -S <interface> when network-interfaces is enabled (empty disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagNetworkInterfacesMax,
+		"network-interfaces-max", 64,
+		"Collect statistics of at most given number of network interfaces (0 collects all)")
+	daemonCmd.PersistentFlags().StringSliceVar(&defaultConfig.FlagJournalFallbackFiles,
+		"journal-fallback-files", nil,
+		"Read logs of units from plain log files given as unit=path when the journal could not be read e.g., without journald")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagJournalHealth,
+		"journal-health", false,
+		"Add disk usage of the systemd journal, the journald configuration and its rotation settings to local bundles on Linux as systemd/journal-health.txt")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagJournalMaxReaders,
+		"journal-max-readers", 0,
+		"Set a number of journal readers open at the same time, more readers wait until one of them is closed (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleRetentionMaxAgeHours,
+		"bundle-retention-max-age", 0,
+		"Remove local bundles finished more than given number of hours ago, pinned bundles are kept (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleRetentionMaxCount,
+		"bundle-retention-max-count", 0,
+		"Keep only given number of the newest local bundles, pinned bundles are kept (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleRetentionGraceMinutes,
+		"bundle-retention-grace", 60,
+		"Never remove local bundles finished or downloaded less than given number of minutes ago")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleCompression,
+		"bundle-compression", string(rest.CompressionDeflate),
+		"Set a codec used to compress local bundles entries: deflate or zstd")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagBundleIncremental,
+		"bundle-incremental", false,
+		"Keep local zip bundles readable while they are created so data collected before the daemon was killed could be downloaded")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagBundleHTMLIndex,
+		"bundle-html-index", false,
+		"Add index.html linking collected entries grouped by category to local bundles so they could be browsed without tools")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleSetupRetries,
+		"bundle-setup-retries", 2,
+		"Retry creation of local bundle data files failed e.g., on transient disk errors with backoff before the bundle fails (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleClusterMaxDurationMinutes,
+		"bundle-cluster-max-duration", 0,
+		"Finish cluster bundles with nodes collected so far after given number of minutes and report the rest of nodes as failed with a timeout (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleClusterMaxRequests,
+		"bundle-cluster-max-requests", 0,
+		"Limit the number of concurrent requests to other nodes made by all cluster bundle operations, requests over the limit wait for a free slot (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleMinFreeInodes,
+		"bundle-min-free-inodes", 0,
+		"Refuse to create local bundles with 507 when the filesystem of diagnostics-bundle-dir has less than given number of free inodes (0 disables)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagBundleRoutes,
+		"bundle-routes", false,
+		"Add routes registered by the daemon with their methods to local bundles as routes.json")
+	daemonCmd.PersistentFlags().StringArrayVar(&defaultConfig.FlagBundleCompactFilters,
+		"bundle-compact-filters", nil,
+		"Remove lines matching given regular expression from text entries of compacted local bundles, could be repeated (empty only recompresses and trims whitespace)")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleReportOrder,
+		"bundle-report-order", string(rest.ReportOrderIP),
+		"Set the order of nodes listed in report.json of cluster bundles: ip, or role to list masters, agents and public agents by IP")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleDuplicateEntries,
+		"bundle-duplicate-entries", string(rest.DuplicateEntriesRename),
+		"Set how node bundle entries stored under the same path of cluster bundles are merged: rename stores duplicates with a numbered suffix, fail fails the bundle")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleDuplicateNodes,
+		"bundle-duplicate-nodes", string(rest.DuplicateNodesDisambiguate),
+		"Set how distinct nodes presenting the same IP are collected to cluster bundles: disambiguate stores them under the IP suffixed with the Mesos ID or hostname, skip collects only the first one")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleEmptyResults,
+		"bundle-empty-results", string(rest.EmptyResultsNote),
+		"Set how collectors returning no data are stored in local bundles: note keeps empty entries marked in the manifest, skip leaves them out")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagBundleEvents,
+		"bundle-events", false,
+		"Stream events of collectors of local bundles in progress as Server-Sent Events")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleCatalog,
+		"bundle-catalog", "",
+		"Keep a catalog of bundles created by the daemon searchable by label, status and time in given JSON file (empty disables)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagBundleCapabilitiesCheck,
+		"bundle-capabilities-check", false,
+		"Check capabilities of the daemon before collecting local bundles and report collectors that may be degraded in capabilities.json and bundle warnings")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundlePostHook,
+		"bundle-post-hook", "",
+		"Run the executable at given absolute path, not writable by group or others, with the bundle ID and path as arguments after every local bundle is collected (empty disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundlePostHookTimeoutSec,
+		"bundle-post-hook-timeout", 60,
+		"Kill the bundle-post-hook after given number of seconds")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleUploadURL,
+		"bundle-upload-url", "",
+		"Upload local bundles on request to the object store at given URL: s3://bucket/prefix?region=us-east-1&endpoint=https://host with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or file:///path (empty disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleUploadPartSizeMB,
+		"bundle-upload-part-size", 8,
+		"Upload bundles in parts of given number of megabytes, at least 5, a failed part is retried and an interrupted upload resumed from the last uploaded part")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleUploadPartRetries,
+		"bundle-upload-part-retries", 3,
+		"Retry a failed part of the bundle upload given number of times before the upload is interrupted")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleUploadPartTimeoutSec,
+		"bundle-upload-part-timeout", 300,
+		"Abort an attempt to upload a part of the bundle after given number of seconds (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleNodeMaxSizeMB,
+		"bundle-node-max-size", 0,
+		"Stop downloading a node bundle larger than given number of megabytes and mark the node as failed (0 disables)")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleTempDir,
+		"bundle-temp-dir", "",
+		"Set a directory for node bundles downloaded and merged into cluster bundles (empty uses diagnostics-bundle-dir and system temp dir)")
+	daemonCmd.PersistentFlags().BoolVar(&defaultConfig.FlagBundleConflictRetry,
+		"bundle-conflict-retry", false,
+		"Retry creation of node bundles of cluster bundles with a fresh ID when the node already has a bundle with the ID, the conflict is reported for the node otherwise")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleScheduleIntervalMinutes,
+		"bundle-schedule-interval", 0,
+		"Create a cluster bundle every given number of minutes on the leading master, a run is skipped while the previous one is in progress (0 disables)")
+	daemonCmd.PersistentFlags().StringVar(&defaultConfig.FlagBundleScheduleOptions,
+		"bundle-schedule-options", "",
+		"Select nodes of scheduled cluster bundles with a JSON body of the cluster bundle create request e.g., {\"agents\": false} (empty selects all nodes)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleStateCacheSize,
+		"bundle-state-cache-size", rest.DefaultStateCacheSize,
+		"Set a number of parsed local bundle states kept in memory to speed up listing bundles (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagBundleStateWriteIntervalSec,
+		"bundle-state-write-interval", 1,
+		"Write progress of local bundles to their state files at most once per given number of seconds, final states are written immediately (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagCollectionHistoryMaxSizeKB,
+		"collection-history-max-size", 0,
+		"Log outcomes of diagnostics jobs to collection-history.log rotated when it is larger than given number of kilobytes (0 disables)")
+	daemonCmd.PersistentFlags().IntVar(&defaultConfig.FlagCollectionHistoryMaxFiles,
+		"collection-history-max-files", 5,
+		"Set a number of compressed rotated collection history logs kept")
 	RootCmd.AddCommand(daemonCmd)
 
 	RootCmd.AddCommand(stateCmd)
@@ -25,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/dcos/dcos-diagnostics/collector"
 	"github.com/dcos/dcos-diagnostics/config"
 )
 
@@ -63,6 +64,41 @@ func Test_initConfig(t *testing.T) {
 		FlagDiagnosticsJobGetSingleURLTimeoutMinutes: 1,
 		FlagCommandExecTimeoutSec:                    50,
 		FlagDiagnosticsBundleFetchersCount:           1,
+		FlagDiagnosticsBundleFetcherConcurrency:      1,
+		FlagAcceleratorInfoCommand:                   "nvidia-smi -q -x",
+		FlagMesosAttributesFile:                      "/var/lib/dcos/mesos-slave-common",
+		FlagMesosWorkDir:                             "/var/lib/mesos/slave",
+		FlagFileSymlinks:                             "follow",
+		FlagBundleCompression:                        "deflate",
+		FlagBundleEmptyResults:                       "note",
+		FlagBundleRetentionGraceMinutes:              60,
+		FlagBundleStateCacheSize:                     1000,
+		FlagBundleStateWriteIntervalSec:              1,
+		FlagBundleSetupRetries:                       2,
+		FlagContainerEventsMax:                       1000,
+		FlagNetworkCaptureInterface:                  "any",
+		FlagNetworkCaptureDurationSec:                5,
+		FlagNetworkCapturePackets:                    1000,
+		FlagNetworkCaptureSnapLen:                    256,
+		FlagNetworkCaptureMaxSizeMB:                  10,
+		FlagNetworkInterfacesEthtool:                 "ethtool",
+		FlagNetworkInterfacesMax:                     64,
+		FlagMasterRediscoveries:                      1,
+		FlagBundleReportOrder:                        "ip",
+		FlagBundleDuplicateEntries:                   "rename",
+		FlagBundleDuplicateNodes:                     "disambiguate",
+		FlagHealthProbeTimeoutSec:                    30,
+		FlagBundlePostHookTimeoutSec:                 60,
+		FlagBundleUploadPartSizeMB:                   8,
+		FlagBundleUploadPartRetries:                  3,
+		FlagBundleUploadPartTimeoutSec:               300,
+		FlagHealthProbeMaxSizeKB:                     1024,
+		FlagCommandElevationWrapper:                  "sudo -n",
+		FlagProcessesTop:                             20,
+		FlagStorageCommands:                          collector.DefaultStorageCommands,
+		FlagCollectionHistoryMaxFiles:                5,
+		FlagDiscoveryDNSTimeoutSec:                   5,
+		FlagDiscoveryMesosTimeoutSec:                 10,
 	}
 
 	assert.Equal(t, expected, defaultConfig)
@@ -103,6 +139,41 @@ func Test_initConfig_multiple_endpints_configs(t *testing.T) {
 		FlagDiagnosticsJobGetSingleURLTimeoutMinutes: 1,
 		FlagCommandExecTimeoutSec:                    50,
 		FlagDiagnosticsBundleFetchersCount:           1,
+		FlagDiagnosticsBundleFetcherConcurrency:      1,
+		FlagAcceleratorInfoCommand:                   "nvidia-smi -q -x",
+		FlagMesosAttributesFile:                      "/var/lib/dcos/mesos-slave-common",
+		FlagMesosWorkDir:                             "/var/lib/mesos/slave",
+		FlagFileSymlinks:                             "follow",
+		FlagBundleCompression:                        "deflate",
+		FlagBundleEmptyResults:                       "note",
+		FlagBundleRetentionGraceMinutes:              60,
+		FlagBundleStateCacheSize:                     1000,
+		FlagBundleStateWriteIntervalSec:              1,
+		FlagBundleSetupRetries:                       2,
+		FlagContainerEventsMax:                       1000,
+		FlagNetworkCaptureInterface:                  "any",
+		FlagNetworkCaptureDurationSec:                5,
+		FlagNetworkCapturePackets:                    1000,
+		FlagNetworkCaptureSnapLen:                    256,
+		FlagNetworkCaptureMaxSizeMB:                  10,
+		FlagNetworkInterfacesEthtool:                 "ethtool",
+		FlagNetworkInterfacesMax:                     64,
+		FlagMasterRediscoveries:                      1,
+		FlagBundleReportOrder:                        "ip",
+		FlagBundleDuplicateEntries:                   "rename",
+		FlagBundleDuplicateNodes:                     "disambiguate",
+		FlagHealthProbeTimeoutSec:                    30,
+		FlagBundlePostHookTimeoutSec:                 60,
+		FlagBundleUploadPartSizeMB:                   8,
+		FlagBundleUploadPartRetries:                  3,
+		FlagBundleUploadPartTimeoutSec:               300,
+		FlagHealthProbeMaxSizeKB:                     1024,
+		FlagCommandElevationWrapper:                  "sudo -n",
+		FlagProcessesTop:                             20,
+		FlagStorageCommands:                          collector.DefaultStorageCommands,
+		FlagCollectionHistoryMaxFiles:                5,
+		FlagDiscoveryDNSTimeoutSec:                   5,
+		FlagDiscoveryMesosTimeoutSec:                 10,
 	}
 
 	assert.Equal(t, expected, defaultConfig)
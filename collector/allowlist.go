@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// EndpointAllowlist restricts URLs endpoint collectors may fetch. Each pattern is host:port where
+// both parts could use glob patterns e.g., *.mesos:* or 127.0.0.1:61001.
+// The empty allowlist allows all URLs.
+type EndpointAllowlist []string
+
+// ParseEndpointAllowlist returns the allowlist with given patterns after checking they are valid
+func ParseEndpointAllowlist(patterns []string) (EndpointAllowlist, error) {
+	for _, p := range patterns {
+		host, port, err := net.SplitHostPort(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint allowlist pattern %s: %s", p, err)
+		}
+		for _, part := range []string{host, port} {
+			if _, err := path.Match(part, ""); err != nil {
+				return nil, fmt.Errorf("invalid endpoint allowlist pattern %s: %s", p, err)
+			}
+		}
+	}
+	return EndpointAllowlist(patterns), nil
+}
+
+// Check returns an error if the URL host and port do not match any pattern of the allowlist.
+// URLs without a port are matched with the default port of their scheme.
+func (a EndpointAllowlist) Check(rawURL string) error {
+	if len(a) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint URL %s: %s", rawURL, err)
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	for _, p := range a {
+		allowedHost, allowedPort, err := net.SplitHostPort(p)
+		if err != nil {
+			continue
+		}
+		hostMatched, _ := path.Match(allowedHost, host)
+		portMatched, _ := path.Match(allowedPort, port)
+		if hostMatched && portMatched {
+			return nil
+		}
+	}
+	return fmt.Errorf("endpoint URL %s is not allowed, %s:%s does not match endpoint allowlist %v", rawURL, host, port, []string(a))
+}
+
+// restrictRedirects returns a copy of the client that refuses redirects to URLs not allowed by the allowlist,
+// so an allowed endpoint could not send the request, and the headers copied along, to other hosts.
+// Redirects are checked by the original CheckRedirect of the client afterwards.
+func (a EndpointAllowlist) restrictRedirects(client *http.Client) *http.Client {
+	if len(a) == 0 || client == nil {
+		return client
+	}
+	restricted := *client
+	checkRedirect := client.CheckRedirect
+	restricted.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := a.Check(req.URL.String()); err != nil {
+			return fmt.Errorf("redirect refused: %s", err)
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		// the limit of the default policy of http.Client
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &restricted
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEndpointAllowedByAllowlist(t *testing.T) {
+	allowlist, err := ParseEndpointAllowlist([]string{"*.mesos:*", "127.0.0.1:61001"})
+	require.NoError(t, err)
+
+	for _, url := range []string{
+		"http://leader.mesos:5050/state",
+		"https://master.mesos/metrics",
+		"http://127.0.0.1:61001/system/health/v1",
+	} {
+		c, err := NewEndpoint("test", false, url, http.DefaultClient, allowlist)
+		assert.NoError(t, err, url)
+		assert.NotNil(t, c, url)
+	}
+}
+
+func TestNewEndpointDisallowedByAllowlist(t *testing.T) {
+	allowlist, err := ParseEndpointAllowlist([]string{"*.mesos:*", "127.0.0.1:61001"})
+	require.NoError(t, err)
+
+	c, err := NewEndpoint("test", false, "http://169.254.169.254/latest/meta-data", http.DefaultClient, allowlist)
+	assert.Nil(t, c)
+	assert.EqualError(t, err, "endpoint URL http://169.254.169.254/latest/meta-data is not allowed, "+
+		"169.254.169.254:80 does not match endpoint allowlist [*.mesos:* 127.0.0.1:61001]")
+
+	_, err = NewEndpoint("test", false, "http://127.0.0.1:8080/", http.DefaultClient, allowlist)
+	assert.Error(t, err)
+}
+
+func TestEndpointRedirectsAreCheckedByAllowlist(t *testing.T) {
+	disallowedRequests := 0
+	disallowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		disallowedRequests++
+		w.Write([]byte("secret"))
+	}))
+	defer disallowed.Close()
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/elsewhere" {
+			http.Redirect(w, r, disallowed.URL+"/latest/meta-data", http.StatusFound)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer allowed.Close()
+
+	u, err := url.Parse(allowed.URL)
+	require.NoError(t, err)
+	allowlist, err := ParseEndpointAllowlist([]string{u.Host})
+	require.NoError(t, err)
+
+	checkedRedirects := 0
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		checkedRedirects++
+		return nil
+	}}
+
+	c, err := NewEndpoint("test", false, allowed.URL+"/elsewhere", client, allowlist)
+	require.NoError(t, err)
+	_, err = c.Collect(context.TODO())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "redirect refused")
+	assert.Equal(t, 0, disallowedRequests)
+	assert.Equal(t, 0, checkedRedirects)
+
+	// redirects within the allowlist are followed and checked by the client as well
+	c, err = NewEndpoint("test", false, allowed.URL+"/elsewhere", client, EndpointAllowlist{u.Host, "127.0.0.1:*"})
+	require.NoError(t, err)
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(data))
+	assert.Equal(t, 1, checkedRedirects)
+}
+
+func TestEmptyAllowlistAllowsAllURLs(t *testing.T) {
+	assert.NoError(t, EndpointAllowlist(nil).Check("http://192.0.2.1:8080/"))
+}
+
+func TestParseEndpointAllowlistValidatesPatterns(t *testing.T) {
+	_, err := ParseEndpointAllowlist([]string{"leader.mesos"})
+	assert.EqualError(t, err, "invalid endpoint allowlist pattern leader.mesos: address leader.mesos: missing port in address")
+
+	_, err = ParseEndpointAllowlist([]string{"[a-:80"})
+	assert.Error(t, err)
+}
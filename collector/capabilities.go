@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ProcSelfStatus is the file the kernel reports capabilities of the daemon in
+const ProcSelfStatus = "/proc/self/status"
+
+// Capability is a Linux capability named as in capabilities(7)
+type Capability string
+
+const (
+	CapDacReadSearch Capability = "CAP_DAC_READ_SEARCH"
+	CapNetAdmin      Capability = "CAP_NET_ADMIN"
+	CapNetRaw        Capability = "CAP_NET_RAW"
+	CapSysPtrace     Capability = "CAP_SYS_PTRACE"
+)
+
+// capabilityBits are numbers of capabilities in capability masks, see linux/capability.h
+var capabilityBits = map[Capability]uint{
+	CapDacReadSearch: 2,
+	CapNetAdmin:      12,
+	CapNetRaw:        13,
+	CapSysPtrace:     19,
+}
+
+// CapabilitySet is a capability mask as reported in /proc/<pid>/status
+type CapabilitySet uint64
+
+// ReadEffectiveCapabilities returns the effective capabilities from the CapEff line of the status file at path
+func ReadEffectiveCapabilities(path string) (CapabilitySet, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not read capabilities: %s", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse capabilities %q: %s", line, err)
+		}
+		return CapabilitySet(mask), nil
+	}
+	return 0, fmt.Errorf("no effective capabilities found in %s", path)
+}
+
+// Has returns true if the capability is in the set. Unknown capabilities are never in the set.
+func (s CapabilitySet) Has(c Capability) bool {
+	bit, ok := capabilityBits[c]
+	return ok && s&(1<<bit) != 0
+}
+
+// Missing returns capabilities that are not in the set keeping their order
+func (s CapabilitySet) Missing(capabilities []Capability) []Capability {
+	var missing []Capability
+	for _, c := range capabilities {
+		if !s.Has(c) {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// PrivilegeRequiring is implemented by collectors that produce partial data without some capabilities
+type PrivilegeRequiring interface {
+	Capabilities() []Capability
+}
+
+// privilegeRequiring wraps a Collector and tells capabilities it requires
+type privilegeRequiring struct {
+	Collector
+	capabilities []Capability
+}
+
+func (c privilegeRequiring) Capabilities() []Capability {
+	return c.capabilities
+}
+
+func (c privilegeRequiring) AppliesTo(p Platform) bool {
+	return AppliesTo(c.Collector, p)
+}
+
+func (c privilegeRequiring) Priority() int {
+	return Priority(c.Collector)
+}
+
+// WithCapabilities returns collector c requiring given capabilities
+func WithCapabilities(c Collector, capabilities ...Capability) Collector {
+	return privilegeRequiring{Collector: c, capabilities: capabilities}
+}
+
+// RequiredCapabilities returns capabilities the collector requires, none if it does not implement PrivilegeRequiring
+func RequiredCapabilities(c Collector) []Capability {
+	if p, ok := c.(PrivilegeRequiring); ok {
+		return p.Capabilities()
+	}
+	return nil
+}
+
+// Capabilities of PacketCapture allow opening raw sockets and putting the interface into promiscuous mode
+func (c PacketCapture) Capabilities() []Capability {
+	return []Capability{CapNetRaw, CapNetAdmin}
+}
+
+// Capabilities of Processes allow reading details of processes of other users
+func (c Processes) Capabilities() []Capability {
+	return []Capability{CapSysPtrace}
+}
+
+// Capabilities of Sandboxes allow listing sandboxes of tasks run as other users
+func (c Sandboxes) Capabilities() []Capability {
+	return []Capability{CapDacReadSearch}
+}
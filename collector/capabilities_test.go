@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeStatusFile(t *testing.T, dir string, content string) string {
+	path := filepath.Join(dir, "status")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestReadEffectiveCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capabilities")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// CAP_DAC_READ_SEARCH and CAP_NET_RAW only
+	path := writeStatusFile(t, dir, "Name:\tdcos-diagnostics\nCapInh:\t0000000000000000\nCapPrm:\t0000003fffffffff\n"+
+		"CapEff:\t0000000000002004\nCapBnd:\t0000003fffffffff\n")
+
+	capabilities, err := ReadEffectiveCapabilities(path)
+	require.NoError(t, err)
+	assert.True(t, capabilities.Has(CapDacReadSearch))
+	assert.True(t, capabilities.Has(CapNetRaw))
+	assert.False(t, capabilities.Has(CapNetAdmin))
+	assert.False(t, capabilities.Has(Capability("CAP_UNKNOWN")))
+	assert.Equal(t, []Capability{CapNetAdmin, CapSysPtrace},
+		capabilities.Missing([]Capability{CapNetRaw, CapNetAdmin, CapSysPtrace}))
+}
+
+func TestReadEffectiveCapabilitiesFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capabilities")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = ReadEffectiveCapabilities(writeStatusFile(t, dir, "Name:\tdcos-diagnostics\n"))
+	assert.Contains(t, err.Error(), "no effective capabilities found")
+
+	_, err = ReadEffectiveCapabilities(writeStatusFile(t, dir, "CapEff:\tnot-hex\n"))
+	assert.Contains(t, err.Error(), "could not parse capabilities")
+
+	_, err = ReadEffectiveCapabilities(filepath.Join(dir, "missing"))
+	assert.Contains(t, err.Error(), "could not read capabilities")
+}
+
+func TestRequiredCapabilitiesAreForwardedByWrappers(t *testing.T) {
+	processes := NewProcesses("processes.json", true, 10)
+	assert.Equal(t, []Capability{CapSysPtrace}, RequiredCapabilities(processes))
+	assert.Equal(t, []Capability{CapSysPtrace},
+		RequiredCapabilities(WithPriority(WithPlatforms(processes, OnlyOS("linux")), PriorityHigh)))
+
+	cmd := WithCapabilities(NewCmd("iptables.output", true, []string{"iptables", "-L"}), CapNetAdmin)
+	assert.Equal(t, []Capability{CapNetAdmin}, RequiredCapabilities(cmd))
+	assert.Equal(t, PriorityHigh, Priority(WithCapabilities(WithPriority(cmd, PriorityHigh))))
+	assert.Empty(t, RequiredCapabilities(NewCmd("uptime.output", true, []string{"uptime"})))
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// certificateBlockType is a type of PEM blocks holding certificates, other blocks e.g., private keys are never read
+const certificateBlockType = "CERTIFICATE"
+
+// keyFileExtensions are extensions of files that hold private keys, they are skipped without being read
+var keyFileExtensions = map[string]bool{".key": true, ".p12": true, ".pfx": true, ".jks": true}
+
+// Certificates is a struct implementing Collector interface. It collects metadata of certificates stored in given
+// PEM or DER files: subject, issuer, SANs and validity, so expired certificates and broken chains could be found
+// without the files being copied. Private keys are never read, key files are skipped.
+type Certificates struct {
+	name     string
+	optional bool
+	paths    []string
+	now      func() time.Time
+}
+
+// certificatesReport is the content of the collected file
+type certificatesReport struct {
+	Files []certificateFile `json:"files"`
+}
+
+type certificateFile struct {
+	Path         string            `json:"path"`
+	Certificates []certificateInfo `json:"certificates,omitempty"`
+	// Skipped explains why the file was not read e.g., it holds a private key
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type certificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	IPAddresses  []string  `json:"ip_addresses,omitempty"`
+	URIs         []string  `json:"uris,omitempty"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Expired      bool      `json:"expired"`
+	IsCA         bool      `json:"is_ca"`
+}
+
+// NewCertificates returns a collector of metadata of certificates stored in files with given paths
+func NewCertificates(name string, optional bool, paths []string) *Certificates {
+	return &Certificates{
+		name:     name,
+		optional: optional,
+		paths:    paths,
+		now:      time.Now,
+	}
+}
+
+func (c Certificates) Name() string {
+	return c.name
+}
+
+func (c Certificates) Optional() bool {
+	return c.optional
+}
+
+func (c Certificates) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	report := certificatesReport{Files: make([]certificateFile, 0, len(c.paths))}
+	now := c.now()
+	for _, path := range c.paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Files = append(report.Files, readCertificateFile(path, now))
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal certificates: %s", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// readCertificateFile returns metadata of certificates stored in the file, errors are reported in the result
+func readCertificateFile(path string, now time.Time) certificateFile {
+	file := certificateFile{Path: path}
+	if keyFileExtensions[strings.ToLower(filepath.Ext(path))] {
+		file.Skipped = "private key file"
+		return file
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		file.Error = fmt.Sprintf("could not read file: %s", err)
+		return file
+	}
+
+	certs, keys, err := parseCertificates(data)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+	if len(certs) == 0 && keys > 0 {
+		file.Skipped = "private key file"
+		return file
+	}
+	if len(certs) == 0 {
+		file.Error = "no certificates found"
+		return file
+	}
+
+	for _, cert := range certs {
+		file.Certificates = append(file.Certificates, newCertificateInfo(cert, now))
+	}
+	return file
+}
+
+// parseCertificates returns certificates stored as PEM blocks or DER and a number of private key blocks that were
+// skipped. Content of blocks other than certificates is not parsed.
+func parseCertificates(data []byte) ([]*x509.Certificate, int, error) {
+	var certs []*x509.Certificate
+	keys := 0
+	blocks := 0
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		blocks++
+		if strings.Contains(block.Type, "PRIVATE KEY") {
+			keys++
+			continue
+		}
+		if block.Type != certificateBlockType {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, keys, fmt.Errorf("could not parse certificate %d: %s", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if blocks > 0 {
+		return certs, keys, nil
+	}
+
+	// not a PEM file, it could be a DER encoded certificate chain
+	certs, err := x509.ParseCertificates(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse certificates: neither PEM nor DER: %s", err)
+	}
+	return certs, 0, nil
+}
+
+func newCertificateInfo(cert *x509.Certificate, now time.Time) certificateInfo {
+	info := certificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		DNSNames:     cert.DNSNames,
+		NotBefore:    cert.NotBefore.UTC(),
+		NotAfter:     cert.NotAfter.UTC(),
+		Expired:      now.After(cert.NotAfter),
+		IsCA:         cert.IsCA,
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
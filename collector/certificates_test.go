@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSampleCertificate writes a self signed CA certificate to the cert file and its private key to the key file
+func writeSampleCertificate(t *testing.T, certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "master.mesos", Organization: []string{"Mesosphere"}},
+		DNSNames:              []string{"master.mesos", "leader.mesos"},
+		IPAddresses:           []net.IP{net.ParseIP("192.0.2.1")},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func TestCertificatesCollectsMetadataAndSkipsKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+	writeSampleCertificate(t, certPath, keyPath)
+	// a key stored with an extension not known as a key file is recognized by its PEM block
+	pemKeyPath := filepath.Join(dir, "ca-key.pem")
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(pemKeyPath, keyPEM, 0600))
+	missingPath := filepath.Join(dir, "missing.crt")
+
+	c := NewCertificates("tls/certs.json", true, []string{certPath, keyPath, pemKeyPath, missingPath})
+	c.now = func() time.Time { return time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC) }
+	assert.Equal(t, "tls/certs.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "PRIVATE KEY")
+
+	var report certificatesReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Files, 4)
+
+	assert.Equal(t, certificateFile{Path: certPath, Certificates: []certificateInfo{{
+		Subject:      "CN=master.mesos,O=Mesosphere",
+		Issuer:       "CN=master.mesos,O=Mesosphere",
+		SerialNumber: "42",
+		DNSNames:     []string{"master.mesos", "leader.mesos"},
+		IPAddresses:  []string{"192.0.2.1"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Expired:      false,
+		IsCA:         true,
+	}}}, report.Files[0])
+	assert.Equal(t, certificateFile{Path: keyPath, Skipped: "private key file"}, report.Files[1])
+	assert.Equal(t, certificateFile{Path: pemKeyPath, Skipped: "private key file"}, report.Files[2])
+	assert.Equal(t, missingPath, report.Files[3].Path)
+	assert.Contains(t, report.Files[3].Error, "could not read file")
+}
+
+func TestCertificatesReportsExpiredCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath := filepath.Join(dir, "ca.crt")
+	writeSampleCertificate(t, certPath, filepath.Join(dir, "ca.key"))
+
+	c := NewCertificates("tls/certs.json", true, []string{certPath})
+	c.now = func() time.Time { return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	r, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	var report certificatesReport
+	require.NoError(t, json.NewDecoder(r).Decode(&report))
+	require.Len(t, report.Files, 1)
+	require.Len(t, report.Files[0].Certificates, 1)
+	assert.True(t, report.Files[0].Certificates[0].Expired)
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/dcos/dcos-diagnostics/io"
@@ -25,11 +27,69 @@ type Collector interface {
 	Collect(ctx context.Context) (goio.ReadCloser, error)
 }
 
+const (
+	// PriorityDefault is a priority of collectors that were not given any
+	PriorityDefault = 0
+	// PriorityHigh is a priority of collectors providing the most important data e.g., health report
+	PriorityHigh = 100
+	// PriorityLow is a priority of collectors that should see effects of other collectors e.g., daemon own logs
+	PriorityLow = -100
+)
+
+// Prioritized is implemented by collectors that should be collected before or after others.
+// Collectors with higher priority are collected first.
+type Prioritized interface {
+	Priority() int
+}
+
+// prioritized wraps a Collector and assigns priority to it
+type prioritized struct {
+	Collector
+	priority int
+}
+
+func (c prioritized) Priority() int {
+	return c.priority
+}
+
+func (c prioritized) AppliesTo(p Platform) bool {
+	return AppliesTo(c.Collector, p)
+}
+
+func (c prioritized) Capabilities() []Capability {
+	return RequiredCapabilities(c.Collector)
+}
+
+// WithPriority returns collector c with given priority
+func WithPriority(c Collector, priority int) Collector {
+	return prioritized{Collector: c, priority: priority}
+}
+
+// Priority returns priority of the collector or PriorityDefault if it does not implement Prioritized
+func Priority(c Collector) int {
+	if p, ok := c.(Prioritized); ok {
+		return p.Priority()
+	}
+	return PriorityDefault
+}
+
+// SortByPriority returns a copy of collectors sorted by priority, highest first.
+// Collectors with equal priority keep their order.
+func SortByPriority(collectors []Collector) []Collector {
+	sorted := append([]Collector{}, collectors...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return Priority(sorted[i]) > Priority(sorted[j])
+	})
+	return sorted
+}
+
 // Cmd is a struct implementing Collector interface. It collects command output for given command configured with Cmd field
 type Cmd struct {
-	name     string
-	optional bool
-	cmd      []string
+	name      string
+	optional  bool
+	cmd       []string
+	elevation []string
+	timeout   time.Duration
 }
 
 func NewCmd(name string, optional bool, cmd []string) *Cmd {
@@ -40,6 +100,19 @@ func NewCmd(name string, optional bool, cmd []string) *Cmd {
 	}
 }
 
+// WithElevation runs the command through the privilege elevation wrapper e.g., sudo -n, limited by the timeout
+// so a wrapper waiting for a password could not block the collection. Elevated commands are optional as elevation
+// could be denied on some nodes. Empty wrapper runs the command as is.
+func (c *Cmd) WithElevation(wrapper []string, timeout time.Duration) *Cmd {
+	if len(wrapper) == 0 {
+		return c
+	}
+	c.elevation = wrapper
+	c.timeout = timeout
+	c.optional = true
+	return c
+}
+
 func (c Cmd) Name() string {
 	return c.name
 }
@@ -49,9 +122,60 @@ func (c Cmd) Optional() bool {
 }
 
 func (c Cmd) Collect(ctx context.Context) (goio.ReadCloser, error) {
-	cmd := exec.CommandContext(ctx, c.cmd[0], c.cmd[1:]...)
-	output, err := cmd.CombinedOutput()
-	return ioutil.NopCloser(bytes.NewReader(output)), err
+	if len(c.elevation) == 0 {
+		return RunCommand(ctx, c.cmd)
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	command := append(append([]string{}, c.elevation...), c.cmd...)
+	output, err := RunCommand(ctx, command)
+	if err != nil {
+		err = fmt.Errorf("could not run %v elevated with %v: %s", c.cmd, c.elevation, err)
+	}
+	return output, err
+}
+
+// CommandOutput is a combined output of the command preceded by the header line with its exit code and duration
+type CommandOutput struct {
+	goio.Reader
+	// ExitCode is the exit code of the command, -1 when it could not be started or was killed
+	ExitCode int
+	Duration time.Duration
+}
+
+func (CommandOutput) Close() error {
+	return nil
+}
+
+// RunCommand runs the command and returns its combined output. The output is returned even if the command fails.
+func RunCommand(ctx context.Context, command []string) (*CommandOutput, error) {
+	start := time.Now()
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	duration := time.Since(start)
+
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	header := CommandHeader(exitCode, duration)
+	return &CommandOutput{
+		Reader:   goio.MultiReader(strings.NewReader(header), bytes.NewReader(output)),
+		ExitCode: exitCode,
+		Duration: duration,
+	}, err
+}
+
+// CommandHeader returns the line put before the command output
+func CommandHeader(exitCode int, duration time.Duration) string {
+	return fmt.Sprintf("# exit code: %d, duration: %s\n", exitCode, duration)
 }
 
 // Systemd is a struct implementing Collector interface. It collects journal logs for given unit
@@ -60,15 +184,44 @@ type Systemd struct {
 	optional bool
 	unitName string
 	duration time.Duration
+	// fallbackFile is a plain log file of the unit read when the journal could not be read
+	fallbackFile string
+	readJournal  func(ctx context.Context, unit string, duration time.Duration) (goio.ReadCloser, error)
 }
 
 func NewSystemd(name string, optional bool, unitName string, duration time.Duration) *Systemd {
 	return &Systemd{
-		name:     name,
-		optional: optional,
-		unitName: unitName,
-		duration: duration,
+		name:        name,
+		optional:    optional,
+		unitName:    unitName,
+		duration:    duration,
+		readJournal: units.ReadJournalOutputSince,
+	}
+}
+
+// WithFallbackFile makes the collector read the plain log file at the path when the journal could not be read
+// e.g., on minimal systems where journald is not running. The whole file is collected after a header line telling
+// why the journal was not used. Empty path disables the fallback.
+func (c *Systemd) WithFallbackFile(path string) *Systemd {
+	c.fallbackFile = path
+	return c
+}
+
+// ParseJournalFallbackFiles returns plain log files given as unit=path by unit names
+func ParseJournalFallbackFiles(files []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(files))
+	for _, f := range files {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid journal fallback file %q, expected unit=path", f)
+		}
+		unit := strings.TrimSpace(parts[0])
+		if _, ok := parsed[unit]; ok {
+			return nil, fmt.Errorf("duplicated journal fallback file of %s", unit)
+		}
+		parsed[unit] = strings.TrimSpace(parts[1])
 	}
+	return parsed, nil
 }
 
 func (c Systemd) Name() string {
@@ -80,30 +233,54 @@ func (c Systemd) Optional() bool {
 }
 
 func (c Systemd) Collect(ctx context.Context) (goio.ReadCloser, error) {
-	rc, err := units.ReadJournalOutputSince(ctx, c.unitName, c.duration)
+	rc, err := c.readJournal(ctx, c.unitName, c.duration)
 
 	if err != nil {
-		return nil, fmt.Errorf("could not read %s logs from journal: %s", c.unitName, err)
+		err = fmt.Errorf("could not read %s logs from journal: %s", c.unitName, err)
+		if c.fallbackFile == "" {
+			return nil, err
+		}
+		return c.readFallbackFile(ctx, err)
 	}
 
 	return rc, err
 }
 
+func (c Systemd) readFallbackFile(ctx context.Context, journalErr error) (goio.ReadCloser, error) {
+	f, err := os.Open(c.fallbackFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s; could not read fallback log file: %s", journalErr, err)
+	}
+	header := fmt.Sprintf("# %s, read from %s instead\n", journalErr, c.fallbackFile)
+	return io.ReadCloserWithContext(ctx, struct {
+		goio.Reader
+		goio.Closer
+	}{goio.MultiReader(strings.NewReader(header), f), f}), nil
+}
+
 // Endpoint is a struct implementing Collector interface. It collects HTTP response for given url
 type Endpoint struct {
 	name     string
 	optional bool
 	client   *http.Client
 	url      string
+	// fallbackURL is the url with the other scheme fetched when the url fails because of the wrong scheme,
+	// empty disables the fallback
+	fallbackURL string
 }
 
-func NewEndpoint(name string, optional bool, url string, client *http.Client) *Endpoint {
+// NewEndpoint returns a collector of the response of the url. It returns an error when the url is not allowed by the allowlist.
+// Redirects to urls not allowed by the allowlist fail the collection.
+func NewEndpoint(name string, optional bool, url string, client *http.Client, allowlist EndpointAllowlist) (*Endpoint, error) {
+	if err := allowlist.Check(url); err != nil {
+		return nil, err
+	}
 	return &Endpoint{
 		name:     name,
 		optional: optional,
 		url:      url,
-		client:   client,
-	}
+		client:   allowlist.restrictRedirects(client),
+	}, nil
 }
 
 func (c Endpoint) Name() string {
@@ -115,16 +292,30 @@ func (c Endpoint) Optional() bool {
 }
 
 func (c Endpoint) Collect(ctx context.Context) (goio.ReadCloser, error) {
-	url := c.url
+	body, wrongScheme, err := c.fetch(ctx, c.url)
+	if err == nil || !wrongScheme || c.fallbackURL == "" {
+		return body, err
+	}
+
+	body, _, fallbackErr := c.fetch(ctx, c.fallbackURL)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%s; fallback failed: %s", err, fallbackErr)
+	}
+	return &EndpointOutput{ReadCloser: body, URL: c.fallbackURL, Reason: err.Error()}, nil
+}
+
+// fetch returns the body of the response of the url. When it fails it also tells if the failure could be caused
+// by the wrong scheme e.g., a TLS handshake with a plain HTTP server.
+func (c Endpoint) fetch(ctx context.Context, url string) (goio.ReadCloser, bool, error) {
 	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("could not create a new HTTP request: %s", err)
+		return nil, false, fmt.Errorf("could not create a new HTTP request: %s", err)
 	}
 	request = request.WithContext(ctx)
 
 	resp, err := c.client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch url %s: %s", url, err)
+		return nil, isTLSHandshakeError(err), fmt.Errorf("could not fetch url %s: %s", url, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -134,19 +325,20 @@ func (c Endpoint) Collect(ctx context.Context) (goio.ReadCloser, error) {
 
 		body, e := ioutil.ReadAll(resp.Body)
 		if e != nil {
-			return nil, fmt.Errorf("%s Could not read body: %s", errMsg, e)
+			return nil, false, fmt.Errorf("%s Could not read body: %s", errMsg, e)
 		}
 
-		return nil, fmt.Errorf("%s Body: %s", errMsg, string(body))
+		return nil, isPlainRequestToTLSServer(resp.StatusCode, body), fmt.Errorf("%s Body: %s", errMsg, string(body))
 	}
 
-	return resp.Body, err
+	return resp.Body, false, err
 }
 
 type File struct {
 	name     string
 	optional bool
 	filePath string
+	symlinks SymlinkPolicy
 }
 
 func NewFile(name string, optional bool, filePath string) *File {
@@ -157,6 +349,12 @@ func NewFile(name string, optional bool, filePath string) *File {
 	}
 }
 
+// WithSymlinks sets how the file is opened when its path leads through a symbolic link
+func (c *File) WithSymlinks(policy SymlinkPolicy) *File {
+	c.symlinks = policy
+	return c
+}
+
 func (c File) Name() string {
 	return c.name
 }
@@ -166,7 +364,7 @@ func (c File) Optional() bool {
 }
 
 func (c File) Collect(ctx context.Context) (goio.ReadCloser, error) {
-	r, err := os.Open(c.filePath)
+	r, err := c.symlinks.Open(c.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("could not open %s: %s", c.Name(), err)
 	}
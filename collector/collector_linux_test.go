@@ -2,8 +2,11 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,3 +34,121 @@ func TestSystemd_Collect(t *testing.T) {
 
 	assert.Empty(t, string(raw))
 }
+
+func TestTimeSync_CollectUsesFirstSucceedingCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "time-sync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	chronyc := filepath.Join(dir, "chronyc")
+	ntpq := filepath.Join(dir, "ntpq")
+	require.NoError(t, ioutil.WriteFile(chronyc, []byte("#!/bin/sh\necho '506 Cannot talk to daemon'\nexit 1\n"), 0700))
+	require.NoError(t, ioutil.WriteFile(ntpq, []byte("#!/bin/sh\necho \"*ntp.example.com $1\"\n"), 0700))
+
+	c := NewTimeSync("time/sync-status.txt", true, time.Second, [][]string{
+		{chronyc, "tracking"},
+		{ntpq, "-p"},
+		{filepath.Join(dir, "timedatectl"), "status"},
+	})
+
+	r, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "$ "+ntpq+" -p\n*ntp.example.com -p\n", string(raw))
+}
+
+func TestTimeSync_CollectFailsWhenNoCommandSucceeds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "time-sync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewTimeSync("time/sync-status.txt", true, time.Second, [][]string{{filepath.Join(dir, "chronyc"), "tracking"}})
+	_, err = c.Collect(context.Background())
+	assert.EqualError(t, err, "could not find any time synchronization command")
+
+	chronyc := filepath.Join(dir, "chronyc")
+	require.NoError(t, ioutil.WriteFile(chronyc, []byte("#!/bin/sh\nexec sleep 10\n"), 0700))
+
+	start := time.Now()
+	_, err = c.Collect(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not get time synchronization status: "+chronyc+" tracking")
+	assert.True(t, time.Since(start) < 5*time.Second, "command should be killed after timeout")
+}
+
+func TestEnvironment_CollectReadsKernelAndMemory(t *testing.T) {
+	r, err := NewEnvironment("environment.json", true).Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+
+	var info EnvironmentInfo
+	require.NoError(t, json.NewDecoder(r).Decode(&info))
+	assert.NotEmpty(t, info.Kernel)
+	assert.NotZero(t, info.MemoryTotal)
+}
+
+func TestParseOSRelease(t *testing.T) {
+	assert.Equal(t, "Debian GNU/Linux 12 (bookworm)", parseOSRelease(strings.NewReader(
+		"NAME=\"Debian GNU/Linux\"\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nID=debian\n")))
+	assert.Equal(t, "CentOS 7", parseOSRelease(strings.NewReader("PRETTY_NAME='CentOS 7'\n")))
+	assert.Empty(t, parseOSRelease(strings.NewReader("ID=coreos\n")))
+}
+
+func TestParseMemTotal(t *testing.T) {
+	assert.Equal(t, uint64(16384*1024), parseMemTotal(strings.NewReader(
+		"MemTotal:          16384 kB\nMemFree:            1024 kB\n")))
+	assert.Zero(t, parseMemTotal(strings.NewReader("MemFree: 1024 kB\n")))
+}
+
+func TestStorage_CollectRunsCommandForEveryDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	lsblk := filepath.Join(dir, "lsblk")
+	smartctl := filepath.Join(dir, "smartctl")
+	require.NoError(t, ioutil.WriteFile(lsblk, []byte("#!/bin/sh\necho /dev/sda\necho /dev/sdb\n"), 0700))
+	require.NoError(t, ioutil.WriteFile(smartctl, []byte("#!/bin/sh\necho \"SMART $2: PASSED\"\n"), 0700))
+
+	c := NewStorage("storage/smartctl.txt", []string{smartctl, "--xall", StorageDiskPlaceholder}, time.Second)
+	c.disksCommand = []string{lsblk}
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	output := string(raw)
+	assert.Contains(t, output, "$ "+smartctl+" --xall /dev/sda\n# exit code: 0")
+	assert.Contains(t, output, "SMART /dev/sda: PASSED\n")
+	assert.Contains(t, output, "$ "+smartctl+" --xall /dev/sdb\n# exit code: 0")
+	assert.Contains(t, output, "SMART /dev/sdb: PASSED\n")
+}
+
+func TestStorage_CollectNotesMissingTool(t *testing.T) {
+	c := NewStorage("storage/pvs.txt", []string{"/does/not/exist/pvs", "--all"}, time.Second)
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "/does/not/exist/pvs is not installed on this node")
+}
+
+func TestStorage_CollectReturnsOutputOfFailedCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	lvs := filepath.Join(dir, "lvs")
+	require.NoError(t, ioutil.WriteFile(lvs, []byte("#!/bin/sh\necho 'WARNING: Running as a non-root user'\nexit 5\n"), 0700))
+
+	r, err := NewStorage("storage/lvs.txt", []string{lvs}, time.Second).Collect(context.TODO())
+	assert.EqualError(t, err, "could not run storage commands: "+lvs+": exit status 5")
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Regexp(t, "# exit code: 5, .+\nWARNING: Running as a non-root user\n$", string(raw))
+}
@@ -2,11 +2,16 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -44,7 +49,7 @@ func TestCmd_Collect(t *testing.T) {
 	raw, err := ioutil.ReadAll(r)
 	require.NoError(t, err)
 
-	assert.Equal(t, "OK\n", string(raw))
+	assert.Regexp(t, "^# exit code: 0, duration: .+\nOK\n$", string(raw))
 
 	c = NewCmd(
 		"unknown",
@@ -57,7 +62,44 @@ func TestCmd_Collect(t *testing.T) {
 	raw, err = ioutil.ReadAll(r)
 	require.NoError(t, err)
 
-	assert.Empty(t, string(raw))
+	assert.Regexp(t, "^# exit code: -1, duration: .+\n$", string(raw))
+}
+
+func TestCmd_CollectRecordsExitCodeOfFailingCommand(t *testing.T) {
+	c := NewCmd("fail", true, []string{"sh", "-c", "echo partial; exit 3"})
+	r, err := c.Collect(context.TODO())
+	assert.EqualError(t, err, "exit status 3")
+
+	output, ok := r.(*CommandOutput)
+	require.True(t, ok)
+	assert.Equal(t, 3, output.ExitCode)
+	assert.True(t, output.Duration > 0)
+
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, CommandHeader(3, output.Duration)+"partial\n", string(raw))
+}
+
+func TestCmd_CollectElevated(t *testing.T) {
+	// the stub wrapper prints the command it was asked to run
+	c := NewCmd("id", false, []string{"id", "-u"}).WithElevation([]string{"echo", "elevated"}, time.Second)
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Regexp(t, "^# exit code: 0, duration: .+\nelevated id -u\n$", string(raw))
+
+	// wrapper denying elevation
+	c = NewCmd("id", false, []string{"id", "-u"}).WithElevation([]string{"false"}, time.Second)
+	_, err = c.Collect(context.TODO())
+	assert.EqualError(t, err, "could not run [id -u] elevated with [false]: exit status 1")
+
+	// no wrapper runs the command as is
+	c = NewCmd("echo", false, []string{"echo", "OK"}).WithElevation(nil, time.Second)
+	assert.False(t, c.Optional())
 }
 
 func TestSystemdIsCollector(t *testing.T) {
@@ -78,29 +120,88 @@ func TestSystemd_Optional(t *testing.T) {
 	assert.True(t, NewSystemd("test", true, "systemd", time.Minute).Optional())
 }
 
+func TestSystemd_CollectFallsBackToLogFileWhenJournalCouldNotBeRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal-fallback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "mesos-master.log")
+	require.NoError(t, ioutil.WriteFile(logFile, []byte("I0102 mesos master started\n"), 0600))
+
+	c := NewSystemd("dcos-mesos-master.service", false, "dcos-mesos-master.service", time.Hour).WithFallbackFile(logFile)
+	c.readJournal = func(ctx context.Context, unit string, duration time.Duration) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("journald is not running")
+	}
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, "# could not read dcos-mesos-master.service logs from journal: journald is not running, "+
+		"read from "+logFile+" instead\nI0102 mesos master started\n", string(data))
+}
+
+func TestSystemd_CollectFailsWhenJournalAndFallbackCouldNotBeRead(t *testing.T) {
+	c := NewSystemd("test", false, "test.service", time.Hour)
+	c.readJournal = func(ctx context.Context, unit string, duration time.Duration) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("journald is not running")
+	}
+
+	_, err := c.Collect(context.TODO())
+	assert.EqualError(t, err, "could not read test.service logs from journal: journald is not running")
+
+	_, err = c.WithFallbackFile("/not/existing.log").Collect(context.TODO())
+	assert.EqualError(t, err, "could not read test.service logs from journal: journald is not running; "+
+		"could not read fallback log file: open /not/existing.log: no such file or directory")
+}
+
+func TestParseJournalFallbackFiles(t *testing.T) {
+	files, err := ParseJournalFallbackFiles([]string{"dcos-mesos-master.service=/var/log/mesos/master.log", " kubelet.service = /var/log/kubelet.log"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"dcos-mesos-master.service": "/var/log/mesos/master.log",
+		"kubelet.service":           "/var/log/kubelet.log",
+	}, files)
+
+	_, err = ParseJournalFallbackFiles([]string{"kubelet.service"})
+	assert.EqualError(t, err, `invalid journal fallback file "kubelet.service", expected unit=path`)
+
+	_, err = ParseJournalFallbackFiles([]string{"a=/a.log", "a=/b.log"})
+	assert.EqualError(t, err, "duplicated journal fallback file of a")
+}
+
 func TestEndpointIsCollector(t *testing.T) {
 	assert.Implements(t, (*Collector)(nil), new(Endpoint))
 }
 
 func TestEndpoint_Name(t *testing.T) {
-	assert.Equal(t, "test", NewEndpoint("test", false, "", nil).Name())
+	c, err := NewEndpoint("test", false, "", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "test", c.Name())
 }
 
 func TestEndpoint_Optional(t *testing.T) {
-	assert.False(t, NewEndpoint("test", false, "", nil).Optional())
-	assert.True(t, NewEndpoint("test", true, "", nil).Optional())
+	c, err := NewEndpoint("test", false, "", nil, nil)
+	require.NoError(t, err)
+	assert.False(t, c.Optional())
+	c, err = NewEndpoint("test", true, "", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, c.Optional())
 }
 
 func TestEndpoint_Collect(t *testing.T) {
 	server, _ := stubServer("/ping", "OK")
 	defer server.Close()
 
-	c := NewEndpoint(
+	c, err := NewEndpoint(
 		"ping",
 		false,
 		server.URL+"/ping",
 		http.DefaultClient,
+		nil,
 	)
+	require.NoError(t, err)
 	r, err := c.Collect(context.TODO())
 
 	require.NoError(t, err)
@@ -110,12 +211,14 @@ func TestEndpoint_Collect(t *testing.T) {
 
 	assert.Equal(t, "OK", string(raw))
 
-	c = NewEndpoint(
+	c, err = NewEndpoint(
 		"test",
 		false,
 		server.URL+"/test",
 		http.DefaultClient,
+		nil,
 	)
+	require.NoError(t, err)
 	r, err = c.Collect(context.TODO())
 
 	assert.EqualError(t, err, fmt.Sprintf("unable to fetch %s. Return code 404. Body: 404 page not found\n", server.URL+"/test"))
@@ -125,12 +228,14 @@ func TestEndpoint_CollectShouldReturnErrorWhen404(t *testing.T) {
 	server, _ := stubServer("/ping", "OK")
 	defer server.Close()
 
-	c := NewEndpoint(
+	c, err := NewEndpoint(
 		"test",
 		false,
 		server.URL+"/test",
 		http.DefaultClient,
+		nil,
 	)
+	require.NoError(t, err)
 	r, err := c.Collect(context.TODO())
 
 	assert.Nil(t, r)
@@ -139,12 +244,14 @@ func TestEndpoint_CollectShouldReturnErrorWhen404(t *testing.T) {
 
 func TestEndpoint_CollectShouldReturnErroronTimeout(t *testing.T) {
 	http.DefaultClient.Timeout = time.Nanosecond
-	c := NewEndpoint(
+	c, err := NewEndpoint(
 		"test",
 		false,
 		"http://192.0.2.0/test",
 		http.DefaultClient,
+		nil,
 	)
+	require.NoError(t, err)
 	r, err := c.Collect(context.TODO())
 
 	assert.Nil(t, r)
@@ -153,12 +260,14 @@ func TestEndpoint_CollectShouldReturnErroronTimeout(t *testing.T) {
 
 func TestEndpoint_CollectShouldReturnErrorWhenInvalidURL(t *testing.T) {
 	http.DefaultClient.Timeout = time.Nanosecond
-	c := NewEndpoint(
+	c, err := NewEndpoint(
 		"test",
 		false,
 		"invalid url",
 		http.DefaultClient,
+		nil,
 	)
+	require.NoError(t, err)
 	r, err := c.Collect(context.TODO())
 
 	assert.Nil(t, r)
@@ -268,3 +377,155 @@ func TestFile_CollectContextDont(t *testing.T) {
 
 	assert.NoError(t, reader.Close())
 }
+
+func TestSortByPriority(t *testing.T) {
+	low := WithPriority(NewCmd("low", false, nil), -1)
+	first := NewCmd("first", false, nil)
+	second := NewFile("second", false, "")
+	high := WithPriority(NewCmd("high", false, nil), PriorityHigh)
+
+	collectors := []Collector{low, first, high, second}
+	sorted := SortByPriority(collectors)
+
+	assert.Equal(t, []Collector{high, first, second, low}, sorted)
+	assert.Equal(t, []Collector{low, first, high, second}, collectors, "input should not be modified")
+
+	assert.Equal(t, PriorityHigh, Priority(high))
+	assert.Equal(t, PriorityDefault, Priority(first))
+	assert.Equal(t, "high", high.Name())
+}
+
+func TestRotatedIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(Rotated))
+}
+
+func TestRotatedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotated")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	files := map[string]time.Duration{
+		"foo.log":      0,
+		"foo.log.1":    time.Hour,
+		"foo.log.2.gz": 2 * time.Hour,
+		"foo.log.3.gz": 3 * time.Hour,
+		"foo.log-old":  4 * time.Hour,
+		"bar.log.1":    time.Minute,
+	}
+	for name, age := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(name), 0600))
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "foo.log.d"), 0700))
+
+	got, err := RotatedFiles(filepath.Join(dir, "foo.log"), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "foo.log.1"),
+		filepath.Join(dir, "foo.log.2.gz"),
+		filepath.Join(dir, "foo.log.3.gz"),
+	}, got)
+
+	got, err = RotatedFiles(filepath.Join(dir, "foo.log"), "foo.log-*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "foo.log-old")}, got)
+}
+
+func TestEnvironmentIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(Environment))
+}
+
+func TestEnvironment_Collect(t *testing.T) {
+	c := NewEnvironment("environment.json", true)
+	assert.Equal(t, "environment.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+
+	var info EnvironmentInfo
+	require.NoError(t, json.NewDecoder(r).Decode(&info))
+	assert.Equal(t, runtime.GOOS, info.OS)
+	assert.Equal(t, runtime.GOARCH, info.Arch)
+	assert.Equal(t, runtime.NumCPU(), info.CPUs)
+}
+
+func TestSandboxesIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(Sandboxes))
+}
+
+func TestSandboxes_CollectListsWorkDirUpToMaxDepth(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "mesos-work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	run := filepath.Join(workDir, "slaves", "S0", "frameworks", "F0", "executors", "E0", "runs", "R0")
+	require.NoError(t, os.MkdirAll(run, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(run, "stdout"), []byte("OK"), 0600))
+
+	modified := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(workDir, "slaves"), modified, modified))
+
+	c := NewSandboxes("mesos/sandboxes.json", true, workDir, 3, 100)
+	assert.Equal(t, "mesos/sandboxes.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+
+	var listing SandboxListing
+	require.NoError(t, json.NewDecoder(r).Decode(&listing))
+
+	assert.Equal(t, workDir, listing.Root)
+	assert.Equal(t, 3, listing.MaxDepth)
+	assert.False(t, listing.Truncated)
+
+	var paths []string
+	for _, e := range listing.Entries {
+		paths = append(paths, e.Path)
+		assert.True(t, e.Dir)
+	}
+	assert.Equal(t, []string{"slaves", "slaves/S0", "slaves/S0/frameworks"}, paths)
+	assert.True(t, modified.Equal(listing.Entries[0].Modified))
+
+	c = NewSandboxes("mesos/sandboxes.json", true, workDir, 10, 100)
+	r, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+	require.NoError(t, json.NewDecoder(r).Decode(&listing))
+
+	last := listing.Entries[len(listing.Entries)-1]
+	assert.Equal(t, SandboxEntry{
+		Path:     "slaves/S0/frameworks/F0/executors/E0/runs/R0/stdout",
+		Size:     2,
+		Modified: last.Modified,
+	}, last)
+}
+
+func TestSandboxes_CollectTruncatesListing(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "mesos-work-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, fmt.Sprintf("file-%d", i)), nil, 0600))
+	}
+
+	r, err := NewSandboxes("mesos/sandboxes.json", true, workDir, 8, 3).Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+
+	var listing SandboxListing
+	require.NoError(t, json.NewDecoder(r).Decode(&listing))
+	assert.True(t, listing.Truncated)
+	assert.Len(t, listing.Entries, 3)
+}
+
+func TestSandboxes_CollectReturnsErrorWhenWorkDirIsMissing(t *testing.T) {
+	_, err := NewSandboxes("mesos/sandboxes.json", true, "/not/existing/work/dir", 8, 100).Collect(context.TODO())
+	assert.Error(t, err)
+}
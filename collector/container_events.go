@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContainerEventsReport is a timeline of container lifecycle events reported by the container runtime
+type ContainerEventsReport struct {
+	Runtime string    `json:"runtime"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	// Truncated is set when only the latest events were kept
+	Truncated  bool                    `json:"truncated,omitempty"`
+	Events     []ContainerEvent        `json:"events"`
+	Containers []ContainerEventSummary `json:"containers"`
+	// Malformed is a number of events that could not be parsed
+	Malformed int `json:"malformed,omitempty"`
+}
+
+// ContainerEvent is a single lifecycle event of a container e.g., start, die or oom
+type ContainerEvent struct {
+	Time        time.Time `json:"time"`
+	ContainerID string    `json:"container_id"`
+	Name        string    `json:"name,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Action      string    `json:"action"`
+	ExitCode    *int      `json:"exit_code,omitempty"`
+}
+
+// ContainerEventSummary correlates events of a single container so containers killed by the OOM killer or
+// restarted many times stand out
+type ContainerEventSummary struct {
+	ContainerID  string    `json:"container_id"`
+	Name         string    `json:"name,omitempty"`
+	Events       int       `json:"events"`
+	OOMKills     int       `json:"oom_kills"`
+	Restarts     int       `json:"restarts"`
+	Deaths       int       `json:"deaths"`
+	LastExitCode *int      `json:"last_exit_code,omitempty"`
+	LastEvent    time.Time `json:"last_event"`
+}
+
+// dockerEvent is an event printed by docker events with the JSON format
+type dockerEvent struct {
+	Type     string `json:"Type"`
+	Action   string `json:"Action"`
+	TimeNano int64  `json:"timeNano"`
+	Actor    struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// ContainerEvents is a struct implementing Collector interface. It collects container events of the given
+// period before the collection reported by docker events. Only the latest maxEvents events are kept.
+type ContainerEvents struct {
+	name      string
+	optional  bool
+	command   string
+	period    time.Duration
+	maxEvents int
+	now       func() time.Time
+}
+
+func NewContainerEvents(name string, optional bool, period time.Duration, maxEvents int) *ContainerEvents {
+	return &ContainerEvents{
+		name:      name,
+		optional:  optional,
+		command:   "docker",
+		period:    period,
+		maxEvents: maxEvents,
+		now:       time.Now,
+	}
+}
+
+func (c ContainerEvents) Name() string {
+	return c.name
+}
+
+func (c ContainerEvents) Optional() bool {
+	return c.optional
+}
+
+func (c ContainerEvents) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	until := c.now().UTC().Truncate(time.Second)
+	since := until.Add(-c.period)
+
+	// with --until docker prints events stored so far and exits instead of streaming new ones
+	cmd := exec.CommandContext(ctx, c.command, "events",
+		"--since", strconv.FormatInt(since.Unix(), 10),
+		"--until", strconv.FormatInt(until.Unix(), 10),
+		"--filter", "type=container",
+		"--format", "{{json .}}")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not get container events: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	report := parseDockerEvents(output, c.maxEvents)
+	report.Runtime = "docker"
+	report.Since = since
+	report.Until = until
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// parseDockerEvents returns container events printed by docker events one JSON object per line
+func parseDockerEvents(output []byte, maxEvents int) ContainerEventsReport {
+	report := ContainerEventsReport{Events: []ContainerEvent{}, Containers: []ContainerEventSummary{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e dockerEvent
+		if err := json.Unmarshal(line, &e); err != nil || e.Actor.ID == "" {
+			report.Malformed++
+			continue
+		}
+		if e.Type != "" && e.Type != "container" {
+			continue
+		}
+		event := ContainerEvent{
+			Time:        time.Unix(0, e.TimeNano).UTC(),
+			ContainerID: e.Actor.ID,
+			Name:        e.Actor.Attributes["name"],
+			Image:       e.Actor.Attributes["image"],
+			Action:      e.Action,
+		}
+		if code, err := strconv.Atoi(e.Actor.Attributes["exitCode"]); err == nil {
+			event.ExitCode = &code
+		}
+		report.Events = append(report.Events, event)
+	}
+
+	sort.SliceStable(report.Events, func(i, j int) bool {
+		return report.Events[i].Time.Before(report.Events[j].Time)
+	})
+	if maxEvents > 0 && len(report.Events) > maxEvents {
+		report.Events = report.Events[len(report.Events)-maxEvents:]
+		report.Truncated = true
+	}
+
+	report.Containers = summarizeContainerEvents(report.Events)
+	return report
+}
+
+// summarizeContainerEvents counts events of every container, containers are sorted by their last event
+func summarizeContainerEvents(events []ContainerEvent) []ContainerEventSummary {
+	byID := make(map[string]*ContainerEventSummary)
+	var order []string
+	for _, e := range events {
+		s, ok := byID[e.ContainerID]
+		if !ok {
+			s = &ContainerEventSummary{ContainerID: e.ContainerID}
+			byID[e.ContainerID] = s
+			order = append(order, e.ContainerID)
+		}
+		s.Events++
+		if e.Name != "" {
+			s.Name = e.Name
+		}
+		s.LastEvent = e.Time
+		switch e.Action {
+		case "oom":
+			s.OOMKills++
+		case "restart":
+			s.Restarts++
+		case "die":
+			s.Deaths++
+			s.LastExitCode = e.ExitCode
+		}
+	}
+
+	summaries := make([]ContainerEventSummary, 0, len(order))
+	for _, id := range order {
+		summaries = append(summaries, *byID[id])
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].LastEvent.Before(summaries[j].LastEvent)
+	})
+	return summaries
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stubbedDockerEvents = `{"status":"start","id":"c1","from":"nginx","Type":"container","Action":"start","Actor":{"ID":"c1","Attributes":{"image":"nginx","name":"web"}},"scope":"local","time":1600000000,"timeNano":1600000000000000000}
+{"status":"oom","id":"c1","from":"nginx","Type":"container","Action":"oom","Actor":{"ID":"c1","Attributes":{"image":"nginx","name":"web"}},"scope":"local","time":1600000060,"timeNano":1600000060000000000}
+{"status":"die","id":"c1","from":"nginx","Type":"container","Action":"die","Actor":{"ID":"c1","Attributes":{"exitCode":"137","image":"nginx","name":"web"}},"scope":"local","time":1600000061,"timeNano":1600000061000000000}
+not an event
+{"status":"restart","id":"c2","from":"redis","Type":"container","Action":"restart","Actor":{"ID":"c2","Attributes":{"image":"redis","name":"cache"}},"scope":"local","time":1600000030,"timeNano":1600000030000000000}
+`
+
+func TestContainerEventsIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(ContainerEvents))
+}
+
+func TestContainerEvents_CollectStructuredEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container-events")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the stub records its arguments and prints events as docker events does
+	stub := filepath.Join(dir, "docker")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "events.json"), []byte(stubbedDockerEvents), 0600))
+	require.NoError(t, ioutil.WriteFile(stub, []byte("#!/bin/sh\n"+
+		"echo \"$@\" > "+filepath.Join(dir, "args.txt")+"\n"+
+		"cat "+filepath.Join(dir, "events.json")+"\n"), 0700))
+
+	c := NewContainerEvents("containers/events.json", true, time.Hour, 100)
+	c.command = stub
+	c.now = func() time.Time { return time.Unix(1600000100, 0) }
+	assert.Equal(t, "containers/events.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "events --since 1599996500 --until 1600000100 --filter type=container --format {{json .}}",
+		strings.TrimSpace(string(args)))
+
+	var report ContainerEventsReport
+	require.NoError(t, json.Unmarshal(raw, &report))
+
+	exitCode := 137
+	assert.Equal(t, ContainerEventsReport{
+		Runtime:   "docker",
+		Since:     time.Unix(1599996500, 0).UTC(),
+		Until:     time.Unix(1600000100, 0).UTC(),
+		Malformed: 1,
+		Events: []ContainerEvent{
+			{Time: time.Unix(1600000000, 0).UTC(), ContainerID: "c1", Name: "web", Image: "nginx", Action: "start"},
+			{Time: time.Unix(1600000030, 0).UTC(), ContainerID: "c2", Name: "cache", Image: "redis", Action: "restart"},
+			{Time: time.Unix(1600000060, 0).UTC(), ContainerID: "c1", Name: "web", Image: "nginx", Action: "oom"},
+			{Time: time.Unix(1600000061, 0).UTC(), ContainerID: "c1", Name: "web", Image: "nginx", Action: "die", ExitCode: &exitCode},
+		},
+		Containers: []ContainerEventSummary{
+			{ContainerID: "c2", Name: "cache", Events: 1, Restarts: 1, LastEvent: time.Unix(1600000030, 0).UTC()},
+			{ContainerID: "c1", Name: "web", Events: 3, OOMKills: 1, Deaths: 1, LastExitCode: &exitCode,
+				LastEvent: time.Unix(1600000061, 0).UTC()},
+		},
+	}, report)
+}
+
+func TestParseDockerEventsKeepsLatestEvents(t *testing.T) {
+	report := parseDockerEvents([]byte(stubbedDockerEvents), 2)
+
+	assert.True(t, report.Truncated)
+	require.Len(t, report.Events, 2)
+	assert.Equal(t, "oom", report.Events[0].Action)
+	assert.Equal(t, "die", report.Events[1].Action)
+	assert.Len(t, report.Containers, 1)
+}
+
+func TestContainerEvents_FailsWhenRuntimeIsNotAvailable(t *testing.T) {
+	c := NewContainerEvents("containers/events.json", true, time.Hour, 100)
+	c.command = "/nonexistent/docker"
+
+	_, err := c.Collect(context.TODO())
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// ContainerStorageReport describes union filesystems used by containers so storage exhaustion could be debugged
+type ContainerStorageReport struct {
+	Runtime string `json:"runtime"`
+	// Driver, DriverStatus and RootDir are reported by the container runtime, empty when it is not available
+	Driver       string            `json:"driver,omitempty"`
+	DriverStatus [][2]string       `json:"driver_status,omitempty"`
+	RootDir      string            `json:"root_dir,omitempty"`
+	Mounts       []UnionMount      `json:"mounts"`
+	Containers   []ContainerLayers `json:"containers"`
+	// Truncated is set when only the first maxEntries mounts or containers were kept
+	Truncated bool `json:"truncated,omitempty"`
+	// Errors are failures of commands that did not prevent the rest of the report
+	Errors []string `json:"errors,omitempty"`
+}
+
+// UnionMount is a mount of an overlay or other union filesystem
+type UnionMount struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+	// LowerDirs is the number of read-only layers of the mount
+	LowerDirs int    `json:"lower_dirs"`
+	UpperDir  string `json:"upper_dir,omitempty"`
+	WorkDir   string `json:"work_dir,omitempty"`
+}
+
+// ContainerLayers is the size of the writable layer of the container and of all its layers as reported by the runtime
+type ContainerLayers struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Image       string `json:"image,omitempty"`
+	State       string `json:"state,omitempty"`
+	Size        string `json:"size,omitempty"`
+	VirtualSize string `json:"virtual_size,omitempty"`
+}
+
+// dockerInfo is a part of docker info printed with the JSON format
+type dockerInfo struct {
+	Driver        string      `json:"Driver"`
+	DriverStatus  [][2]string `json:"DriverStatus"`
+	DockerRootDir string      `json:"DockerRootDir"`
+}
+
+// dockerContainer is a container printed by docker ps with the JSON format
+type dockerContainer struct {
+	ID    string `json:"ID"`
+	Names string `json:"Names"`
+	Image string `json:"Image"`
+	State string `json:"State"`
+	Size  string `json:"Size"`
+}
+
+// mountLine matches lines printed by mount e.g., overlay on /merged type overlay (rw,lowerdir=/l1:/l2)
+var mountLine = regexp.MustCompile(`^(\S+) on (\S+) type (\S+) \((.*)\)$`)
+
+// ContainerStorage is a struct implementing Collector interface. It collects union filesystem mounts, the storage
+// driver status of the container runtime and sizes of container layers. At most maxEntries mounts and containers
+// are kept. When the runtime is not available only mounts are reported.
+type ContainerStorage struct {
+	name         string
+	optional     bool
+	command      string
+	mountCommand string
+	maxEntries   int
+}
+
+func NewContainerStorage(name string, optional bool, maxEntries int) *ContainerStorage {
+	return &ContainerStorage{
+		name:         name,
+		optional:     optional,
+		command:      "docker",
+		mountCommand: "mount",
+		maxEntries:   maxEntries,
+	}
+}
+
+func (c ContainerStorage) Name() string {
+	return c.name
+}
+
+func (c ContainerStorage) Optional() bool {
+	return c.optional
+}
+
+func (c ContainerStorage) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	report := ContainerStorageReport{Runtime: "docker", Mounts: []UnionMount{}, Containers: []ContainerLayers{}}
+
+	mounts, mountErr := c.run(ctx, c.mountCommand)
+	if mountErr != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not list mounts: %s", mountErr))
+	} else {
+		report.Mounts, report.Truncated = parseUnionMounts(mounts, c.maxEntries)
+	}
+
+	info, err := c.run(ctx, c.command, "info", "--format", "{{json .}}")
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not get storage driver status: %s", err))
+	} else {
+		var i dockerInfo
+		if err := json.Unmarshal(bytes.TrimSpace(info), &i); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("could not parse storage driver status: %s", err))
+		} else {
+			report.Driver, report.DriverStatus, report.RootDir = i.Driver, i.DriverStatus, i.DockerRootDir
+		}
+	}
+
+	containers, psErr := c.run(ctx, c.command, "ps", "--all", "--no-trunc", "--size", "--format", "{{json .}}")
+	if psErr != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not get container sizes: %s", psErr))
+	} else {
+		var truncated bool
+		report.Containers, truncated = parseContainerLayers(containers, c.maxEntries)
+		report.Truncated = report.Truncated || truncated
+	}
+
+	if mountErr != nil && psErr != nil {
+		return nil, fmt.Errorf("could not get container storage: %s", strings.Join(report.Errors, "; "))
+	}
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+func (c ContainerStorage) run(ctx context.Context, command string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// parseUnionMounts returns overlay, aufs and fuse-overlayfs mounts printed by mount
+func parseUnionMounts(output []byte, maxEntries int) ([]UnionMount, bool) {
+	mounts := []UnionMount{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		m := mountLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil || !isUnionFilesystem(m[3]) {
+			continue
+		}
+		if maxEntries > 0 && len(mounts) == maxEntries {
+			return mounts, true
+		}
+		mount := UnionMount{Source: m[1], Target: m[2], Type: m[3]}
+		for _, option := range strings.Split(m[4], ",") {
+			parts := strings.SplitN(option, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "lowerdir":
+				mount.LowerDirs = len(strings.Split(parts[1], ":"))
+			case "upperdir":
+				mount.UpperDir = parts[1]
+			case "workdir":
+				mount.WorkDir = parts[1]
+			}
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, false
+}
+
+func isUnionFilesystem(fsType string) bool {
+	return strings.Contains(fsType, "overlay") || fsType == "aufs"
+}
+
+// parseContainerLayers returns sizes of containers printed by docker ps one JSON object per line.
+// Sizes are printed as "2B (virtual 187MB)".
+func parseContainerLayers(output []byte, maxEntries int) ([]ContainerLayers, bool) {
+	containers := []ContainerLayers{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var c dockerContainer
+		if err := json.Unmarshal(line, &c); err != nil || c.ID == "" {
+			continue
+		}
+		if maxEntries > 0 && len(containers) == maxEntries {
+			return containers, true
+		}
+		layers := ContainerLayers{ID: c.ID, Name: c.Names, Image: c.Image, State: c.State, Size: c.Size}
+		if i := strings.Index(c.Size, " (virtual "); i >= 0 {
+			layers.Size = c.Size[:i]
+			layers.VirtualSize = strings.TrimSuffix(c.Size[i+len(" (virtual "):], ")")
+		}
+		containers = append(containers, layers)
+	}
+	return containers, false
+}
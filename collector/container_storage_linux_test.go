@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stubbedMounts = `/dev/sda1 on / type ext4 (rw,relatime)
+overlay on /var/lib/docker/overlay2/abc/merged type overlay (rw,relatime,lowerdir=/var/lib/docker/overlay2/l/A:/var/lib/docker/overlay2/l/B,upperdir=/var/lib/docker/overlay2/abc/diff,workdir=/var/lib/docker/overlay2/abc/work)
+tmpfs on /run type tmpfs (rw,nosuid,nodev)
+overlay on /var/lib/docker/overlay2/def/merged type overlay (rw,relatime,lowerdir=/var/lib/docker/overlay2/l/C,upperdir=/var/lib/docker/overlay2/def/diff,workdir=/var/lib/docker/overlay2/def/work)
+`
+
+const stubbedDockerInfo = `{"Driver":"overlay2","DriverStatus":[["Backing Filesystem","extfs"],["Supports d_type","true"]],"DockerRootDir":"/var/lib/docker"}`
+
+const stubbedDockerPs = `{"ID":"c1","Names":"web","Image":"nginx","State":"running","Size":"2B (virtual 187MB)"}
+{"ID":"c2","Names":"cache","Image":"redis","State":"exited","Size":"1.5GB (virtual 1.6GB)"}
+`
+
+// writeStub writes an executable shell script printing outputs depending on its first argument.
+// The output under an empty argument is printed for any other argument.
+func writeStub(t *testing.T, dir string, name string, outputs map[string]string) string {
+	script := "#!/bin/sh\ncase \"$1\" in\n"
+	fallback := "*) echo unknown command >&2; exit 1 ;;\n"
+	for arg, output := range outputs {
+		file := filepath.Join(dir, name+"-"+arg+".out")
+		require.NoError(t, ioutil.WriteFile(file, []byte(output), 0600))
+		if arg == "" {
+			fallback = "*) cat " + file + " ;;\n"
+			continue
+		}
+		script += arg + ") cat " + file + " ;;\n"
+	}
+	script += fallback + "esac\n"
+	stub := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(stub, []byte(script), 0700))
+	return stub
+}
+
+func TestContainerStorageIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(ContainerStorage))
+}
+
+func TestContainerStorage_CollectMountsDriverAndLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container-storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewContainerStorage("containers/storage.json", true, 100)
+	c.mountCommand = writeStub(t, dir, "mount", map[string]string{"": stubbedMounts})
+	c.command = writeStub(t, dir, "docker", map[string]string{"info": stubbedDockerInfo, "ps": stubbedDockerPs})
+	assert.Equal(t, "containers/storage.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	var report ContainerStorageReport
+	require.NoError(t, json.Unmarshal(raw, &report))
+	assert.Equal(t, ContainerStorageReport{
+		Runtime:      "docker",
+		Driver:       "overlay2",
+		DriverStatus: [][2]string{{"Backing Filesystem", "extfs"}, {"Supports d_type", "true"}},
+		RootDir:      "/var/lib/docker",
+		Mounts: []UnionMount{
+			{Source: "overlay", Target: "/var/lib/docker/overlay2/abc/merged", Type: "overlay", LowerDirs: 2,
+				UpperDir: "/var/lib/docker/overlay2/abc/diff", WorkDir: "/var/lib/docker/overlay2/abc/work"},
+			{Source: "overlay", Target: "/var/lib/docker/overlay2/def/merged", Type: "overlay", LowerDirs: 1,
+				UpperDir: "/var/lib/docker/overlay2/def/diff", WorkDir: "/var/lib/docker/overlay2/def/work"},
+		},
+		Containers: []ContainerLayers{
+			{ID: "c1", Name: "web", Image: "nginx", State: "running", Size: "2B", VirtualSize: "187MB"},
+			{ID: "c2", Name: "cache", Image: "redis", State: "exited", Size: "1.5GB", VirtualSize: "1.6GB"},
+		},
+	}, report)
+}
+
+func TestContainerStorage_ReportsMountsWithoutRuntime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container-storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewContainerStorage("containers/storage.json", true, 1)
+	c.mountCommand = writeStub(t, dir, "mount", map[string]string{"": stubbedMounts})
+	c.command = filepath.Join(dir, "missing-docker")
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	var report ContainerStorageReport
+	require.NoError(t, json.Unmarshal(raw, &report))
+	assert.True(t, report.Truncated)
+	assert.Len(t, report.Mounts, 1)
+	assert.Empty(t, report.Containers)
+	assert.Empty(t, report.Driver)
+	assert.Len(t, report.Errors, 2)
+}
+
+func TestContainerStorage_FailsWithoutMountsAndRuntime(t *testing.T) {
+	c := NewContainerStorage("containers/storage.json", true, 100)
+	c.mountCommand = "/nonexistent/mount"
+	c.command = "/nonexistent/docker"
+
+	_, err := c.Collect(context.TODO())
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goio "io"
+	"io/ioutil"
+	"runtime"
+)
+
+// EnvironmentInfo describes the node the bundle was collected on. Fields that could not be read
+// on the platform are left empty.
+type EnvironmentInfo struct {
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+	Kernel      string `json:"kernel,omitempty"`
+	Distro      string `json:"distro,omitempty"`
+	CPUs        int    `json:"cpus"`
+	MemoryTotal uint64 `json:"memory_total_bytes,omitempty"`
+}
+
+// Environment is a struct implementing Collector interface. It collects basic information about
+// the node operating system and hardware as JSON.
+type Environment struct {
+	name     string
+	optional bool
+}
+
+func NewEnvironment(name string, optional bool) *Environment {
+	return &Environment{
+		name:     name,
+		optional: optional,
+	}
+}
+
+func (c Environment) Name() string {
+	return c.name
+}
+
+func (c Environment) Optional() bool {
+	return c.optional
+}
+
+func (c Environment) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	info := EnvironmentInfo{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		CPUs: runtime.NumCPU(),
+	}
+	readPlatformEnvironment(ctx, &info)
+
+	raw, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
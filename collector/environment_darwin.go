@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// readPlatformEnvironment fills kernel, distribution and memory using sysctl and sw_vers
+func readPlatformEnvironment(ctx context.Context, info *EnvironmentInfo) {
+	if kernel, err := exec.CommandContext(ctx, "sysctl", "-n", "kern.osrelease").Output(); err == nil {
+		info.Kernel = strings.TrimSpace(string(kernel))
+	}
+	if version, err := exec.CommandContext(ctx, "sw_vers", "-productVersion").Output(); err == nil {
+		info.Distro = "macOS " + strings.TrimSpace(string(version))
+	}
+	if memory, err := exec.CommandContext(ctx, "sysctl", "-n", "hw.memsize").Output(); err == nil {
+		if total, err := strconv.ParseUint(strings.TrimSpace(string(memory)), 10, 64); err == nil {
+			info.MemoryTotal = total
+		}
+	}
+}
+
+// readDistroID returns empty ID as macOS has no distributions
+func readDistroID() string {
+	return ""
+}
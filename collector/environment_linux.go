@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	kernelReleaseFile = "/proc/sys/kernel/osrelease"
+	osReleaseFile     = "/etc/os-release"
+	memInfoFile       = "/proc/meminfo"
+)
+
+// readPlatformEnvironment fills kernel, distribution and memory from procfs and os-release
+func readPlatformEnvironment(_ context.Context, info *EnvironmentInfo) {
+	if kernel, err := ioutil.ReadFile(kernelReleaseFile); err == nil {
+		info.Kernel = strings.TrimSpace(string(kernel))
+	}
+	if f, err := os.Open(osReleaseFile); err == nil {
+		info.Distro = parseOSRelease(f)
+		f.Close()
+	}
+	if f, err := os.Open(memInfoFile); err == nil {
+		info.MemoryTotal = parseMemTotal(f)
+		f.Close()
+	}
+}
+
+// readDistroID returns the distribution ID from os-release e.g., centos, empty when it is unknown
+func readDistroID() string {
+	f, err := os.Open(osReleaseFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return parseOSReleaseField(f, "ID")
+}
+
+// parseOSRelease returns PRETTY_NAME from os-release(5) content
+func parseOSRelease(r goio.Reader) string {
+	return parseOSReleaseField(r, "PRETTY_NAME")
+}
+
+// parseOSReleaseField returns the value of the field from os-release(5) content
+func parseOSReleaseField(r goio.Reader, field string) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, field+"=") {
+			continue
+		}
+		value := strings.TrimPrefix(line, field+"=")
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return strings.Trim(value, `'"`)
+	}
+	return ""
+}
+
+// parseMemTotal returns MemTotal from /proc/meminfo content in bytes
+func parseMemTotal(r goio.Reader) uint64 {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
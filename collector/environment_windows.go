@@ -0,0 +1,19 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// readPlatformEnvironment fills the Windows version reported by ver
+func readPlatformEnvironment(ctx context.Context, info *EnvironmentInfo) {
+	if version, err := exec.CommandContext(ctx, "cmd", "/c", "ver").Output(); err == nil {
+		info.Kernel = strings.TrimSpace(string(version))
+	}
+}
+
+// readDistroID returns empty ID as Windows has no distributions
+func readDistroID() string {
+	return ""
+}
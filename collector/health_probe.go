@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// HealthProbe is a struct implementing Collector interface. It runs a site specific health probe script
+// and collects its stdout, stderr and exit code. A non zero exit code is the result of the probe so it is
+// collected as any other output, only a script that could not be started fails the collection.
+type HealthProbe struct {
+	name     string
+	optional bool
+	path     string
+	timeout  time.Duration
+	maxSize  int64
+}
+
+// NewHealthProbe returns a collector running the script at the path for at most timeout. Only the first
+// maxSize bytes of stdout and stderr are kept. The path must come from the daemon configuration and should
+// be checked with CheckHealthProbeScript first.
+func NewHealthProbe(name string, optional bool, path string, timeout time.Duration, maxSize int64) *HealthProbe {
+	return &HealthProbe{
+		name:     name,
+		optional: optional,
+		path:     path,
+		timeout:  timeout,
+		maxSize:  maxSize,
+	}
+}
+
+// CheckHealthProbeScript returns an error if the script at the path could not be trusted to run with
+// privileges of the daemon, see CheckTrustedExecutable.
+func CheckHealthProbeScript(path string) error {
+	return CheckTrustedExecutable("health probe script", path)
+}
+
+// CheckTrustedExecutable returns an error if the executable at the path could not be trusted to run with
+// privileges of the daemon: the path must be absolute and point to an executable regular file that is not
+// writable by group or others. The kind names the executable in errors.
+func CheckTrustedExecutable(kind string, path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("%s %s is not an absolute path", kind, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("could not stat %s: %s", kind, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s %s is not a regular file", kind, path)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s %s is not executable", kind, path)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s %s is writable by group or others", kind, path)
+	}
+	return nil
+}
+
+func (c HealthProbe) Name() string {
+	return c.name
+}
+
+func (c HealthProbe) Optional() bool {
+	return c.optional
+}
+
+func (c HealthProbe) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// output goes to files instead of pipes so children of the script that keep them open could not
+	// block the collection after the script is killed
+	stdout, err := ioutil.TempFile("", "health-probe-stdout")
+	if err != nil {
+		return nil, fmt.Errorf("could not create health probe output file: %s", err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+	stderr, err := ioutil.TempFile("", "health-probe-stderr")
+	if err != nil {
+		return nil, fmt.Errorf("could not create health probe output file: %s", err)
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	cmd := exec.CommandContext(ctx, c.path)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start health probe %s: %s", c.path, err)
+	}
+	err = cmd.Wait()
+
+	buf := bytes.NewBufferString(fmt.Sprintf("$ %s\n", c.path))
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		fmt.Fprintf(buf, "exit code: -1 (killed after %s)\n", c.timeout)
+	case err != nil && cmd.ProcessState == nil:
+		return nil, fmt.Errorf("could not run health probe %s: %s", c.path, err)
+	default:
+		fmt.Fprintf(buf, "exit code: %d\n", cmd.ProcessState.ExitCode())
+	}
+
+	for _, output := range []struct {
+		name string
+		f    *os.File
+	}{{"stdout", stdout}, {"stderr", stderr}} {
+		fmt.Fprintf(buf, "--- %s ---\n", output.name)
+		if err := c.copyOutput(buf, output.f); err != nil {
+			return nil, fmt.Errorf("could not read health probe %s: %s", output.name, err)
+		}
+	}
+
+	return ioutil.NopCloser(buf), nil
+}
+
+// copyOutput copies at most maxSize bytes of the output file and notes if the rest was truncated
+func (c HealthProbe) copyOutput(w *bytes.Buffer, f *os.File) error {
+	if _, err := f.Seek(0, goio.SeekStart); err != nil {
+		return err
+	}
+	var r goio.Reader = f
+	if c.maxSize > 0 {
+		r = goio.LimitReader(f, c.maxSize)
+	}
+	output, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(output) > 0 && !bytes.HasSuffix(output, []byte("\n")) {
+		output = append(output, '\n')
+	}
+	if _, err := w.Write(output); err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > c.maxSize && c.maxSize > 0 {
+		fmt.Fprintf(w, "[truncated %d of %d bytes]\n", info.Size()-c.maxSize, info.Size())
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProbeScript(t *testing.T, dir string, script string) string {
+	path := filepath.Join(dir, "probe.sh")
+	require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700))
+	return path
+}
+
+func TestHealthProbeIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(HealthProbe))
+}
+
+func TestHealthProbe_CollectOutputAndExitCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health-probe")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := writeProbeScript(t, dir, "echo mesos OK\necho 'zk: 2 of 3 peers' >&2\nexit 3\n")
+	require.NoError(t, CheckHealthProbeScript(path))
+
+	c := NewHealthProbe("custom/health-probe.txt", true, path, time.Second, 1024)
+	assert.Equal(t, "custom/health-probe.txt", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, "$ "+path+"\n"+
+		"exit code: 3\n"+
+		"--- stdout ---\nmesos OK\n"+
+		"--- stderr ---\nzk: 2 of 3 peers\n", string(data))
+}
+
+func TestHealthProbe_CollectTruncatesOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health-probe")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := writeProbeScript(t, dir, "printf 0123456789\n")
+
+	r, err := NewHealthProbe("custom/health-probe.txt", true, path, time.Second, 4).Collect(context.TODO())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, "$ "+path+"\n"+
+		"exit code: 0\n"+
+		"--- stdout ---\n0123\n[truncated 6 of 10 bytes]\n"+
+		"--- stderr ---\n", string(data))
+}
+
+func TestHealthProbe_CollectKillsProbeAfterTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health-probe")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := writeProbeScript(t, dir, "echo started\nexec sleep 10\n")
+
+	start := time.Now()
+	r, err := NewHealthProbe("custom/health-probe.txt", true, path, 100*time.Millisecond, 1024).Collect(context.TODO())
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, "$ "+path+"\n"+
+		"exit code: -1 (killed after 100ms)\n"+
+		"--- stdout ---\nstarted\n"+
+		"--- stderr ---\n", string(data))
+}
+
+func TestHealthProbe_CollectFailsWhenProbeCouldNotBeStarted(t *testing.T) {
+	_, err := NewHealthProbe("custom/health-probe.txt", true, "/not/existing/probe.sh", time.Second, 1024).Collect(context.TODO())
+	assert.Error(t, err)
+}
+
+func TestCheckHealthProbeScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health-probe")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := writeProbeScript(t, dir, "exit 0\n")
+
+	assert.NoError(t, CheckHealthProbeScript(path))
+	assert.EqualError(t, CheckHealthProbeScript("probe.sh"), "health probe script probe.sh is not an absolute path")
+	assert.EqualError(t, CheckHealthProbeScript(dir), "health probe script "+dir+" is not a regular file")
+
+	require.NoError(t, os.Chmod(path, 0600))
+	assert.EqualError(t, CheckHealthProbeScript(path), "health probe script "+path+" is not executable")
+
+	require.NoError(t, os.Chmod(path, 0777))
+	assert.EqualError(t, CheckHealthProbeScript(path), "health probe script "+path+" is writable by group or others")
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultJournaldConfig is the main configuration file of journald
+const DefaultJournaldConfig = "/etc/systemd/journald.conf"
+
+// DefaultJournaldDropInDirs are directories of journald configuration drop-ins in the order they are applied
+var DefaultJournaldDropInDirs = []string{
+	"/usr/lib/systemd/journald.conf.d",
+	"/run/systemd/journald.conf.d",
+	"/etc/systemd/journald.conf.d",
+}
+
+// journaldRotationSettings are journald options limiting how much and how long logs are kept
+var journaldRotationSettings = []string{
+	"Storage",
+	"SystemMaxUse", "SystemKeepFree", "SystemMaxFileSize", "SystemMaxFiles",
+	"RuntimeMaxUse", "RuntimeKeepFree", "RuntimeMaxFileSize", "RuntimeMaxFiles",
+	"MaxRetentionSec", "MaxFileSec",
+	"RateLimitIntervalSec", "RateLimitBurst",
+}
+
+// JournalHealth is a struct implementing Collector interface. It collects disk usage of the systemd journal,
+// the journald configuration and its effective rotation settings, so it is visible why logs of units might be
+// shorter than requested.
+type JournalHealth struct {
+	name       string
+	optional   bool
+	timeout    time.Duration
+	command    string
+	config     string
+	dropInDirs []string
+}
+
+func NewJournalHealth(name string, optional bool, timeout time.Duration) *JournalHealth {
+	return &JournalHealth{
+		name:       name,
+		optional:   optional,
+		timeout:    timeout,
+		command:    "journalctl",
+		config:     DefaultJournaldConfig,
+		dropInDirs: DefaultJournaldDropInDirs,
+	}
+}
+
+func (c JournalHealth) Name() string {
+	return c.name
+}
+
+func (c JournalHealth) Optional() bool {
+	return c.optional
+}
+
+func (c JournalHealth) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "$ %s --disk-usage\n", c.command)
+	usage, usageErr := c.diskUsage(ctx)
+	if usageErr != nil {
+		fmt.Fprintf(buf, "error: %s\n", usageErr)
+	} else {
+		buf.Write(usage)
+	}
+
+	files := c.configFiles()
+	settings := map[string]string{}
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			fmt.Fprintf(buf, "\n# %s\nerror: %s\n", f, err)
+			continue
+		}
+		fmt.Fprintf(buf, "\n# %s\n", f)
+		buf.Write(content)
+		parseJournaldConfig(content, settings)
+	}
+	if len(files) == 0 {
+		buf.WriteString("\n# no journald configuration found, defaults are used\n")
+	}
+
+	buf.WriteString("\nRotation settings:\n")
+	for _, key := range journaldRotationSettings {
+		value, ok := settings[key]
+		if !ok {
+			value = "(default)"
+		}
+		fmt.Fprintf(buf, "%s=%s\n", key, value)
+	}
+
+	if usageErr != nil && len(files) == 0 {
+		return nil, fmt.Errorf("could not get journal health: %s", usageErr)
+	}
+	return ioutil.NopCloser(buf), nil
+}
+
+func (c JournalHealth) diskUsage(ctx context.Context) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	output, err := exec.CommandContext(ctx, c.command, "--disk-usage").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+// configFiles returns the main configuration file and drop-ins that exist in the order they are applied
+func (c JournalHealth) configFiles() []string {
+	var files []string
+	if _, err := os.Stat(c.config); err == nil {
+		files = append(files, c.config)
+	}
+	for _, dir := range c.dropInDirs {
+		dropIns, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+		if err != nil {
+			continue
+		}
+		sort.Strings(dropIns)
+		files = append(files, dropIns...)
+	}
+	return files
+}
+
+// parseJournaldConfig sets options of the [Journal] section to settings, later assignments override earlier ones
+func parseJournaldConfig(content []byte, settings map[string]string) {
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line
+			continue
+		}
+		if section != "[Journal]" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		settings[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+}
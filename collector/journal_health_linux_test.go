@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalHealthIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(JournalHealth))
+}
+
+func TestJournalHealth_CollectDiskUsageAndRotationSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal-health")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stub := filepath.Join(dir, "journalctl")
+	require.NoError(t, ioutil.WriteFile(stub, []byte("#!/bin/sh\n"+
+		"echo \"Archived and active journals take up 3.9G in the file system.\"\n"), 0700))
+
+	config := filepath.Join(dir, "journald.conf")
+	require.NoError(t, ioutil.WriteFile(config, []byte("[Journal]\n#Storage=auto\nSystemMaxUse=4G\n"), 0600))
+	dropIns := filepath.Join(dir, "journald.conf.d")
+	require.NoError(t, os.MkdirAll(dropIns, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dropIns, "10-retention.conf"),
+		[]byte("[Journal]\nSystemMaxUse=1G\nMaxRetentionSec=1week\n"), 0600))
+
+	c := NewJournalHealth("systemd/journal-health.txt", true, time.Second)
+	c.command = stub
+	c.config = config
+	c.dropInDirs = []string{filepath.Join(dir, "missing"), dropIns}
+	assert.Equal(t, "systemd/journal-health.txt", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, "$ "+stub+" --disk-usage\n"+
+		"Archived and active journals take up 3.9G in the file system.\n"+
+		"\n# "+config+"\n"+
+		"[Journal]\n#Storage=auto\nSystemMaxUse=4G\n"+
+		"\n# "+filepath.Join(dropIns, "10-retention.conf")+"\n"+
+		"[Journal]\nSystemMaxUse=1G\nMaxRetentionSec=1week\n"+
+		"\nRotation settings:\n"+
+		"Storage=(default)\n"+
+		"SystemMaxUse=1G\n"+
+		"SystemKeepFree=(default)\n"+
+		"SystemMaxFileSize=(default)\n"+
+		"SystemMaxFiles=(default)\n"+
+		"RuntimeMaxUse=(default)\n"+
+		"RuntimeKeepFree=(default)\n"+
+		"RuntimeMaxFileSize=(default)\n"+
+		"RuntimeMaxFiles=(default)\n"+
+		"MaxRetentionSec=1week\n"+
+		"MaxFileSec=(default)\n"+
+		"RateLimitIntervalSec=(default)\n"+
+		"RateLimitBurst=(default)\n", string(output))
+}
+
+func TestJournalHealth_FailsWithoutJournalAndConfig(t *testing.T) {
+	c := NewJournalHealth("systemd/journal-health.txt", true, time.Second)
+	c.command = "/nonexistent/journalctl"
+	c.config = "/nonexistent/journald.conf"
+	c.dropInDirs = nil
+
+	_, err := c.Collect(context.TODO())
+	assert.Error(t, err)
+}
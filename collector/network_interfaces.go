@@ -0,0 +1,229 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// NetworkInterfacesReport holds statistics of network interfaces so NIC errors and drops could be spotted
+type NetworkInterfacesReport struct {
+	Interfaces []InterfaceStats `json:"interfaces"`
+	// Truncated is set when only the first maxInterfaces interfaces were kept
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// InterfaceStats are counters of the interface from /proc/net/dev, its link state from sysfs and driver
+// statistics printed by ethtool -S
+type InterfaceStats struct {
+	Name string `json:"name"`
+	// OperState, Carrier, Speed and MTU are read from /sys/class/net, they are left empty when they could not be read
+	// e.g., the speed of interfaces that are down
+	OperState string `json:"operstate,omitempty"`
+	Carrier   *bool  `json:"carrier,omitempty"`
+	SpeedMbps *int   `json:"speed_mbps,omitempty"`
+	MTU       *int   `json:"mtu,omitempty"`
+
+	Receive  InterfaceCounters `json:"receive"`
+	Transmit InterfaceCounters `json:"transmit"`
+
+	// Ethtool are driver statistics e.g., rx_crc_errors, nil when ethtool is disabled or failed
+	Ethtool      map[string]uint64 `json:"ethtool,omitempty"`
+	EthtoolError string            `json:"ethtool_error,omitempty"`
+}
+
+// InterfaceCounters are counters of one direction of the interface as reported in /proc/net/dev.
+// Frame is reported only for receive and Collisions and Carrier only for transmit.
+type InterfaceCounters struct {
+	Bytes      uint64 `json:"bytes"`
+	Packets    uint64 `json:"packets"`
+	Errors     uint64 `json:"errors"`
+	Dropped    uint64 `json:"dropped"`
+	FIFO       uint64 `json:"fifo"`
+	Frame      uint64 `json:"frame,omitempty"`
+	Collisions uint64 `json:"collisions,omitempty"`
+	Carrier    uint64 `json:"carrier,omitempty"`
+	Compressed uint64 `json:"compressed"`
+	Multicast  uint64 `json:"multicast,omitempty"`
+}
+
+// NetworkInterfaces is a struct implementing Collector interface. It collects counters of at most maxInterfaces
+// network interfaces with their link state and driver statistics printed by the ethtool command. Empty ethtool
+// command skips driver statistics.
+type NetworkInterfaces struct {
+	name           string
+	optional       bool
+	procNetDev     string
+	sysClassNet    string
+	ethtoolCommand string
+	maxInterfaces  int
+}
+
+func NewNetworkInterfaces(name string, optional bool, ethtoolCommand string, maxInterfaces int) *NetworkInterfaces {
+	return &NetworkInterfaces{
+		name:           name,
+		optional:       optional,
+		procNetDev:     "/proc/net/dev",
+		sysClassNet:    "/sys/class/net",
+		ethtoolCommand: ethtoolCommand,
+		maxInterfaces:  maxInterfaces,
+	}
+}
+
+func (c NetworkInterfaces) Name() string {
+	return c.name
+}
+
+func (c NetworkInterfaces) Optional() bool {
+	return c.optional
+}
+
+func (c NetworkInterfaces) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	dev, err := ioutil.ReadFile(c.procNetDev)
+	if err != nil {
+		return nil, fmt.Errorf("could not read interface statistics: %s", err)
+	}
+	report := NetworkInterfacesReport{}
+	report.Interfaces, report.Truncated, err = parseProcNetDev(dev, c.maxInterfaces)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		c.readLinkState(iface)
+		if c.ethtoolCommand == "" {
+			continue
+		}
+		if ctx.Err() != nil {
+			iface.EthtoolError = ctx.Err().Error()
+			continue
+		}
+		iface.Ethtool, err = c.ethtool(ctx, iface.Name)
+		if err != nil {
+			iface.EthtoolError = err.Error()
+		}
+	}
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// readLinkState fills the link state of the interface from sysfs, attributes that could not be read are skipped
+func (c NetworkInterfaces) readLinkState(iface *InterfaceStats) {
+	read := func(attribute string) (string, bool) {
+		raw, err := ioutil.ReadFile(filepath.Join(c.sysClassNet, iface.Name, attribute))
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(string(raw)), true
+	}
+	readInt := func(attribute string) (int, bool) {
+		value, ok := read(attribute)
+		if !ok {
+			return 0, false
+		}
+		i, err := strconv.Atoi(value)
+		return i, err == nil
+	}
+
+	if state, ok := read("operstate"); ok {
+		iface.OperState = state
+	}
+	if carrier, ok := readInt("carrier"); ok {
+		up := carrier == 1
+		iface.Carrier = &up
+	}
+	// the speed is -1 when the link is down or the driver does not report it
+	if speed, ok := readInt("speed"); ok && speed >= 0 {
+		iface.SpeedMbps = &speed
+	}
+	if mtu, ok := readInt("mtu"); ok {
+		iface.MTU = &mtu
+	}
+}
+
+// ethtool returns driver statistics of the interface printed by ethtool -S
+func (c NetworkInterfaces) ethtool(ctx context.Context, iface string) (map[string]uint64, error) {
+	cmd := exec.CommandContext(ctx, c.ethtoolCommand, "-S", iface)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseEthtoolStats(output), nil
+}
+
+// parseProcNetDev returns counters of at most maxInterfaces interfaces listed in /proc/net/dev. The first two lines
+// are headers and every other line is the interface name followed by a colon, 8 receive and 8 transmit counters.
+func parseProcNetDev(content []byte, maxInterfaces int) ([]InterfaceStats, bool, error) {
+	interfaces := []InterfaceStats{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 0; scanner.Scan(); line++ {
+		if line < 2 {
+			continue
+		}
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) != 16 {
+			return nil, false, fmt.Errorf("could not parse interface statistics %q: expected 16 counters", scanner.Text())
+		}
+		counters := make([]uint64, len(fields))
+		for i, f := range fields {
+			value, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("could not parse interface statistics %q: %s", scanner.Text(), err)
+			}
+			counters[i] = value
+		}
+		if maxInterfaces > 0 && len(interfaces) == maxInterfaces {
+			return interfaces, true, nil
+		}
+		interfaces = append(interfaces, InterfaceStats{
+			Name: strings.TrimSpace(parts[0]),
+			Receive: InterfaceCounters{
+				Bytes: counters[0], Packets: counters[1], Errors: counters[2], Dropped: counters[3],
+				FIFO: counters[4], Frame: counters[5], Compressed: counters[6], Multicast: counters[7],
+			},
+			Transmit: InterfaceCounters{
+				Bytes: counters[8], Packets: counters[9], Errors: counters[10], Dropped: counters[11],
+				FIFO: counters[12], Collisions: counters[13], Carrier: counters[14], Compressed: counters[15],
+			},
+		})
+	}
+	return interfaces, false, nil
+}
+
+// parseEthtoolStats returns counters printed by ethtool -S as "     name: value" lines following the
+// "NIC statistics:" header. Lines that are not numeric counters are skipped.
+func parseEthtoolStats(output []byte) map[string]uint64 {
+	stats := map[string]uint64{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		stats[strings.TrimSpace(parts[0])] = value
+	}
+	return stats
+}
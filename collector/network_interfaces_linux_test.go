@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stubbedProcNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  104792    1212    0    0    0     0          0         0   104792    1212    0    0    0     0       0          0
+  eth0:9876543210 1234567  12   34    1     5          0        17 123456789  654321    2    3    0     4       6          0
+docker0:       0       0    0    0    0     0          0         0        0       0    0    7    0     0       0          0
+`
+
+const stubbedEthtool = `NIC statistics:
+     rx_packets: 1234567
+     rx_crc_errors: 5
+     tx_timeout: 1
+     rx_queue_0_drops: 34
+`
+
+func TestNetworkInterfacesIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(NetworkInterfaces))
+}
+
+func TestNetworkInterfaces_CollectCountersLinkStateAndEthtool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network-interfaces")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	procNetDev := filepath.Join(dir, "dev")
+	require.NoError(t, ioutil.WriteFile(procNetDev, []byte(stubbedProcNetDev), 0600))
+	sysClassNet := filepath.Join(dir, "net")
+	for iface, attributes := range map[string]map[string]string{
+		"lo":      {"operstate": "unknown\n", "carrier": "1\n", "mtu": "65536\n"},
+		"eth0":    {"operstate": "up\n", "carrier": "1\n", "speed": "10000\n", "mtu": "9001\n"},
+		"docker0": {"operstate": "down\n", "speed": "-1\n", "mtu": "1500\n"},
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(sysClassNet, iface), 0700))
+		for attribute, value := range attributes {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(sysClassNet, iface, attribute), []byte(value), 0600))
+		}
+	}
+	statsFile := filepath.Join(dir, "ethtool.out")
+	require.NoError(t, ioutil.WriteFile(statsFile, []byte(stubbedEthtool), 0600))
+	ethtool := filepath.Join(dir, "ethtool")
+	require.NoError(t, ioutil.WriteFile(ethtool, []byte("#!/bin/sh\ncase \"$2\" in\n"+
+		"eth0) cat "+statsFile+" ;;\n"+
+		"*) echo \"no stats available\" >&2; exit 94 ;;\nesac\n"), 0700))
+
+	c := NewNetworkInterfaces("network/interfaces.json", true, ethtool, 2)
+	c.procNetDev = procNetDev
+	c.sysClassNet = sysClassNet
+	assert.Equal(t, "network/interfaces.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	var report NetworkInterfacesReport
+	require.NoError(t, json.Unmarshal(raw, &report))
+
+	// docker0 is over the limit
+	assert.True(t, report.Truncated)
+	require.Len(t, report.Interfaces, 2)
+
+	lo := report.Interfaces[0]
+	assert.Equal(t, "lo", lo.Name)
+	assert.Equal(t, uint64(1212), lo.Receive.Packets)
+	assert.Nil(t, lo.SpeedMbps)
+	assert.Nil(t, lo.Ethtool)
+	assert.Equal(t, "exit status 94: no stats available", lo.EthtoolError)
+
+	eth0 := report.Interfaces[1]
+	assert.Equal(t, "eth0", eth0.Name)
+	assert.Equal(t, "up", eth0.OperState)
+	require.NotNil(t, eth0.Carrier)
+	assert.True(t, *eth0.Carrier)
+	require.NotNil(t, eth0.SpeedMbps)
+	assert.Equal(t, 10000, *eth0.SpeedMbps)
+	require.NotNil(t, eth0.MTU)
+	assert.Equal(t, 9001, *eth0.MTU)
+	assert.Equal(t, InterfaceCounters{Bytes: 9876543210, Packets: 1234567, Errors: 12, Dropped: 34, FIFO: 1, Frame: 5,
+		Multicast: 17}, eth0.Receive)
+	assert.Equal(t, InterfaceCounters{Bytes: 123456789, Packets: 654321, Errors: 2, Dropped: 3, Collisions: 4,
+		Carrier: 6}, eth0.Transmit)
+	assert.Equal(t, map[string]uint64{"rx_packets": 1234567, "rx_crc_errors": 5, "tx_timeout": 1, "rx_queue_0_drops": 34},
+		eth0.Ethtool)
+	assert.Empty(t, eth0.EthtoolError)
+}
+
+func TestNetworkInterfaces_CollectWithoutEthtool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network-interfaces")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewNetworkInterfaces("network/interfaces.json", true, "", 0)
+	c.procNetDev = filepath.Join(dir, "dev")
+	c.sysClassNet = filepath.Join(dir, "net")
+
+	_, err = c.Collect(context.TODO())
+	assert.Contains(t, err.Error(), "could not read interface statistics")
+
+	require.NoError(t, ioutil.WriteFile(c.procNetDev, []byte(stubbedProcNetDev), 0600))
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	var report NetworkInterfacesReport
+	require.NoError(t, json.Unmarshal(raw, &report))
+	assert.False(t, report.Truncated)
+	require.Len(t, report.Interfaces, 3)
+	assert.Equal(t, "docker0", report.Interfaces[2].Name)
+	assert.Equal(t, uint64(7), report.Interfaces[2].Transmit.Dropped)
+	assert.Empty(t, report.Interfaces[2].OperState)
+	assert.Nil(t, report.Interfaces[2].Ethtool)
+	assert.Empty(t, report.Interfaces[2].EthtoolError)
+}
+
+func TestParseProcNetDevRejectsMalformedCounters(t *testing.T) {
+	_, _, err := parseProcNetDev([]byte("header\nheader\n eth0: 1 2 3\n"), 0)
+	assert.Contains(t, err.Error(), "expected 16 counters")
+}
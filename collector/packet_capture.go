@@ -0,0 +1,215 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hard limits of packet captures, captures are refused when configured limits exceed them so a capture
+// could not be turned into a long running or disk filling traffic recorder
+const (
+	MaxCaptureDuration = time.Minute
+	MaxCapturePackets  = 100000
+	MaxCaptureSnapLen  = 65535
+	MaxCaptureSize     = 100 * 1024 * 1024
+)
+
+// Sizes of pcap headers written by tcpdump, the global header is written once and the record header for every packet
+const (
+	pcapGlobalHeaderSize = 24
+	pcapRecordHeaderSize = 16
+)
+
+// captureStopGrace is the time tcpdump has to flush the capture after it was interrupted before it is killed
+const captureStopGrace = 2 * time.Second
+
+// CaptureLimits bound a packet capture
+type CaptureLimits struct {
+	// Duration is the time after which the capture is stopped
+	Duration time.Duration
+	// Packets is the number of packets after which tcpdump exits
+	Packets int
+	// SnapLen is the number of bytes captured from every packet
+	SnapLen int
+	// Size is the number of bytes the capture file could take, Packets of SnapLen bytes with pcap headers must fit in it
+	Size int64
+}
+
+// Check returns an error if any of the limits is not set or exceeds its hard limit
+func (l CaptureLimits) Check() error {
+	if l.Duration <= 0 || l.Duration > MaxCaptureDuration {
+		return fmt.Errorf("capture duration %s must be positive and at most %s", l.Duration, MaxCaptureDuration)
+	}
+	if l.Packets <= 0 || l.Packets > MaxCapturePackets {
+		return fmt.Errorf("capture packets %d must be between 1 and %d", l.Packets, MaxCapturePackets)
+	}
+	if l.SnapLen <= 0 || l.SnapLen > MaxCaptureSnapLen {
+		return fmt.Errorf("capture snap length %d must be between 1 and %d", l.SnapLen, MaxCaptureSnapLen)
+	}
+	if l.Size <= 0 || l.Size > MaxCaptureSize {
+		return fmt.Errorf("capture size %d must be between 1 and %d bytes", l.Size, MaxCaptureSize)
+	}
+	if size := l.maxFileSize(); size > l.Size {
+		return fmt.Errorf("capture of %d packets of %d bytes could take %d bytes, more than capture size %d bytes",
+			l.Packets, l.SnapLen, size, l.Size)
+	}
+	return nil
+}
+
+// maxFileSize returns the size of the capture file when tcpdump captures all packets with the whole snap length,
+// so the capture could not grow past the size while tcpdump is running
+func (l CaptureLimits) maxFileSize() int64 {
+	return pcapGlobalHeaderSize + int64(l.Packets)*int64(pcapRecordHeaderSize+l.SnapLen)
+}
+
+// PacketCapture is a struct implementing Collector interface. It captures packets on the interface with tcpdump
+// for a short time and collects the capture in the pcap format. It is always optional as tcpdump could be missing
+// or not permitted to capture on some nodes.
+type PacketCapture struct {
+	name    string
+	command string
+	iface   string
+	filter  []string
+	limits  CaptureLimits
+}
+
+// NewPacketCapture returns a collector capturing packets matching the filter, a tcpdump expression, on the
+// interface within the limits. The interface and filter must come from the daemon configuration.
+func NewPacketCapture(name string, iface string, filter string, limits CaptureLimits) (*PacketCapture, error) {
+	if err := limits.Check(); err != nil {
+		return nil, err
+	}
+	if iface == "" || strings.HasPrefix(iface, "-") {
+		return nil, fmt.Errorf("invalid capture interface %q", iface)
+	}
+	return &PacketCapture{
+		name:    name,
+		command: "tcpdump",
+		iface:   iface,
+		filter:  strings.Fields(filter),
+		limits:  limits,
+	}, nil
+}
+
+func (c PacketCapture) Name() string {
+	return c.name
+}
+
+func (c PacketCapture) Optional() bool {
+	return true
+}
+
+// args returns tcpdump arguments writing the capture to the path. Packets are written as they are captured (-U)
+// so the capture is valid when tcpdump is stopped, names are not resolved (-n) to keep the capture passive.
+func (c PacketCapture) args(path string) []string {
+	args := []string{
+		"-n", "-U",
+		"-i", c.iface,
+		"-c", strconv.Itoa(c.limits.Packets),
+		"-s", strconv.Itoa(c.limits.SnapLen),
+		"-w", path,
+	}
+	if len(c.filter) > 0 {
+		args = append(append(args, "--"), c.filter...)
+	}
+	return args
+}
+
+func (c PacketCapture) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	if _, err := exec.LookPath(c.command); err != nil {
+		return nil, fmt.Errorf("could not find %s: %s", c.command, err)
+	}
+
+	f, err := ioutil.TempFile("", "capture")
+	if err != nil {
+		return nil, fmt.Errorf("could not create capture file: %s", err)
+	}
+	path := f.Name()
+	f.Close()
+
+	stderr, err := c.run(ctx, path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("could not capture packets on %s: %s: %s", c.iface, err, strings.TrimSpace(stderr))
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("could not open capture: %s", err)
+	}
+	return captureReadCloser{Reader: goio.LimitReader(f, c.limits.Size), file: f}, nil
+}
+
+// run runs tcpdump until it captures the number of packets or the duration passes. It is interrupted, not killed,
+// after the duration so it could flush the capture, it is killed only when it does not exit in captureStopGrace.
+func (c PacketCapture) run(ctx context.Context, path string) (string, error) {
+	stderr := &limitedBuffer{max: 4096}
+	cmd := exec.Command(c.command, c.args(path)...)
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	timer := time.NewTimer(c.limits.Duration)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return stderr.String(), err
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-done
+		return stderr.String(), ctx.Err()
+	case <-timer.C:
+	}
+
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-done:
+		// tcpdump exits with an error code when interrupted, the capture is what was requested
+		return stderr.String(), nil
+	case <-time.After(captureStopGrace):
+		cmd.Process.Kill()
+		<-done
+		return stderr.String(), fmt.Errorf("killed after it did not stop in %s", captureStopGrace)
+	}
+}
+
+// captureReadCloser removes the capture file when it is closed
+type captureReadCloser struct {
+	goio.Reader
+	file *os.File
+}
+
+func (r captureReadCloser) Close() error {
+	err := r.file.Close()
+	os.Remove(r.file.Name())
+	return err
+}
+
+// limitedBuffer keeps at most max bytes written to it
+type limitedBuffer struct {
+	strings.Builder
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.Builder.Write(p[:remaining])
+		} else {
+			b.Builder.Write(p)
+		}
+	}
+	return len(p), nil
+}
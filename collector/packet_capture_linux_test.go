@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCaptureStub writes a script standing for tcpdump that records its arguments in args.txt next to it,
+// runs the script and writes the capture to the path given with -w
+func writeCaptureStub(t *testing.T, dir string, script string) string {
+	path := filepath.Join(dir, "tcpdump")
+	stub := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > " + filepath.Join(dir, "args.txt") + "\n" +
+		"while [ $# -gt 0 ]; do if [ \"$1\" = -w ]; then out=$2; fi; shift; done\n" +
+		script
+	require.NoError(t, ioutil.WriteFile(path, []byte(stub), 0700))
+	return path
+}
+
+func testCaptureLimits() CaptureLimits {
+	return CaptureLimits{Duration: time.Second, Packets: 100, SnapLen: 128, Size: 16 * 1024}
+}
+
+func TestPacketCaptureIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(PacketCapture))
+}
+
+func TestPacketCapture_PassesBoundsToTcpdump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := NewPacketCapture("network/capture.pcap", "eth0", "port 53 or port 8080", testCaptureLimits())
+	require.NoError(t, err)
+	c.command = writeCaptureStub(t, dir, "printf pcap > \"$out\"\n")
+	assert.Equal(t, "network/capture.pcap", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "pcap", string(data))
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args.txt"))
+	require.NoError(t, err)
+	fields := strings.Split(strings.TrimSpace(string(args)), "\n")
+	out := fields[len(fields)-7]
+	assert.Equal(t, []string{"-n", "-U", "-i", "eth0", "-c", "100", "-s", "128", "-w", out,
+		"--", "port", "53", "or", "port", "8080"}, fields)
+
+	// the capture file is removed after it was collected
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPacketCapture_StopsAfterDurationAndTruncatesCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	limits := testCaptureLimits()
+	limits.Duration = 100 * time.Millisecond
+	c, err := NewPacketCapture("network/capture.pcap", "any", "", limits)
+	require.NoError(t, err)
+	// the capture is still truncated when it is read back in case tcpdump writes more than expected
+	c.limits.Size = 4
+	// the stub writes the capture only when it is interrupted as tcpdump flushes it on SIGINT
+	c.command = writeCaptureStub(t, dir, "trap 'printf 0123456789 > \"$out\"; exit 1' INT\nwhile true; do sleep 0.01; done\n")
+
+	start := time.Now()
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < captureStopGrace, "capture took %s", time.Since(start))
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "0123", string(data))
+}
+
+func TestPacketCapture_FailsWithoutTcpdump(t *testing.T) {
+	c, err := NewPacketCapture("network/capture.pcap", "any", "", testCaptureLimits())
+	require.NoError(t, err)
+	c.command = "/nonexistent/tcpdump"
+
+	_, err = c.Collect(context.TODO())
+	assert.Error(t, err)
+}
+
+func TestNewPacketCapture_RefusesLimitsAboveHardLimits(t *testing.T) {
+	for _, limits := range []CaptureLimits{
+		{},
+		{Duration: 2 * MaxCaptureDuration, Packets: 1, SnapLen: 1, Size: 1},
+		{Duration: time.Second, Packets: MaxCapturePackets + 1, SnapLen: 1, Size: 1},
+		{Duration: time.Second, Packets: 1, SnapLen: MaxCaptureSnapLen + 1, Size: 1},
+		{Duration: time.Second, Packets: 1, SnapLen: 1, Size: MaxCaptureSize + 1},
+	} {
+		_, err := NewPacketCapture("network/capture.pcap", "any", "", limits)
+		assert.Error(t, err, "%+v", limits)
+	}
+
+	// the capture could not take more than the size while tcpdump is running
+	limits := CaptureLimits{Duration: time.Second, Packets: 100, SnapLen: 128, Size: 14423}
+	_, err := NewPacketCapture("network/capture.pcap", "any", "", limits)
+	assert.EqualError(t, err, "capture of 100 packets of 128 bytes could take 14424 bytes, more than capture size 14423 bytes")
+	limits.Size++
+	_, err = NewPacketCapture("network/capture.pcap", "any", "", limits)
+	assert.NoError(t, err)
+
+	_, err = NewPacketCapture("network/capture.pcap", "-w/etc/passwd", "", testCaptureLimits())
+	assert.EqualError(t, err, `invalid capture interface "-w/etc/passwd"`)
+}
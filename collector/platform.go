@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"runtime"
+)
+
+// Platform describes the operating system of the node collectors run on
+type Platform struct {
+	// OS is the operating system as reported by runtime.GOOS e.g., linux
+	OS string
+	// Distro is the distribution ID from os-release e.g., centos, empty when it is unknown
+	Distro string
+}
+
+// DetectPlatform returns the platform of this node
+func DetectPlatform() Platform {
+	return Platform{OS: runtime.GOOS, Distro: readDistroID()}
+}
+
+// PlatformPredicate returns true if the collector applies to the platform
+type PlatformPredicate func(Platform) bool
+
+// OnlyOS returns a predicate matching given operating systems
+func OnlyOS(oses ...string) PlatformPredicate {
+	return func(p Platform) bool {
+		return contains(oses, p.OS)
+	}
+}
+
+// OnlyDistros returns a predicate matching given distribution IDs. Platforms with unknown distribution
+// are matched so collectors are not dropped when os-release could not be read.
+func OnlyDistros(distros ...string) PlatformPredicate {
+	return func(p Platform) bool {
+		return p.Distro == "" || contains(distros, p.Distro)
+	}
+}
+
+// ExceptDistros returns a predicate matching all distribution IDs but the given ones
+func ExceptDistros(distros ...string) PlatformPredicate {
+	return func(p Platform) bool {
+		return !contains(distros, p.Distro)
+	}
+}
+
+// PlatformSpecific is implemented by collectors that apply only to some platforms
+type PlatformSpecific interface {
+	AppliesTo(p Platform) bool
+}
+
+// platformSpecific wraps a Collector and restricts platforms it applies to
+type platformSpecific struct {
+	Collector
+	predicates []PlatformPredicate
+}
+
+func (c platformSpecific) AppliesTo(p Platform) bool {
+	for _, predicate := range c.predicates {
+		if !predicate(p) {
+			return false
+		}
+	}
+	return AppliesTo(c.Collector, p)
+}
+
+func (c platformSpecific) Priority() int {
+	return Priority(c.Collector)
+}
+
+func (c platformSpecific) Capabilities() []Capability {
+	return RequiredCapabilities(c.Collector)
+}
+
+// WithPlatforms returns collector c applying only to platforms matching all predicates
+func WithPlatforms(c Collector, predicates ...PlatformPredicate) Collector {
+	return platformSpecific{Collector: c, predicates: predicates}
+}
+
+// AppliesTo returns true if the collector applies to the platform, collectors that do not
+// implement PlatformSpecific apply to all platforms
+func AppliesTo(c Collector, p Platform) bool {
+	if s, ok := c.(PlatformSpecific); ok {
+		return s.AppliesTo(p)
+	}
+	return true
+}
+
+// FilterByPlatform returns collectors applying to the platform keeping their order
+func FilterByPlatform(collectors []Collector, p Platform) []Collector {
+	filtered := make([]Collector, 0, len(collectors))
+	for _, c := range collectors {
+		if AppliesTo(c, p) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
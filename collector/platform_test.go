@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterByPlatform(t *testing.T) {
+	linux := Platform{OS: "linux", Distro: "centos"}
+	windows := Platform{OS: "windows"}
+
+	any := NewEnvironment("any", true)
+	linuxOnly := WithPlatforms(NewEnvironment("linux-only", true), OnlyOS("linux"))
+	ubuntuOnly := WithPlatforms(NewEnvironment("ubuntu-only", true), OnlyOS("linux"), OnlyDistros("ubuntu"))
+	exceptCoreOS := WithPlatforms(NewEnvironment("except-coreos", true), ExceptDistros("coreos"))
+	collectors := []Collector{any, linuxOnly, ubuntuOnly, exceptCoreOS}
+
+	assert.Equal(t, []Collector{any, linuxOnly, exceptCoreOS}, FilterByPlatform(collectors, linux))
+	assert.Equal(t, []Collector{any, exceptCoreOS}, FilterByPlatform(collectors, windows))
+	assert.Equal(t, []Collector{any, linuxOnly}, FilterByPlatform(collectors, Platform{OS: "linux", Distro: "coreos"}))
+	// unknown distribution does not drop distribution specific collectors
+	assert.Equal(t, collectors, FilterByPlatform(collectors, Platform{OS: "linux"}))
+}
+
+func TestWithPlatformsKeepsPriority(t *testing.T) {
+	c := WithPlatforms(WithPriority(NewEnvironment("test", true), PriorityHigh), OnlyOS("linux"))
+	assert.Equal(t, PriorityHigh, Priority(c))
+	assert.False(t, AppliesTo(c, Platform{OS: "darwin"}))
+
+	c = WithPriority(WithPlatforms(NewEnvironment("test", true), OnlyOS("linux")), PriorityLow)
+	assert.Equal(t, PriorityLow, Priority(c))
+	assert.False(t, AppliesTo(c, Platform{OS: "darwin"}))
+	assert.True(t, AppliesTo(c, Platform{OS: "linux"}))
+}
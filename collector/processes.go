@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// ProcessInfo describes a single process in the processes snapshot
+type ProcessInfo struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	Cmdline    string  `json:"cmdline,omitempty"`
+	Status     string  `json:"status,omitempty"`
+	CPUPercent float64 `json:"cpu_percent"`
+	RSS        uint64  `json:"rss_bytes"`
+}
+
+// ProcessesSnapshot lists processes using the most CPU and memory
+type ProcessesSnapshot struct {
+	Total     int           `json:"total"`
+	TopCPU    []ProcessInfo `json:"top_cpu"`
+	TopMemory []ProcessInfo `json:"top_memory"`
+}
+
+// Processes is a struct implementing Collector interface. It collects top processes by CPU
+// and memory usage as JSON reading them with gopsutil instead of shelling out.
+type Processes struct {
+	name     string
+	optional bool
+	limit    int
+	list     func(ctx context.Context) ([]ProcessInfo, error)
+}
+
+// NewProcesses returns a collector of at most limit processes using the most CPU and
+// at most limit processes using the most memory
+func NewProcesses(name string, optional bool, limit int) *Processes {
+	return &Processes{
+		name:     name,
+		optional: optional,
+		limit:    limit,
+		list:     listProcesses,
+	}
+}
+
+func (c Processes) Name() string {
+	return c.name
+}
+
+func (c Processes) Optional() bool {
+	return c.optional
+}
+
+func (c Processes) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	processes, err := c.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list processes: %s", err)
+	}
+
+	snapshot := ProcessesSnapshot{
+		Total: len(processes),
+		TopCPU: top(processes, c.limit, func(a, b ProcessInfo) bool {
+			return a.CPUPercent > b.CPUPercent
+		}),
+		TopMemory: top(processes, c.limit, func(a, b ProcessInfo) bool {
+			return a.RSS > b.RSS
+		}),
+	}
+
+	raw, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// top returns at most limit processes ordered with less, ties are ordered by PID
+func top(processes []ProcessInfo, limit int, less func(a, b ProcessInfo) bool) []ProcessInfo {
+	sorted := make([]ProcessInfo, len(processes))
+	copy(sorted, processes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].PID < sorted[j].PID
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// listProcesses reads all processes. Processes that exit while they are read are skipped
+// and fields that could not be read are left empty.
+func listProcesses(ctx context.Context) ([]ProcessInfo, error) {
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ProcessInfo, 0, len(processes))
+	for _, p := range processes {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		info := ProcessInfo{PID: p.Pid, Name: name}
+		info.Cmdline, _ = p.CmdlineWithContext(ctx)
+		info.Status, _ = p.StatusWithContext(ctx)
+		info.CPUPercent, _ = p.CPUPercentWithContext(ctx)
+		if memory, err := p.MemoryInfoWithContext(ctx); err == nil {
+			info.RSS = memory.RSS
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
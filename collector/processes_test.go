@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessesIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(Processes))
+}
+
+func TestProcesses_CollectTopProcesses(t *testing.T) {
+	c := NewProcesses("processes.json", true, 2)
+	assert.Equal(t, "processes.json", c.Name())
+	assert.True(t, c.Optional())
+
+	c.list = func(ctx context.Context) ([]ProcessInfo, error) {
+		return []ProcessInfo{
+			{PID: 1, Name: "systemd", CPUPercent: 0.1, RSS: 300},
+			{PID: 2, Name: "mesos-master", CPUPercent: 50, RSS: 100},
+			{PID: 3, Name: "java", CPUPercent: 10, RSS: 900},
+			{PID: 4, Name: "dcos-diagnostics", CPUPercent: 50, RSS: 200},
+		}, nil
+	}
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	var snapshot ProcessesSnapshot
+	require.NoError(t, json.NewDecoder(r).Decode(&snapshot))
+
+	assert.Equal(t, 4, snapshot.Total)
+	assert.Equal(t, []ProcessInfo{
+		{PID: 2, Name: "mesos-master", CPUPercent: 50, RSS: 100},
+		{PID: 4, Name: "dcos-diagnostics", CPUPercent: 50, RSS: 200},
+	}, snapshot.TopCPU)
+	assert.Equal(t, []ProcessInfo{
+		{PID: 3, Name: "java", CPUPercent: 10, RSS: 900},
+		{PID: 1, Name: "systemd", CPUPercent: 0.1, RSS: 300},
+	}, snapshot.TopMemory)
+}
+
+func TestProcesses_CollectFailsWhenProcessesCouldNotBeListed(t *testing.T) {
+	c := NewProcesses("processes.json", true, 2)
+	c.list = func(ctx context.Context) ([]ProcessInfo, error) {
+		return nil, fmt.Errorf("no procfs")
+	}
+
+	_, err := c.Collect(context.TODO())
+	assert.EqualError(t, err, "could not list processes: no procfs")
+}
+
+func TestProcesses_CollectReadsRunningProcesses(t *testing.T) {
+	r, err := NewProcesses("processes.json", true, 5).Collect(context.TODO())
+	require.NoError(t, err)
+	var snapshot ProcessesSnapshot
+	require.NoError(t, json.NewDecoder(r).Decode(&snapshot))
+
+	assert.NotZero(t, snapshot.Total)
+	assert.NotEmpty(t, snapshot.TopCPU)
+	assert.True(t, len(snapshot.TopMemory) <= 5)
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// ProvenanceFileName is a name of the provenance file, cluster bundles have a copy of it at their root
+	ProvenanceFileName = "provenance.json"
+	// DefaultClusterIDPath is a file with the cluster ID written when the cluster is installed
+	DefaultClusterIDPath = "/var/lib/dcos/cluster-id"
+	// DefaultVersionPath is a file describing the installed DC/OS version and variant
+	DefaultVersionPath = "/opt/mesosphere/etc/dcos-version.json"
+)
+
+// ProvenanceInfo identifies the cluster and the installation the bundle was collected from.
+// Fields that could not be read are left empty.
+type ProvenanceInfo struct {
+	ClusterID   string     `json:"cluster_id,omitempty"`
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	Variant     string     `json:"variant,omitempty"`
+	BootstrapID string     `json:"bootstrap_id,omitempty"`
+	ImageCommit string     `json:"image_commit,omitempty"`
+}
+
+// Provenance is a struct implementing Collector interface. It collects the cluster ID, the install time
+// and the DC/OS version as JSON so bundles could be correlated with the cluster installation.
+type Provenance struct {
+	name          string
+	optional      bool
+	clusterIDPath string
+	versionPath   string
+}
+
+func NewProvenance(name string, optional bool, clusterIDPath string, versionPath string) *Provenance {
+	return &Provenance{
+		name:          name,
+		optional:      optional,
+		clusterIDPath: clusterIDPath,
+		versionPath:   versionPath,
+	}
+}
+
+func (c Provenance) Name() string {
+	return c.name
+}
+
+func (c Provenance) Optional() bool {
+	return c.optional
+}
+
+func (c Provenance) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	var info ProvenanceInfo
+	var errs []string
+
+	if err := c.readClusterID(&info); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := c.readVersion(&info); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 2 {
+		return nil, fmt.Errorf("could not read provenance: %s", strings.Join(errs, "; "))
+	}
+
+	raw, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// readClusterID reads the cluster ID. The file is written once on install so its modification time
+// is the install time.
+func (c Provenance) readClusterID(info *ProvenanceInfo) error {
+	raw, err := ioutil.ReadFile(c.clusterIDPath)
+	if err != nil {
+		return fmt.Errorf("could not read cluster ID: %s", err)
+	}
+	info.ClusterID = strings.TrimSpace(string(raw))
+
+	stat, err := os.Stat(c.clusterIDPath)
+	if err != nil {
+		return fmt.Errorf("could not stat cluster ID: %s", err)
+	}
+	installedAt := stat.ModTime().UTC()
+	info.InstalledAt = &installedAt
+	return nil
+}
+
+func (c Provenance) readVersion(info *ProvenanceInfo) error {
+	raw, err := ioutil.ReadFile(c.versionPath)
+	if err != nil {
+		return fmt.Errorf("could not read version: %s", err)
+	}
+	var version struct {
+		Version     string `json:"version"`
+		Variant     string `json:"dcos-variant"`
+		BootstrapID string `json:"bootstrap-id"`
+		ImageCommit string `json:"dcos-image-commit"`
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return fmt.Errorf("could not parse version: %s", err)
+	}
+	info.Version = version.Version
+	info.Variant = version.Variant
+	info.BootstrapID = version.BootstrapID
+	info.ImageCommit = version.ImageCommit
+	return nil
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenanceIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(Provenance))
+}
+
+func TestProvenance_Collect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provenance")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clusterIDPath := filepath.Join(dir, "cluster-id")
+	versionPath := filepath.Join(dir, "dcos-version.json")
+	require.NoError(t, ioutil.WriteFile(clusterIDPath, []byte("b7a6d2b0-2b2f-4c8a-9c1e-5f2a3d4e5f60\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(versionPath, []byte(`{
+		"version": "2.1.0",
+		"dcos-variant": "open",
+		"bootstrap-id": "d4ad5f5d",
+		"dcos-image-commit": "2a3b4c5d"
+	}`), 0600))
+	stat, err := os.Stat(clusterIDPath)
+	require.NoError(t, err)
+
+	c := NewProvenance(ProvenanceFileName, true, clusterIDPath, versionPath)
+	assert.Equal(t, "provenance.json", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	var info ProvenanceInfo
+	require.NoError(t, json.NewDecoder(r).Decode(&info))
+
+	require.NotNil(t, info.InstalledAt)
+	assert.True(t, stat.ModTime().Equal(*info.InstalledAt))
+	info.InstalledAt = nil
+	assert.Equal(t, ProvenanceInfo{
+		ClusterID:   "b7a6d2b0-2b2f-4c8a-9c1e-5f2a3d4e5f60",
+		Version:     "2.1.0",
+		Variant:     "open",
+		BootstrapID: "d4ad5f5d",
+		ImageCommit: "2a3b4c5d",
+	}, info)
+}
+
+func TestProvenance_CollectFailsWhenNothingCouldBeRead(t *testing.T) {
+	c := NewProvenance(ProvenanceFileName, true, "/not/existing/cluster-id", "/not/existing/dcos-version.json")
+
+	_, err := c.Collect(context.TODO())
+	assert.Error(t, err)
+}
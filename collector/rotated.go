@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	goio "io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dcos/dcos-diagnostics/io"
+)
+
+const gzipExt = ".gz"
+
+// Rotated is a struct implementing Collector interface. It collects the n-th most recent rotated
+// sibling of a file (e.g., foo.log.1, foo.log.2.gz). The file is resolved on every collection
+// so it follows rotations happening while the daemon is running.
+type Rotated struct {
+	name       string
+	optional   bool
+	filePath   string
+	pattern    string
+	index      int
+	decompress bool
+	symlinks   SymlinkPolicy
+}
+
+// NewRotated returns a collector for the rotated sibling of filePath with given index, 0 being the newest one.
+// Siblings are files in the same directory matching the glob pattern, by default the file name followed by a dot.
+// When decompress is set, gzipped rotations are decompressed.
+func NewRotated(name string, optional bool, filePath string, pattern string, index int, decompress bool) *Rotated {
+	return &Rotated{
+		name:       name,
+		optional:   optional,
+		filePath:   filePath,
+		pattern:    pattern,
+		index:      index,
+		decompress: decompress,
+	}
+}
+
+// WithSymlinks sets how the rotated file is opened when its path leads through a symbolic link
+func (c *Rotated) WithSymlinks(policy SymlinkPolicy) *Rotated {
+	c.symlinks = policy
+	return c
+}
+
+func (c Rotated) Name() string {
+	return c.name
+}
+
+func (c Rotated) Optional() bool {
+	return c.optional
+}
+
+func (c Rotated) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	files, err := RotatedFiles(c.filePath, c.pattern)
+	if err != nil {
+		return nil, err
+	}
+	if c.index >= len(files) {
+		return nil, fmt.Errorf("could not find rotated file %d of %s: only %d found", c.index+1, c.filePath, len(files))
+	}
+
+	path := files[c.index]
+	f, err := c.symlinks.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %s", path, err)
+	}
+
+	if !c.decompress || !strings.HasSuffix(path, gzipExt) {
+		return io.ReadCloserWithContext(ctx, f), nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not decompress %s: %s", path, err)
+	}
+	return io.ReadCloserWithContext(ctx, gzipReadCloser{Reader: gz, file: f}), nil
+}
+
+// gzipReadCloser closes both the gzip reader and the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file goio.Closer
+}
+
+func (r gzipReadCloser) Close() error {
+	if err := r.Reader.Close(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// RotatedFiles returns paths of regular files in the directory of filePath matching the glob pattern,
+// sorted by modification time, newest first. The filePath itself is never included.
+// An empty pattern matches the file name followed by a dot and any suffix.
+func RotatedFiles(filePath string, pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = filepath.Base(filePath) + ".*"
+	}
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(filePath), pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid rotated files pattern %s: %s", pattern, err)
+	}
+
+	type rotatedFile struct {
+		path string
+		info os.FileInfo
+	}
+
+	files := make([]rotatedFile, 0, len(matches))
+	for _, path := range matches {
+		if filepath.Clean(path) == filepath.Clean(filePath) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, rotatedFile{path: path, info: info})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].info.ModTime().After(files[j].info.ModTime())
+	})
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errListingTruncated stops walking the work dir when the listing is full
+var errListingTruncated = errors.New("listing truncated")
+
+// SandboxListing is a metadata only listing of the Mesos agent work dir
+type SandboxListing struct {
+	Root       string         `json:"root"`
+	MaxDepth   int            `json:"max_depth"`
+	MaxEntries int            `json:"max_entries"`
+	Truncated  bool           `json:"truncated"`
+	Entries    []SandboxEntry `json:"entries"`
+}
+
+// SandboxEntry describes a single file or directory found in the work dir. Path is relative to the work dir root.
+type SandboxEntry struct {
+	Path     string    `json:"path"`
+	Dir      bool      `json:"dir"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Sandboxes is a struct implementing Collector interface. It lists paths, sizes and modification times
+// of files in the Mesos agent work dir without reading their content. The listing is limited to maxDepth
+// levels below the root and maxEntries entries so agents running many tasks do not produce huge output.
+type Sandboxes struct {
+	name       string
+	optional   bool
+	root       string
+	maxDepth   int
+	maxEntries int
+}
+
+func NewSandboxes(name string, optional bool, root string, maxDepth int, maxEntries int) *Sandboxes {
+	return &Sandboxes{
+		name:       name,
+		optional:   optional,
+		root:       root,
+		maxDepth:   maxDepth,
+		maxEntries: maxEntries,
+	}
+}
+
+func (c Sandboxes) Name() string {
+	return c.name
+}
+
+func (c Sandboxes) Optional() bool {
+	return c.optional
+}
+
+func (c Sandboxes) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	listing, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.MarshalIndent(listing, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+func (c Sandboxes) list(ctx context.Context) (SandboxListing, error) {
+	listing := SandboxListing{
+		Root:       c.root,
+		MaxDepth:   c.maxDepth,
+		MaxEntries: c.maxEntries,
+		Entries:    []SandboxEntry{},
+	}
+
+	if _, err := os.Stat(c.root); err != nil {
+		return listing, fmt.Errorf("could not read work dir %s: %s", c.root, err)
+	}
+
+	err := filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if path == c.root {
+			return err
+		}
+		// sandboxes could be removed by the agent while we walk so skip entries we could not read
+		if err != nil {
+			return nil
+		}
+
+		if len(listing.Entries) >= c.maxEntries {
+			listing.Truncated = true
+			return errListingTruncated
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		listing.Entries = append(listing.Entries, SandboxEntry{
+			Path:     filepath.ToSlash(rel),
+			Dir:      info.IsDir(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+
+		depth := strings.Count(rel, string(filepath.Separator)) + 1
+		if info.IsDir() && depth >= c.maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil && err != errListingTruncated {
+		return listing, fmt.Errorf("could not list work dir %s: %s", c.root, err)
+	}
+
+	return listing, nil
+}
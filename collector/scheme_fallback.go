@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	goio "io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EndpointOutput is the response of the endpoint fetched with the other scheme after the configured one failed
+type EndpointOutput struct {
+	goio.ReadCloser
+	// URL is the url the response was fetched from
+	URL string
+	// Reason is the failure of the configured url
+	Reason string
+}
+
+// WithSchemeFallback makes the collector fetch the url with the other scheme, https instead of http and the other
+// way around, when the configured scheme fails e.g., during partial TLS rollouts. The port is kept so the fallback
+// reaches the same host and port the allowlist was checked for. Urls with other schemes are not changed.
+func (c *Endpoint) WithSchemeFallback() *Endpoint {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return c
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "https"
+		if port == "" {
+			port = "80"
+		}
+	case "https":
+		u.Scheme = "http"
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return c
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
+	c.fallbackURL = u.String()
+	return c
+}
+
+// isTLSHandshakeError returns true if the request failed because the server does not speak TLS on the port e.g., it
+// answered with plain HTTP. Certificate errors are never a scheme mismatch, falling back to plain HTTP on them would
+// silently downgrade the connection the certificate check was protecting.
+func isTLSHandshakeError(err error) bool {
+	if e, ok := err.(*url.Error); ok {
+		err = e.Err
+	}
+	switch err.(type) {
+	case *tls.CertificateVerificationError, x509.UnknownAuthorityError, x509.HostnameError,
+		x509.CertificateInvalidError, x509.SystemRootsError, x509.ConstraintViolationError:
+		return false
+	case tls.RecordHeaderError:
+		return true
+	}
+	return strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+}
+
+// isPlainRequestToTLSServer returns true if the response tells a plain HTTP request was sent to a TLS port.
+// Go servers and nginx, that admin router is based on, answer such requests with 400 and an explanation.
+func isPlainRequestToTLSServer(status int, body []byte) bool {
+	if status != http.StatusBadRequest {
+		return false
+	}
+	msg := string(body)
+	return strings.Contains(msg, "HTTP request to an HTTPS server") || strings.Contains(msg, "plain HTTP request was sent to HTTPS port")
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoint_WithSchemeFallback(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "http://192.0.2.1:61001/system/health/v1", expected: "https://192.0.2.1:61001/system/health/v1"},
+		{url: "https://192.0.2.1:443/metrics?x=1", expected: "http://192.0.2.1:443/metrics?x=1"},
+		// the port is kept so the fallback reaches the same host and port
+		{url: "http://leader.mesos/state", expected: "https://leader.mesos:80/state"},
+		{url: "https://leader.mesos/state", expected: "http://leader.mesos:443/state"},
+		{url: "file:///etc/hosts", expected: ""},
+	}
+	for _, tc := range tests {
+		c, err := NewEndpoint("test", true, tc.url, http.DefaultClient, nil)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, c.WithSchemeFallback().fallbackURL, tc.url)
+	}
+}
+
+func TestEndpoint_CollectFallsBackToTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+	plainURL := strings.Replace(server.URL, "https://", "http://", 1) + "/system/health/v1"
+
+	c, err := NewEndpoint("test", true, plainURL, server.Client(), nil)
+	require.NoError(t, err)
+	_, err = c.Collect(context.TODO())
+	require.Error(t, err)
+
+	r, err := c.WithSchemeFallback().Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(data))
+
+	output, ok := r.(*EndpointOutput)
+	require.True(t, ok)
+	assert.Equal(t, server.URL+"/system/health/v1", output.URL)
+	assert.Contains(t, output.Reason, "Return code 400")
+}
+
+func TestEndpoint_CollectFallsBackToPlainHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+	tlsURL := strings.Replace(server.URL, "http://", "https://", 1) + "/metrics"
+
+	c, err := NewEndpoint("test", true, tlsURL, server.Client(), nil)
+	require.NoError(t, err)
+	r, err := c.WithSchemeFallback().Collect(context.TODO())
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(data))
+
+	output, ok := r.(*EndpointOutput)
+	require.True(t, ok)
+	assert.Equal(t, server.URL+"/metrics", output.URL)
+	assert.Contains(t, output.Reason, "server gave HTTP response to HTTPS client")
+}
+
+func TestEndpoint_CollectDoesNotFallBackOnOtherFailures(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	c, err := NewEndpoint("test", true, server.URL+"/missing", server.Client(), nil)
+	require.NoError(t, err)
+	_, err = c.WithSchemeFallback().Collect(context.TODO())
+	assert.Contains(t, err.Error(), "Return code 404")
+	assert.NotContains(t, err.Error(), "fallback")
+	assert.Equal(t, 1, requests)
+}
+
+func TestEndpoint_CollectDoesNotFallBackOnCertificateErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	// a client without the test server certificate does not trust it
+	c, err := NewEndpoint("test", true, server.URL+"/metrics", &http.Client{}, nil)
+	require.NoError(t, err)
+	r, err := c.WithSchemeFallback().Collect(context.TODO())
+	require.Error(t, err)
+	assert.Nil(t, r)
+	assert.Contains(t, err.Error(), "certificate")
+	assert.NotContains(t, err.Error(), "fallback")
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	goio "io"
+	"io/ioutil"
+	"runtime"
+	"runtime/pprof"
+)
+
+// maxStackSize limits the size of the goroutines dump of the daemon
+const maxStackSize = 64 << 20
+
+// Goroutines is a struct implementing Collector interface. It collects stack traces of all goroutines
+// of the daemon itself so hangs of the collection could be diagnosed.
+type Goroutines struct {
+	name     string
+	optional bool
+}
+
+func NewGoroutines(name string, optional bool) *Goroutines {
+	return &Goroutines{
+		name:     name,
+		optional: optional,
+	}
+}
+
+func (c Goroutines) Name() string {
+	return c.name
+}
+
+func (c Goroutines) Optional() bool {
+	return c.optional
+}
+
+func (c Goroutines) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackSize {
+			return ioutil.NopCloser(bytes.NewReader(buf[:n])), nil
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// HeapProfile is a struct implementing Collector interface. It collects the heap profile of the daemon itself
+// in pprof format.
+type HeapProfile struct {
+	name     string
+	optional bool
+}
+
+func NewHeapProfile(name string, optional bool) *HeapProfile {
+	return &HeapProfile{
+		name:     name,
+		optional: optional,
+	}
+}
+
+func (c HeapProfile) Name() string {
+	return c.name
+}
+
+func (c HeapProfile) Optional() bool {
+	return c.optional
+}
+
+func (c HeapProfile) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	var buf bytes.Buffer
+	if err := pprof.WriteHeapProfile(&buf); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(&buf), nil
+}
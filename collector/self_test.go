@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutinesIsCollector(t *testing.T) {
+	assert.Implements(t, (*Collector)(nil), new(Goroutines))
+	assert.Implements(t, (*Collector)(nil), new(HeapProfile))
+}
+
+func TestGoroutines_Collect(t *testing.T) {
+	c := NewGoroutines("self/goroutines.txt", true)
+	assert.Equal(t, "self/goroutines.txt", c.Name())
+	assert.True(t, c.Optional())
+
+	r, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.NotEmpty(t, raw)
+	assert.Contains(t, string(raw), "TestGoroutines_Collect")
+}
+
+func TestHeapProfile_Collect(t *testing.T) {
+	r, err := NewHeapProfile("self/heap.pprof", true).Collect(context.TODO())
+	require.NoError(t, err)
+
+	raw, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	// pprof profiles are gzipped protobufs
+	require.True(t, len(raw) > 2)
+	assert.Equal(t, []byte{0x1f, 0x8b}, raw[:2])
+}
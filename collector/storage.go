@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// StorageDiskPlaceholder in a storage command is replaced with a path of each disk of the node,
+// the command is run once per disk e.g., smartctl -x {disk}
+const StorageDiskPlaceholder = "{disk}"
+
+// DefaultStorageCommands describe block devices, LVM and SMART state of disks. Commands are given as
+// name=command, the output of each command is stored as storage/<name>.txt
+var DefaultStorageCommands = []string{
+	"lsblk=lsblk --all --paths --output-all",
+	"pvs=pvs --all --verbose",
+	"vgs=vgs --all --verbose",
+	"lvs=lvs --all --verbose",
+	"smartctl=smartctl --xall " + StorageDiskPlaceholder,
+}
+
+// storageDisksCommand lists paths of disks without partitions
+var storageDisksCommand = []string{"lsblk", "--nodeps", "--noheadings", "--paths", "--output", "NAME"}
+
+// Storage is a struct implementing Collector interface. It collects the output of the command describing
+// the storage of the node. It is always optional and a command that is not installed is noted in the output
+// instead of failing.
+type Storage struct {
+	name         string
+	command      []string
+	timeout      time.Duration
+	disksCommand []string
+}
+
+// NewStorage returns a collector of the command output, every run of the command is limited by the timeout
+func NewStorage(name string, command []string, timeout time.Duration) *Storage {
+	return &Storage{
+		name:         name,
+		command:      command,
+		timeout:      timeout,
+		disksCommand: storageDisksCommand,
+	}
+}
+
+// ParseStorageCommands returns commands given as name=command by their names
+func ParseStorageCommands(commands []string) (map[string][]string, error) {
+	parsed := make(map[string][]string, len(commands))
+	for _, c := range commands {
+		parts := strings.SplitN(c, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || len(strings.Fields(parts[1])) == 0 {
+			return nil, fmt.Errorf("invalid storage command %q, expected name=command", c)
+		}
+		name := strings.TrimSpace(parts[0])
+		if _, ok := parsed[name]; ok {
+			return nil, fmt.Errorf("duplicated storage command %s", name)
+		}
+		parsed[name] = strings.Fields(parts[1])
+	}
+	return parsed, nil
+}
+
+func (c Storage) Name() string {
+	return c.name
+}
+
+func (c Storage) Optional() bool {
+	return true
+}
+
+func (c Storage) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	if _, err := exec.LookPath(c.command[0]); err != nil {
+		note := fmt.Sprintf("%s is not installed on this node: %s\n", c.command[0], err)
+		return ioutil.NopCloser(strings.NewReader(note)), nil
+	}
+
+	commands := [][]string{c.command}
+	if hasDiskPlaceholder(c.command) {
+		disks, err := c.disks(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not list disks: %s", err)
+		}
+		commands = make([][]string, 0, len(disks))
+		for _, disk := range disks {
+			commands = append(commands, replaceDiskPlaceholder(c.command, disk))
+		}
+	}
+
+	var output bytes.Buffer
+	var errs []string
+	for _, command := range commands {
+		output.WriteString(fmt.Sprintf("$ %s\n", strings.Join(command, " ")))
+		if err := c.run(ctx, command, &output); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", strings.Join(command, " "), err))
+		}
+	}
+
+	var err error
+	if len(errs) > 0 {
+		err = fmt.Errorf("could not run storage commands: %s", strings.Join(errs, "; "))
+	}
+	return ioutil.NopCloser(&output), err
+}
+
+func (c Storage) run(ctx context.Context, command []string, w goio.Writer) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	output, err := RunCommand(ctx, command)
+	if _, e := goio.Copy(w, output); e != nil {
+		return e
+	}
+	return err
+}
+
+// disks returns paths of disks of the node
+func (c Storage) disks(ctx context.Context) ([]string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	output, err := exec.CommandContext(ctx, c.disksCommand[0], c.disksCommand[1:]...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+func hasDiskPlaceholder(command []string) bool {
+	for _, arg := range command {
+		if strings.Contains(arg, StorageDiskPlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceDiskPlaceholder(command []string, disk string) []string {
+	replaced := make([]string, len(command))
+	for i, arg := range command {
+		replaced[i] = strings.Replace(arg, StorageDiskPlaceholder, disk, -1)
+	}
+	return replaced
+}
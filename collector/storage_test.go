@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStorageCommands(t *testing.T) {
+	commands, err := ParseStorageCommands(DefaultStorageCommands)
+	require.NoError(t, err)
+	assert.Len(t, commands, 5)
+	assert.Equal(t, []string{"smartctl", "--xall", StorageDiskPlaceholder}, commands["smartctl"])
+
+	_, err = ParseStorageCommands([]string{"lsblk"})
+	assert.EqualError(t, err, `invalid storage command "lsblk", expected name=command`)
+
+	_, err = ParseStorageCommands([]string{"lsblk=lsblk", "lsblk=lsblk -a"})
+	assert.EqualError(t, err, "duplicated storage command lsblk")
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkMode defines how file collectors handle paths leading through symbolic links
+type SymlinkMode string
+
+const (
+	// SymlinkFollow reads the file the link points to
+	SymlinkFollow SymlinkMode = "follow"
+	// SymlinkSkip refuses to read files behind links
+	SymlinkSkip SymlinkMode = "skip"
+	// SymlinkMetadata records where the link points to instead of the file content
+	SymlinkMetadata SymlinkMode = "metadata"
+)
+
+// ParseSymlinkMode returns mode with given name. Empty name means following links.
+func ParseSymlinkMode(name string) (SymlinkMode, error) {
+	switch SymlinkMode(name) {
+	case "", SymlinkFollow:
+		return SymlinkFollow, nil
+	case SymlinkSkip, SymlinkMetadata:
+		return SymlinkMode(name), nil
+	}
+	return "", fmt.Errorf("unknown symlink mode %s, expected %s, %s or %s", name, SymlinkFollow, SymlinkSkip, SymlinkMetadata)
+}
+
+// SymlinkPolicy defines how files are opened when their path leads through a symbolic link.
+// When Roots are given, links are followed only if the resolved path is inside one of them.
+// The zero value follows all links.
+type SymlinkPolicy struct {
+	Mode  SymlinkMode
+	Roots []string
+}
+
+// Open opens the file at path applying the policy
+func (p SymlinkPolicy) Open(path string) (goio.ReadCloser, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// let os.Open report missing files the same way as without the policy
+		return openFile(path)
+	}
+	if resolved == filepath.Clean(path) {
+		return openFile(path)
+	}
+
+	switch p.Mode {
+	case SymlinkSkip:
+		return nil, fmt.Errorf("%s is a symbolic link to %s, skipped", path, resolved)
+	case SymlinkMetadata:
+		return ioutil.NopCloser(bytes.NewReader([]byte(fmt.Sprintf("%s is a symbolic link to %s\n", path, resolved)))), nil
+	}
+
+	if !p.allowed(resolved) {
+		return nil, fmt.Errorf("%s is a symbolic link to %s outside of allowed roots", path, resolved)
+	}
+	return openFile(resolved)
+}
+
+// openFile opens the file returning nil reader on error
+func openFile(path string) (goio.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p SymlinkPolicy) allowed(resolved string) bool {
+	if len(p.Roots) == 0 {
+		return true
+	}
+	for _, root := range p.Roots {
+		// roots could be links themselves e.g., /var/log mounted elsewhere
+		if r, err := filepath.EvalSymlinks(root); err == nil {
+			root = r
+		}
+		root = filepath.Clean(root)
+		if resolved == root || strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// symlinkFixture creates a root dir with a regular file, a link to it and a link to a file outside the root
+func symlinkFixture(t *testing.T) (root string, outside string, cleanup func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links require extra privileges on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "symlinks")
+	require.NoError(t, err)
+	// temp dir could be a link itself e.g., on darwin
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	root = filepath.Join(dir, "root")
+	outside = filepath.Join(dir, "outside")
+	require.NoError(t, os.Mkdir(root, 0700))
+	require.NoError(t, os.Mkdir(outside, 0700))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "file.log"), []byte("inside\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("outside\n"), 0600))
+	require.NoError(t, os.Symlink(filepath.Join(root, "file.log"), filepath.Join(root, "link.log")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "escape.log")))
+
+	return root, outside, func() { os.RemoveAll(dir) }
+}
+
+func readPolicy(t *testing.T, policy SymlinkPolicy, path string) (string, error) {
+	rc, err := policy.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data), nil
+}
+
+func TestSymlinkPolicyFollow(t *testing.T) {
+	root, outside, cleanup := symlinkFixture(t)
+	defer cleanup()
+
+	policy := SymlinkPolicy{Mode: SymlinkFollow, Roots: []string{root}}
+
+	data, err := readPolicy(t, policy, filepath.Join(root, "file.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "inside\n", data)
+
+	data, err = readPolicy(t, policy, filepath.Join(root, "link.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "inside\n", data)
+
+	_, err = readPolicy(t, policy, filepath.Join(root, "escape.log"))
+	assert.EqualError(t, err, filepath.Join(root, "escape.log")+" is a symbolic link to "+
+		filepath.Join(outside, "secret")+" outside of allowed roots")
+}
+
+func TestSymlinkPolicyFollowWithoutRoots(t *testing.T) {
+	root, _, cleanup := symlinkFixture(t)
+	defer cleanup()
+
+	data, err := readPolicy(t, SymlinkPolicy{}, filepath.Join(root, "escape.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "outside\n", data)
+}
+
+func TestSymlinkPolicySkip(t *testing.T) {
+	root, _, cleanup := symlinkFixture(t)
+	defer cleanup()
+
+	policy := SymlinkPolicy{Mode: SymlinkSkip}
+
+	data, err := readPolicy(t, policy, filepath.Join(root, "file.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "inside\n", data)
+
+	_, err = readPolicy(t, policy, filepath.Join(root, "link.log"))
+	assert.EqualError(t, err, filepath.Join(root, "link.log")+" is a symbolic link to "+
+		filepath.Join(root, "file.log")+", skipped")
+}
+
+func TestSymlinkPolicyMetadata(t *testing.T) {
+	root, outside, cleanup := symlinkFixture(t)
+	defer cleanup()
+
+	policy := SymlinkPolicy{Mode: SymlinkMetadata, Roots: []string{root}}
+
+	data, err := readPolicy(t, policy, filepath.Join(root, "file.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "inside\n", data)
+
+	data, err = readPolicy(t, policy, filepath.Join(root, "escape.log"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "escape.log")+" is a symbolic link to "+filepath.Join(outside, "secret")+"\n", data)
+}
+
+func TestSymlinkPolicyFollowsLinkedParentDirInsideRoot(t *testing.T) {
+	root, outside, cleanup := symlinkFixture(t)
+	defer cleanup()
+
+	// root/logs points to the outside dir like /var/log mounted elsewhere
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "logs")))
+
+	_, err := readPolicy(t, SymlinkPolicy{Roots: []string{root}}, filepath.Join(root, "logs", "secret"))
+	assert.Error(t, err)
+
+	data, err := readPolicy(t, SymlinkPolicy{Roots: []string{filepath.Join(root, "logs")}}, filepath.Join(root, "logs", "secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "outside\n", data)
+}
+
+func TestFileCollectorAppliesSymlinkPolicy(t *testing.T) {
+	root, _, cleanup := symlinkFixture(t)
+	defer cleanup()
+
+	c := NewFile("escape.log", false, filepath.Join(root, "escape.log")).
+		WithSymlinks(SymlinkPolicy{Mode: SymlinkFollow, Roots: []string{root}})
+	_, err := c.Collect(context.Background())
+	assert.Error(t, err)
+}
+
+func TestParseSymlinkMode(t *testing.T) {
+	for name, expected := range map[string]SymlinkMode{
+		"":         SymlinkFollow,
+		"follow":   SymlinkFollow,
+		"skip":     SymlinkSkip,
+		"metadata": SymlinkMetadata,
+	} {
+		mode, err := ParseSymlinkMode(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, mode)
+	}
+
+	_, err := ParseSymlinkMode("ignore")
+	assert.EqualError(t, err, "unknown symlink mode ignore, expected follow, skip or metadata")
+}
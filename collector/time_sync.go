@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	goio "io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TimeSyncCommands are commands reporting time synchronization state in order of preference.
+// chronyc works only when chronyd is running and ntpq only when ntpd is running so the first
+// command that succeeds tells which daemon is used. timedatectl is a fallback for other daemons.
+var TimeSyncCommands = [][]string{
+	{"chronyc", "tracking"},
+	{"ntpq", "-p"},
+	{"timedatectl", "status"},
+}
+
+// TimeSync is a struct implementing Collector interface. It collects status of the time
+// synchronization daemon using the first of configured commands that is available and succeeds
+type TimeSync struct {
+	name     string
+	optional bool
+	timeout  time.Duration
+	commands [][]string
+}
+
+func NewTimeSync(name string, optional bool, timeout time.Duration, commands [][]string) *TimeSync {
+	return &TimeSync{
+		name:     name,
+		optional: optional,
+		timeout:  timeout,
+		commands: commands,
+	}
+}
+
+func (c TimeSync) Name() string {
+	return c.name
+}
+
+func (c TimeSync) Optional() bool {
+	return c.optional
+}
+
+func (c TimeSync) Collect(ctx context.Context) (goio.ReadCloser, error) {
+	var errs []string
+	for _, command := range c.commands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+
+		output, err := c.run(ctx, command)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", strings.Join(command, " "), err))
+			continue
+		}
+
+		header := fmt.Sprintf("$ %s\n", strings.Join(command, " "))
+		return ioutil.NopCloser(bytes.NewReader(append([]byte(header), output...))), nil
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("could not find any time synchronization command")
+	}
+	return nil, fmt.Errorf("could not get time synchronization status: %s", strings.Join(errs, "; "))
+}
+
+func (c TimeSync) run(ctx context.Context, command []string) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
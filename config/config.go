@@ -16,34 +16,128 @@ type Config struct {
 	SystemdUnits []string
 
 	// dcos-diagnostics flags
-	FlagCACertFile                 string `mapstructure:"ca-cert"`
-	FlagPull                       bool   `mapstructure:"pull"`
-	FlagVerbose                    bool   `mapstructure:"verbose"`
-	FlagPort                       int    `mapstructure:"port"`
-	FlagDisableUnixSocket          bool   `mapstructure:"no-unix-socket"`
-	FlagMasterPort                 int    `mapstructure:"master-port"`
-	FlagAgentPort                  int    `mapstructure:"agent-port"`
-	FlagPullInterval               int    `mapstructure:"pull-interval"`
-	FlagPullTimeoutSec             int    `mapstructure:"pull-timeout"`
-	FlagUpdateHealthReportInterval int    `mapstructure:"health-update-interval"`
-	FlagExhibitorClusterStatusURL  string `mapstructure:"exhibitor-ip"`
-	FlagForceTLS                   bool   `mapstructure:"force-tls"`
-	FlagDebug                      bool   `mapstructure:"debug"`
-	FlagRole                       string `mapstructure:"role"`
-	FlagIAMConfig                  string `mapstructure:"iam-config"`
-	FlagHostname                   string `mapstructure:"hostname"`
-	FlagIPDiscoveryCommandLocation string `mapstructure:"ip-discovery-command-location"`
+	FlagCACertFile                 string   `mapstructure:"ca-cert"`
+	FlagPull                       bool     `mapstructure:"pull"`
+	FlagVerbose                    bool     `mapstructure:"verbose"`
+	FlagPort                       int      `mapstructure:"port"`
+	FlagDisableUnixSocket          bool     `mapstructure:"no-unix-socket"`
+	FlagMasterRediscoveries        int      `mapstructure:"master-rediscoveries"`
+	FlagMasterPort                 int      `mapstructure:"master-port"`
+	FlagAgentPort                  int      `mapstructure:"agent-port"`
+	FlagNodeCredentials            []string `mapstructure:"node-credentials"`
+	FlagPullInterval               int      `mapstructure:"pull-interval"`
+	FlagPullTimeoutSec             int      `mapstructure:"pull-timeout"`
+	FlagUpdateHealthReportInterval int      `mapstructure:"health-update-interval"`
+	FlagExhibitorClusterStatusURL  string   `mapstructure:"exhibitor-ip"`
+	FlagForceTLS                   bool     `mapstructure:"force-tls"`
+	FlagInsecureSkipVerify         bool     `mapstructure:"insecure-skip-verify"`
+	FlagDebug                      bool     `mapstructure:"debug"`
+	FlagRole                       string   `mapstructure:"role"`
+	FlagIAMConfig                  string   `mapstructure:"iam-config"`
+	FlagHostname                   string   `mapstructure:"hostname"`
+	FlagCollectorsHostname         string   `mapstructure:"collectors-hostname"`
+	FlagIPDiscoveryCommandLocation string   `mapstructure:"ip-discovery-command-location"`
+	FlagDiscoveryDNSTimeoutSec     int      `mapstructure:"discovery-dns-timeout"`
+	FlagDiscoveryMesosTimeoutSec   int      `mapstructure:"discovery-mesos-timeout"`
+
+	// proxy flags
+	FlagHTTPProxy  string   `mapstructure:"http-proxy"`
+	FlagHTTPSProxy string   `mapstructure:"https-proxy"`
+	FlagNoProxy    []string `mapstructure:"no-proxy"`
 
 	// diagnostics job flags
 	FlagDiagnosticsBundleDir                     string   `mapstructure:"diagnostics-bundle-dir"`
+	FlagDiagnosticsBundleMinFreeSpaceMB          int      `mapstructure:"diagnostics-bundle-min-free-space"`
+	FlagDiagnosticsBundleEstimateSpace           bool     `mapstructure:"diagnostics-bundle-estimate-space"`
 	FlagDiagnosticsBundleEndpointsConfigFiles    []string `mapstructure:"endpoint-config"`
+	FlagEndpointAllowlist                        []string `mapstructure:"endpoint-allowlist"`
+	FlagEndpointSchemeFallback                   bool     `mapstructure:"endpoint-scheme-fallback"`
 	FlagDiagnosticsBundleUnitsLogsSinceString    string   `mapstructure:"diagnostics-units-since"`
+	FlagDiagnosticsBundleUnitsDenylist           []string `mapstructure:"diagnostics-units-denylist"`
 	FlagDiagnosticsJobTimeoutMinutes             int      `mapstructure:"diagnostics-job-timeout"`
 	FlagDiagnosticsJobGetSingleURLTimeoutMinutes int      `mapstructure:"diagnostics-url-timeout"`
 	FlagCommandExecTimeoutSec                    int      `mapstructure:"command-exec-timeout"`
+	FlagCommandElevationWrapper                  string   `mapstructure:"command-elevation-wrapper"`
 	FlagDiagnosticsBundleFetchersCount           int      `mapstructure:"fetchers-count"`
+	FlagDiagnosticsBundleFetcherConcurrency      int      `mapstructure:"fetcher-concurrency"`
+	FlagAcceleratorInfoCommand                   string   `mapstructure:"accelerator-info-command"`
+	FlagMesosAttributesFile                      string   `mapstructure:"mesos-attributes-file"`
+	FlagMesosWorkDir                             string   `mapstructure:"mesos-work-dir"`
+	FlagLeader                                   string   `mapstructure:"leader"`
+	FlagAuthzConfig                              string   `mapstructure:"authz-config"`
+	FlagFileSymlinks                             string   `mapstructure:"file-symlinks"`
+	FlagFileSymlinksRoots                        []string `mapstructure:"file-symlinks-roots"`
+	FlagKubernetesLogs                           bool     `mapstructure:"kubernetes-logs"`
+	FlagSelfDiagnostics                          bool     `mapstructure:"self-diagnostics"`
+	FlagProcessesTop                             int      `mapstructure:"processes-top"`
+	FlagStorageInfo                              bool     `mapstructure:"storage-info"`
+	FlagStorageCommands                          []string `mapstructure:"storage-commands"`
+	FlagHealthAnalysis                           bool     `mapstructure:"health-analysis"`
+	FlagHealthHintsFile                          string   `mapstructure:"health-hints-file"`
+	FlagTLSCertFiles                             []string `mapstructure:"tls-cert-files"`
+	FlagContainerEventsPeriodMinutes             int      `mapstructure:"container-events-period"`
+	FlagContainerEventsMax                       int      `mapstructure:"container-events-max"`
+	FlagNetworkCapture                           bool     `mapstructure:"network-capture"`
+	FlagNetworkCaptureInterface                  string   `mapstructure:"network-capture-interface"`
+	FlagNetworkCaptureFilter                     string   `mapstructure:"network-capture-filter"`
+	FlagNetworkCaptureDurationSec                int      `mapstructure:"network-capture-duration"`
+	FlagNetworkCapturePackets                    int      `mapstructure:"network-capture-packets"`
+	FlagNetworkCaptureSnapLen                    int      `mapstructure:"network-capture-snaplen"`
+	FlagNetworkCaptureMaxSizeMB                  int      `mapstructure:"network-capture-max-size"`
+	FlagNetworkInterfaces                        bool     `mapstructure:"network-interfaces"`
+	FlagNetworkInterfacesEthtool                 string   `mapstructure:"network-interfaces-ethtool"`
+	FlagNetworkInterfacesMax                     int      `mapstructure:"network-interfaces-max"`
+	FlagHealthProbeScript                        string   `mapstructure:"health-probe-script"`
+	FlagHealthProbeTimeoutSec                    int      `mapstructure:"health-probe-timeout"`
+	FlagHealthProbeMaxSizeKB                     int      `mapstructure:"health-probe-max-size"`
+	FlagJournalMaxReaders                        int      `mapstructure:"journal-max-readers"`
+	FlagJournalFallbackFiles                     []string `mapstructure:"journal-fallback-files"`
+	FlagJournalHealth                            bool     `mapstructure:"journal-health"`
+	FlagBundleRetentionMaxAgeHours               int      `mapstructure:"bundle-retention-max-age"`
+	FlagBundleRetentionMaxCount                  int      `mapstructure:"bundle-retention-max-count"`
+	FlagBundleRetentionGraceMinutes              int      `mapstructure:"bundle-retention-grace"`
+	FlagBundleCompression                        string   `mapstructure:"bundle-compression"`
+	FlagBundleIncremental                        bool     `mapstructure:"bundle-incremental"`
+	FlagBundleHTMLIndex                          bool     `mapstructure:"bundle-html-index"`
+	FlagBundleSetupRetries                       int      `mapstructure:"bundle-setup-retries"`
+	FlagBundleMinFreeInodes                      int      `mapstructure:"bundle-min-free-inodes"`
+	FlagBundleRoutes                             bool     `mapstructure:"bundle-routes"`
+	FlagBundleCompactFilters                     []string `mapstructure:"bundle-compact-filters"`
+	FlagBundleClusterMaxDurationMinutes          int      `mapstructure:"bundle-cluster-max-duration"`
+	FlagBundleClusterMaxRequests                 int      `mapstructure:"bundle-cluster-max-requests"`
+	FlagBundleReportOrder                        string   `mapstructure:"bundle-report-order"`
+	FlagBundleDuplicateEntries                   string   `mapstructure:"bundle-duplicate-entries"`
+	FlagBundleDuplicateNodes                     string   `mapstructure:"bundle-duplicate-nodes"`
+	FlagBundleEvents                             bool     `mapstructure:"bundle-events"`
+	FlagBundleCatalog                            string   `mapstructure:"bundle-catalog"`
+	FlagBundleCapabilitiesCheck                  bool     `mapstructure:"bundle-capabilities-check"`
+	FlagBundlePostHook                           string   `mapstructure:"bundle-post-hook"`
+	FlagBundlePostHookTimeoutSec                 int      `mapstructure:"bundle-post-hook-timeout"`
+	FlagBundleUploadURL                          string   `mapstructure:"bundle-upload-url"`
+	FlagBundleUploadPartSizeMB                   int      `mapstructure:"bundle-upload-part-size"`
+	FlagBundleUploadPartRetries                  int      `mapstructure:"bundle-upload-part-retries"`
+	FlagBundleUploadPartTimeoutSec               int      `mapstructure:"bundle-upload-part-timeout"`
+	FlagBundleEmptyResults                       string   `mapstructure:"bundle-empty-results"`
+	FlagBundleTempDir                            string   `mapstructure:"bundle-temp-dir"`
+	FlagBundleConflictRetry                      bool     `mapstructure:"bundle-conflict-retry"`
+	FlagBundleScheduleIntervalMinutes            int      `mapstructure:"bundle-schedule-interval"`
+	FlagBundleScheduleOptions                    string   `mapstructure:"bundle-schedule-options"`
+	FlagBundleNodeMaxSizeMB                      int      `mapstructure:"bundle-node-max-size"`
+	FlagBundleStateCacheSize                     int      `mapstructure:"bundle-state-cache-size"`
+	FlagBundleStateWriteIntervalSec              int      `mapstructure:"bundle-state-write-interval"`
+	FlagCollectionHistoryMaxSizeKB               int      `mapstructure:"collection-history-max-size"`
+	FlagCollectionHistoryMaxFiles                int      `mapstructure:"collection-history-max-files"`
 }
 
 func (c Config) GetSingleEntryTimeout() time.Duration {
 	return time.Duration(c.FlagDiagnosticsJobGetSingleURLTimeoutMinutes) * time.Minute
 }
+
+// GetCollectorsHostname returns a host name used to build URLs of endpoints collected from the local node.
+// It defaults to FlagHostname when no override is set.
+func (c Config) GetCollectorsHostname() string {
+	if c.FlagCollectorsHostname != "" {
+		return c.FlagCollectorsHostname
+	}
+	return c.FlagHostname
+}
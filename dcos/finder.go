@@ -1,6 +1,7 @@
 package dcos
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,6 +18,22 @@ import (
 // calls to Mesos should be given relatively long timeouts to work reliably
 const mesosHTTPTimeout = 10 * time.Second
 
+// defaultDNSTimeout limits resolving of Mesos DNS records so discovery does not hang on DNS issues
+const defaultDNSTimeout = 5 * time.Second
+
+// resolver looks up host addresses, it is implemented by net.Resolver
+type resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// timeoutOrDefault returns timeout or the default when it is not set
+func timeoutOrDefault(timeout time.Duration, defaultTimeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
 // nodeFinder interface allows chain finding methods
 type nodeFinder interface {
 	Find() ([]Node, error)
@@ -24,8 +41,9 @@ type nodeFinder interface {
 
 // Find masters via dns. Used to Find master nodes from agents.
 type findMastersInExhibitor struct {
-	url  string
-	next nodeFinder
+	url     string
+	next    nodeFinder
+	timeout time.Duration
 
 	// getFn takes url and timeout and returns a read body, HTTP status code and error.
 	getFn func(string, time.Duration) ([]byte, int, error)
@@ -43,7 +61,7 @@ func (f *findMastersInExhibitor) findMesosMasters() (nodes []Node, err error) {
 		return nodes, errors.New("could not initialize HTTP GET function. Make sure you set getFn in the constructor")
 	}
 
-	body, statusCode, err := f.getFn(f.url, mesosHTTPTimeout)
+	body, statusCode, err := f.getFn(f.url, timeoutOrDefault(f.timeout, mesosHTTPTimeout))
 	if err != nil {
 		return nodes, err
 	}
@@ -99,6 +117,11 @@ type findNodesInDNS struct {
 	role      string
 	next      nodeFinder
 
+	// resolver is used to resolve dnsRecord, net.DefaultResolver when not set
+	resolver   resolver
+	dnsTimeout time.Duration
+	timeout    time.Duration
+
 	// getFn takes url and timeout and returns a read body, HTTP status code and error.
 	getFn func(string, time.Duration) ([]byte, int, error)
 }
@@ -114,7 +137,19 @@ type agentsResponse struct {
 }
 
 func (f *findNodesInDNS) resolveDomain() (ips []string, err error) {
-	return net.LookupHost(f.dnsRecord)
+	var r resolver = net.DefaultResolver
+	if f.resolver != nil {
+		r = f.resolver
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutOrDefault(f.dnsTimeout, defaultDNSTimeout))
+	defer cancel()
+
+	ips, err = r.LookupHost(ctx, f.dnsRecord)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s: %s", f.dnsRecord, err)
+	}
+	return ips, nil
 }
 
 func (f *findNodesInDNS) getMesosMasters() (nodes []Node, err error) {
@@ -152,7 +187,7 @@ func (f *findNodesInDNS) getMesosAgents() (nodes []Node, err error) {
 		return nodes, err
 	}
 
-	body, statusCode, err := f.getFn(url, mesosHTTPTimeout)
+	body, statusCode, err := f.getFn(url, timeoutOrDefault(f.timeout, mesosHTTPTimeout))
 	if err != nil {
 		return nodes, err
 	}
@@ -1,6 +1,7 @@
 package dcos
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -76,3 +77,77 @@ func Test_findMastersInExhibitor_FindWithEmptyBody(t *testing.T) {
 	assert.EqualError(t, err, "unexpected end of JSON input")
 	assert.Empty(t, nodes)
 }
+
+// slowResolver never resolves the host before the context is done
+type slowResolver struct{}
+
+func (slowResolver) LookupHost(ctx context.Context, _ string) ([]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type staticResolver []string
+
+func (r staticResolver) LookupHost(context.Context, string) ([]string, error) {
+	return r, nil
+}
+
+func Test_findNodesInDNS_FindHonorsDNSTimeout(t *testing.T) {
+	finder := findNodesInDNS{
+		dnsRecord:  "master.mesos",
+		role:       MasterRole,
+		resolver:   slowResolver{},
+		dnsTimeout: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	nodes, err := finder.Find()
+
+	assert.EqualError(t, err, "could not resolve master.mesos: context deadline exceeded")
+	assert.Empty(t, nodes)
+	assert.True(t, time.Since(start) < defaultDNSTimeout, "took %s", time.Since(start))
+}
+
+func Test_findNodesInDNS_FindAgentsHonorsMesosTimeout(t *testing.T) {
+	var timeouts []time.Duration
+	getFn := func(url string, timeout time.Duration) ([]byte, int, error) {
+		timeouts = append(timeouts, timeout)
+		assert.Equal(t, "http://192.0.2.1:5050/slaves", url)
+		return []byte(`{"slaves": [{"hostname": "192.0.2.2"}, {"hostname": "192.0.2.3", "attributes": {"public_ip": "true"}}]}`), 200, nil
+	}
+
+	finder := findNodesInDNS{
+		dnsRecord: "leader.mesos",
+		role:      AgentRole,
+		resolver:  staticResolver{"192.0.2.1"},
+		getFn:     getFn,
+		timeout:   time.Minute,
+	}
+
+	nodes, err := finder.Find()
+	assert.NoError(t, err)
+	assert.Equal(t, []Node{
+		{Role: AgentRole, IP: "192.0.2.2"},
+		{Role: AgentPublicRole, IP: "192.0.2.3"},
+	}, nodes)
+
+	finder.timeout = 0
+	_, err = finder.Find()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []time.Duration{time.Minute, mesosHTTPTimeout}, timeouts)
+}
+
+func Test_findMastersInExhibitor_FindHonorsTimeout(t *testing.T) {
+	var timeout time.Duration
+	getFn := func(url string, d time.Duration) ([]byte, int, error) {
+		timeout = d
+		return []byte(`[{"Hostname": "192.0.2.1"}]`), 200, nil
+	}
+
+	finder := findMastersInExhibitor{getFn: getFn, timeout: 30 * time.Second}
+
+	_, err := finder.Find()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, timeout)
+}
@@ -119,12 +119,14 @@ func (st *Tools) GetMasterNodes() (nodesResponse []Node, err error) {
 	}()
 
 	finder := &findMastersInExhibitor{
-		url:   st.ExhibitorURL,
-		getFn: st.Get,
+		url:     st.ExhibitorURL,
+		getFn:   st.Get,
+		timeout: st.MesosTimeout,
 		next: &findNodesInDNS{
-			forceTLS:  st.ForceTLS,
-			dnsRecord: "master.mesos",
-			role:      MasterRole,
+			forceTLS:   st.ForceTLS,
+			dnsRecord:  "master.mesos",
+			role:       MasterRole,
+			dnsTimeout: st.DNSTimeout,
 		},
 	}
 
@@ -134,10 +136,12 @@ func (st *Tools) GetMasterNodes() (nodesResponse []Node, err error) {
 // GetAgentNodes finds DC/OS agents.
 func (st *Tools) GetAgentNodes() (nodes []Node, err error) {
 	finder := &findNodesInDNS{
-		forceTLS:  st.ForceTLS,
-		dnsRecord: "leader.mesos",
-		role:      AgentRole,
-		getFn:     st.Get,
+		forceTLS:   st.ForceTLS,
+		dnsRecord:  "leader.mesos",
+		role:       AgentRole,
+		getFn:      st.Get,
+		dnsTimeout: st.DNSTimeout,
+		timeout:    st.MesosTimeout,
 	}
 	return finder.Find()
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/dcos/dcos-go/dcos/nodeutil"
 )
@@ -17,6 +18,10 @@ type Tools struct {
 	ForceTLS     bool
 	NodeInfo     nodeutil.NodeInfo
 	Transport    http.RoundTripper
+	// DNSTimeout limits resolving of Mesos DNS records during node discovery, default is used when not set
+	DNSTimeout time.Duration
+	// MesosTimeout limits requests to Exhibitor and Mesos during node discovery, default is used when not set
+	MesosTimeout time.Duration
 
 	hostname string
 }
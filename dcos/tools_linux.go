@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dcos/dcos-diagnostics/units"
 
@@ -26,6 +27,10 @@ type Tools struct {
 	ForceTLS     bool
 	NodeInfo     nodeutil.NodeInfo
 	Transport    http.RoundTripper
+	// DNSTimeout limits resolving of Mesos DNS records during node discovery, default is used when not set
+	DNSTimeout time.Duration
+	// MesosTimeout limits requests to Exhibitor and Mesos during node discovery, default is used when not set
+	MesosTimeout time.Duration
 
 	dcon     *dbus.Conn
 	hostname string
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dcos/dcos-go/dcos/nodeutil"
 	"github.com/sirupsen/logrus"
@@ -30,6 +31,10 @@ type Tools struct {
 	ForceTLS     bool
 	NodeInfo     nodeutil.NodeInfo
 	Transport    http.RoundTripper
+	// DNSTimeout limits resolving of Mesos DNS records during node discovery, default is used when not set
+	DNSTimeout time.Duration
+	// MesosTimeout limits requests to Exhibitor and Mesos during node discovery, default is used when not set
+	MesosTimeout time.Duration
 
 	svcManager *mgr.Mgr
 
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dcos/dcos-diagnostics/dcos"
@@ -48,6 +49,8 @@ type Fetcher struct {
 	results      chan<- BulkResponse
 	// This vector is used to collect the HTTP response times of all endpoints.
 	prometheusVector prometheus.ObserverVec
+	// concurrency is a number of endpoints fetched at the same time, their entries are written to the zip one at a time
+	concurrency int
 }
 
 // New creates new Fetcher. Fetcher needs to be started with Run()
@@ -64,11 +67,23 @@ func New(
 		return nil, fmt.Errorf("could not create temp zip file in %s: %s", tempdir, err)
 	}
 
-	fetcher := &Fetcher{f, client, input, statusUpdate, output, prometheusVector}
+	fetcher := &Fetcher{f, client, input, statusUpdate, output, prometheusVector, 1}
 
 	return fetcher, nil
 }
 
+// WithConcurrency makes the fetcher fetch up to given number of endpoints at the same time. Endpoints of
+// a node e.g., files, commands and units, do not depend on each other so they could be fetched concurrently.
+// Fetched data is spooled to temporary files and only writes to the zip are serialized. Values lower than 1
+// fetch endpoints one by one.
+func (f *Fetcher) WithConcurrency(n int) *Fetcher {
+	if n < 1 {
+		n = 1
+	}
+	f.concurrency = n
+	return f
+}
+
 // Run starts fetcher. This method should be run as a goroutine
 func (f *Fetcher) Run(ctx context.Context) {
 	zipWriter := zip.NewWriter(f.file)
@@ -91,6 +106,25 @@ func (f *Fetcher) Run(ctx context.Context) {
 }
 
 func (f *Fetcher) workOffRequests(ctx context.Context, zipWriter *zip.Writer) {
+	if f.concurrency <= 1 {
+		f.work(ctx, zipWriter, &sync.Mutex{})
+		return
+	}
+
+	var zipMutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < f.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.work(ctx, zipWriter, &zipMutex)
+		}()
+	}
+	wg.Wait()
+}
+
+// work fetches endpoints until there are no more of them or ctx is done, zipMutex guards writes to the zip
+func (f *Fetcher) work(ctx context.Context, zipWriter *zip.Writer, zipMutex *sync.Mutex) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -99,7 +133,7 @@ func (f *Fetcher) workOffRequests(ctx context.Context, zipWriter *zip.Writer) {
 			if !ok {
 				return
 			}
-			err := f.getDataToZip(ctx, in, zipWriter)
+			err := f.getDataToZip(ctx, in, zipWriter, zipMutex)
 			select {
 			case <-ctx.Done():
 				return
@@ -113,7 +147,7 @@ func (f *Fetcher) workOffRequests(ctx context.Context, zipWriter *zip.Writer) {
 	}
 }
 
-func (f *Fetcher) getDataToZip(ctx context.Context, r EndpointRequest, zipWriter *zip.Writer) error {
+func (f *Fetcher) getDataToZip(ctx context.Context, r EndpointRequest, zipWriter *zip.Writer, zipMutex *sync.Mutex) error {
 	start := time.Now()
 
 	resp, err := get(ctx, f.client, r.URL)
@@ -133,18 +167,52 @@ func (f *Fetcher) getDataToZip(ctx context.Context, r EndpointRequest, zipWriter
 		r.FileName += ".gz"
 	}
 
+	var body io.Reader = resp.Body
+	if f.concurrency > 1 {
+		spool, err := f.spool(resp.Body)
+		if err != nil {
+			return fmt.Errorf("could not fetch %s: %s", r.URL, err)
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		body = spool
+	}
+
+	zipMutex.Lock()
+	defer zipMutex.Unlock()
+
 	filename := filepath.Join(r.Node.IP+"_"+r.Node.Role, r.FileName)
 	zipFile, err := zipWriter.Create(filename)
 	if err != nil {
 		return fmt.Errorf("could not create a %s in the zip: %s", filename, err)
 	}
-	if _, err := io.Copy(zipFile, resp.Body); err != nil {
+	if _, err := io.Copy(zipFile, body); err != nil {
 		return fmt.Errorf("could not copy data to zip: %s", err)
 	}
 
 	return nil
 }
 
+// spool copies the body to a temporary file next to the fetcher zip so it could be written to the zip
+// while other endpoints are still fetched
+func (f *Fetcher) spool(body io.Reader) (*os.File, error) {
+	spool, err := ioutil.TempFile(filepath.Dir(f.file.Name()), "spool")
+	if err != nil {
+		return nil, fmt.Errorf("could not create spool file: %s", err)
+	}
+	if _, err := io.Copy(spool, body); err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("could not spool data: %s", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("could not rewind spool file: %s", err)
+	}
+	return spool, nil
+}
+
 func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
 	logrus.Debugf("Using URL %s to collect a log", url)
 	request, err := http.NewRequest("GET", url, nil)
@@ -2,13 +2,19 @@ package fetcher
 
 import (
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dcos/dcos-diagnostics/dcos"
 	"github.com/dcos/dcos-diagnostics/mocks"
@@ -140,3 +146,106 @@ func mockServer(handle func(w http.ResponseWriter, r *http.Request)) (*httptest.
 
 	return server, transport
 }
+
+// fetchAll fetches endpoints of the server with a fetcher of given concurrency and returns the zip it created
+func fetchAll(t testing.TB, serverURL string, endpoints []string, concurrency int) string {
+	input := make(chan EndpointRequest, len(endpoints))
+	statusUpdate := make(chan StatusUpdate, len(endpoints))
+	output := make(chan BulkResponse)
+
+	observer := &mocks.MockObserver{}
+	observer.On("Observe", mock.Anything)
+	mockHistogram := &mocks.MockHistogram{}
+	mockHistogram.On("WithLabelValues", mock.Anything, "200").Return(observer)
+
+	f, err := New("", http.DefaultClient, input, statusUpdate, output, mockHistogram)
+	require.NoError(t, err)
+	go f.WithConcurrency(concurrency).Run(context.TODO())
+
+	for _, e := range endpoints {
+		input <- EndpointRequest{
+			URL:      serverURL + "/" + e,
+			Node:     dcos.Node{IP: "127.0.0.1", Role: dcos.AgentRole},
+			FileName: e,
+		}
+	}
+	close(input)
+
+	for range endpoints {
+		assert.NoError(t, (<-statusUpdate).Error)
+	}
+	result := <-output
+	require.NoError(t, result.Error)
+	return result.ZipFilePath
+}
+
+func Test_FetcherFetchesEndpointsConcurrently(t *testing.T) {
+	const concurrency = 4
+
+	var inFlight int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&inFlight, 1) == concurrency {
+			close(release)
+		}
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+			http.Error(w, "endpoints were not fetched concurrently", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(r.URL.Path)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	endpoints := []string{"file", "command", "unit", "endpoint"}
+	zipPath := fetchAll(t, server.URL, endpoints, concurrency)
+	defer os.Remove(zipPath)
+
+	z, err := zip.OpenReader(zipPath)
+	require.NoError(t, err)
+	defer z.Close()
+
+	files := map[string]string{}
+	for _, f := range z.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	assert.Equal(t, map[string]string{
+		"127.0.0.1_agent/file":     "/file",
+		"127.0.0.1_agent/command":  "/command",
+		"127.0.0.1_agent/unit":     "/unit",
+		"127.0.0.1_agent/endpoint": "/endpoint",
+	}, files)
+
+	spools, err := filepath.Glob(filepath.Join(os.TempDir(), "spool*"))
+	require.NoError(t, err)
+	assert.Empty(t, spools)
+}
+
+// BenchmarkFetcher fetches many mixed providers of a single node which take some time to respond
+func BenchmarkFetcher(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write(bytes.Repeat([]byte("log line\n"), 1000)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	var endpoints []string
+	for i := 0; i < 10; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("files/file-%d", i), fmt.Sprintf("cmds/cmd-%d", i),
+			fmt.Sprintf("units/unit-%d", i))
+	}
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				os.Remove(fetchAll(b, server.URL, endpoints, concurrency))
+			}
+		})
+	}
+}
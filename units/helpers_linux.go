@@ -12,12 +12,16 @@ import (
 
 // ReadJournalOutputSince returns logs since given duration from journal
 func ReadJournalOutputSince(ctx context.Context, unit string, duration time.Duration) (goio.ReadCloser, error) {
-	return readJournalOutput(ctx, unit, duration, 0)
+	return journalReaders.open(ctx, func() (goio.ReadCloser, error) {
+		return readJournalOutput(ctx, unit, duration, 0)
+	})
 }
 
 // ReadJournalTail returns numFromTail log lines from the end of the log
 func ReadJournalTail(ctx context.Context, unit string, numFromTail uint64) (goio.ReadCloser, error) {
-	return readJournalOutput(ctx, unit, 0, numFromTail)
+	return journalReaders.open(ctx, func() (goio.ReadCloser, error) {
+		return readJournalOutput(ctx, unit, 0, numFromTail)
+	})
 }
 
 func readJournalOutput(ctx context.Context, unit string, d time.Duration, n uint64) (goio.ReadCloser, error) {
@@ -0,0 +1,66 @@
+package units
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// journalReaders limits the number of journal readers open at the same time on this node
+var journalReaders = newReadersLimit(0)
+
+// SetMaxJournalReaders sets how many journal readers could be open at the same time, 0 means no limit.
+// Readers over the limit wait until one of the open readers is closed. It must be called before journal
+// is read for the first time.
+func SetMaxJournalReaders(n int) {
+	journalReaders = newReadersLimit(n)
+}
+
+// MaxJournalReaders returns how many journal readers could be open at the same time, 0 means no limit
+func MaxJournalReaders() int {
+	return cap(journalReaders.sem)
+}
+
+// readersLimit is a semaphore held by a reader from the moment it is opened until it is closed
+type readersLimit struct {
+	sem chan struct{}
+}
+
+func newReadersLimit(n int) *readersLimit {
+	if n <= 0 {
+		return &readersLimit{}
+	}
+	return &readersLimit{sem: make(chan struct{}, n)}
+}
+
+// open waits for a free slot and calls openReader. The slot is released when the returned reader is closed,
+// openReader fails or ctx is done before a slot was acquired.
+func (l *readersLimit) open(ctx context.Context, openReader func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	if l.sem == nil {
+		return openReader()
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	r, err := openReader()
+	if err != nil {
+		<-l.sem
+		return r, err
+	}
+	return &limitedReader{ReadCloser: r, release: func() { <-l.sem }}, nil
+}
+
+type limitedReader struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *limitedReader) Close() error {
+	defer r.once.Do(r.release)
+	return r.ReadCloser.Close()
+}
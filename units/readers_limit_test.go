@@ -0,0 +1,89 @@
+package units
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadersLimitSerializesReaders(t *testing.T) {
+	limit := newReadersLimit(1)
+	openReader := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("logs")), nil
+	}
+
+	first, err := limit.open(context.Background(), openReader)
+	require.NoError(t, err)
+
+	opened := make(chan io.ReadCloser)
+	go func() {
+		second, err := limit.open(context.Background(), openReader)
+		assert.NoError(t, err)
+		opened <- second
+	}()
+
+	select {
+	case <-opened:
+		t.Fatal("second reader was opened while the first one is still open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, first.Close())
+	// closing twice must not release the slot twice
+	require.NoError(t, first.Close())
+
+	select {
+	case second := <-opened:
+		data, err := ioutil.ReadAll(second)
+		require.NoError(t, err)
+		assert.Equal(t, "logs", string(data))
+		require.NoError(t, second.Close())
+	case <-time.After(time.Second):
+		t.Fatal("second reader was not opened after the first one was closed")
+	}
+	assert.Empty(t, limit.sem)
+}
+
+func TestReadersLimitReleasesSlotWhenOpenFails(t *testing.T) {
+	limit := newReadersLimit(1)
+
+	_, err := limit.open(context.Background(), func() (io.ReadCloser, error) {
+		return nil, errors.New("no journal")
+	})
+	assert.EqualError(t, err, "no journal")
+	assert.Empty(t, limit.sem)
+}
+
+func TestReadersLimitStopsWaitingWhenContextIsDone(t *testing.T) {
+	limit := newReadersLimit(1)
+	first, err := limit.open(context.Background(), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	})
+	require.NoError(t, err)
+	defer first.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limit.open(ctx, func() (io.ReadCloser, error) {
+		t.Fatal("reader must not be opened over the limit")
+		return nil, nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestReadersLimitWithoutLimit(t *testing.T) {
+	limit := newReadersLimit(0)
+	for i := 0; i < 3; i++ {
+		_, err := limit.open(context.Background(), func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader("")), nil
+		})
+		require.NoError(t, err)
+	}
+}
@@ -1,9 +1,11 @@
 package util
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSanitizeString(t *testing.T) {
@@ -64,3 +66,37 @@ func TestUseTLSScheme(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "https://google.com", url)
 }
+
+func TestNewProxyFunc(t *testing.T) {
+	proxy, err := NewProxyFunc("", "", []string{"example.com"})
+	assert.NoError(t, err)
+	assert.Nil(t, proxy)
+
+	_, err = NewProxyFunc("proxy:3128", "", nil)
+	assert.EqualError(t, err, "invalid http proxy URL proxy:3128: scheme and host are required")
+
+	proxy, err = NewProxyFunc("http://proxy:3128", "http://secure-proxy:3129", []string{"localhost", ".internal", "10.0.0.0/8"})
+	require.NoError(t, err)
+
+	for _, item := range []struct {
+		url, expected string
+	}{
+		{url: "http://192.0.2.1:61001/system/health/v1", expected: "http://proxy:3128"},
+		{url: "https://192.0.2.1:61002/system/health/v1", expected: "http://secure-proxy:3129"},
+		{url: "http://agent.example.com/system/health/v1", expected: "http://proxy:3128"},
+		{url: "http://localhost:1050/system/health/v1"},
+		{url: "http://agent.internal:1050/system/health/v1"},
+		{url: "http://10.0.4.2:1050/system/health/v1"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, item.url, nil)
+		require.NoError(t, err)
+
+		got, err := proxy(req)
+		assert.NoError(t, err)
+		if item.expected == "" {
+			assert.Nil(t, got, item.url)
+		} else {
+			assert.Equal(t, item.expected, got.String(), item.url)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	netUrl "net/url"
 	"strings"
@@ -49,6 +50,61 @@ func NewHTTPClient(timeout time.Duration, transport http.RoundTripper) *http.Cli
 	return client
 }
 
+// NewProxyFunc returns a function that could be used as http.Transport Proxy. Requests are sent through
+// httpProxy or httpsProxy depending on their scheme, except requests to hosts matching any of noProxy entries.
+// A noProxy entry could be a host name, a domain suffix starting with a dot, an IP, a CIDR or "*".
+// If no proxy is configured, nil is returned.
+func NewProxyFunc(httpProxy, httpsProxy string, noProxy []string) (func(*http.Request) (*netUrl.URL, error), error) {
+	if httpProxy == "" && httpsProxy == "" {
+		return nil, nil
+	}
+
+	proxies := make(map[string]*netUrl.URL, 2)
+	for scheme, proxy := range map[string]string{"http": httpProxy, "https": httpsProxy} {
+		if proxy == "" {
+			continue
+		}
+		proxyURL, err := netUrl.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s proxy URL %s: %s", scheme, proxy, err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid %s proxy URL %s: scheme and host are required", scheme, proxy)
+		}
+		proxies[scheme] = proxyURL
+	}
+
+	return func(req *http.Request) (*netUrl.URL, error) {
+		proxyURL, ok := proxies[req.URL.Scheme]
+		if !ok || matchesNoProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+func matchesNoProxy(host string, noProxy []string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case entry == "*", strings.EqualFold(entry, host):
+			return true
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(strings.ToLower(host), strings.ToLower(entry)) {
+				return true
+			}
+		case ip != nil:
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && cidr.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // IsInList returns true if item is in list
 func IsInList(item string, l []string) bool {
 	for _, listItem := range l {